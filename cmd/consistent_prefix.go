@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"context"
+	"practice/internal/accessor"
+
+	"github.com/spf13/cobra"
+)
+
+var consistentPrefixFix bool
+
+var consistentPrefixCmd = &cobra.Command{
+	Use:   "consistent_prefix",
+	Short: "Reads logs and wallets from different replicas and sees a transfer log without its withdrawal",
+	Long: `Requires rdb.replicas, a replica delayed with SOURCE_DELAY (MySQL) or recovery_min_apply_delay (PostgreSQL) reproduces it reliably.
+List the replica without delay before the delayed one in rdb.replicas, with a single replica the primary takes its place.
+--fix reads both in one read-only transaction on a single pinned replica.`,
+	RunE: RunConsistentPrefixCmd,
+}
+
+func init() {
+	consistentPrefixCmd.Flags().BoolVar(&consistentPrefixFix, "fix", false, "runs the scenario with the solution")
+
+	rootCmd.AddCommand(consistentPrefixCmd)
+}
+
+func RunConsistentPrefixCmd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(ctx)
+	infra.InitRouter(ctx)
+
+	infra.RDB.SimulateConsistentPrefix(ctx, infra.Router, consistentPrefixFix)
+
+	return nil
+}
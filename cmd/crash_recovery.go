@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"practice/internal/accessor"
+	"practice/internal/pipeline"
+	"practice/internal/pipeline/source"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	crashRecoveryTransfers int
+	crashRecoveryPipeline  string
+)
+
+var crashRecoveryCmd = &cobra.Command{
+	Use:   "crash_recovery",
+	Short: "Injects a crash between sink write and source ack, then verifies no duplicates or gaps after restart",
+	Long:  ``,
+	RunE:  RunCrashRecoveryCmd,
+}
+
+func init() {
+	crashRecoveryCmd.Flags().StringVarP(&crashRecoveryPipeline, "pipeline", "p", "default", "name of the pipeline in the configuration file")
+	crashRecoveryCmd.Flags().IntVarP(&crashRecoveryTransfers, "transfers", "n", 10, "number of transfers generated in the source database")
+	rootCmd.AddCommand(crashRecoveryCmd)
+}
+
+func RunCrashRecoveryCmd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(ctx)
+
+	if err := infra.SelectPipeline(crashRecoveryPipeline); err != nil {
+		return err
+	}
+
+	infra.InitRDB(ctx)
+	infra.InitSinks(ctx)
+
+	return pipeline.SimulateCrashRecovery(ctx,
+		infra.RDB.DB(),
+		infra.Config.RDB.Driver,
+		infra.Pipeline.Name,
+		func() source.Source { return infra.BuildSource(ctx) },
+		infra.Sinks,
+		infra.Pipeline.CheckpointDir,
+		crashRecoveryTransfers,
+	)
+}
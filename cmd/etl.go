@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"practice/internal/accessor"
+	"practice/internal/etl"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	etlTables    []string
+	etlBatchSize int
+	etlTruncate  bool
+)
+
+var etlCmd = &cobra.Command{
+	Use:   "etl",
+	Short: "Moves data between the mysql and postgres connections of the rdb section",
+	Long:  ``,
+}
+
+var etlCopyCmd = &cobra.Command{
+	Use:   "copy",
+	Short: "Copies tables from mysql into postgres in batches, then compares row counts and checksums of every chunk",
+	Long:  ``,
+	RunE:  RunEtlCopyCmd,
+}
+
+func init() {
+	etlCopyCmd.Flags().StringSliceVarP(&etlTables, "tables", "t", []string{"users", "wallets", "logs"}, "copied tables")
+	etlCopyCmd.Flags().IntVarP(&etlBatchSize, "batch-size", "b", 1000, "rows copied per chunk")
+	etlCopyCmd.Flags().BoolVar(&etlTruncate, "truncate", false, "truncates target tables before copying, otherwise existing rows are upserted")
+
+	etlCmd.AddCommand(etlCopyCmd)
+	rootCmd.AddCommand(etlCmd)
+}
+
+func RunEtlCopyCmd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(ctx)
+
+	infra.InitRDBs(ctx)
+
+	copier := etl.NewCopier(infra.MySQL.DB(), infra.Postgres.DB(), infra.Config.RDB.MysqlOpts.DBName, etlBatchSize, etlTruncate)
+	reports, err := copier.Copy(ctx, etlTables, func(chunk *etl.ChunkReport) {
+		if chunk.Match() {
+			logrus.Infof("%v chunk %v [%v, %v] copied, %v rows, checksum %v",
+				chunk.Table, chunk.Index, chunk.First, chunk.Last, chunk.SourceRows, chunk.SourceChecksum)
+			return
+		}
+		logrus.Errorf("%v chunk %v [%v, %v] mismatched, source %v rows (%v), target %v rows (%v)",
+			chunk.Table, chunk.Index, chunk.First, chunk.Last,
+			chunk.SourceRows, chunk.SourceChecksum, chunk.TargetRows, chunk.TargetChecksum)
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tROWS\tCHUNKS\tMISMATCHED\tELAPSED")
+	mismatched := 0
+	for _, report := range reports {
+		mismatched += len(report.Mismatched())
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", report.Table, report.Rows, len(report.Chunks), len(report.Mismatched()), report.Elapsed)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+	if mismatched > 0 {
+		return fmt.Errorf("%v chunks mismatched after copy", mismatched)
+	}
+	return nil
+}
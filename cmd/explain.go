@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"practice/internal/accessor"
+	"practice/internal/explain"
+	"practice/internal/storage/dialect"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	explainAnalyze bool
+	explainRaw     bool
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain [SCENARIO...]",
+	Short: "Shows the index choice, covering index use and full scans of every statement the scenarios run",
+	Long: `Scenarios are dirty_read, read_skew, lost_update, write_skew_1, write_skew_2 and lock_failed_1, all of them when omitted.
+Plans depend on the data and the statistics, run generate_data first so that the planner has rows to choose indexes for.
+--analyze also executes the statements inside a transaction that is rolled back,
+MySQL only supports EXPLAIN ANALYZE for SELECT and reports it as a tree.`,
+	RunE: RunExplainCmd,
+}
+
+func init() {
+	explainCmd.Flags().BoolVar(&explainAnalyze, "analyze", false, "runs EXPLAIN ANALYZE inside a rolled back transaction")
+	explainCmd.Flags().BoolVar(&explainRaw, "raw", false, "prints the plan returned by the database")
+
+	rootCmd.AddCommand(explainCmd)
+}
+
+func RunExplainCmd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(ctx)
+
+	infra.InitRDB(ctx)
+
+	d, err := dialect.New(infra.Config.RDB.Driver)
+	if err != nil {
+		return err
+	}
+
+	scenarios, err := explain.Scenarios(d)
+	if err != nil {
+		return err
+	}
+	scenarios, err = explain.Find(scenarios, args)
+	if err != nil {
+		return err
+	}
+
+	explainer := explain.NewExplainer(infra.RDB.DB(), d, explainAnalyze)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, scenario := range scenarios {
+		fmt.Fprintf(w, "== %s (%s)\n", scenario.Name, d.Driver())
+		for _, statement := range scenario.Statements {
+			plan, err := explainer.Explain(ctx, statement)
+			if err != nil {
+				return err
+			}
+			printPlan(w, plan)
+		}
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
+
+func printPlan(w *tabwriter.Writer, plan *explain.Plan) {
+	fmt.Fprintf(w, "-- %s: %s\n", plan.Statement.Name, plan.Statement.Query)
+	fmt.Fprintln(w, "   TABLE\tACCESS\tINDEX\tROWS\tACTUAL\tHIGHLIGHT\tEXTRA")
+	for _, access := range plan.Accesses {
+		actual := "-"
+		if access.Actual != nil {
+			actual = fmt.Sprint(*access.Actual)
+		}
+
+		highlights := []string{}
+		if access.FullScan {
+			highlights = append(highlights, "FULL SCAN")
+		}
+		if access.Covering {
+			highlights = append(highlights, "COVERING INDEX")
+		}
+		fmt.Fprintf(w, "   %s\t%s\t%s\t%v\t%s\t%s\t%s\n",
+			access.Table, access.Method, access.Index, access.Rows, actual, strings.Join(highlights, ", "), access.Extra)
+	}
+
+	if plan.Statement.Note != "" {
+		fmt.Fprintf(w, "   note: %s\n", plan.Statement.Note)
+	}
+	for _, warning := range plan.Warnings {
+		fmt.Fprintf(w, "   warning: %s\n", warning)
+	}
+	if plan.Tree != "" {
+		printIndented(w, plan.Tree)
+	}
+	if explainRaw {
+		printIndented(w, plan.Raw)
+	}
+}
+
+func printIndented(w *tabwriter.Writer, text string) {
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		fmt.Fprintf(w, "   | %s\n", strings.ReplaceAll(line, "\t", "    "))
+	}
+}
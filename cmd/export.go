@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"practice/internal/accessor"
+	"practice/internal/dataset"
+	"practice/internal/storage/dialect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportOpts        dataset.ExportOptions
+	exportMaxFileSize string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export TABLE [TABLE...]",
+	Short: "Streams tables of the configured rdb into CSV, JSONL or Parquet files",
+	Long: `Rows are read in primary key order by independent statements of --chunk-size rows,
+so no transaction stays open while the files are written.
+CSV writes a header row and NULL as an empty field, JSONL writes one object per row, Parquet keeps the column types.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: RunExportCmd,
+}
+
+func init() {
+	exportCmd.Flags().StringSliceVarP(&exportOpts.Columns, "columns", "c", nil, "exported columns, all columns when omitted")
+	exportCmd.Flags().StringVar(&exportOpts.Where, "where", "", "SQL condition filtering the exported rows (e.g. \"amount > 1000\")")
+	exportCmd.Flags().StringVar(&exportOpts.Format, "format", dataset.FormatCSV, "csv, jsonl or parquet")
+	exportCmd.Flags().StringVar(&exportOpts.Compression, "compression", "", "none, gzip, zstd or snappy (parquet only), defaults to snappy for parquet and none otherwise")
+	exportCmd.Flags().IntVar(&exportOpts.ChunkSize, "chunk-size", 10000, "rows read per primary key range")
+	exportCmd.Flags().StringVar(&exportMaxFileSize, "max-file-size", "0", "starts a new file after the size (e.g. 512KB, 64MB, 1GB), 0 writes a single file")
+	exportCmd.Flags().StringVarP(&exportOpts.Dir, "dir", "o", "./exports", "output directory")
+
+	rootCmd.AddCommand(exportCmd)
+}
+
+func RunExportCmd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	size, err := parseSize(exportMaxFileSize)
+	if err != nil {
+		return err
+	}
+	exportOpts.MaxFileSize = size
+
+	if exportOpts.Compression == "" {
+		exportOpts.Compression = dataset.CompressionNone
+		if exportOpts.Format == dataset.FormatParquet {
+			exportOpts.Compression = dataset.CompressionSnappy
+		}
+	}
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(ctx)
+
+	infra.InitRDB(ctx)
+
+	d, err := dialect.New(infra.Config.RDB.Driver)
+	if err != nil {
+		return err
+	}
+
+	exporter, err := dataset.NewExporter(infra.RDB.DB(), d, exportOpts)
+	if err != nil {
+		return err
+	}
+
+	reports := []*dataset.ExportReport{}
+	for _, table := range args {
+		report, err := exporter.Export(ctx, table, func(r *dataset.ExportReport) {
+			logrus.Debugf("%v: %v rows in %v chunks, %v files", r.Table, r.Rows, r.Chunks, len(r.Files))
+		})
+		if err != nil {
+			return fmt.Errorf("failed to export %v: %w", table, err)
+		}
+		reports = append(reports, report)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tFILE\tROWS\tBYTES\tELAPSED")
+	for _, report := range reports {
+		for _, file := range report.Files {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", report.Table, file.Path, file.Rows, file.Bytes, report.Elapsed)
+		}
+	}
+	return w.Flush()
+}
+
+// parseSize 解析帶有 KB, MB 或 GB 單位的大小, 沒有單位時為 bytes
+func parseSize(text string) (int64, error) {
+	units := []struct {
+		suffix string
+		bytes  int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+	upper := strings.ToUpper(strings.TrimSpace(text))
+	multiplier := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(upper, unit.suffix) {
+			upper = strings.TrimSpace(strings.TrimSuffix(upper, unit.suffix))
+			multiplier = unit.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %v", text)
+	}
+	return n * multiplier, nil
+}
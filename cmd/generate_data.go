@@ -2,31 +2,100 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"practice/internal/accessor"
+	"practice/internal/storage/bulk"
+	"practice/internal/storage/generator"
+	"time"
 
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
+var (
+	generateOpts  = generator.DefaultOptions()
+	generateSince string
+	generateRate  float64
+	generateBulk  bool
+	bulkOpts      bulk.Options
+)
+
 var generateDataCmd = &cobra.Command{
 	Use:   "generate_data",
-	Short: "",
-	Long:  ``,
-	RunE:  RunGenerateDataCmd,
+	Short: "Truncates users, wallets and logs, then inserts a reproducible dataset",
+	Long: `Every value is derived from --seed, so the same flags always generate the same rows on both drivers.
+Transfer history in logs starts with the initial deposit of every wallet from user 0, followed by transfers
+with time-of-day seasonality and power-law user activity, so wallet amounts equal the sum of logs and never become negative.
+
+--bulk streams the rows through LOAD DATA LOCAL INFILE (mysql, requires local_infile) or COPY FROM STDIN (postgresql).
+Every chunk commits on its own, and --resume continues after the largest loaded id of each table,
+so it must be run with the same generator flags as the interrupted load.`,
+	RunE: RunGenerateDataCmd,
 }
 
 func init() {
+	defaults := generator.DefaultOptions()
+
+	generateDataCmd.Flags().IntVar(&generateOpts.Users, "users", defaults.Users, "number of users")
+	generateDataCmd.Flags().IntVar(&generateOpts.Wallets, "wallets", -1, "number of wallets owned by the first users, defaults to --users")
+	generateDataCmd.Flags().IntVar(&generateOpts.Logs, "logs", 0, "number of transfers generated in logs, 0 skips the transfer history")
+	generateDataCmd.Flags().Float64Var(&generateRate, "rate", 0, "average transfers per hour over --period, overrides --logs")
+	generateDataCmd.Flags().Float64Var(&generateOpts.Activity, "activity", defaults.Activity, "pareto shape of the user activity, smaller values concentrate transfers on fewer users")
+	generateDataCmd.Flags().IntVarP(&generateOpts.BatchSize, "batch-size", "b", defaults.BatchSize, "rows per parameterized INSERT, can not be used with --bulk")
+	generateDataCmd.Flags().Int64Var(&generateOpts.Seed, "seed", defaults.Seed, "random seed")
+	generateDataCmd.Flags().StringVar(&generateOpts.Distribution, "distribution", defaults.Distribution, "wallet amount distribution (fixed, uniform, normal, pareto)")
+	generateDataCmd.Flags().Int64Var(&generateOpts.Amount, "amount", defaults.Amount, "fixed amount, mean of normal or minimum of pareto")
+	generateDataCmd.Flags().Int64Var(&generateOpts.MaxAmount, "max-amount", defaults.MaxAmount, "upper bound of the initial wallet amounts")
+	generateDataCmd.Flags().StringVar(&generateSince, "since", defaults.Since.Format("2006-01-02"), "registration date of the first user")
+	generateDataCmd.Flags().DurationVar(&generateOpts.Period, "period", defaults.Period, "period over which users register and transfer")
+	generateDataCmd.Flags().BoolVar(&generateBulk, "bulk", false, "loads rows with LOAD DATA or COPY instead of INSERT")
+	generateDataCmd.Flags().Int64Var(&bulkOpts.ChunkRows, "chunk-rows", 500000, "rows committed per LOAD DATA or COPY statement with --bulk")
+	generateDataCmd.Flags().BoolVar(&bulkOpts.Resume, "resume", false, "keeps loaded rows and continues after the last committed chunk with --bulk")
+	generateDataCmd.Flags().BoolVar(&bulkOpts.RebuildIndexes, "rebuild-indexes", false, "drops secondary indexes before loading and rebuilds them afterwards with --bulk")
+
 	rootCmd.AddCommand(generateDataCmd)
 }
 
 func RunGenerateDataCmd(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
 
+	// 大量載入不使用 INSERT, 每個 statement 的資料列數量由 --chunk-rows 決定
+	if generateBulk && cmd.Flags().Changed("batch-size") {
+		return fmt.Errorf("--batch-size sets the rows per INSERT and can not be used with --bulk, please use --chunk-rows")
+	}
+
+	since, err := time.ParseInLocation("2006-01-02", generateSince, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid --since %v: %w", generateSince, err)
+	}
+	generateOpts.Since = since
+
+	if generateRate > 0 {
+		generateOpts.Logs = int(generateRate * generateOpts.Period.Hours())
+	}
+	if generateOpts.Wallets < 0 {
+		generateOpts.Wallets = generateOpts.Users
+	}
+	if err := generateOpts.Validate(); err != nil {
+		return err
+	}
+
 	infra := accessor.BuildAccessor()
 	defer infra.Close(ctx)
 
 	infra.InitRDB(ctx)
 
-	infra.RDB.GenerateData(ctx)
+	if !generateBulk {
+		return infra.RDB.GenerateData(ctx, generateOpts)
+	}
+
+	loader, err := bulk.NewLoader(infra.RDB.DB(), infra.Config.RDB.Driver, bulkOpts)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return loader.Load(ctx, generateOpts, func(p *bulk.Progress) {
+		logrus.Infof("%v: %v/%v rows (%.1f%%), %.0f rows/s, elapsed %v",
+			p.Table, p.Skipped+p.Rows, p.Total, 100*float64(p.Skipped+p.Rows)/float64(p.Total), p.Throughput(), p.Elapsed.Truncate(time.Second))
+	})
 }
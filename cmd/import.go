@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"practice/internal/accessor"
+	"practice/internal/dataset"
+	"practice/internal/storage/dialect"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var importOpts dataset.ImportOptions
+
+var importCmd = &cobra.Command{
+	Use:   "import TABLE FILE",
+	Short: "Loads a CSV or JSONL file into a table of the configured rdb",
+	Long: `The format and compression (.gz, .zst) are detected from the file extension unless --format is given.
+Fields are written into columns of the same name unless mapped by --map, fields without a column are ignored.
+Strings are coerced into the column types (e.g. datetime, int), an empty CSV field is NULL.
+Rows that cannot be converted or written are appended to the reject file with the reason.
+With --on-conflict fail the file is imported in one transaction, a conflict rolls back every row of the file.
+Files written by the export command can be imported into either driver.`,
+	Args: cobra.ExactArgs(2),
+	RunE: RunImportCmd,
+}
+
+func init() {
+	importCmd.Flags().StringVar(&importOpts.Format, "format", "", "csv or jsonl, detected from the file extension when omitted")
+	importCmd.Flags().StringVar(&importOpts.Compression, "compression", dataset.CompressionNone, "none, gzip or zstd, only used with --format")
+	importCmd.Flags().StringToStringVar(&importOpts.Mapping, "map", nil, "maps fields of the file to columns (e.g. login=account,name=nickname)")
+	importCmd.Flags().IntVarP(&importOpts.BatchSize, "batch-size", "b", 1000, "rows written by one INSERT")
+	importCmd.Flags().StringVar(&importOpts.Conflict, "on-conflict", dataset.ConflictFail, "fail (rolls back the whole file), skip or upsert when a row conflicts on a primary or unique key")
+	importCmd.Flags().StringSliceVar(&importOpts.Keys, "keys", nil, "unique key columns of the conflict (e.g. account), required by upsert on postgresql")
+	importCmd.Flags().StringVar(&importOpts.RejectFile, "reject", "", "JSONL file receiving the rejected rows, defaults to FILE.rejects.jsonl")
+
+	rootCmd.AddCommand(importCmd)
+}
+
+func RunImportCmd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	table, path := args[0], args[1]
+
+	if importOpts.RejectFile == "" {
+		importOpts.RejectFile = path + ".rejects.jsonl"
+	}
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(ctx)
+
+	infra.InitRDB(ctx)
+
+	d, err := dialect.New(infra.Config.RDB.Driver)
+	if err != nil {
+		return err
+	}
+
+	importer, err := dataset.NewImporter(infra.RDB.DB(), d, importOpts)
+	if err != nil {
+		return err
+	}
+
+	report, err := importer.Import(ctx, table, path, func(r *dataset.ImportReport) {
+		logrus.Debugf("%v: %v rows read, %v written in %v batches", r.Table, r.Rows, r.Written, r.Batches)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to import %v into %v: %w", path, table, err)
+	}
+
+	if len(report.Ignored) > 0 {
+		logrus.Warnf("ignored fields without a column in %v: %v", report.Table, report.Ignored)
+	}
+	logrus.Infof("imported %v into %v (%v): %v rows read, %v written, %v skipped, %v rejected in %v",
+		report.File, report.Table, report.Columns, report.Rows, report.Written, report.Skipped, report.Rejected, report.Elapsed)
+	if report.Rejected > 0 {
+		logrus.Warnf("rejected rows are written to %v", importOpts.RejectFile)
+	}
+	return nil
+}
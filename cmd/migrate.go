@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"practice/deployments"
+	"practice/internal/accessor"
+	"practice/internal/migration"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateDriver      string
+	migrateLockTimeout int
+	migrateAll         bool
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Applies the embedded migrations through the configured rdb connection",
+	Long: `Migrations under deployments/*/migration are compiled into the binary.
+The applied version and dirty flag are kept in the schema_migrations table, which is compatible with golang-migrate.`,
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up [N]",
+	Short: "Applies N pending migrations, all of them when N is omitted",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		n, err := migrateSteps(args)
+		if err != nil {
+			return err
+		}
+		return runMigration(func(ctx context.Context, runner *migration.Runner) error {
+			return runner.Up(ctx, n)
+		})
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down [N]",
+	Short: "Reverts N applied migrations, --all reverts every migration",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		n, err := migrateSteps(args)
+		if err != nil {
+			return err
+		}
+		if n == 0 && !migrateAll {
+			return fmt.Errorf("please specify N or --all to revert every migration")
+		}
+		return runMigration(func(ctx context.Context, runner *migration.Runner) error {
+			return runner.Down(ctx, n)
+		})
+	},
+}
+
+var migrateGotoCmd = &cobra.Command{
+	Use:   "goto VERSION",
+	Short: "Applies or reverts migrations until VERSION, 0 reverts every migration",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		version, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %v: %w", args[0], err)
+		}
+		return runMigration(func(ctx context.Context, runner *migration.Runner) error {
+			return runner.Goto(ctx, version)
+		})
+	},
+}
+
+var migrateForceCmd = &cobra.Command{
+	Use:   "force VERSION",
+	Short: "Sets VERSION and clears the dirty flag without running any migration, after fixing a failed one manually",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		version, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %v: %w", args[0], err)
+		}
+		return runMigration(func(ctx context.Context, runner *migration.Runner) error {
+			return runner.Force(ctx, version)
+		})
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Shows the current version, the dirty flag and whether each migration is applied",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runMigration(func(ctx context.Context, runner *migration.Runner) error {
+			current, dirty, statuses, err := runner.Status(ctx)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("version: %v, dirty: %v\n\n", current, dirty)
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+			for _, status := range statuses {
+				fmt.Fprintf(w, "%v\t%v\t%v\n", status.Version, status.Name, status.Applied)
+			}
+			return w.Flush()
+		})
+	},
+}
+
+func init() {
+	migrateCmd.PersistentFlags().StringVar(&migrateDriver, "driver", "", "mysql or postgresql, defaults to rdb.driver of the config")
+	migrateCmd.PersistentFlags().IntVar(&migrateLockTimeout, "lock-timeout", 15, "seconds waiting for another running migration")
+	migrateDownCmd.Flags().BoolVar(&migrateAll, "all", false, "reverts every applied migration")
+
+	migrateCmd.AddCommand(migrateUpCmd)
+	migrateCmd.AddCommand(migrateDownCmd)
+	migrateCmd.AddCommand(migrateGotoCmd)
+	migrateCmd.AddCommand(migrateForceCmd)
+	migrateCmd.AddCommand(migrateStatusCmd)
+	rootCmd.AddCommand(migrateCmd)
+}
+
+func migrateSteps(args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("N must be a positive integer, got %v", args[0])
+	}
+	return n, nil
+}
+
+func runMigration(fn func(ctx context.Context, runner *migration.Runner) error) error {
+	ctx := context.Background()
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(ctx)
+
+	if migrateDriver != "" {
+		infra.Config.RDB.Driver = migrateDriver
+	}
+	dir, err := deployments.MigrationDir(infra.Config.RDB.Driver)
+	if err != nil {
+		return err
+	}
+	migrations, err := migration.Load(deployments.Migrations, dir)
+	if err != nil {
+		return err
+	}
+
+	infra.InitRDB(ctx)
+
+	runner := migration.NewRunner(infra.RDB.DB(), infra.Config.RDB.Driver, migrations, time.Duration(migrateLockTimeout)*time.Second)
+	return fn(ctx, runner)
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"context"
+	"practice/internal/accessor"
+
+	"github.com/spf13/cobra"
+)
+
+var monotonicReadsFix bool
+
+var monotonicReadsCmd = &cobra.Command{
+	Use:   "monotonic_reads",
+	Short: "Reads a balance alternately from replicas with different delays, later reads may return older data",
+	Long: `Requires rdb.replicas, a replica delayed with SOURCE_DELAY (MySQL) or recovery_min_apply_delay (PostgreSQL) reproduces it reliably.
+List the replica without delay before the delayed one in rdb.replicas, with a single replica the primary takes its place.
+--fix pins the session to a single replica.`,
+	RunE: RunMonotonicReadsCmd,
+}
+
+func init() {
+	monotonicReadsCmd.Flags().BoolVar(&monotonicReadsFix, "fix", false, "runs the scenario with the solution")
+
+	rootCmd.AddCommand(monotonicReadsCmd)
+}
+
+func RunMonotonicReadsCmd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(ctx)
+	infra.InitRouter(ctx)
+
+	infra.RDB.SimulateMonotonicReads(ctx, infra.Router, monotonicReadsFix)
+
+	return nil
+}
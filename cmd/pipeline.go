@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"practice/internal/accessor"
+	"practice/internal/pipeline"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var pipelineCmd = &cobra.Command{
+	Use:   "pipeline",
+	Short: "Change data capture pipeline",
+	Long:  ``,
+}
+
+var pipelineRunCmd = &cobra.Command{
+	Use:   "run <name>",
+	Short: "Streams change events of the named pipeline from its source through its transforms into its sinks",
+	Long:  ``,
+	Args:  cobra.ExactArgs(1),
+	RunE:  RunPipelineRunCmd,
+}
+
+var pipelineValidateCmd = &cobra.Command{
+	Use:   "validate [names...]",
+	Short: "Validates the pipelines defined in the configuration file without connecting to any database",
+	Long:  ``,
+	RunE:  RunPipelineValidateCmd,
+}
+
+var pipelineSnapshotCmd = &cobra.Command{
+	Use:   "snapshot <name> <tables...>",
+	Short: "Requests the named running pipeline to (re)snapshot the given tables incrementally",
+	Long:  ``,
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  RunPipelineSnapshotCmd,
+}
+
+func init() {
+	pipelineCmd.AddCommand(pipelineRunCmd)
+	pipelineCmd.AddCommand(pipelineValidateCmd)
+	pipelineCmd.AddCommand(pipelineSnapshotCmd)
+	rootCmd.AddCommand(pipelineCmd)
+}
+
+func RunPipelineRunCmd(cmd *cobra.Command, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(context.Background())
+
+	if err := infra.SelectPipeline(args[0]); err != nil {
+		return err
+	}
+	if problems := infra.Config.ValidatePipelines()[args[0]]; len(problems) > 0 {
+		return fmt.Errorf("invalid pipeline %v: %v", args[0], strings.Join(problems, "; "))
+	}
+
+	cfg := infra.Pipeline
+	transforms, err := infra.BuildTransforms(cfg.Transforms)
+	if err != nil {
+		return err
+	}
+
+	infra.InitRDB(ctx)
+	infra.InitSource(ctx)
+	infra.InitSinks(ctx)
+	infra.InitDeadLetters(ctx)
+
+	p, err := pipeline.New(cfg.Name, infra.Source, infra.Sinks, cfg.CheckpointDir)
+	if err != nil {
+		return err
+	}
+
+	p.SchemaRegistry(infra.Schema)
+	p.Transforms(transforms)
+	p.Retry(cfg.DeadLetter.MaxRetries, time.Duration(cfg.DeadLetter.Backoff)*time.Millisecond)
+	if infra.DeadLetters != nil {
+		p.DeadLetter(infra.DeadLetters)
+	}
+
+	switch cfg.Snapshot.Mode {
+	case "initial":
+		p.InitialSnapshot(cfg.Snapshot.ChunkSize)
+	case "incremental":
+		p.IncrementalSnapshot(cfg.Snapshot.ChunkSize)
+	case "never":
+	default:
+		return fmt.Errorf("pipeline snapshot mode undifined: %v", cfg.Snapshot.Mode)
+	}
+
+	return p.Run(ctx)
+}
+
+func RunPipelineValidateCmd(cmd *cobra.Command, args []string) error {
+	infra := accessor.BuildAccessor()
+
+	names := args
+	if len(names) == 0 {
+		for _, p := range infra.Config.Pipelines {
+			names = append(names, p.Name)
+		}
+	}
+
+	problems := infra.Config.ValidatePipelines()
+	invalid := len(problems[""])
+	for _, problem := range problems[""] {
+		fmt.Printf("configuration: %v\n", problem)
+	}
+
+	for _, name := range names {
+		opts, err := infra.Config.FindPipeline(name)
+		if err != nil {
+			fmt.Printf("%v: %v\n", name, err)
+			invalid++
+			continue
+		}
+
+		// 轉換的參數只有在建立時才能完整檢查
+		found := problems[name]
+		if _, err := infra.BuildTransforms(opts.Transforms); err != nil {
+			found = append(found, err.Error())
+		}
+
+		if len(found) == 0 {
+			fmt.Printf("%v: ok (%v source, %v transforms, sinks: %v)\n", name, opts.Source.Type, len(opts.Transforms), strings.Join(opts.Sinks, ", "))
+			continue
+		}
+
+		invalid++
+		for _, problem := range found {
+			fmt.Printf("%v: %v\n", name, problem)
+		}
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%v invalid pipeline definitions", invalid)
+	}
+	return nil
+}
+
+func RunPipelineSnapshotCmd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(ctx)
+
+	if err := infra.SelectPipeline(args[0]); err != nil {
+		return err
+	}
+
+	infra.InitRDB(ctx)
+
+	src := infra.BuildSource(ctx)
+	defer src.Shutdown(ctx)
+
+	return pipeline.RequestSnapshot(ctx, src, infra.Pipeline.Name, args[1:])
+}
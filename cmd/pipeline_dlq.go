@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"practice/internal/accessor"
+	"practice/internal/pipeline/dlq"
+	"practice/internal/pipeline/sink"
+	"practice/internal/storage"
+	"practice/internal/storage/codec"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var pipelineDlqCmd = &cobra.Command{
+	Use:   "dlq",
+	Short: "Inspects, replays or discards dead-lettered change events of the named pipeline",
+	Long:  ``,
+}
+
+var pipelineDlqListCmd = &cobra.Command{
+	Use:   "list <name>",
+	Short: "Lists dead-lettered change events",
+	Long:  ``,
+	Args:  cobra.ExactArgs(1),
+	RunE:  RunPipelineDlqListCmd,
+}
+
+var pipelineDlqShowCmd = &cobra.Command{
+	Use:   "show <name> <id>",
+	Short: "Shows a dead-lettered change event with its error",
+	Long:  ``,
+	Args:  cobra.ExactArgs(2),
+	RunE:  RunPipelineDlqShowCmd,
+}
+
+var pipelineDlqReplayCmd = &cobra.Command{
+	Use:   "replay <name> [ids...]",
+	Short: "Writes dead-lettered change events into their sink again, removes them once written",
+	Long:  ``,
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  RunPipelineDlqReplayCmd,
+}
+
+var pipelineDlqDiscardCmd = &cobra.Command{
+	Use:   "discard <name> [ids...]",
+	Short: "Removes dead-lettered change events without writing them",
+	Long:  ``,
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  RunPipelineDlqDiscardCmd,
+}
+
+var dlqAll bool
+
+func init() {
+	pipelineDlqReplayCmd.Flags().BoolVar(&dlqAll, "all", false, "replays all dead-lettered events")
+	pipelineDlqDiscardCmd.Flags().BoolVar(&dlqAll, "all", false, "discards all dead-lettered events")
+
+	pipelineDlqCmd.AddCommand(pipelineDlqListCmd)
+	pipelineDlqCmd.AddCommand(pipelineDlqShowCmd)
+	pipelineDlqCmd.AddCommand(pipelineDlqReplayCmd)
+	pipelineDlqCmd.AddCommand(pipelineDlqDiscardCmd)
+	pipelineCmd.AddCommand(pipelineDlqCmd)
+}
+
+func RunPipelineDlqListCmd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(ctx)
+
+	if err := infra.SelectPipeline(args[0]); err != nil {
+		return err
+	}
+
+	infra.InitDeadLetters(ctx)
+	store := infra.DeadLetters
+	if store == nil {
+		return fmt.Errorf("dead-letter store of pipeline %v is disabled", infra.Pipeline.Name)
+	}
+
+	entries, err := store.List(ctx, infra.Pipeline.Name)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSINK\tTABLE\tOPERATION\tATTEMPTS\tFAILED AT\tERROR")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%v\t%v\t%v.%v\t%v\t%v\t%v\t%v\n",
+			entry.ID, entry.Sink, entry.Event.Database, entry.Event.Table, entry.Event.Operation,
+			entry.Attempts, entry.FailedAt.Format(time.RFC3339), entry.Error)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%v dead-lettered events of pipeline %v\n", len(entries), infra.Pipeline.Name)
+	return nil
+}
+
+func RunPipelineDlqShowCmd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(ctx)
+
+	if err := infra.SelectPipeline(args[0]); err != nil {
+		return err
+	}
+
+	infra.InitDeadLetters(ctx)
+	store := infra.DeadLetters
+	if store == nil {
+		return fmt.Errorf("dead-letter store of pipeline %v is disabled", infra.Pipeline.Name)
+	}
+
+	entry, err := store.Get(ctx, infra.Pipeline.Name, args[1])
+	if err != nil {
+		return err
+	}
+
+	event, err := codec.NewJSONCodec().Encode(entry.Event)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"id":        entry.ID,
+		"pipeline":  entry.Pipeline,
+		"sink":      entry.Sink,
+		"position":  entry.Position,
+		"error":     entry.Error,
+		"attempts":  entry.Attempts,
+		"failed_at": entry.FailedAt,
+		"event":     json.RawMessage(event),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(data))
+	return nil
+}
+
+func RunPipelineDlqReplayCmd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(ctx)
+
+	if err := infra.SelectPipeline(args[0]); err != nil {
+		return err
+	}
+
+	infra.InitDeadLetters(ctx)
+	store := infra.DeadLetters
+	if store == nil {
+		return fmt.Errorf("dead-letter store of pipeline %v is disabled", infra.Pipeline.Name)
+	}
+
+	entries, err := selectDeadLetters(ctx, store, infra.Pipeline.Name, args[1:])
+	if err != nil {
+		return err
+	}
+
+	infra.InitSinks(ctx)
+	sinks := map[string]sink.Sink{}
+	for _, s := range infra.Sinks {
+		sinks[s.Name()] = s
+	}
+
+	replayed, failed := 0, 0
+	for _, entry := range entries {
+		s, ok := sinks[entry.Sink]
+		if !ok {
+			logrus.Errorf("dead-letter %v skipped, %v sink is not enabled", entry.ID, entry.Sink)
+			failed++
+			continue
+		}
+
+		if err := s.Write(ctx, []*storage.ChangeEvent{entry.Event}); err != nil {
+			entry.Attempts++
+			entry.Error = err.Error()
+			entry.FailedAt = time.Now()
+			if err := store.Put(ctx, entry); err != nil {
+				return err
+			}
+
+			logrus.Errorf("failed to replay dead-letter %v into %v sink: %v", entry.ID, entry.Sink, err)
+			failed++
+			continue
+		}
+
+		if err := store.Delete(ctx, entry.Pipeline, entry.ID); err != nil {
+			return err
+		}
+		replayed++
+	}
+
+	fmt.Printf("%v dead-lettered events replayed, %v failed\n", replayed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%v dead-lettered events failed to replay", failed)
+	}
+	return nil
+}
+
+func RunPipelineDlqDiscardCmd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(ctx)
+
+	if err := infra.SelectPipeline(args[0]); err != nil {
+		return err
+	}
+
+	infra.InitDeadLetters(ctx)
+	store := infra.DeadLetters
+	if store == nil {
+		return fmt.Errorf("dead-letter store of pipeline %v is disabled", infra.Pipeline.Name)
+	}
+
+	entries, err := selectDeadLetters(ctx, store, infra.Pipeline.Name, args[1:])
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := store.Delete(ctx, entry.Pipeline, entry.ID); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("%v dead-lettered events discarded\n", len(entries))
+	return nil
+}
+
+// selectDeadLetters 取得指定的 dead-letter, --all 時取得所有 dead-letter
+func selectDeadLetters(ctx context.Context, store dlq.Store, pipeline string, ids []string) ([]*dlq.Entry, error) {
+	if dlqAll {
+		return store.List(ctx, pipeline)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("requires dead-letter ids or --all")
+	}
+
+	entries := make([]*dlq.Entry, 0, len(ids))
+	for _, id := range ids {
+		entry, err := store.Get(ctx, pipeline, id)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"context"
+	"practice/internal/accessor"
+
+	"github.com/spf13/cobra"
+)
+
+var readYourWritesFix bool
+
+var readYourWritesCmd = &cobra.Command{
+	Use:   "read_your_writes",
+	Short: "Transfers and immediately reads the balance back through a session, which may hit a replica that has not applied the transfer",
+	Long: `Requires rdb.replicas, a replica delayed with SOURCE_DELAY (MySQL) or recovery_min_apply_delay (PostgreSQL) reproduces it reliably.
+--fix waits until the replica applies the position of the primary after the write.`,
+	RunE: RunReadYourWritesCmd,
+}
+
+func init() {
+	readYourWritesCmd.Flags().BoolVar(&readYourWritesFix, "fix", false, "runs the scenario with the solution")
+
+	rootCmd.AddCommand(readYourWritesCmd)
+}
+
+func RunReadYourWritesCmd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(ctx)
+	infra.InitRouter(ctx)
+
+	infra.RDB.SimulateReadYourWrites(ctx, infra.Router, readYourWritesFix)
+
+	return nil
+}
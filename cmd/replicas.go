@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"practice/internal/accessor"
+	"practice/internal/storage/router"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var replicasWatch time.Duration
+
+var replicasCmd = &cobra.Command{
+	Use:   "replicas",
+	Short: "Shows the health and lag of the replicas in rdb.replicas and where the router sends reads",
+	Long: `Replicas that cannot be reached, stopped replicating or lag more than routing.max_lag are evicted from reads
+and come back once they catch up. Reads go to the primary when no replica is eligible.`,
+	RunE: RunReplicasCmd,
+}
+
+func init() {
+	replicasCmd.Flags().DurationVar(&replicasWatch, "watch", 0, "prints the status again after the interval until interrupted, 0 prints once")
+
+	rootCmd.AddCommand(replicasCmd)
+}
+
+func RunReplicasCmd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(ctx)
+
+	infra.InitRouter(ctx)
+
+	for {
+		printReplicas(infra.Router)
+		if replicasWatch <= 0 {
+			return nil
+		}
+		time.Sleep(replicasWatch)
+	}
+}
+
+func printReplicas(r *router.Router) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPLICA\tELIGIBLE\tLAG\tERROR")
+	for _, replica := range r.Replicas() {
+		lag, healthy, err := replica.Status()
+		message := ""
+		if err != nil {
+			message = err.Error()
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", replica.Name, healthy, lag, message)
+	}
+
+	target := router.PrimaryName
+	if _, replica := r.Reader(); replica != nil {
+		target = replica.Name
+	}
+	fmt.Fprintf(w, "next read (%v)\t%v\n", r.Policy(), target)
+	w.Flush()
+}
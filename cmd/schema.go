@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"practice/deployments"
+	"practice/internal/accessor"
+	"practice/internal/ddl"
+	"practice/internal/migration"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	schemaDriver string
+	schemaIgnore []string
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Manages the single schema definition of users, wallets and logs",
+	Long:  ``,
+}
+
+var schemaGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Prints the mysql and postgres DDL creating the schema definition from an empty database",
+	Long: `The output is a reference for writing a new migration and is never executed by migrate.
+Released migrations are never rewritten, add a new migration for every change of the definition.`,
+	RunE: RunSchemaGenerateCmd,
+}
+
+var schemaCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Fails when replaying the embedded migrations of each driver does not produce the schema definition",
+	Long: `Applies the up migrations in version order to an empty schema without a database connection,
+then compares tables, columns, types, indexes, checks and comments with the definition like schema diff.`,
+	RunE: RunSchemaCheckCmd,
+}
+
+var schemaDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compares tables, columns, types, indexes and comments of the connected database with the schema definition",
+	Long: `Reports missing, extra and mismatched objects of users, wallets and logs.
+Tables created by the other embedded migrations or listed in --ignore are not reported as extra.`,
+	RunE: RunSchemaDiffCmd,
+}
+
+func init() {
+	schemaCmd.PersistentFlags().StringVar(&schemaDriver, "driver", "", "mysql or postgresql, generate and check default to both, diff defaults to rdb.driver of the config")
+	schemaDiffCmd.Flags().StringSliceVar(&schemaIgnore, "ignore", []string{"pipeline_offsets", "pipeline_dead_letters"}, "tables created outside the migrations")
+
+	schemaCmd.AddCommand(schemaGenerateCmd)
+	schemaCmd.AddCommand(schemaCheckCmd)
+	schemaCmd.AddCommand(schemaDiffCmd)
+	rootCmd.AddCommand(schemaCmd)
+}
+
+// schemaDialects 回傳 --driver 指定的 dialect, 未指定時回傳所有 dialect
+func schemaDialects() ([]ddl.Dialect, error) {
+	if schemaDriver == "" {
+		return ddl.Dialects, nil
+	}
+	dialect, err := ddl.FindDialect(schemaDriver)
+	if err != nil {
+		return nil, err
+	}
+	return []ddl.Dialect{dialect}, nil
+}
+
+// embeddedMigrations 回傳 driver 依版本排序的 up migrations 與其中建立的資料表
+func embeddedMigrations(driver string) ([]string, []string, error) {
+	dir, err := deployments.MigrationDir(driver)
+	if err != nil {
+		return nil, nil, err
+	}
+	migrations, err := migration.Load(deployments.Migrations, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ups, tables := []string{}, []string{}
+	for _, m := range migrations {
+		ups = append(ups, m.Up)
+		tables = append(tables, m.Tables()...)
+	}
+	return ups, tables, nil
+}
+
+func RunSchemaGenerateCmd(cmd *cobra.Command, args []string) error {
+	dialects, err := schemaDialects()
+	if err != nil {
+		return err
+	}
+
+	for _, dialect := range dialects {
+		fmt.Printf("-- %v up\n%v\n-- %v down\n%v\n", dialect.Driver(), dialect.Up(ddl.Initial), dialect.Driver(), dialect.Down(ddl.Initial))
+	}
+	return nil
+}
+
+func RunSchemaCheckCmd(cmd *cobra.Command, args []string) error {
+	dialects, err := schemaDialects()
+	if err != nil {
+		return err
+	}
+
+	for _, dialect := range dialects {
+		ups, known, err := embeddedMigrations(dialect.Driver())
+		if err != nil {
+			return err
+		}
+		if err := ddl.Check(ddl.Initial, dialect, ups, known); err != nil {
+			return fmt.Errorf("%v: %w", dialect.Driver(), err)
+		}
+		fmt.Printf("%v migrations match the schema definition\n", dialect.Driver())
+	}
+	return nil
+}
+
+func RunSchemaDiffCmd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(ctx)
+
+	if schemaDriver != "" {
+		infra.Config.RDB.Driver = schemaDriver
+	}
+	driver := infra.Config.RDB.Driver
+	dialect, err := ddl.FindDialect(driver)
+	if err != nil {
+		return err
+	}
+
+	// 其他 migration 建立的資料表不在 schema 定義中, 但也不是多餘的資料表
+	_, tables, err := embeddedMigrations(driver)
+	if err != nil {
+		return err
+	}
+	known := append(append([]string{migration.VersionTable}, schemaIgnore...), tables...)
+
+	infra.InitRDB(ctx)
+
+	namespace := "public"
+	if driver == "mysql" {
+		namespace = infra.Config.RDB.MysqlOpts.DBName
+	}
+	live, err := ddl.Inspect(ctx, infra.RDB.DB(), driver, namespace)
+	if err != nil {
+		return err
+	}
+
+	drifts := ddl.Compare(ddl.Initial, dialect, live, known)
+	if len(drifts) == 0 {
+		fmt.Printf("%v.%v matches the schema definition\n", driver, namespace)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tOBJECT\tNAME\tPROBLEM\tEXPECTED\tACTUAL")
+	for _, drift := range drifts {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", drift.Table, drift.Object, drift.Name, drift.Problem, drift.Expected, drift.Actual)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%v.%v drifted from the schema definition in %v places", driver, namespace, len(drifts))
+}
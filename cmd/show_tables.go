@@ -2,19 +2,27 @@ package cmd
 
 import (
 	"context"
+	"os"
 	"practice/internal/accessor"
+	"practice/internal/ddl"
 
 	"github.com/spf13/cobra"
 )
 
+var showTablesOutput string
+
 var showTablesCmd = &cobra.Command{
-	Use:   "show_tables",
-	Short: "",
-	Long:  ``,
-	RunE:  RunShowTablesCmd,
+	Use:   "show_tables [TABLE...]",
+	Short: "Describes columns, indexes, approximate rows and size of the tables in the configured rdb",
+	Long: `Reads information_schema (MySQL) or pg_catalog (PostgreSQL) instead of querying the tables.
+Row counts and sizes come from the table statistics and are estimates,
+PostgreSQL reports 0 rows until the table is analyzed.`,
+	RunE: RunShowTablesCmd,
 }
 
 func init() {
+	showTablesCmd.Flags().StringVarP(&showTablesOutput, "output", "o", ddl.OutputTable, "table, json or yaml")
+
 	rootCmd.AddCommand(showTablesCmd)
 }
 
@@ -26,7 +34,14 @@ func RunShowTablesCmd(cmd *cobra.Command, args []string) error {
 
 	infra.InitRDB(ctx)
 
-	infra.RDB.ShowTables(ctx)
+	live, err := infra.RDB.ShowTables(ctx)
+	if err != nil {
+		return err
+	}
+	live, err = live.Select(args)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return ddl.Describe(os.Stdout, live, showTablesOutput)
 }
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"practice/internal/accessor"
+	"practice/internal/storage/dialect"
+	"practice/internal/storage/generator"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	streamOpts     generator.StreamOptions
+	streamDuration time.Duration
+)
+
+var transfersCmd = &cobra.Command{
+	Use:   "transfers",
+	Short: "Generates live transfers between the users of the rdb section and checks wallets against logs",
+	Long:  ``,
+}
+
+var transfersStreamCmd = &cobra.Command{
+	Use:   "stream",
+	Short: "Keeps committing transfers in real time so that CDC pipelines have live traffic to consume",
+	Long: `Every transfer locks both wallets in one transaction, moves the amount and inserts the log,
+so wallets.amount stays consistent with logs and never becomes negative.
+The rate follows the time of day and the weekday, users transfer according to a power-law activity.`,
+	RunE: RunTransfersStreamCmd,
+}
+
+var transfersCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Lists wallets whose amount differs from their deposits minus their withdrawals in logs",
+	Long:  ``,
+	RunE:  RunTransfersCheckCmd,
+}
+
+func init() {
+	transfersStreamCmd.Flags().Float64Var(&streamOpts.Rate, "rate", 3600, "average transfers per hour")
+	transfersStreamCmd.Flags().Float64Var(&streamOpts.Activity, "activity", generator.DefaultOptions().Activity, "pareto shape of the user activity, smaller values concentrate transfers on fewer users")
+	transfersStreamCmd.Flags().Int64Var(&streamOpts.Seed, "seed", 0, "random seed, 0 seeds with the current time")
+	transfersStreamCmd.Flags().DurationVar(&streamOpts.Report, "report", 10*time.Second, "interval of the progress log")
+	transfersStreamCmd.Flags().DurationVar(&streamDuration, "duration", 0, "stops after the duration, 0 streams until interrupted")
+
+	transfersCmd.AddCommand(transfersStreamCmd)
+	transfersCmd.AddCommand(transfersCheckCmd)
+	rootCmd.AddCommand(transfersCmd)
+}
+
+func RunTransfersStreamCmd(cmd *cobra.Command, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if streamDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, streamDuration)
+		defer cancel()
+	}
+
+	if streamOpts.Seed == 0 {
+		streamOpts.Seed = time.Now().UnixNano()
+	}
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(context.Background())
+
+	infra.InitRDB(ctx)
+
+	d, err := dialect.New(infra.Config.RDB.Driver)
+	if err != nil {
+		return err
+	}
+
+	streamer, err := generator.NewStreamer(infra.RDB.DB(), infra.RDB.Repositories(), d, streamOpts)
+	if err != nil {
+		return err
+	}
+
+	stats, err := streamer.Run(ctx)
+	if stats != nil {
+		logrus.Infof("committed %v transfers, skipped %v, retried %v", stats.Transfers, stats.Skipped, stats.Retries)
+	}
+	return err
+}
+
+func RunTransfersCheckCmd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(ctx)
+
+	infra.InitRDB(ctx)
+
+	imbalances, err := generator.CheckBalances(ctx, infra.RDB.DB())
+	if err != nil {
+		return err
+	}
+
+	for _, imbalance := range imbalances {
+		logrus.Errorf("wallet of user %v holds %v, logs sum up to %v", imbalance.UserID, imbalance.Amount, imbalance.Expected)
+	}
+	if len(imbalances) > 0 {
+		return fmt.Errorf("%v wallets are inconsistent with logs", len(imbalances))
+	}
+
+	logrus.Info("every wallet is consistent with logs")
+	return nil
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"practice/internal/accessor"
+	"practice/internal/etl"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifySource    string
+	verifyTarget    string
+	verifyChunkSize int64
+	verifyRowsLimit int64
+	verifyRepair    string
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [tables...]",
+	Short: "Compares tables between two configured databases, lists missing, extra and different rows",
+	Long: `Splits every table into chunks of --chunk-size rows by primary key and hashes each chunk inside both databases,
+only the row count and the md5 of the chunk leave the database.
+Mismatched chunks are split at their median key until they hold at most --rows-limit rows, which are read and compared row by row.
+The source holds the expected rows, --repair writes statements that make the target match it.
+
+Endpoints:
+  mysql, postgresql  the primary of the driver section in rdb
+  replica:<name>     a replica listed in rdb.replicas
+  pipeline:<name>    the rdb sink target of the pipeline, table_prefix included`,
+	RunE: RunVerifyCmd,
+}
+
+func init() {
+	verifyCmd.Flags().StringVar(&verifySource, "source", "mysql", "endpoint holding the expected rows (mysql, postgresql, replica:<name>, pipeline:<name>)")
+	verifyCmd.Flags().StringVar(&verifyTarget, "target", "postgresql", "endpoint compared against the source (mysql, postgresql, replica:<name>, pipeline:<name>)")
+	verifyCmd.Flags().Int64Var(&verifyChunkSize, "chunk-size", 10000, "rows of each side hashed at once")
+	verifyCmd.Flags().Int64Var(&verifyRowsLimit, "rows-limit", 64, "mismatched chunks holding at most this many rows are compared row by row")
+	verifyCmd.Flags().StringVar(&verifyRepair, "repair", "", "writes repair statements for the target into the file, - for standard output")
+
+	rootCmd.AddCommand(verifyCmd)
+}
+
+func RunVerifyCmd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	if verifySource == verifyTarget {
+		return fmt.Errorf("source and target are both %v, please compare different endpoints", verifySource)
+	}
+	if verifyChunkSize <= 0 || verifyRowsLimit <= 0 {
+		return fmt.Errorf("chunk size and rows limit must be positive")
+	}
+
+	tables := args
+	if len(tables) == 0 {
+		tables = []string{"users", "wallets", "logs"}
+	}
+
+	infra := accessor.BuildAccessor()
+	defer infra.Close(ctx)
+
+	endpoint := func(name string) (etl.Endpoint, error) {
+		opts, prefix, err := infra.Config.Endpoint(name)
+		if err != nil {
+			return etl.Endpoint{}, err
+		}
+
+		namespace := "public"
+		if opts.Driver == "mysql" {
+			namespace = opts.MysqlOpts.DBName
+		}
+		return etl.Endpoint{
+			Name:      name,
+			Driver:    opts.Driver,
+			DB:        infra.OpenRDB(ctx, name, opts).DB(),
+			Namespace: namespace,
+			Prefix:    prefix,
+		}, nil
+	}
+
+	source, err := endpoint(verifySource)
+	if err != nil {
+		return err
+	}
+	target, err := endpoint(verifyTarget)
+	if err != nil {
+		return err
+	}
+
+	verifier := etl.NewVerifier(source, target, verifyChunkSize, verifyRowsLimit)
+
+	var repair io.Writer
+	switch verifyRepair {
+	case "":
+	case "-":
+		repair = os.Stdout
+	default:
+		f, err := os.Create(verifyRepair)
+		if err != nil {
+			return fmt.Errorf("failed to create repair file: %w", err)
+		}
+		defer f.Close()
+		repair = f
+	}
+
+	diffs := []*etl.Diff{}
+	for _, name := range tables {
+		diff, err := verifier.Verify(ctx, name)
+		if err != nil {
+			return err
+		}
+		diffs = append(diffs, diff)
+
+		if repair != nil && len(diff.Differences) > 0 {
+			fmt.Fprintf(repair, "-- %v: %v -> %v\n", diff.Table, verifySource, verifyTarget)
+			for _, statement := range verifier.Repair(diff) {
+				fmt.Fprintln(repair, statement)
+			}
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tCHUNKS\tNARROWED\tMISSING\tEXTRA\tDIFFERENT\tELAPSED")
+	total := 0
+	for _, diff := range diffs {
+		total += len(diff.Differences)
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", diff.Table, diff.Chunks, diff.Narrowed,
+			diff.Count(etl.Missing), diff.Count(etl.Extra), diff.Count(etl.Different), diff.Elapsed)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if total == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tKEY\tKIND\tCOLUMNS")
+	for _, diff := range diffs {
+		for _, difference := range diff.Differences {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", diff.Table, difference.Key, difference.Kind, strings.Join(difference.Columns, ", "))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%v rows differ between %v and %v", total, verifySource, verifyTarget)
+}
@@ -0,0 +1,22 @@
+package deployments
+
+import (
+	"embed"
+	"fmt"
+)
+
+// Migrations 為編譯進執行檔的 migration SQL, 讓執行檔不需要原始碼目錄或外部 migrate CLI 即可建立環境
+//
+//go:embed mysql/migration/*.sql postgres/migration/*.sql
+var Migrations embed.FS
+
+// MigrationDir 回傳 driver 的 migration 在 Migrations 中的目錄
+func MigrationDir(driver string) (string, error) {
+	switch driver {
+	case "mysql":
+		return "mysql/migration", nil
+	case "postgresql":
+		return "postgres/migration", nil
+	}
+	return "", fmt.Errorf("migrations of driver %v are undifined", driver)
+}
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `pipeline_signals`;
\ No newline at end of file
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS `pipeline_signals`;
+CREATE TABLE `pipeline_signals` (
+    `id` varchar(255) NOT NULL COMMENT '訊號 ID',
+    `type` varchar(32) NOT NULL COMMENT '訊號類型 (execute-snapshot, watermark)',
+    `data` text NOT NULL COMMENT '訊號內容',
+    `created_at` datetime NOT NULL COMMENT '建立日期',
+    PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='data pipeline 訊號';
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS `outbox_events`;
+CREATE TABLE `outbox_events` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT COMMENT '事件 ID, 依寫入順序遞增',
+    `aggregate_type` varchar(255) NOT NULL COMMENT 'aggregate 類型, 對應下游的資料表',
+    `aggregate_id` varchar(255) NOT NULL COMMENT 'aggregate ID, 對應下游的主鍵',
+    `operation` varchar(32) NOT NULL COMMENT '異動類型 (insert, update, delete)',
+    `payload` text NOT NULL COMMENT 'aggregate 異動後的內容 (JSON)',
+    `created_at` datetime NOT NULL COMMENT '建立日期',
+    PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='data pipeline transactional outbox';
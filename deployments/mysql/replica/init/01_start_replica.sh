@@ -0,0 +1,17 @@
+#!/bin/bash
+# 第一次啟動時由 primary 以 GTID auto-position 開始複寫, SOURCE_DELAY 讓 replica 刻意落後指定的秒數,
+# 重現 read_your_writes, monotonic_reads 與 consistent_prefix 情境
+set -e
+
+docker_process_sql <<-SQL
+	CHANGE REPLICATION SOURCE TO
+		SOURCE_HOST = 'mysql',
+		SOURCE_PORT = 3306,
+		SOURCE_USER = 'root',
+		SOURCE_PASSWORD = '${MYSQL_ROOT_PASSWORD}',
+		SOURCE_AUTO_POSITION = 1,
+		SOURCE_CONNECT_RETRY = 5,
+		SOURCE_DELAY = ${SOURCE_DELAY:-0},
+		GET_SOURCE_PUBLIC_KEY = 1;
+	START REPLICA;
+SQL
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS pipeline_signals;
+CREATE TABLE pipeline_signals (
+    id VARCHAR(255) PRIMARY KEY,
+    type VARCHAR(32) NOT NULL,
+    data TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+COMMENT ON TABLE pipeline_signals IS 'data pipeline 訊號';
+COMMENT ON COLUMN pipeline_signals.id IS '訊號 ID';
+COMMENT ON COLUMN pipeline_signals.type IS '訊號類型 (execute-snapshot, watermark)';
+COMMENT ON COLUMN pipeline_signals.data IS '訊號內容';
+COMMENT ON COLUMN pipeline_signals.created_at IS '建立日期';
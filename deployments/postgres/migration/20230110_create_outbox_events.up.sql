@@ -0,0 +1,17 @@
+DROP TABLE IF EXISTS outbox_events;
+CREATE TABLE outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    aggregate_type VARCHAR(255) NOT NULL,
+    aggregate_id VARCHAR(255) NOT NULL,
+    operation VARCHAR(32) NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+COMMENT ON TABLE outbox_events IS 'data pipeline transactional outbox';
+COMMENT ON COLUMN outbox_events.id IS '事件 ID, 依寫入順序遞增';
+COMMENT ON COLUMN outbox_events.aggregate_type IS 'aggregate 類型, 對應下游的資料表';
+COMMENT ON COLUMN outbox_events.aggregate_id IS 'aggregate ID, 對應下游的主鍵';
+COMMENT ON COLUMN outbox_events.operation IS '異動類型 (insert, update, delete)';
+COMMENT ON COLUMN outbox_events.payload IS 'aggregate 異動後的內容 (JSON)';
+COMMENT ON COLUMN outbox_events.created_at IS '建立日期';
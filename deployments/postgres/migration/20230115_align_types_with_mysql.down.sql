@@ -0,0 +1,21 @@
+ALTER SEQUENCE logs_id_seq AS INTEGER;
+ALTER TABLE logs
+    ALTER COLUMN id TYPE INTEGER,
+    ALTER COLUMN deposit_user_id TYPE INTEGER,
+    ALTER COLUMN withdraw_user_id TYPE INTEGER,
+    ALTER COLUMN amount TYPE INTEGER,
+    ALTER COLUMN created_at TYPE DATE;
+
+ALTER SEQUENCE wallets_id_seq AS INTEGER;
+ALTER TABLE wallets
+    ALTER COLUMN id TYPE INTEGER,
+    ALTER COLUMN user_id TYPE INTEGER,
+    ALTER COLUMN amount TYPE INTEGER,
+    ALTER COLUMN created_at TYPE DATE,
+    ALTER COLUMN modified_at TYPE DATE;
+
+ALTER SEQUENCE users_id_seq AS INTEGER;
+ALTER TABLE users
+    ALTER COLUMN id TYPE INTEGER,
+    ALTER COLUMN created_at TYPE DATE,
+    ALTER COLUMN modified_at TYPE DATE;
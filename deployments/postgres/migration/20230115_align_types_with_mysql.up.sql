@@ -0,0 +1,22 @@
+-- MySQL 的 int(11) unsigned 上限超過 INTEGER, 以 BIGINT 保存; datetime 需要保留時間, 以 TIMESTAMP 取代 DATE
+ALTER TABLE users
+    ALTER COLUMN id TYPE BIGINT,
+    ALTER COLUMN created_at TYPE TIMESTAMP,
+    ALTER COLUMN modified_at TYPE TIMESTAMP;
+ALTER SEQUENCE users_id_seq AS BIGINT;
+
+ALTER TABLE wallets
+    ALTER COLUMN id TYPE BIGINT,
+    ALTER COLUMN user_id TYPE BIGINT,
+    ALTER COLUMN amount TYPE BIGINT,
+    ALTER COLUMN created_at TYPE TIMESTAMP,
+    ALTER COLUMN modified_at TYPE TIMESTAMP;
+ALTER SEQUENCE wallets_id_seq AS BIGINT;
+
+ALTER TABLE logs
+    ALTER COLUMN id TYPE BIGINT,
+    ALTER COLUMN deposit_user_id TYPE BIGINT,
+    ALTER COLUMN withdraw_user_id TYPE BIGINT,
+    ALTER COLUMN amount TYPE BIGINT,
+    ALTER COLUMN created_at TYPE TIMESTAMP;
+ALTER SEQUENCE logs_id_seq AS BIGINT;
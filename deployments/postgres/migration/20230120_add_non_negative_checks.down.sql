@@ -0,0 +1,8 @@
+ALTER TABLE logs
+    DROP CONSTRAINT IF EXISTS logs_amount_check,
+    DROP CONSTRAINT IF EXISTS logs_withdraw_user_id_check,
+    DROP CONSTRAINT IF EXISTS logs_deposit_user_id_check;
+
+ALTER TABLE wallets
+    DROP CONSTRAINT IF EXISTS wallets_amount_check,
+    DROP CONSTRAINT IF EXISTS wallets_user_id_check;
@@ -0,0 +1,9 @@
+-- MySQL 以 unsigned 限制非負, PostgreSQL 沒有 unsigned 整數, 以 CHECK 限制並與 internal/ddl 的定義對齊
+ALTER TABLE wallets
+    ADD CONSTRAINT wallets_user_id_check CHECK (user_id >= 0),
+    ADD CONSTRAINT wallets_amount_check CHECK (amount >= 0);
+
+ALTER TABLE logs
+    ADD CONSTRAINT logs_deposit_user_id_check CHECK (deposit_user_id >= 0),
+    ADD CONSTRAINT logs_withdraw_user_id_check CHECK (withdraw_user_id >= 0),
+    ADD CONSTRAINT logs_amount_check CHECK (amount >= 0);
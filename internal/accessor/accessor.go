@@ -2,8 +2,20 @@ package accessor
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"path/filepath"
 	"practice/internal/config"
+	"practice/internal/pipeline"
+	"practice/internal/pipeline/dlq"
+	"practice/internal/pipeline/schema"
+	"practice/internal/pipeline/sink"
+	"practice/internal/pipeline/source"
+	"practice/internal/pipeline/transform"
+	"practice/internal/storage/dialect"
 	"practice/internal/storage/rdb"
+	"practice/internal/storage/router"
+	"strconv"
 	"sync"
 	"time"
 
@@ -16,8 +28,17 @@ type accessor struct {
 	shutdownOnce     sync.Once
 	shutdownHandlers []shutdownHandler
 
-	Config *config.Config // configuration management
-	RDB    rdb.Rdb        // relational database instance
+	Config      *config.Config       // configuration management
+	Pipeline    *config.PipelineOpts // configuration of the selected pipeline
+	RDB         rdb.Rdb              // relational database instance
+	MySQL       rdb.Rdb              // mysql instance held alongside postgres, see InitRDBs
+	Postgres    rdb.Rdb              // postgres instance held alongside mysql, see InitRDBs
+	Replicas    []rdb.Rdb            // read-only replicas of rdb, see InitRouter
+	Router      *router.Router       // read/write splitting between RDB and Replicas, see InitRouter
+	Source      source.Source        // change event source of the pipeline
+	Schema      *schema.Registry     // schema registry of the captured tables
+	Sinks       []sink.Sink          // change event sinks of the pipeline
+	DeadLetters dlq.Store            // dead-letter store of the pipeline, nil when disabled
 }
 
 func BuildAccessor() *accessor {
@@ -37,34 +58,357 @@ func (a *accessor) Close(ctx context.Context) {
 	logrus.Infoln("all accessors closed.")
 }
 
+// SelectPipeline 選擇 pipelines 中的 pipeline, 必須在其他 pipeline 相關的 Init 之前呼叫
+func (a *accessor) SelectPipeline(name string) error {
+	opts, err := a.Config.FindPipeline(name)
+	if err != nil {
+		return err
+	}
+
+	a.Pipeline = opts
+	return nil
+}
+
 func (a *accessor) InitRDB(ctx context.Context) {
-	switch a.Config.RDB.Driver {
-	case "mysql":
-		a.RDB = rdb.NewMysqlClient(ctx,
+	a.RDB = newRdb(ctx, a.Config.RDB)
+
+	a.shutdownHandlers = append(a.shutdownHandlers, func(c context.Context) {
+		a.RDB.Shutdown(c)
+		logrus.Infoln("relational database accessor closed.")
+	})
+
+	logrus.Infoln("initial relational database accessor successful.")
+}
+
+// InitRDBs 同時建立 rdb 區段中 MySQL 與 PostgreSQL 的連線, 不受 rdb.driver 限制, 供跨資料庫的工作使用
+func (a *accessor) InitRDBs(ctx context.Context) {
+	mysqlOpts := a.Config.RDB
+	mysqlOpts.Driver = "mysql"
+	a.MySQL = newRdb(ctx, mysqlOpts)
+
+	a.shutdownHandlers = append(a.shutdownHandlers, func(c context.Context) {
+		a.MySQL.Shutdown(c)
+		logrus.Infoln("mysql accessor closed.")
+	})
+
+	postgresOpts := a.Config.RDB
+	postgresOpts.Driver = "postgresql"
+	a.Postgres = newRdb(ctx, postgresOpts)
+
+	a.shutdownHandlers = append(a.shutdownHandlers, func(c context.Context) {
+		a.Postgres.Shutdown(c)
+		logrus.Infoln("postgres accessor closed.")
+	})
+
+	logrus.Infoln("initial mysql and postgres accessors successful.")
+}
+
+// OpenRDB 建立 rdb 區段以外的連線 (e.g. replicas, pipeline 的 rdb sink 目標), 連線在 Close 時關閉
+func (a *accessor) OpenRDB(ctx context.Context, name string, opts config.RdbOpts) rdb.Rdb {
+	r := newRdb(ctx, opts)
+
+	a.shutdownHandlers = append(a.shutdownHandlers, func(c context.Context) {
+		r.Shutdown(c)
+		logrus.Infof("%v accessor closed.", name)
+	})
+
+	logrus.Infof("initial %v accessor successful.", name)
+	return r
+}
+
+// InitRouter 建立 rdb.replicas 的連線與讀寫分離的 router, 寫入與交易送往 rdb 區段的 primary
+func (a *accessor) InitRouter(ctx context.Context) {
+	if a.RDB == nil {
+		a.InitRDB(ctx)
+	}
+
+	d, err := dialect.New(a.Config.RDB.Driver)
+	if err != nil {
+		logrus.Panicf("failed to create router: %v", err)
+	}
+
+	replicas := []*router.Replica{}
+	for _, replicaOpts := range a.Config.RDB.Replicas {
+		opts, err := a.Config.RDB.Replica(replicaOpts)
+		if err != nil {
+			logrus.Panicf("failed to create router: %v", err)
+		}
+
+		replica := newRdb(ctx, opts)
+		a.Replicas = append(a.Replicas, replica)
+		replicas = append(replicas, router.NewReplica(replicaOpts.Name, replica.DB()))
+
+		name := replicaOpts.Name
+		a.shutdownHandlers = append(a.shutdownHandlers, func(c context.Context) {
+			replica.Shutdown(c)
+			logrus.Infof("replica %v accessor closed.", name)
+		})
+	}
+
+	routing := a.Config.RDB.Routing
+	a.Router, err = router.NewRouter(a.RDB.DB(), d, replicas, router.Options{
+		Policy:         routing.Policy,
+		MaxLag:         time.Duration(routing.MaxLag) * time.Millisecond,
+		HealthInterval: time.Duration(routing.HealthInterval) * time.Millisecond,
+		HealthTimeout:  time.Duration(routing.HealthTimeout) * time.Millisecond,
+		WaitTimeout:    time.Duration(routing.WaitTimeout) * time.Millisecond,
+	})
+	if err != nil {
+		logrus.Panicf("failed to create router: %v", err)
+	}
+	a.Router.Start(ctx)
+
+	// 背景的健康檢查必須在關閉 replicas 的連線之前停止
+	a.shutdownHandlers = append([]shutdownHandler{func(c context.Context) {
+		a.Router.Close()
+		logrus.Infoln("router accessor closed.")
+	}}, a.shutdownHandlers...)
+
+	logrus.Infof("initial router with %v replicas successful.", len(replicas))
+}
+
+func (a *accessor) InitSinks(ctx context.Context) {
+	for _, name := range a.Pipeline.Sinks {
+		var s sink.Sink
+
+		switch name {
+		case "stdout":
+			s = sink.NewStdoutSink(ctx)
+		case "file":
+			s = sink.NewFileSink(ctx,
+				a.Pipeline.File.Dir,
+				a.Pipeline.File.Prefix,
+				a.Pipeline.File.Format,
+				a.Pipeline.File.MaxSize,
+				a.Pipeline.File.MaxBackups,
+			)
+		case "rdb":
+			s = sink.NewRdbSink(ctx,
+				a.Pipeline.Rdb.Target.Driver,
+				newRdb(ctx, a.Pipeline.Rdb.Target),
+				a.Pipeline.Rdb.TablePrefix,
+			)
+		case "mongodb":
+			s = sink.NewMongoSink(ctx,
+				a.Pipeline.Mongo.URI,
+				a.Pipeline.Mongo.Database,
+				a.Pipeline.Mongo.Collection,
+			)
+		default:
+			logrus.Panicf("pipeline sink undifined: %v", name)
+		}
+
+		a.Sinks = append(a.Sinks, s)
+
+		sinkName := name
+		a.shutdownHandlers = append(a.shutdownHandlers, func(c context.Context) {
+			s.Shutdown(c)
+			logrus.Infof("%v sink accessor closed.", sinkName)
+		})
+
+		logrus.Infof("initial %v sink accessor successful.", name)
+	}
+}
+
+// InitDeadLetters 依照 pipeline.dead_letter 建立 dead-letter store, store 為 rdb 時使用 rdb 區段的資料庫
+func (a *accessor) InitDeadLetters(ctx context.Context) {
+	opts := a.Pipeline.DeadLetter
+	if opts.Store == "rdb" && a.RDB == nil {
+		a.InitRDB(ctx)
+	}
+
+	var (
+		store dlq.Store
+		err   error
+	)
+	switch opts.Store {
+	case "":
+		return
+	case "file":
+		store, err = dlq.NewFileStore(opts.Dir)
+	case "rdb":
+		store, err = dlq.NewRdbStore(ctx, a.Config.RDB.Driver, a.RDB)
+	default:
+		logrus.Panicf("dead-letter store undifined: %v", opts.Store)
+	}
+	if err != nil {
+		logrus.Panicf("failed to create dead-letter store: %v", err)
+	}
+
+	a.DeadLetters = store
+
+	a.shutdownHandlers = append(a.shutdownHandlers, func(c context.Context) {
+		a.DeadLetters.Shutdown(c)
+		logrus.Infoln("dead-letter store accessor closed.")
+	})
+
+	logrus.Infof("initial %v dead-letter store accessor successful.", opts.Store)
+}
+
+// InitSource 依照 pipeline.source 建立 change event source, 必須在 InitRDB 之後呼叫
+func (a *accessor) InitSource(ctx context.Context) {
+	a.Source = a.BuildSource(ctx)
+
+	a.shutdownHandlers = append(a.shutdownHandlers, func(c context.Context) {
+		a.Source.Shutdown(c)
+		logrus.Infoln("change event source accessor closed.")
+	})
+
+	logrus.Infoln("initial change event source accessor successful.")
+}
+
+// BuildSource 建立新的 change event source 但不註冊 shutdown handler, 由呼叫端自行關閉
+func (a *accessor) BuildSource(ctx context.Context) source.Source {
+	opts := a.Pipeline.Source
+
+	if a.Schema == nil {
+		registry, err := schema.NewRegistry(filepath.Join(a.Pipeline.CheckpointDir, a.Pipeline.Name+".schema.json"))
+		if err != nil {
+			logrus.Panicf("failed to load schema registry: %v", err)
+		}
+		a.Schema = registry
+	}
+
+	// 增量快照的 watermark 寫在 signal table, 必須一併擷取
+	tables := opts.Tables
+	if a.Pipeline.Snapshot.Mode == "incremental" && len(tables) > 0 {
+		tables = append(append([]string{}, tables...), pipeline.SignalTable)
+	}
+
+	switch opts.Type {
+	case "binlog":
+		if a.Config.RDB.Driver != "mysql" {
+			logrus.Panicf("binlog source requires mysql driver, got: %v", a.Config.RDB.Driver)
+		}
+
+		host, port, err := net.SplitHostPort(a.Config.RDB.MysqlOpts.Address)
+		if err != nil {
+			logrus.Panicf("failed to parse mysql address: %v", err)
+		}
+		portNum, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			logrus.Panicf("failed to parse mysql port: %v", err)
+		}
+
+		return source.NewBinlogSource(ctx,
+			a.RDB.DB(),
+			host,
+			uint16(portNum),
 			a.Config.RDB.MysqlOpts.UserName,
 			a.Config.RDB.MysqlOpts.Password,
-			a.Config.RDB.MysqlOpts.Address,
 			a.Config.RDB.MysqlOpts.DBName,
-			time.Duration(a.Config.RDB.MysqlOpts.ConnMaxLifetime)*time.Minute,
-			a.Config.RDB.MysqlOpts.MaxOpenConns,
-			a.Config.RDB.MysqlOpts.MaxIdleConns,
+			opts.ServerID,
+			tables,
+			a.Schema,
 		)
-	case "postgresql":
-		a.RDB = rdb.NewPostgresClient(ctx,
-			a.Config.RDB.PostgresOpts.Host,
-			a.Config.RDB.PostgresOpts.Port,
-			a.Config.RDB.PostgresOpts.User,
-			a.Config.RDB.PostgresOpts.Password,
-			a.Config.RDB.PostgresOpts.DBName,
+	case "polling":
+		return source.NewPollingSource(ctx,
+			a.RDB.DB(),
+			a.Config.RDB.Driver,
+			a.databaseName(),
+			tables,
+			opts.Column,
+			opts.BatchSize,
+			time.Duration(opts.Interval)*time.Millisecond,
+			a.Schema,
+		)
+	case "outbox":
+		return source.NewOutboxSource(ctx,
+			a.RDB.DB(),
+			a.Config.RDB.Driver,
+			a.databaseName(),
+			opts.Outbox,
+			opts.BatchSize,
+			time.Duration(opts.Interval)*time.Millisecond,
+		)
+	case "logical":
+		if a.Config.RDB.Driver != "postgresql" {
+			logrus.Panicf("logical source requires postgresql driver, got: %v", a.Config.RDB.Driver)
+		}
+
+		pg := a.Config.RDB.PostgresOpts
+		return source.NewLogicalSource(ctx,
+			a.RDB.DB(),
+			fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable replication=database",
+				pg.Host, pg.Port, pg.User, pg.Password, pg.DBName),
+			pg.DBName,
+			opts.Slot,
+			tables,
+			a.Schema,
 		)
 	default:
-		logrus.Panicf("RDB driver undifined: %v", a.Config.RDB.Driver)
+		logrus.Panicf("pipeline source undifined: %v", opts.Type)
 	}
 
-	a.shutdownHandlers = append(a.shutdownHandlers, func(c context.Context) {
-		a.RDB.Shutdown(c)
-		logrus.Infoln("relational database accessor closed.")
-	})
+	return nil
+}
 
-	logrus.Infoln("initial relational database accessor successful.")
+// BuildTransforms 依照設定建立依序套用的轉換, 不需要連線至任何資料庫
+func (a *accessor) BuildTransforms(opts []config.TransformOpts) (transform.Chain, error) {
+	chain := transform.Chain{}
+	for i, o := range opts {
+		var (
+			t   transform.Transform
+			err error
+		)
+
+		switch o.Type {
+		case "drop":
+			t, err = transform.NewDrop(o.Columns)
+		case "rename":
+			t, err = transform.NewRename(o.Mapping)
+		case "cast":
+			t, err = transform.NewCast(o.Mapping)
+		case "filter":
+			t, err = transform.NewFilter(o.Expr)
+		case "hash":
+			t, err = transform.NewHash(o.Columns, o.HashSecret())
+		case "mask":
+			t, err = transform.NewMask(o.Columns, o.Keep)
+		default:
+			err = fmt.Errorf("pipeline transform undifined: %v", o.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("transforms[%v]: %w", i, err)
+		}
+
+		chain = append(chain, transform.ForTables(t, o.Tables))
+	}
+
+	return chain, nil
+}
+
+// databaseName 回傳 rdb 區段設定的資料庫名稱
+func (a *accessor) databaseName() string {
+	if a.Config.RDB.Driver == "mysql" {
+		return a.Config.RDB.MysqlOpts.DBName
+	}
+	return a.Config.RDB.PostgresOpts.DBName
+}
+
+func newRdb(ctx context.Context, opts config.RdbOpts) rdb.Rdb {
+	switch opts.Driver {
+	case "mysql":
+		return rdb.NewMysqlClient(ctx,
+			opts.MysqlOpts.UserName,
+			opts.MysqlOpts.Password,
+			opts.MysqlOpts.Address,
+			opts.MysqlOpts.DBName,
+			time.Duration(opts.MysqlOpts.ConnMaxLifetime)*time.Minute,
+			opts.MysqlOpts.MaxOpenConns,
+			opts.MysqlOpts.MaxIdleConns,
+		)
+	case "postgresql":
+		return rdb.NewPostgresClient(ctx,
+			opts.PostgresOpts.Host,
+			opts.PostgresOpts.Port,
+			opts.PostgresOpts.User,
+			opts.PostgresOpts.Password,
+			opts.PostgresOpts.DBName,
+		)
+	default:
+		logrus.Panicf("RDB driver undifined: %v", opts.Driver)
+	}
+
+	return nil
 }
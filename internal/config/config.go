@@ -8,7 +8,8 @@ import (
 var cfg *Config
 
 type Config struct {
-	RDB RdbOpts `mapstructure:"rdb"`
+	RDB       RdbOpts        `mapstructure:"rdb"`
+	Pipelines []PipelineOpts `mapstructure:"pipelines"`
 }
 
 func NewFromViper() *Config {
@@ -40,10 +41,56 @@ func NewFromDefault() *Config {
 			MaxOpenConns:    100,
 			ConnMaxLifetime: 60,
 		},
+		Routing: RoutingOpts{
+			Policy:         "round_robin",
+			MaxLag:         5000,
+			HealthInterval: 1000,
+			HealthTimeout:  500,
+			WaitTimeout:    1000,
+		},
+	}
+
+	pipeline := PipelineOpts{
+		Name: "default",
+		Source: SourceOpts{
+			Type:      "binlog",
+			ServerID:  1001,
+			Slot:      "data_pipeline",
+			Tables:    []string{"users", "wallets", "logs"},
+			Column:    "modified_at",
+			Outbox:    "outbox_events",
+			BatchSize: 1000,
+			Interval:  1000,
+		},
+		Snapshot: SnapshotOpts{
+			Mode:      "initial",
+			ChunkSize: 1000,
+		},
+		// users.password 為明文密碼, users.email 為個人資料, 不可以明文寫入下游
+		Transforms: []TransformOpts{
+			{Type: "hash", Tables: []string{"users"}, Columns: []string{"password"}, SecretEnv: "PIPELINE_HASH_SECRET"},
+			{Type: "mask", Tables: []string{"users"}, Columns: []string{"email"}, Keep: 1},
+		},
+		CheckpointDir: "./deployments/data/pipeline/checkpoints",
+		DeadLetter: DeadLetterOpts{
+			Store:      "file",
+			Dir:        "./deployments/data/pipeline/dead_letters",
+			MaxRetries: 3,
+			Backoff:    200,
+		},
+		Sinks: []string{"stdout"},
+		File: FileSinkOpts{
+			Dir:        "./deployments/data/pipeline",
+			Prefix:     "events",
+			Format:     "json",
+			MaxSize:    100,
+			MaxBackups: 10,
+		},
 	}
 
 	cfg = &Config{
-		RDB: rdb,
+		RDB:       rdb,
+		Pipelines: []PipelineOpts{pipeline},
 	}
 
 	return cfg
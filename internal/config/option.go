@@ -1,9 +1,24 @@
 package config
 
 type RdbOpts struct {
-	Driver       string       `mapstructure:"driver"`     //
-	MysqlOpts    MysqlOpts    `mapstructure:"mysql"`      //
-	PostgresOpts PostgresOpts `mapstructure:"postgresql"` //
+	Driver       string        `mapstructure:"driver"`     //
+	MysqlOpts    MysqlOpts     `mapstructure:"mysql"`      // primary, 寫入與交易皆送往此處
+	PostgresOpts PostgresOpts  `mapstructure:"postgresql"` // primary, 寫入與交易皆送往此處
+	Replicas     []ReplicaOpts `mapstructure:"replicas"`   // 唯讀 replicas, 帳號密碼與資料庫沿用 driver 對應的 primary 設定
+	Routing      RoutingOpts   `mapstructure:"routing"`    //
+}
+
+type ReplicaOpts struct {
+	Name    string `mapstructure:"name"`    // 日誌與狀態中顯示的名稱
+	Address string `mapstructure:"address"` // host:port
+}
+
+type RoutingOpts struct {
+	Policy         string `mapstructure:"policy"`          // round_robin, least_lag or read_your_writes
+	MaxLag         int    `mapstructure:"max_lag"`         // 延遲超過時暫時移出讀取名單, 0 表示不因延遲移出 (ms)
+	HealthInterval int    `mapstructure:"health_interval"` // replica 健康檢查與延遲量測的間隔 (ms)
+	HealthTimeout  int    `mapstructure:"health_timeout"`  // 單一 replica 健康檢查的逾時, 逾時視為無法讀取 (ms)
+	WaitTimeout    int    `mapstructure:"wait_timeout"`    // read_your_writes 等待 replica 追上寫入的上限, 逾時改讀 primary (ms)
 }
 
 type MysqlOpts struct {
@@ -23,3 +38,69 @@ type PostgresOpts struct {
 	Password string `mapstructure:"password"` //
 	DBName   string `mapstructure:"dbname"`   //
 }
+
+type PipelineOpts struct {
+	Name          string          `mapstructure:"name"`           // pipeline 名稱, 同時作為消費進度的紀錄名稱
+	Source        SourceOpts      `mapstructure:"source"`         //
+	Snapshot      SnapshotOpts    `mapstructure:"snapshot"`       //
+	Transforms    []TransformOpts `mapstructure:"transforms"`     // 寫入 sink 之前依序套用的轉換
+	CheckpointDir string          `mapstructure:"checkpoint_dir"` // 非交易型 sink 的消費進度保存目錄
+	DeadLetter    DeadLetterOpts  `mapstructure:"dead_letter"`    //
+	Sinks         []string        `mapstructure:"sinks"`          // 啟用的 sinks (stdout, file, rdb, mongodb)
+	File          FileSinkOpts    `mapstructure:"file"`           //
+	Rdb           RdbSinkOpts     `mapstructure:"rdb"`            //
+	Mongo         MongoSinkOpts   `mapstructure:"mongodb"`        //
+}
+
+type SourceOpts struct {
+	Type      string   `mapstructure:"type"`       // binlog (mysql), logical (postgresql), polling or outbox, 連線設定沿用 rdb 區段
+	ServerID  uint32   `mapstructure:"server_id"`  // 以 replica 身份讀取 binlog 時使用的 server id
+	Slot      string   `mapstructure:"slot"`       // logical replication slot 名稱
+	Tables    []string `mapstructure:"tables"`     // 擷取的資料表, 為空時擷取所有資料表
+	Column    string   `mapstructure:"column"`     // polling 的追蹤欄位, 資料表沒有此欄位時只以主鍵追蹤新增
+	Outbox    string   `mapstructure:"outbox"`     // outbox 資料表名稱
+	BatchSize int      `mapstructure:"batch_size"` // polling 與 outbox 每次讀取的資料列數量
+	Interval  int      `mapstructure:"interval"`   // polling 與 outbox 沒有新資料時的輪詢間隔 (ms)
+}
+
+type TransformOpts struct {
+	Type      string            `mapstructure:"type"`       // drop, rename, cast, filter, hash or mask
+	Tables    []string          `mapstructure:"tables"`     // 套用的資料表, 為空時套用至所有資料表
+	Columns   []string          `mapstructure:"columns"`    // drop, hash 與 mask 處理的欄位
+	Mapping   map[string]string `mapstructure:"mapping"`    // rename: 原欄位 -> 新欄位, cast: 欄位 -> 型別 (string, int, float, bool)
+	Expr      string            `mapstructure:"expr"`       // filter: 只保留符合條件的異動, e.g. amount >= 1000 && $operation != "delete"
+	Secret    string            `mapstructure:"secret"`     // hash: HMAC-SHA256 金鑰
+	SecretEnv string            `mapstructure:"secret_env"` // hash: 保存 HMAC-SHA256 金鑰的環境變數, 金鑰不需要寫入設定檔
+	Keep      int               `mapstructure:"keep"`       // mask: 保留開頭的字元數
+}
+
+type SnapshotOpts struct {
+	Mode      string `mapstructure:"mode"`       // initial: 一致性快照後再串流, incremental: 以 watermark 分段快照並同時串流, never: 直接串流
+	ChunkSize int    `mapstructure:"chunk_size"` // 快照時每次讀取的資料列數量
+}
+
+type DeadLetterOpts struct {
+	Store      string `mapstructure:"store"`       // file: 每筆一個 JSON 檔案, rdb: 寫入來源資料庫的 pipeline_dead_letters 資料表, 為空時不啟用
+	Dir        string `mapstructure:"dir"`         // store 為 file 時的保存目錄
+	MaxRetries int    `mapstructure:"max_retries"` // sink 寫入失敗時的重試次數
+	Backoff    int    `mapstructure:"backoff"`     // 第一次重試前的等待時間, 之後每次加倍 (ms)
+}
+
+type FileSinkOpts struct {
+	Dir        string `mapstructure:"dir"`         // 輸出目錄
+	Prefix     string `mapstructure:"prefix"`      // 檔名前綴
+	Format     string `mapstructure:"format"`      // change event 編碼格式 (json or protobuf)
+	MaxSize    int    `mapstructure:"max_size"`    // 單一檔案大小上限 (MB), 超過時切換新檔案
+	MaxBackups int    `mapstructure:"max_backups"` // 最多保留的檔案數量, 0 表示全部保留
+}
+
+type RdbSinkOpts struct {
+	Target      RdbOpts `mapstructure:"target"`       // 目標資料庫連線設定
+	TablePrefix string  `mapstructure:"table_prefix"` // 寫入目標資料表時附加的前綴
+}
+
+type MongoSinkOpts struct {
+	URI        string `mapstructure:"uri"`        //
+	Database   string `mapstructure:"database"`   //
+	Collection string `mapstructure:"collection"` // 為空時以來源資料表名稱作為 collection 名稱
+}
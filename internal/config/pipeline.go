@@ -0,0 +1,172 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FindPipeline 依名稱取得 pipelines 中的 pipeline 設定
+func (c *Config) FindPipeline(name string) (*PipelineOpts, error) {
+	for i := range c.Pipelines {
+		if c.Pipelines[i].Name == name {
+			return &c.Pipelines[i], nil
+		}
+	}
+
+	names := make([]string, len(c.Pipelines))
+	for i, p := range c.Pipelines {
+		names[i] = p.Name
+	}
+	return nil, fmt.Errorf("pipeline %q not found in configuration, defined pipelines: [%v]", name, strings.Join(names, ", "))
+}
+
+// HashSecret 取得 hash 轉換的金鑰, 設定 secret_env 時由環境變數讀取
+func (t TransformOpts) HashSecret() string {
+	if t.SecretEnv != "" {
+		return os.Getenv(t.SecretEnv)
+	}
+	return t.Secret
+}
+
+// ValidatePipelines 檢查 pipelines 的設定, 不連線至任何資料庫
+// 回傳每個 pipeline 的問題, 沒有問題的 pipeline 不會出現在結果中
+func (c *Config) ValidatePipelines() map[string][]string {
+	problems := map[string][]string{}
+	report := func(name, format string, args ...interface{}) {
+		problems[name] = append(problems[name], fmt.Sprintf(format, args...))
+	}
+
+	if len(c.Pipelines) == 0 {
+		report("", "no pipeline defined")
+	}
+
+	names := map[string]bool{}
+	serverIDs := map[uint32]string{}
+	slots := map[string]string{}
+	for _, p := range c.Pipelines {
+		name := p.Name
+		if name == "" {
+			report(name, "name is required")
+		} else if names[name] {
+			report(name, "name is used by another pipeline")
+		}
+		names[name] = true
+
+		switch p.Source.Type {
+		case "binlog":
+			if c.RDB.Driver != "mysql" {
+				report(name, "binlog source requires rdb.driver mysql, got %q", c.RDB.Driver)
+			}
+			if p.Source.ServerID == 0 {
+				report(name, "source.server_id is required by binlog source")
+			} else if other, ok := serverIDs[p.Source.ServerID]; ok {
+				report(name, "source.server_id %v is used by pipeline %v", p.Source.ServerID, other)
+			}
+			serverIDs[p.Source.ServerID] = name
+		case "logical":
+			if c.RDB.Driver != "postgresql" {
+				report(name, "logical source requires rdb.driver postgresql, got %q", c.RDB.Driver)
+			}
+			if p.Source.Slot == "" {
+				report(name, "source.slot is required by logical source")
+			} else if other, ok := slots[p.Source.Slot]; ok {
+				report(name, "source.slot %v is used by pipeline %v", p.Source.Slot, other)
+			}
+			slots[p.Source.Slot] = name
+		case "polling", "outbox":
+			if p.Source.BatchSize <= 0 {
+				report(name, "source.batch_size must be positive")
+			}
+			if p.Source.Interval <= 0 {
+				report(name, "source.interval must be positive")
+			}
+			if p.Source.Type == "outbox" && p.Source.Outbox == "" {
+				report(name, "source.outbox is required by outbox source")
+			}
+		default:
+			report(name, "source.type %q undefined, expected binlog, logical, polling or outbox", p.Source.Type)
+		}
+
+		switch p.Snapshot.Mode {
+		case "never":
+		case "initial", "incremental":
+			if p.Snapshot.ChunkSize <= 0 {
+				report(name, "snapshot.chunk_size must be positive")
+			}
+			if p.Snapshot.Mode == "incremental" && p.Source.Type != "binlog" && p.Source.Type != "logical" {
+				report(name, "incremental snapshot requires binlog or logical source")
+			}
+		default:
+			report(name, "snapshot.mode %q undefined, expected initial, incremental or never", p.Snapshot.Mode)
+		}
+
+		for i, t := range p.Transforms {
+			if t.Type == "" {
+				report(name, "transforms[%v].type is required", i)
+			}
+			if t.Type != "hash" {
+				continue
+			}
+			switch {
+			case t.Secret != "" && t.SecretEnv != "":
+				report(name, "transforms[%v].secret and transforms[%v].secret_env must not be both set", i, i)
+			case t.SecretEnv != "" && t.HashSecret() == "":
+				report(name, "transforms[%v].secret_env %v is not set in the environment", i, t.SecretEnv)
+			case t.HashSecret() == "":
+				report(name, "transforms[%v].secret_env is required by hash transform", i)
+			}
+		}
+
+		if p.CheckpointDir == "" {
+			report(name, "checkpoint_dir is required")
+		}
+
+		switch p.DeadLetter.Store {
+		case "", "rdb":
+		case "file":
+			if p.DeadLetter.Dir == "" {
+				report(name, "dead_letter.dir is required by file store")
+			}
+		default:
+			report(name, "dead_letter.store %q undefined, expected file, rdb or empty", p.DeadLetter.Store)
+		}
+		if p.DeadLetter.MaxRetries < 0 || p.DeadLetter.Backoff < 0 {
+			report(name, "dead_letter.max_retries and dead_letter.backoff must not be negative")
+		}
+
+		if len(p.Sinks) == 0 {
+			report(name, "sinks must not be empty")
+		}
+		enabled := map[string]bool{}
+		for _, s := range p.Sinks {
+			if enabled[s] {
+				report(name, "sink %v is enabled twice", s)
+			}
+			enabled[s] = true
+
+			switch s {
+			case "stdout":
+			case "file":
+				if p.File.Dir == "" {
+					report(name, "file.dir is required by file sink")
+				}
+				if p.File.Format != "" && p.File.Format != "json" && p.File.Format != "protobuf" {
+					report(name, "file.format %q undefined, expected json or protobuf", p.File.Format)
+				}
+			case "rdb":
+				if p.Rdb.Target.Driver != "mysql" && p.Rdb.Target.Driver != "postgresql" {
+					report(name, "rdb.target.driver %q undefined, expected mysql or postgresql", p.Rdb.Target.Driver)
+				}
+			case "mongodb":
+				if p.Mongo.URI == "" || p.Mongo.Database == "" {
+					report(name, "mongodb.uri and mongodb.database are required by mongodb sink")
+				}
+			default:
+				report(name, "sink %q undefined, expected stdout, file, rdb or mongodb", s)
+			}
+		}
+	}
+
+	return problems
+}
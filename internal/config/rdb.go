@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Replica 回傳連線至 replica 的 rdb 設定, 只替換 driver 對應區段的位址, replicas 清空避免再次展開
+func (o RdbOpts) Replica(replica ReplicaOpts) (RdbOpts, error) {
+	opts := o
+	opts.Replicas = nil
+
+	switch o.Driver {
+	case "mysql":
+		opts.MysqlOpts.Address = replica.Address
+	case "postgresql":
+		host, port, err := net.SplitHostPort(replica.Address)
+		if err != nil {
+			return opts, fmt.Errorf("replica %v: %w", replica.Name, err)
+		}
+		opts.PostgresOpts.Host = host
+		if opts.PostgresOpts.Port, err = strconv.Atoi(port); err != nil {
+			return opts, fmt.Errorf("replica %v: invalid port %v", replica.Name, port)
+		}
+	default:
+		return opts, fmt.Errorf("RDB driver undifined: %v", o.Driver)
+	}
+	return opts, nil
+}
+
+// Endpoint 依名稱取得跨資料庫比對的其中一端, prefix 為資料表名稱的前綴
+// mysql 與 postgresql 為 rdb 區段的 primary, replica:<name> 為 rdb.replicas, pipeline:<name> 為 pipeline 的 rdb sink 目標
+func (c *Config) Endpoint(name string) (opts RdbOpts, prefix string, err error) {
+	kind, value, _ := strings.Cut(name, ":")
+	switch kind {
+	case "mysql", "postgresql":
+		opts = c.RDB
+		opts.Driver = kind
+		opts.Replicas = nil
+		return opts, "", nil
+	case "replica":
+		for _, replica := range c.RDB.Replicas {
+			if replica.Name == value {
+				opts, err = c.RDB.Replica(replica)
+				return opts, "", err
+			}
+		}
+		return opts, "", fmt.Errorf("replica %q not found in rdb.replicas", value)
+	case "pipeline":
+		p, err := c.FindPipeline(value)
+		if err != nil {
+			return opts, "", err
+		}
+		if p.Rdb.Target.Driver == "" {
+			return opts, "", fmt.Errorf("pipeline %v has no rdb sink target", value)
+		}
+		return p.Rdb.Target, p.Rdb.TablePrefix, nil
+	}
+	return opts, "", fmt.Errorf("endpoint undifined: %v, expected mysql, postgresql, replica:<name> or pipeline:<name>", name)
+}
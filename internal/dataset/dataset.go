@@ -0,0 +1,196 @@
+package dataset
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"practice/internal/ddl"
+	"practice/internal/storage/dialect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind 為欄位內容在檔案中的型別
+type Kind int
+
+const (
+	String Kind = iota // 文字, 也用來保存 numeric/decimal 等不可失真的數字
+	Integer
+	Float
+	Bool
+	Time
+)
+
+// TimeLayout 為 CSV 中時間欄位的格式, 兩種資料庫皆可直接解析
+const TimeLayout = "2006-01-02 15:04:05.999999"
+
+// Column 為匯出或匯入的欄位
+type Column struct {
+	Name     string
+	Type     string // 資料庫中的型別, e.g. int(11) unsigned, character varying(255)
+	Kind     Kind
+	Nullable bool
+}
+
+// Table 為匯出或匯入的資料表, 以單一整數主鍵分段讀取
+type Table struct {
+	Name       string
+	Columns    []*Column
+	PrimaryKey *Column
+}
+
+// Column 以名稱取得欄位
+func (t *Table) Column(name string) (*Column, bool) {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return nil, false
+}
+
+// Select 依照 names 的順序回傳欄位, names 為空時回傳所有欄位
+func (t *Table) Select(names []string) ([]*Column, error) {
+	if len(names) == 0 {
+		return t.Columns, nil
+	}
+
+	columns := make([]*Column, 0, len(names))
+	for _, name := range names {
+		column, ok := t.Column(name)
+		if !ok {
+			return nil, fmt.Errorf("column %v of %v undifined", name, t.Name)
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// LoadTable 由 ddl.Inspect 讀取連線中資料表的欄位與主鍵
+func LoadTable(ctx context.Context, db *sql.DB, d dialect.Dialect, name string) (*Table, error) {
+	var namespace string
+	if err := db.QueryRowContext(ctx, "SELECT "+d.CurrentSchema()).Scan(&namespace); err != nil {
+		return nil, fmt.Errorf("failed to query the current schema: %w", err)
+	}
+
+	live, err := ddl.Inspect(ctx, db, d.Driver(), namespace)
+	if err != nil {
+		return nil, err
+	}
+	liveTable, ok := live.Table(name)
+	if !ok {
+		return nil, fmt.Errorf("table %v not found in %v", name, namespace)
+	}
+
+	t := &Table{Name: name}
+	for _, column := range liveTable.Columns {
+		t.Columns = append(t.Columns, &Column{
+			Name:     column.Name,
+			Type:     column.Type,
+			Kind:     kindOf(column.Type),
+			Nullable: column.Nullable,
+		})
+	}
+
+	key := liveTable.PrimaryKey()
+	if len(key) == 1 {
+		if column, _ := t.Column(key[0]); column.Kind == Integer {
+			t.PrimaryKey = column
+		}
+	}
+	if t.PrimaryKey == nil {
+		return nil, fmt.Errorf("table %v needs a single integer primary key, found %v", name, key)
+	}
+	return t, nil
+}
+
+// kindOf 依照資料庫型別的名稱決定檔案中的型別
+func kindOf(columnType string) Kind {
+	base := strings.ToLower(columnType)
+	if i := strings.IndexAny(base, "( "); i >= 0 {
+		base = base[:i]
+	}
+
+	switch base {
+	case "tinyint", "smallint", "mediumint", "int", "integer", "bigint", "serial", "bigserial", "smallserial":
+		return Integer
+	case "float", "double", "real":
+		return Float
+	case "bool", "boolean":
+		return Bool
+	case "datetime", "timestamp", "date":
+		return Time
+	}
+	return String
+}
+
+// Normalize 將資料庫 driver 讀出的內容轉換成欄位型別對應的 Go 型別
+// MySQL 的 text protocol 以 []byte 回傳所有欄位, 因此依照欄位型別解析
+func Normalize(column *Column, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return Parse(column, string(v))
+	case string:
+		return Parse(column, v)
+	case int64:
+		switch column.Kind {
+		case Bool:
+			return v != 0, nil
+		case Float:
+			return float64(v), nil
+		case String:
+			return strconv.FormatInt(v, 10), nil
+		}
+		return v, nil
+	case uint64:
+		return Normalize(column, int64(v))
+	case float64:
+		if column.Kind == String {
+			return strconv.FormatFloat(v, 'g', -1, 64), nil
+		}
+		return v, nil
+	case float32:
+		return Normalize(column, float64(v))
+	case bool, time.Time:
+		return v, nil
+	}
+	return nil, fmt.Errorf("unexpected %T in column %v", value, column.Name)
+}
+
+// Parse 將文字轉換成欄位型別對應的 Go 型別
+func Parse(column *Column, text string) (interface{}, error) {
+	var value interface{}
+	var err error
+	switch column.Kind {
+	case Integer:
+		value, err = strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+	case Float:
+		value, err = strconv.ParseFloat(strings.TrimSpace(text), 64)
+	case Bool:
+		value, err = strconv.ParseBool(strings.TrimSpace(text))
+	case Time:
+		value, err = parseTime(strings.TrimSpace(text))
+	default:
+		value = text
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("column %v: %w", column.Name, err)
+	}
+	return value, nil
+}
+
+// timeLayouts 為可以解析的時間格式, 依序嘗試
+var timeLayouts = []string{TimeLayout, time.RFC3339Nano, "2006-01-02T15:04:05.999999", "2006-01-02"}
+
+func parseTime(text string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as time", text)
+}
@@ -0,0 +1,296 @@
+package dataset
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"practice/internal/storage/dialect"
+	"strings"
+	"time"
+)
+
+// ExportOptions 為匯出單一資料表的設定
+type ExportOptions struct {
+	Columns     []string // 匯出的欄位, 空白表示所有欄位
+	Where       string   // 附加在 WHERE 的過濾條件, 直接放入 SQL
+	Format      string
+	Compression string
+	ChunkSize   int    // 每次查詢以主鍵範圍讀取的資料列數量, 每次查詢都是獨立的 statement, 不會長時間持有交易
+	MaxFileSize int64  // 檔案超過此大小 (bytes) 時換下一個檔案, 0 表示不分割; Parquet 只在 chunk 之間分割
+	Dir         string // 輸出目錄
+}
+
+// File 為匯出的單一檔案
+type File struct {
+	Path  string
+	Rows  int64
+	Bytes int64
+}
+
+// ExportReport 為匯出單一資料表的結果
+type ExportReport struct {
+	Table   string
+	Rows    int64
+	Chunks  int
+	Files   []*File
+	Elapsed time.Duration
+}
+
+// Exporter 將連線中的資料表依照主鍵順序分段讀出, 寫入 CSV, JSONL 或 Parquet 檔案
+type Exporter struct {
+	db      *sql.DB
+	dialect dialect.Dialect
+	opts    ExportOptions
+}
+
+// NewExporter New Table Exporter
+// @param db       connection of the exported database
+// @param d        dialect of the same database
+// @param opts     columns, filter, format and file splitting
+func NewExporter(db *sql.DB, d dialect.Dialect, opts ExportOptions) (*Exporter, error) {
+	if err := ValidateFormat(opts.Format, opts.Compression); err != nil {
+		return nil, err
+	}
+	if opts.ChunkSize <= 0 {
+		return nil, fmt.Errorf("chunk size must be positive")
+	}
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &Exporter{db: db, dialect: d, opts: opts}, nil
+}
+
+// Export 匯出資料表, 每個 chunk 寫入後呼叫 progress
+func (e *Exporter) Export(ctx context.Context, name string, progress func(*ExportReport)) (*ExportReport, error) {
+	start := time.Now()
+
+	table, err := LoadTable(ctx, e.db, e.dialect, name)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := table.Select(e.opts.Columns)
+	if err != nil {
+		return nil, err
+	}
+
+	// 主鍵沒有被選擇時仍然需要讀出, 用來決定下一個 chunk 的起點
+	read := columns
+	keyIndex := -1
+	for i, column := range columns {
+		if column == table.PrimaryKey {
+			keyIndex = i
+		}
+	}
+	if keyIndex < 0 {
+		read = append(append([]*Column{}, columns...), table.PrimaryKey)
+		keyIndex = len(columns)
+	}
+
+	quoted := make([]string, len(read))
+	for i, column := range read {
+		quoted[i] = e.dialect.Quote(column.Name)
+	}
+	key := e.dialect.Quote(table.PrimaryKey.Name)
+	where := key + " > ?"
+	if e.opts.Where != "" {
+		where = fmt.Sprintf("(%s) AND %s", e.opts.Where, where)
+	}
+	query := e.dialect.Rebind(fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s LIMIT %d",
+		strings.Join(quoted, ", "), e.dialect.Quote(table.Name), where, key, e.opts.ChunkSize))
+
+	out := &splitter{opts: e.opts, table: table.Name, columns: columns}
+	defer out.abort()
+
+	report := &ExportReport{Table: table.Name}
+	var after int64
+	for {
+		rows, last, err := e.chunk(ctx, query, after, read, keyIndex, len(columns), out)
+		if err != nil {
+			return nil, err
+		}
+		if rows == 0 {
+			break
+		}
+
+		if err := out.endChunk(); err != nil {
+			return nil, err
+		}
+		after = last
+		report.Rows += int64(rows)
+		report.Chunks++
+		report.Files = out.files
+		report.Elapsed = time.Since(start)
+		if progress != nil {
+			progress(report)
+		}
+
+		if rows < e.opts.ChunkSize {
+			break
+		}
+	}
+
+	// 沒有任何資料列時仍然產生只有標題或 schema 的檔案
+	if len(out.files) == 0 {
+		if err := out.open(); err != nil {
+			return nil, err
+		}
+	}
+	if err := out.close(); err != nil {
+		return nil, err
+	}
+	report.Files = out.files
+	report.Elapsed = time.Since(start)
+	return report, nil
+}
+
+// chunk 讀取主鍵大於 after 的一段資料列並寫入檔案, 回傳資料列數量與最後一個主鍵
+func (e *Exporter) chunk(ctx context.Context, query string, after int64, read []*Column, keyIndex, width int, out *splitter) (int, int64, error) {
+	rows, err := e.db.QueryContext(ctx, query, after)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read chunk after %v: %w", after, err)
+	}
+	defer rows.Close()
+
+	raw := make([]interface{}, len(read))
+	pointers := make([]interface{}, len(read))
+	for i := range raw {
+		pointers[i] = &raw[i]
+	}
+
+	count := 0
+	var last int64
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return 0, 0, err
+		}
+
+		values := make([]interface{}, len(read))
+		for i, column := range read {
+			if values[i], err = Normalize(column, raw[i]); err != nil {
+				return 0, 0, err
+			}
+		}
+
+		last = values[keyIndex].(int64)
+		if err := out.write(values[:width]); err != nil {
+			return 0, 0, err
+		}
+		count++
+	}
+	return count, last, rows.Err()
+}
+
+// splitter 依照大小將資料列寫入一個或多個檔案
+type splitter struct {
+	opts    ExportOptions
+	table   string
+	columns []*Column
+
+	files   []*File
+	file    *os.File
+	counter *countingWriter
+	encoder encoder
+}
+
+// countingWriter 記錄寫入檔案的 bytes, 壓縮時為壓縮後的大小
+type countingWriter struct {
+	file  *os.File
+	bytes int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (s *splitter) path() string {
+	ext := Extension(s.opts.Format, s.opts.Compression)
+	if s.opts.MaxFileSize <= 0 {
+		return filepath.Join(s.opts.Dir, fmt.Sprintf("%s.%s", s.table, ext))
+	}
+	return filepath.Join(s.opts.Dir, fmt.Sprintf("%s-%05d.%s", s.table, len(s.files)+1, ext))
+}
+
+func (s *splitter) open() error {
+	path := s.path()
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	s.file = file
+	s.counter = &countingWriter{file: file}
+	s.encoder, err = newEncoder(s.opts.Format, s.opts.Compression, s.counter, s.columns)
+	if err != nil {
+		return err
+	}
+	s.files = append(s.files, &File{Path: path})
+	return nil
+}
+
+func (s *splitter) write(values []interface{}) error {
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+
+	if err := s.encoder.write(values); err != nil {
+		return err
+	}
+	s.files[len(s.files)-1].Rows++
+
+	// Parquet 的 row group 在 chunk 結束時才寫入, 因此只在 chunk 之間分割
+	if s.opts.Format != FormatParquet && s.full() {
+		return s.close()
+	}
+	return nil
+}
+
+// endChunk 將 chunk 的資料列寫入檔案, 超過大小時換下一個檔案
+func (s *splitter) endChunk() error {
+	if s.file == nil {
+		return nil
+	}
+	if err := s.encoder.flush(); err != nil {
+		return err
+	}
+	s.files[len(s.files)-1].Bytes = s.counter.bytes
+
+	if s.full() {
+		return s.close()
+	}
+	return nil
+}
+
+// full 判斷目前的檔案是否達到分割的大小, 包含尚未寫入的緩衝
+func (s *splitter) full() bool {
+	return s.opts.MaxFileSize > 0 && s.counter.bytes+int64(s.encoder.buffered()) >= s.opts.MaxFileSize
+}
+
+// close 寫入檔案結尾並關閉目前的檔案, 下一列資料會開啟新的檔案
+func (s *splitter) close() error {
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.encoder.close()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.files[len(s.files)-1].Bytes = s.counter.bytes
+	s.file = nil
+	return err
+}
+
+// abort 在匯出失敗時關閉檔案, 已寫入的檔案保留供檢查
+func (s *splitter) abort() {
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+}
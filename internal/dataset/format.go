@@ -0,0 +1,282 @@
+package dataset
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// 檔案格式
+const (
+	FormatCSV     = "csv"
+	FormatJSONL   = "jsonl"
+	FormatParquet = "parquet"
+)
+
+// 壓縮方式, CSV 與 JSONL 壓縮整個檔案, Parquet 壓縮檔案中的每個 page
+const (
+	CompressionNone   = "none"
+	CompressionGzip   = "gzip"
+	CompressionZstd   = "zstd"
+	CompressionSnappy = "snappy" // 只適用於 Parquet
+)
+
+// Extension 回傳格式與壓縮方式對應的副檔名, e.g. csv.gz
+func Extension(format, compression string) string {
+	if format == FormatParquet {
+		return format
+	}
+
+	switch compression {
+	case CompressionGzip:
+		return format + ".gz"
+	case CompressionZstd:
+		return format + ".zst"
+	}
+	return format
+}
+
+// ValidateFormat 檢查格式與壓縮方式的組合
+func ValidateFormat(format, compression string) error {
+	switch format {
+	case FormatCSV, FormatJSONL:
+		if compression == CompressionSnappy {
+			return fmt.Errorf("compression %v is only supported by %v", compression, FormatParquet)
+		}
+	case FormatParquet:
+	default:
+		return fmt.Errorf("format %v undifined", format)
+	}
+
+	switch compression {
+	case CompressionNone, CompressionGzip, CompressionZstd, CompressionSnappy:
+		return nil
+	}
+	return fmt.Errorf("compression %v undifined", compression)
+}
+
+// encoder 將資料列寫入單一檔案
+type encoder interface {
+	write(values []interface{}) error
+
+	// 將緩衝的資料列寫入檔案, Parquet 每次 flush 產生一個 row group
+	flush() error
+
+	// 尚未寫入檔案的 bytes, 壓縮時為壓縮前的大小
+	buffered() int
+
+	// 寫入檔案結尾並關閉壓縮, 不關閉底層的檔案
+	close() error
+}
+
+// newEncoder 建立格式對應的 encoder, 並寫入檔案開頭 (e.g. CSV 標題列)
+func newEncoder(format, compression string, w io.Writer, columns []*Column) (encoder, error) {
+	if format == FormatParquet {
+		return newParquetEncoder(compression, w, columns)
+	}
+
+	var compressor io.WriteCloser
+	switch compression {
+	case CompressionGzip:
+		compressor = gzip.NewWriter(w)
+	case CompressionZstd:
+		z, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		compressor = z
+	default:
+		compressor = nopCloser{w}
+	}
+	buf := bufio.NewWriterSize(compressor, 1<<16)
+
+	if format == FormatCSV {
+		e := &csvEncoder{compressor: compressor, buf: buf, w: csv.NewWriter(buf), record: make([]string, len(columns))}
+		names := make([]string, len(columns))
+		for i, column := range columns {
+			names[i] = column.Name
+		}
+		return e, e.w.Write(names)
+	}
+	return &jsonlEncoder{compressor: compressor, buf: buf, columns: columns}, nil
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// csvEncoder 寫入 RFC 4180 CSV, 第一列為欄位名稱, NULL 為空字串
+type csvEncoder struct {
+	compressor io.WriteCloser
+	buf        *bufio.Writer
+	w          *csv.Writer
+	record     []string
+}
+
+func (e *csvEncoder) write(values []interface{}) error {
+	for i, value := range values {
+		e.record[i] = FormatText(value)
+	}
+	return e.w.Write(e.record)
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	if err := e.w.Error(); err != nil {
+		return err
+	}
+	return e.buf.Flush()
+}
+
+func (e *csvEncoder) buffered() int {
+	return e.buf.Buffered()
+}
+
+func (e *csvEncoder) close() error {
+	if err := e.flush(); err != nil {
+		return err
+	}
+	return e.compressor.Close()
+}
+
+// FormatText 回傳欄位內容在 CSV 中的文字, NULL 為空字串
+func FormatText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(TimeLayout)
+	}
+	return fmt.Sprint(value)
+}
+
+// jsonlEncoder 每列寫入一個 JSON 物件, 欄位依照選擇的順序排列, 時間為 RFC 3339
+type jsonlEncoder struct {
+	compressor io.WriteCloser
+	buf        *bufio.Writer
+	columns    []*Column
+}
+
+func (e *jsonlEncoder) write(values []interface{}) error {
+	e.buf.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+
+		name, _ := json.Marshal(e.columns[i].Name)
+		e.buf.Write(name)
+		e.buf.WriteByte(':')
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("column %v: %w", e.columns[i].Name, err)
+		}
+		e.buf.Write(encoded)
+	}
+	e.buf.WriteByte('}')
+	return e.buf.WriteByte('\n')
+}
+
+func (e *jsonlEncoder) flush() error {
+	return e.buf.Flush()
+}
+
+func (e *jsonlEncoder) buffered() int {
+	return e.buf.Buffered()
+}
+
+func (e *jsonlEncoder) close() error {
+	if err := e.flush(); err != nil {
+		return err
+	}
+	return e.compressor.Close()
+}
+
+// parquetCodecs 為 Parquet page 的壓縮方式
+var parquetCodecs = map[string]parquet.CompressionCodec{
+	CompressionNone:   parquet.CompressionCodec_UNCOMPRESSED,
+	CompressionSnappy: parquet.CompressionCodec_SNAPPY,
+	CompressionGzip:   parquet.CompressionCodec_GZIP,
+	CompressionZstd:   parquet.CompressionCodec_ZSTD,
+}
+
+// parquetEncoder 以欄位型別產生 Parquet schema, 時間保存為 TIMESTAMP_MICROS
+type parquetEncoder struct {
+	w       *writer.CSVWriter
+	columns []*Column
+}
+
+func newParquetEncoder(compression string, w io.Writer, columns []*Column) (*parquetEncoder, error) {
+	metadata := make([]string, len(columns))
+	for i, column := range columns {
+		var physical string
+		switch column.Kind {
+		case Integer:
+			physical = "type=INT64"
+		case Float:
+			physical = "type=DOUBLE"
+		case Bool:
+			physical = "type=BOOLEAN"
+		case Time:
+			physical = "type=INT64, convertedtype=TIMESTAMP_MICROS"
+		default:
+			physical = "type=BYTE_ARRAY, convertedtype=UTF8"
+		}
+
+		repetition := "REQUIRED"
+		if column.Nullable {
+			repetition = "OPTIONAL"
+		}
+		metadata[i] = fmt.Sprintf("name=%s, %s, repetitiontype=%s", column.Name, physical, repetition)
+	}
+
+	pw, err := writer.NewCSVWriterFromWriter(metadata, w, 1)
+	if err != nil {
+		return nil, err
+	}
+	pw.CompressionType = parquetCodecs[compression]
+	return &parquetEncoder{w: pw, columns: columns}, nil
+}
+
+func (e *parquetEncoder) write(values []interface{}) error {
+	// writer 保留資料列直到 flush, 每列都必須是新的 slice
+	row := make([]interface{}, len(values))
+	for i, value := range values {
+		if t, ok := value.(time.Time); ok {
+			value = t.UnixMicro()
+		}
+		row[i] = value
+	}
+	return e.w.Write(row)
+}
+
+func (e *parquetEncoder) flush() error {
+	return e.w.Flush(true)
+}
+
+// buffered 不估計尚未寫入的 row group, Parquet 只在 chunk 之間分割
+func (e *parquetEncoder) buffered() int {
+	return 0
+}
+
+func (e *parquetEncoder) close() error {
+	return e.w.WriteStop()
+}
@@ -0,0 +1,532 @@
+package dataset
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"practice/internal/storage/dialect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 唯一鍵衝突的處理方式
+const (
+	ConflictFail   = "fail"   // 整個檔案在同一個交易中寫入, 遇到衝突的資料列即停止並復原所有資料列
+	ConflictSkip   = "skip"   // 略過衝突的資料列, 保留資料庫中的內容
+	ConflictUpsert = "upsert" // 以檔案的內容更新衝突的資料列
+)
+
+// maxPlaceholders 為單一 statement 的參數上限, 取 MySQL 與 PostgreSQL 中較小的 65535
+const maxPlaceholders = 65535
+
+// ImportOptions 為匯入單一檔案的設定
+type ImportOptions struct {
+	Format      string            // csv 或 jsonl, 空白時依照副檔名判斷
+	Compression string            // none, gzip 或 zstd, Format 空白時依照副檔名判斷
+	Mapping     map[string]string // 檔案欄位對應的資料表欄位, 沒有指定的欄位使用相同名稱
+	BatchSize   int               // 每個 INSERT 的資料列數量
+	Conflict    string
+	Keys        []string // 衝突判斷的欄位 (e.g. account), PostgreSQL 的 upsert 必須指定
+	RejectFile  string   // 無法匯入的資料列以 JSONL 寫入的檔案, 有資料列被拒絕時才建立
+}
+
+// ImportReport 為匯入單一檔案的結果
+type ImportReport struct {
+	Table    string
+	File     string
+	Columns  []string // 寫入的資料表欄位
+	Ignored  []string // 資料表中沒有對應欄位而忽略的檔案欄位
+	Rows     int64    // 檔案中的資料列
+	Written  int64    // 新增或更新的資料列
+	Skipped  int64    // 因衝突而略過的資料列
+	Rejected int64    // 寫入 reject 檔案的資料列
+	Batches  int
+	Elapsed  time.Duration
+}
+
+// execer 為 *sql.DB 與 *sql.Tx 共用的寫入方法
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Importer 將 CSV 或 JSONL 檔案分批寫入連線中的資料表, 與 Exporter 搭配可以在資料庫之間搬移 fixtures
+type Importer struct {
+	db      *sql.DB
+	dialect dialect.Dialect
+	opts    ImportOptions
+}
+
+// NewImporter New File Importer
+// @param db       connection of the imported database
+// @param d        dialect of the same database
+// @param opts     format, column mapping, batch size and conflict handling
+func NewImporter(db *sql.DB, d dialect.Dialect, opts ImportOptions) (*Importer, error) {
+	if opts.Format != "" && opts.Format != FormatCSV && opts.Format != FormatJSONL {
+		return nil, fmt.Errorf("import format %v undifined", opts.Format)
+	}
+	if opts.BatchSize <= 0 {
+		return nil, fmt.Errorf("batch size must be positive")
+	}
+
+	switch opts.Conflict {
+	case ConflictFail, ConflictSkip:
+	case ConflictUpsert:
+		if d.Driver() == "postgresql" && len(opts.Keys) == 0 {
+			return nil, fmt.Errorf("upsert on %v needs conflict keys (e.g. account)", d.Driver())
+		}
+	default:
+		return nil, fmt.Errorf("conflict handling %v undifined", opts.Conflict)
+	}
+
+	return &Importer{db: db, dialect: d, opts: opts}, nil
+}
+
+// Import 將檔案匯入資料表, 每個 batch 寫入後呼叫 progress
+// ConflictFail 時所有資料列在交易 commit 後才生效, 回傳錯誤時資料表沒有任何改變, 修正檔案後可以直接重新匯入
+func (im *Importer) Import(ctx context.Context, name, path string, progress func(*ImportReport)) (*ImportReport, error) {
+	start := time.Now()
+
+	table, err := LoadTable(ctx, im.db, im.dialect, name)
+	if err != nil {
+		return nil, err
+	}
+	for field, column := range im.opts.Mapping {
+		if _, ok := table.Column(column); !ok {
+			return nil, fmt.Errorf("field %v is mapped to column %v of %v, which is undifined", field, column, table.Name)
+		}
+	}
+	if _, err := table.Select(im.opts.Keys); err != nil {
+		return nil, err
+	}
+
+	format, compression := im.opts.Format, im.opts.Compression
+	if format == "" {
+		if format, compression, err = DetectFormat(path); err != nil {
+			return nil, err
+		}
+	}
+	if compression == "" {
+		compression = CompressionNone
+	}
+	src, err := openSource(path, format, compression)
+	if err != nil {
+		return nil, err
+	}
+	defer src.close()
+
+	rejects := &rejectWriter{path: im.opts.RejectFile}
+	defer rejects.close()
+
+	var tx *sql.Tx
+	var exec execer = im.db
+	if im.opts.Conflict == ConflictFail {
+		if tx, err = im.db.BeginTx(ctx, nil); err != nil {
+			return nil, fmt.Errorf("failed to begin import transaction: %w", err)
+		}
+		defer tx.Rollback()
+		exec = tx
+	}
+
+	report := &ImportReport{Table: table.Name, File: path}
+	var b *batcher
+	for {
+		rec, err := src.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && rec == nil {
+			return nil, fmt.Errorf("failed to read %v: %w", path, err)
+		}
+		report.Rows++
+
+		// 以第一筆資料的欄位決定寫入的資料表欄位, 之後的資料列都寫入相同的欄位
+		if b == nil && err == nil {
+			if b, err = im.newBatcher(exec, tx != nil, table, rec, format, report); err != nil {
+				return nil, err
+			}
+		}
+
+		var values []interface{}
+		if err == nil {
+			values, err = b.convert(rec)
+		}
+		if err != nil {
+			if err := rejects.write(rec, err); err != nil {
+				return nil, err
+			}
+			report.Rejected++
+			continue
+		}
+
+		b.add(rec, values)
+		if len(b.records) >= b.size {
+			if err := b.flush(ctx, rejects, report); err != nil {
+				return nil, err
+			}
+			report.Elapsed = time.Since(start)
+			if progress != nil {
+				progress(report)
+			}
+		}
+	}
+
+	if b != nil {
+		if err := b.flush(ctx, rejects, report); err != nil {
+			return nil, err
+		}
+		if err := im.resetSequence(ctx, exec, table, b.columns); err != nil {
+			return nil, err
+		}
+	}
+	if tx != nil {
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit import of %v: %w", path, err)
+		}
+	}
+	report.Elapsed = time.Since(start)
+	return report, nil
+}
+
+// newBatcher 依照欄位對應決定寫入的資料表欄位, 欄位依照資料表中的順序排列
+// 資料列寫入 exec, 在交易中寫入時 savepoint 為 true, 避免失敗的 statement 中止整個交易
+func (im *Importer) newBatcher(exec execer, savepoint bool, table *Table, first *record, format string, report *ImportReport) (*batcher, error) {
+	fields := map[string]string{}
+	for field := range first.values {
+		column := field
+		if mapped, ok := im.opts.Mapping[field]; ok {
+			column = mapped
+		}
+		if _, ok := table.Column(column); !ok {
+			report.Ignored = append(report.Ignored, field)
+			continue
+		}
+		if other, ok := fields[column]; ok {
+			return nil, fmt.Errorf("fields %v and %v are both mapped to column %v", other, field, column)
+		}
+		fields[column] = field
+	}
+	sort.Strings(report.Ignored)
+
+	b := &batcher{im: im, exec: exec, savepoint: savepoint, table: table, csv: format == FormatCSV, fields: map[string]int{}}
+	for _, column := range table.Columns {
+		if field, ok := fields[column.Name]; ok {
+			b.fields[field] = len(b.columns)
+			b.columns = append(b.columns, column)
+			report.Columns = append(report.Columns, column.Name)
+		}
+	}
+	if len(b.columns) == 0 {
+		return nil, fmt.Errorf("no field of the file matches a column of %v", table.Name)
+	}
+	for _, key := range im.opts.Keys {
+		if _, ok := fields[key]; !ok {
+			return nil, fmt.Errorf("conflict key %v is not imported", key)
+		}
+	}
+
+	names := make([]string, len(b.columns))
+	quoted := make([]string, len(b.columns))
+	for i, column := range b.columns {
+		names[i] = column.Name
+		quoted[i] = im.dialect.Quote(column.Name)
+	}
+	b.prefix = fmt.Sprintf("INSERT INTO %s (%s) VALUES ", im.dialect.Quote(table.Name), strings.Join(quoted, ", "))
+
+	if im.opts.Conflict != ConflictFail {
+		// 以唯一鍵 (e.g. account) 判斷衝突時不更新既有資料列的主鍵, 避免破壞其他資料表的參照
+		updated := []string{}
+		for _, name := range names {
+			if name != table.PrimaryKey.Name {
+				updated = append(updated, name)
+			}
+		}
+		if len(updated) == 0 {
+			updated = names
+		}
+		clause, err := im.dialect.OnConflict(updated, im.opts.Keys, im.opts.Conflict == ConflictUpsert)
+		if err != nil {
+			return nil, err
+		}
+		b.suffix = " " + clause
+	}
+
+	b.size = im.opts.BatchSize
+	if limit := maxPlaceholders / len(b.columns); b.size > limit {
+		b.size = limit
+	}
+	return b, nil
+}
+
+// resetSequence PostgreSQL 寫入指定的主鍵後不會推進 sequence, 之後新增的資料列會與匯入的主鍵衝突
+func (im *Importer) resetSequence(ctx context.Context, exec execer, table *Table, columns []*Column) error {
+	if im.dialect.Driver() != "postgresql" {
+		return nil
+	}
+	for _, column := range columns {
+		if column != table.PrimaryKey {
+			continue
+		}
+
+		key := im.dialect.Quote(column.Name)
+		_, err := exec.ExecContext(ctx, fmt.Sprintf(
+			"SELECT setval(pg_get_serial_sequence('%s', '%s'), COALESCE(MAX(%s), 0) + 1, false) FROM %s",
+			table.Name, column.Name, key, im.dialect.Quote(table.Name)))
+		if err != nil {
+			return fmt.Errorf("failed to reset the sequence of %v: %w", table.Name, err)
+		}
+	}
+	return nil
+}
+
+// batcher 累積資料列並以多列 INSERT 寫入
+type batcher struct {
+	im        *Importer
+	exec      execer
+	savepoint bool
+	table     *Table
+	csv       bool
+	columns   []*Column
+	fields    map[string]int // 檔案欄位對應的 columns 位置
+	prefix    string
+	suffix    string
+	size      int
+
+	records []*record
+	rows    [][]interface{}
+}
+
+// convert 依照欄位型別轉換檔案中的內容, 缺少的欄位為 NULL
+func (b *batcher) convert(rec *record) ([]interface{}, error) {
+	values := make([]interface{}, len(b.columns))
+	for field, value := range rec.values {
+		i, ok := b.fields[field]
+		if !ok {
+			if column := b.column(field); column != "" {
+				if _, ok := b.table.Column(column); ok {
+					return nil, fmt.Errorf("field %v is not in the first row, column %v is not imported", field, column)
+				}
+			}
+			continue
+		}
+
+		converted, err := Coerce(b.columns[i], value, b.csv)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = converted
+	}
+	return values, nil
+}
+
+// column 回傳檔案欄位對應的資料表欄位名稱
+func (b *batcher) column(field string) string {
+	if mapped, ok := b.im.opts.Mapping[field]; ok {
+		return mapped
+	}
+	return field
+}
+
+func (b *batcher) add(rec *record, values []interface{}) {
+	b.records = append(b.records, rec)
+	b.rows = append(b.rows, values)
+}
+
+// statement 回傳寫入 n 列的 SQL
+func (b *batcher) statement(n int) string {
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(b.columns)), ", ") + ")"
+	rows := strings.TrimSuffix(strings.Repeat(row+", ", n), ", ")
+	return b.im.dialect.Rebind(b.prefix + rows + b.suffix)
+}
+
+// flush 以單一 statement 寫入累積的資料列, 失敗時逐列重新寫入, 找出造成失敗的資料列寫入 reject 檔案
+func (b *batcher) flush(ctx context.Context, rejects *rejectWriter, report *ImportReport) error {
+	if len(b.rows) == 0 {
+		return nil
+	}
+	defer func() {
+		b.records = b.records[:0]
+		b.rows = b.rows[:0]
+	}()
+
+	args := make([]interface{}, 0, len(b.rows)*len(b.columns))
+	for _, row := range b.rows {
+		args = append(args, row...)
+	}
+	report.Batches++
+
+	result, err := b.execute(ctx, b.statement(len(b.rows)), args...)
+	if err == nil {
+		b.count(result, len(b.rows), report)
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	single := b.statement(1)
+	for i, row := range b.rows {
+		result, err := b.execute(ctx, single, row...)
+		if err == nil {
+			b.count(result, 1, report)
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if b.im.opts.Conflict == ConflictFail && b.im.dialect.Duplicate(err) {
+			return fmt.Errorf("line %v conflicts with an existing row of %v, no row of the file is imported, rerun with skip or upsert: %w", b.records[i].line, b.table.Name, err)
+		}
+
+		if err := rejects.write(b.records[i], err); err != nil {
+			return err
+		}
+		report.Rejected++
+	}
+	return nil
+}
+
+// execute 執行 statement, savepoint 時失敗只復原這個 statement, PostgreSQL 的交易才能繼續寫入之後的資料列
+func (b *batcher) execute(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if !b.savepoint {
+		return b.exec.ExecContext(ctx, query, args...)
+	}
+
+	if _, err := b.exec.ExecContext(ctx, "SAVEPOINT import_rows"); err != nil {
+		return nil, err
+	}
+	result, err := b.exec.ExecContext(ctx, query, args...)
+	if err != nil {
+		if _, rollbackErr := b.exec.ExecContext(ctx, "ROLLBACK TO SAVEPOINT import_rows"); rollbackErr != nil {
+			return nil, fmt.Errorf("failed to roll back to savepoint after %v: %w", err, rollbackErr)
+		}
+		return nil, err
+	}
+	if _, err := b.exec.ExecContext(ctx, "RELEASE SAVEPOINT import_rows"); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// count 依照影響的資料列數量更新結果
+// MySQL 的 ON DUPLICATE KEY UPDATE 更新時回傳 2, 因此 upsert 以寫入的資料列數量計算
+func (b *batcher) count(result sql.Result, rows int, report *ImportReport) {
+	if b.im.opts.Conflict == ConflictUpsert {
+		report.Written += int64(rows)
+		return
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		report.Written += int64(rows)
+		return
+	}
+	report.Written += affected
+	report.Skipped += int64(rows) - affected
+}
+
+// Coerce 將檔案中的內容轉換成欄位型別對應的 Go 型別
+// CSV 沒有 NULL, 以空字串表示, 但不可為 NULL 的文字欄位保留空字串; JSON 的數字為 json.Number
+func Coerce(column *Column, value interface{}, csv bool) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if csv && v == "" && (column.Nullable || column.Kind != String) {
+			return nil, nil
+		}
+		return Parse(column, v)
+	case json.Number:
+		switch column.Kind {
+		case Integer:
+			n, err := v.Int64()
+			if err != nil {
+				return nil, fmt.Errorf("column %v: %v is not an integer", column.Name, v)
+			}
+			return n, nil
+		case Float:
+			return v.Float64()
+		case Time:
+			// 整數視為 unix 秒數
+			n, err := v.Int64()
+			if err != nil {
+				return nil, fmt.Errorf("column %v: %v is not unix seconds", column.Name, v)
+			}
+			return time.Unix(n, 0), nil
+		case Bool:
+			return nil, fmt.Errorf("column %v: number %v is not a boolean", column.Name, v)
+		}
+		return v.String(), nil
+	case bool:
+		switch column.Kind {
+		case Bool:
+			return v, nil
+		case Integer:
+			if v {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case String:
+			return strconv.FormatBool(v), nil
+		}
+		return nil, fmt.Errorf("column %v: boolean is not %v", column.Name, column.Type)
+	case map[string]interface{}, []interface{}:
+		// 巢狀的物件或陣列只能寫入文字欄位 (e.g. json)
+		if column.Kind != String {
+			return nil, fmt.Errorf("column %v: object is not %v", column.Name, column.Type)
+		}
+		text, err := json.Marshal(v)
+		return string(text), err
+	}
+	return nil, fmt.Errorf("unexpected %T in column %v", value, column.Name)
+}
+
+// rejectWriter 將無法匯入的資料列與原因以 JSONL 寫入, 第一次寫入時才建立檔案
+type rejectWriter struct {
+	path string
+	file *os.File
+}
+
+// reject 為 reject 檔案中的一行
+type reject struct {
+	Line   int                    `json:"line"`
+	Error  string                 `json:"error"`
+	Record map[string]interface{} `json:"record,omitempty"`
+	Raw    string                 `json:"raw,omitempty"`
+}
+
+func (w *rejectWriter) write(rec *record, cause error) error {
+	if w.path == "" {
+		return fmt.Errorf("line %v is rejected: %w", rec.line, cause)
+	}
+
+	if w.file == nil {
+		if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+			return err
+		}
+		file, err := os.Create(w.path)
+		if err != nil {
+			return err
+		}
+		w.file = file
+	}
+
+	line, err := json.Marshal(reject{Line: rec.line, Error: cause.Error(), Record: rec.values, Raw: rec.raw})
+	if err != nil {
+		return err
+	}
+	_, err = w.file.Write(append(line, '\n'))
+	return err
+}
+
+func (w *rejectWriter) close() error {
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,193 @@
+package dataset
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// record 為檔案中的一筆資料, CSV 的內容皆為 string, JSONL 為解碼後的 JSON 值 (數字為 json.Number)
+type record struct {
+	line   int
+	values map[string]interface{}
+	raw    string // 無法解析的原始內容
+}
+
+// source 依序讀出檔案中的資料
+type source interface {
+	// 回傳下一筆資料, 檔案結束時回傳 io.EOF; 同時回傳資料與錯誤時表示該筆資料無法解析, 仍可繼續讀取
+	next() (*record, error)
+	close() error
+}
+
+// DetectFormat 依照副檔名判斷檔案格式與壓縮方式, e.g. users.csv.gz
+func DetectFormat(path string) (string, string, error) {
+	name := strings.ToLower(filepath.Base(path))
+	compression := CompressionNone
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		compression = CompressionGzip
+	case strings.HasSuffix(name, ".zst"):
+		compression = CompressionZstd
+	}
+	name = strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ".zst")
+
+	switch filepath.Ext(name) {
+	case ".csv":
+		return FormatCSV, compression, nil
+	case ".jsonl", ".ndjson", ".json":
+		return FormatJSONL, compression, nil
+	}
+	return "", "", fmt.Errorf("cannot detect the format of %v, specify csv or jsonl", path)
+}
+
+// openSource 開啟檔案並依照壓縮方式解壓縮
+func openSource(path, format, compression string) (source, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = bufio.NewReaderSize(file, 1<<16)
+	closers := []func() error{file.Close}
+	switch compression {
+	case CompressionGzip:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read %v as gzip: %w", path, err)
+		}
+		r = gz
+		closers = append([]func() error{gz.Close}, closers...)
+	case CompressionZstd:
+		z, err := zstd.NewReader(r)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read %v as zstd: %w", path, err)
+		}
+		r = z
+		closers = append([]func() error{func() error { z.Close(); return nil }}, closers...)
+	case CompressionNone:
+	default:
+		file.Close()
+		return nil, fmt.Errorf("compression %v undifined", compression)
+	}
+
+	closeAll := func() error {
+		var err error
+		for _, c := range closers {
+			if closeErr := c(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}
+
+	switch format {
+	case FormatCSV:
+		s := &csvSource{r: csv.NewReader(r), closeAll: closeAll}
+		if err := s.readHeader(); err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to read the header of %v: %w", path, err)
+		}
+		return s, nil
+	case FormatJSONL:
+		return &jsonlSource{r: bufio.NewReaderSize(r, 1<<16), closeAll: closeAll}, nil
+	}
+	closeAll()
+	return nil, fmt.Errorf("format %v undifined", format)
+}
+
+// csvSource 以第一列為欄位名稱讀取 RFC 4180 CSV
+type csvSource struct {
+	r        *csv.Reader
+	header   []string
+	closeAll func() error
+}
+
+func (s *csvSource) readHeader() error {
+	header, err := s.r.Read()
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		if seen[name] {
+			return fmt.Errorf("field %v appears twice", name)
+		}
+		seen[name] = true
+		header[i] = name
+	}
+	s.header = header
+	return nil
+}
+
+func (s *csvSource) next() (*record, error) {
+	fields, err := s.r.Read()
+	var parseErr *csv.ParseError
+	if err != nil && !(errors.As(err, &parseErr) && parseErr.Err == csv.ErrFieldCount) {
+		return nil, err
+	}
+
+	line, _ := s.r.FieldPos(0)
+	values := make(map[string]interface{}, len(fields))
+	for i, field := range fields {
+		if i < len(s.header) {
+			values[s.header[i]] = field
+		}
+	}
+	if err != nil {
+		return &record{line: line, values: values}, fmt.Errorf("line %v: %v fields, header has %v", line, len(fields), len(s.header))
+	}
+	return &record{line: line, values: values}, nil
+}
+
+func (s *csvSource) close() error {
+	return s.closeAll()
+}
+
+// jsonlSource 每行讀取一個 JSON 物件, 略過空白行
+type jsonlSource struct {
+	r        *bufio.Reader
+	line     int
+	closeAll func() error
+}
+
+func (s *jsonlSource) next() (*record, error) {
+	for {
+		text, err := s.r.ReadString('\n')
+		if err != nil && (err != io.EOF || text == "") {
+			return nil, err
+		}
+		s.line++
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.UseNumber()
+		values := map[string]interface{}{}
+		if err := decoder.Decode(&values); err != nil {
+			return &record{line: s.line, raw: strings.TrimSpace(text)}, fmt.Errorf("line %v: %w", s.line, err)
+		}
+		return &record{line: s.line, values: values}, nil
+	}
+}
+
+func (s *jsonlSource) close() error {
+	return s.closeAll()
+}
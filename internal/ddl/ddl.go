@@ -0,0 +1,61 @@
+package ddl
+
+// Kind 為欄位的邏輯型別, 由各 dialect 轉換成實際的欄位型別
+type Kind int
+
+const (
+	Serial   Kind = iota // 自動遞增的非負整數主鍵
+	Unsigned             // 非負整數
+	Varchar              // 限制長度的字串
+	Text                 // 不限長度的字串
+	DateTime             // 日期與時間, 不含時區
+)
+
+// Schema 為資料庫中由 migration 管理的資料表
+type Schema struct {
+	Database string // MySQL 在 migration 中建立的資料庫, 空字串時不建立
+	Tables   []*Table
+}
+
+// Table 為資料表定義
+type Table struct {
+	Name       string
+	Comment    string
+	Columns    []*Column
+	PrimaryKey []string
+	Indexes    []*Index
+}
+
+// Column 為欄位定義, 所有欄位皆為 NOT NULL
+type Column struct {
+	Name    string
+	Kind    Kind
+	Length  int // Varchar 的長度
+	Comment string
+}
+
+// Index 為資料表索引, 不指定名稱, 由資料庫依照欄位命名
+type Index struct {
+	Columns []string
+	Unique  bool
+}
+
+// Table 以名稱取得資料表定義
+func (s *Schema) Table(name string) (*Table, bool) {
+	for _, table := range s.Tables {
+		if table.Name == name {
+			return table, true
+		}
+	}
+	return nil, false
+}
+
+// Column 以名稱取得欄位定義
+func (t *Table) Column(name string) (*Column, bool) {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return nil, false
+}
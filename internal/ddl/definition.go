@@ -0,0 +1,53 @@
+package ddl
+
+// Initial 為 users, wallets 與 logs 的唯一定義, 兩種資料庫依序執行所有 migrations 後必須與此一致
+var Initial = &Schema{
+	Database: "development",
+	Tables: []*Table{
+		{
+			Name:    "users",
+			Comment: "用戶資訊",
+			Columns: []*Column{
+				{Name: "id", Kind: Serial, Comment: "用戶 UUID"},
+				{Name: "account", Kind: Varchar, Length: 255, Comment: "用戶帳號"},
+				{Name: "password", Kind: Text, Comment: "用戶密碼"},
+				{Name: "nickname", Kind: Varchar, Length: 255, Comment: "用戶暱稱"},
+				{Name: "email", Kind: Varchar, Length: 255, Comment: "用戶信箱"},
+				{Name: "created_at", Kind: DateTime, Comment: "註冊日期"},
+				{Name: "modified_at", Kind: DateTime, Comment: "修改日期"},
+			},
+			PrimaryKey: []string{"id"},
+			Indexes: []*Index{
+				{Columns: []string{"account"}, Unique: true},
+				{Columns: []string{"nickname"}, Unique: true},
+			},
+		},
+		{
+			Name:    "wallets",
+			Comment: "錢包資訊",
+			Columns: []*Column{
+				{Name: "id", Kind: Serial, Comment: "錢包 UUID"},
+				{Name: "user_id", Kind: Unsigned, Comment: "用戶 UUID"},
+				{Name: "amount", Kind: Unsigned, Comment: "用戶餘額"},
+				{Name: "created_at", Kind: DateTime, Comment: "註冊日期"},
+				{Name: "modified_at", Kind: DateTime, Comment: "修改日期"},
+			},
+			PrimaryKey: []string{"id"},
+			Indexes: []*Index{
+				{Columns: []string{"user_id"}, Unique: true},
+			},
+		},
+		{
+			Name:    "logs",
+			Comment: "轉帳記錄",
+			Columns: []*Column{
+				{Name: "id", Kind: Serial, Comment: "日誌 UUID"},
+				{Name: "deposit_user_id", Kind: Unsigned, Comment: "存款用戶 UUID"},
+				{Name: "withdraw_user_id", Kind: Unsigned, Comment: "出款用戶 UUID"},
+				{Name: "amount", Kind: Unsigned, Comment: "轉帳金額"},
+				{Name: "created_at", Kind: DateTime, Comment: "註冊日期"},
+			},
+			PrimaryKey: []string{"id"},
+		},
+	},
+}
@@ -0,0 +1,122 @@
+package ddl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 輸出格式
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+)
+
+// Select 回傳只包含指定資料表的結構, names 為空時回傳全部
+func (l *Live) Select(names []string) (*Live, error) {
+	if len(names) == 0 {
+		return l, nil
+	}
+
+	selected := &Live{Driver: l.Driver, Namespace: l.Namespace}
+	for _, name := range names {
+		table, ok := l.Table(name)
+		if !ok {
+			return nil, fmt.Errorf("table %v not found in %v", name, l.Namespace)
+		}
+		selected.Tables = append(selected.Tables, table)
+	}
+	return selected, nil
+}
+
+// Describe 將資料表結構以表格, JSON 或 YAML 寫入 w
+func Describe(w io.Writer, live *Live, output string) error {
+	switch output {
+	case OutputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(live)
+	case OutputYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(live); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case OutputTable:
+		return describeTables(w, live)
+	}
+	return fmt.Errorf("output %v undifined", output)
+}
+
+// describeTables 每個資料表輸出標題、欄位與索引, 資料列數量與大小為估計值
+func describeTables(w io.Writer, live *Live) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, table := range live.Tables {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+
+		title := fmt.Sprintf("%s.%s  ~%d rows, %s", live.Namespace, table.Name, table.Rows, formatBytes(table.Bytes))
+		if table.Comment != "" {
+			title += "  -- " + table.Comment
+		}
+		fmt.Fprintln(tw, title)
+
+		fmt.Fprintln(tw, "  COLUMN\tTYPE\tNULL\tDEFAULT\tEXTRA\tCOMMENT")
+		for _, column := range table.Columns {
+			null, def, extra := "NO", "", ""
+			if column.Nullable {
+				null = "YES"
+			}
+			if column.Default != nil {
+				def = *column.Default
+			}
+			if column.AutoIncrement {
+				extra = "auto_increment"
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%s\n", column.Name, column.Type, null, def, extra, column.Comment)
+		}
+
+		fmt.Fprintln(tw, "  INDEX\tKIND\tCOLUMNS")
+		for _, index := range table.Indexes {
+			kind := "secondary"
+			switch {
+			case index.Primary:
+				kind = "primary"
+			case index.Unique:
+				kind = "unique"
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", index.Name, kind, strings.Join(index.Columns, ", "))
+		}
+
+		if len(table.Checks) > 0 {
+			fmt.Fprintln(tw, "  CHECK\tDEFINITION")
+			for _, check := range table.Checks {
+				fmt.Fprintf(tw, "  %s\t%s\n", check.Name, check.Definition)
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+// formatBytes 以 KB, MB 或 GB 顯示大小
+func formatBytes(bytes int64) string {
+	units := []struct {
+		suffix string
+		bytes  int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}}
+
+	for _, unit := range units {
+		if bytes >= unit.bytes {
+			return fmt.Sprintf("%.1f %s", float64(bytes)/float64(unit.bytes), unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%d B", bytes)
+}
@@ -0,0 +1,203 @@
+package ddl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect 將 schema 定義轉換成特定資料庫的 DDL
+type Dialect interface {
+	// 對應 config.RdbOpts.Driver
+	Driver() string
+
+	// migration 檔案所在的目錄, 相對於專案根目錄
+	Dir() string
+
+	// 欄位型別, 不包含 NOT NULL 等限制
+	ColumnType(column *Column) string
+
+	// 由 Inspect 讀出的欄位型別, 用來比對連線中的資料庫
+	InspectedType(column *Column) string
+
+	Up(schema *Schema) string
+	Down(schema *Schema) string
+}
+
+// Dialects 為所有支援的資料庫
+var Dialects = []Dialect{Mysql{}, Postgres{}}
+
+// FindDialect 以 driver 名稱取得 dialect
+func FindDialect(driver string) (Dialect, error) {
+	for _, dialect := range Dialects {
+		if dialect.Driver() == driver {
+			return dialect, nil
+		}
+	}
+	return nil, fmt.Errorf("driver %v is undifined", driver)
+}
+
+// Mysql 產生 MySQL DDL, 非負整數以 unsigned 表示, 註解寫在欄位定義中
+type Mysql struct{}
+
+func (Mysql) Driver() string { return "mysql" }
+func (Mysql) Dir() string    { return "deployments/mysql/migration" }
+
+func (Mysql) ColumnType(column *Column) string {
+	switch column.Kind {
+	case Serial, Unsigned:
+		return "int(11) unsigned"
+	case Varchar:
+		return fmt.Sprintf("varchar(%d)", column.Length)
+	case Text:
+		return "text"
+	case DateTime:
+		return "datetime"
+	}
+	return ""
+}
+
+func (d Mysql) InspectedType(column *Column) string {
+	return d.ColumnType(column)
+}
+
+func (d Mysql) Up(schema *Schema) string {
+	b := &strings.Builder{}
+	if schema.Database != "" {
+		fmt.Fprintf(b, "CREATE DATABASE IF NOT EXISTS %s;\n\n", d.quote(schema.Database))
+	}
+
+	for i, table := range schema.Tables {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		lines := []string{}
+		for _, column := range table.Columns {
+			line := fmt.Sprintf("%s %s NOT NULL", d.quote(column.Name), d.ColumnType(column))
+			if column.Kind == Serial {
+				line += " AUTO_INCREMENT"
+			}
+			lines = append(lines, line+" COMMENT "+literal(column.Comment))
+		}
+		lines = append(lines, fmt.Sprintf("PRIMARY KEY (%s)", d.quoteAll(table.PrimaryKey)))
+		for _, index := range table.Indexes {
+			key := "KEY"
+			if index.Unique {
+				key = "UNIQUE KEY"
+			}
+			lines = append(lines, fmt.Sprintf("%s (%s)", key, d.quoteAll(index.Columns)))
+		}
+
+		fmt.Fprintf(b, "DROP TABLE IF EXISTS %s;\n", d.quote(table.Name))
+		fmt.Fprintf(b, "CREATE TABLE %s (\n    %s\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT=%s;\n",
+			d.quote(table.Name), strings.Join(lines, ",\n    "), literal(table.Comment))
+	}
+	return b.String()
+}
+
+func (d Mysql) Down(schema *Schema) string {
+	b := &strings.Builder{}
+	for _, table := range schema.Tables {
+		fmt.Fprintf(b, "DROP TABLE IF EXISTS %s;\n", d.quote(table.Name))
+	}
+	return b.String()
+}
+
+func (Mysql) quote(identifier string) string {
+	return "`" + identifier + "`"
+}
+
+func (d Mysql) quoteAll(identifiers []string) string {
+	quoted := make([]string, len(identifiers))
+	for i, identifier := range identifiers {
+		quoted[i] = d.quote(identifier)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// Postgres 產生 PostgreSQL DDL, 沒有 unsigned 整數, 以 BIGINT 保存完整範圍並以 CHECK 限制非負, 註解以 COMMENT ON 設定
+type Postgres struct{}
+
+func (Postgres) Driver() string { return "postgresql" }
+func (Postgres) Dir() string    { return "deployments/postgres/migration" }
+
+func (Postgres) ColumnType(column *Column) string {
+	switch column.Kind {
+	case Serial:
+		return "BIGSERIAL"
+	case Unsigned:
+		return "BIGINT"
+	case Varchar:
+		return fmt.Sprintf("VARCHAR(%d)", column.Length)
+	case Text:
+		return "TEXT"
+	case DateTime:
+		return "TIMESTAMP"
+	}
+	return ""
+}
+
+func (Postgres) InspectedType(column *Column) string {
+	switch column.Kind {
+	case Serial, Unsigned:
+		return "bigint"
+	case Varchar:
+		return fmt.Sprintf("character varying(%d)", column.Length)
+	case Text:
+		return "text"
+	case DateTime:
+		return "timestamp without time zone"
+	}
+	return ""
+}
+
+func (d Postgres) Up(schema *Schema) string {
+	b := &strings.Builder{}
+	for i, table := range schema.Tables {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		lines := []string{}
+		for _, column := range table.Columns {
+			line := fmt.Sprintf("%s %s NOT NULL", column.Name, d.ColumnType(column))
+			if column.Kind == Unsigned {
+				line += fmt.Sprintf(" CHECK (%s >= 0)", column.Name)
+			}
+			lines = append(lines, line)
+		}
+		lines = append(lines, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(table.PrimaryKey, ", ")))
+		indexes := []string{}
+		for _, index := range table.Indexes {
+			if index.Unique {
+				lines = append(lines, fmt.Sprintf("UNIQUE (%s)", strings.Join(index.Columns, ", ")))
+				continue
+			}
+			indexes = append(indexes, fmt.Sprintf("CREATE INDEX ON %s (%s);\n", table.Name, strings.Join(index.Columns, ", ")))
+		}
+
+		fmt.Fprintf(b, "DROP TABLE IF EXISTS %s;\n", table.Name)
+		fmt.Fprintf(b, "CREATE TABLE %s (\n    %s\n);\n", table.Name, strings.Join(lines, ",\n    "))
+		for _, index := range indexes {
+			b.WriteString(index)
+		}
+
+		fmt.Fprintf(b, "\nCOMMENT ON TABLE %s IS %s;\n", table.Name, literal(table.Comment))
+		for _, column := range table.Columns {
+			fmt.Fprintf(b, "COMMENT ON COLUMN %s.%s IS %s;\n", table.Name, column.Name, literal(column.Comment))
+		}
+	}
+	return b.String()
+}
+
+func (Postgres) Down(schema *Schema) string {
+	b := &strings.Builder{}
+	for _, table := range schema.Tables {
+		fmt.Fprintf(b, "DROP TABLE IF EXISTS %s;\n", table.Name)
+	}
+	return b.String()
+}
+
+func literal(text string) string {
+	return "'" + strings.ReplaceAll(text, "'", "''") + "'"
+}
@@ -0,0 +1,181 @@
+package ddl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Drift 問題種類
+const (
+	Missing  = "missing"  // 定義中有, 資料庫中沒有
+	Extra    = "extra"    // 資料庫中有, 定義中沒有
+	Mismatch = "mismatch" // 兩邊都有但內容不同
+)
+
+// Drift 為資料庫與 schema 定義之間的單一差異
+type Drift struct {
+	Table    string
+	Object   string // table, column, index, check
+	Name     string
+	Problem  string
+	Expected string
+	Actual   string
+}
+
+func (d *Drift) String() string {
+	switch d.Problem {
+	case Missing:
+		return fmt.Sprintf("%v %v %v is missing, expected %v", d.Table, d.Object, d.Name, d.Expected)
+	case Extra:
+		return fmt.Sprintf("%v %v %v is not defined: %v", d.Table, d.Object, d.Name, d.Actual)
+	default:
+		return fmt.Sprintf("%v %v %v is %v, expected %v", d.Table, d.Object, d.Name, d.Actual, d.Expected)
+	}
+}
+
+// displayWidth 為 MySQL 8.0.19 之後不再顯示的整數寬度, e.g. int(11) unsigned 顯示為 int unsigned
+var displayWidth = regexp.MustCompile(`\b(tinyint|smallint|mediumint|int|bigint)\(\d+\)`)
+
+func normalizeType(columnType string) string {
+	return displayWidth.ReplaceAllString(strings.ToLower(strings.TrimSpace(columnType)), "$1")
+}
+
+// normalizeCheck 移除括號與空白, e.g. CHECK ((amount >= 0)) -> CHECKamount>=0
+func normalizeCheck(definition string) string {
+	return strings.NewReplacer("(", "", ")", "", " ", "").Replace(strings.ToLower(definition))
+}
+
+// Compare 比較資料庫與 schema 定義, known 為其他 migration 或程式建立的資料表, 不視為多餘的資料表
+func Compare(schema *Schema, dialect Dialect, live *Live, known []string) []*Drift {
+	drifts := []*Drift{}
+
+	for _, expected := range schema.Tables {
+		actual, ok := live.Table(expected.Name)
+		if !ok {
+			drifts = append(drifts, &Drift{Table: expected.Name, Object: "table", Name: expected.Name, Problem: Missing, Expected: expected.Comment})
+			continue
+		}
+		drifts = append(drifts, compareTable(expected, actual, dialect)...)
+	}
+
+	ignored := map[string]bool{}
+	for _, name := range known {
+		ignored[name] = true
+	}
+	for _, actual := range live.Tables {
+		if _, ok := schema.Table(actual.Name); !ok && !ignored[actual.Name] {
+			drifts = append(drifts, &Drift{Table: actual.Name, Object: "table", Name: actual.Name, Problem: Extra, Actual: actual.Comment})
+		}
+	}
+
+	return drifts
+}
+
+func compareTable(expected *Table, actual *LiveTable, dialect Dialect) []*Drift {
+	drifts := []*Drift{}
+	mismatch := func(object, name, want, got string) {
+		if want != got {
+			drifts = append(drifts, &Drift{Table: expected.Name, Object: object, Name: name, Problem: Mismatch, Expected: want, Actual: got})
+		}
+	}
+
+	mismatch("table", "comment", expected.Comment, actual.Comment)
+
+	for _, column := range expected.Columns {
+		c, ok := actual.Column(column.Name)
+		if !ok {
+			drifts = append(drifts, &Drift{Table: expected.Name, Object: "column", Name: column.Name, Problem: Missing, Expected: dialect.InspectedType(column)})
+			continue
+		}
+		mismatch("column", column.Name+" type", normalizeType(dialect.InspectedType(column)), normalizeType(c.Type))
+		mismatch("column", column.Name+" nullable", "false", fmt.Sprint(c.Nullable))
+		mismatch("column", column.Name+" auto increment", fmt.Sprint(column.Kind == Serial), fmt.Sprint(c.AutoIncrement))
+		mismatch("column", column.Name+" comment", column.Comment, c.Comment)
+	}
+	for _, c := range actual.Columns {
+		if _, ok := expected.Column(c.Name); !ok {
+			drifts = append(drifts, &Drift{Table: expected.Name, Object: "column", Name: c.Name, Problem: Extra, Actual: c.Type})
+		}
+	}
+
+	mismatch("index", "primary key", "("+strings.Join(expected.PrimaryKey, ", ")+")", "("+strings.Join(actual.PrimaryKey(), ", ")+")")
+	drifts = append(drifts, compareIndexes(expected, actual)...)
+
+	if dialect.Driver() == "postgresql" {
+		drifts = append(drifts, compareChecks(expected, actual)...)
+	}
+
+	return drifts
+}
+
+// compareIndexes 以欄位與是否唯一比對索引, 定義中的索引不指定名稱
+func compareIndexes(expected *Table, actual *LiveTable) []*Drift {
+	describe := func(unique bool, columns []string) string {
+		kind := "INDEX"
+		if unique {
+			kind = "UNIQUE"
+		}
+		return fmt.Sprintf("%v (%v)", kind, strings.Join(columns, ", "))
+	}
+
+	drifts := []*Drift{}
+	matched := map[*LiveIndex]bool{}
+	for _, index := range expected.Indexes {
+		var found *LiveIndex
+		for _, live := range actual.Indexes {
+			if !live.Primary && !matched[live] && strings.Join(live.Columns, ",") == strings.Join(index.Columns, ",") {
+				found = live
+				break
+			}
+		}
+
+		want := describe(index.Unique, index.Columns)
+		switch {
+		case found == nil:
+			drifts = append(drifts, &Drift{Table: expected.Name, Object: "index", Name: strings.Join(index.Columns, "_"), Problem: Missing, Expected: want})
+		case found.Unique != index.Unique:
+			matched[found] = true
+			drifts = append(drifts, &Drift{Table: expected.Name, Object: "index", Name: found.Name, Problem: Mismatch, Expected: want, Actual: describe(found.Unique, found.Columns)})
+		default:
+			matched[found] = true
+		}
+	}
+
+	for _, live := range actual.Indexes {
+		if !live.Primary && !matched[live] {
+			drifts = append(drifts, &Drift{Table: expected.Name, Object: "index", Name: live.Name, Problem: Extra, Actual: describe(live.Unique, live.Columns)})
+		}
+	}
+	return drifts
+}
+
+// compareChecks 比對 PostgreSQL 非負整數欄位的 CHECK 限制
+func compareChecks(expected *Table, actual *LiveTable) []*Drift {
+	drifts := []*Drift{}
+	matched := map[*LiveCheck]bool{}
+	for _, column := range expected.Columns {
+		if column.Kind != Unsigned {
+			continue
+		}
+
+		want := fmt.Sprintf("CHECK (%s >= 0)", column.Name)
+		found := false
+		for _, check := range actual.Checks {
+			if normalizeCheck(check.Definition) == normalizeCheck(want) {
+				matched[check] = true
+				found = true
+			}
+		}
+		if !found {
+			drifts = append(drifts, &Drift{Table: expected.Name, Object: "check", Name: column.Name, Problem: Missing, Expected: want})
+		}
+	}
+
+	for _, check := range actual.Checks {
+		if !matched[check] {
+			drifts = append(drifts, &Drift{Table: expected.Name, Object: "check", Name: check.Name, Problem: Extra, Actual: check.Definition})
+		}
+	}
+	return drifts
+}
@@ -0,0 +1,289 @@
+package ddl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Live 為連線中資料庫實際的資料表結構
+type Live struct {
+	Driver    string       `json:"driver" yaml:"driver"`
+	Namespace string       `json:"namespace" yaml:"namespace"`
+	Tables    []*LiveTable `json:"tables" yaml:"tables"`
+}
+
+// LiveTable 為資料庫中實際的資料表
+type LiveTable struct {
+	Name    string        `json:"name" yaml:"name"`
+	Comment string        `json:"comment" yaml:"comment"`
+	Rows    int64         `json:"rows" yaml:"rows"`   // 統計資訊估計的資料列數量, 不是精確的 COUNT(*), PostgreSQL 尚未 ANALYZE 時為 0
+	Bytes   int64         `json:"bytes" yaml:"bytes"` // 資料與索引佔用的空間
+	Columns []*LiveColumn `json:"columns" yaml:"columns"`
+	Indexes []*LiveIndex  `json:"indexes" yaml:"indexes"`
+	Checks  []*LiveCheck  `json:"checks,omitempty" yaml:"checks,omitempty"` // PostgreSQL CHECK 限制, MySQL 不讀取
+}
+
+// LiveColumn 為資料庫中實際的欄位
+type LiveColumn struct {
+	Name          string  `json:"name" yaml:"name"`
+	Type          string  `json:"type" yaml:"type"` // MySQL 為 COLUMN_TYPE, PostgreSQL 為 format_type
+	Nullable      bool    `json:"nullable" yaml:"nullable"`
+	Default       *string `json:"default" yaml:"default"`
+	AutoIncrement bool    `json:"auto_increment" yaml:"auto_increment"` // MySQL AUTO_INCREMENT 或 PostgreSQL nextval 預設值
+	Comment       string  `json:"comment" yaml:"comment"`
+}
+
+// LiveIndex 為資料庫中實際的索引, 主鍵也是一個索引
+type LiveIndex struct {
+	Name    string   `json:"name" yaml:"name"`
+	Columns []string `json:"columns" yaml:"columns"`
+	Unique  bool     `json:"unique" yaml:"unique"`
+	Primary bool     `json:"primary" yaml:"primary"`
+}
+
+// LiveCheck 為資料庫中實際的 CHECK 限制
+type LiveCheck struct {
+	Name       string `json:"name" yaml:"name"`
+	Definition string `json:"definition" yaml:"definition"`
+}
+
+// Table 以名稱取得資料表
+func (l *Live) Table(name string) (*LiveTable, bool) {
+	for _, table := range l.Tables {
+		if table.Name == name {
+			return table, true
+		}
+	}
+	return nil, false
+}
+
+// Column 以名稱取得欄位
+func (t *LiveTable) Column(name string) (*LiveColumn, bool) {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return nil, false
+}
+
+// PrimaryKey 回傳主鍵欄位, 沒有主鍵時回傳 nil
+func (t *LiveTable) PrimaryKey() []string {
+	for _, index := range t.Indexes {
+		if index.Primary {
+			return index.Columns
+		}
+	}
+	return nil
+}
+
+// Inspect 由 information_schema (MySQL) 或 pg_catalog (PostgreSQL) 讀取 namespace 中所有資料表的結構
+func Inspect(ctx context.Context, db *sql.DB, driver, namespace string) (*Live, error) {
+	live := &Live{Driver: driver, Namespace: namespace}
+
+	var err error
+	switch driver {
+	case "mysql":
+		err = inspectMysql(ctx, db, live)
+	case "postgresql":
+		err = inspectPostgres(ctx, db, live)
+	default:
+		err = fmt.Errorf("driver %v is undifined", driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return live, nil
+}
+
+func inspectMysql(ctx context.Context, db *sql.DB, live *Live) error {
+	tables := map[string]*LiveTable{}
+
+	err := query(ctx, db, func(rows *sql.Rows) error {
+		t := &LiveTable{}
+		if err := rows.Scan(&t.Name, &t.Comment, &t.Rows, &t.Bytes); err != nil {
+			return err
+		}
+		tables[t.Name] = t
+		live.Tables = append(live.Tables, t)
+		return nil
+	}, `
+	SELECT TABLE_NAME, TABLE_COMMENT, COALESCE(TABLE_ROWS, 0), COALESCE(DATA_LENGTH, 0) + COALESCE(INDEX_LENGTH, 0)
+	FROM information_schema.TABLES
+	WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'BASE TABLE'
+	ORDER BY TABLE_NAME`, live.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to inspect tables: %w", err)
+	}
+
+	err = query(ctx, db, func(rows *sql.Rows) error {
+		var table, nullable, extra string
+		c := &LiveColumn{}
+		if err := rows.Scan(&table, &c.Name, &c.Type, &nullable, &c.Default, &extra, &c.Comment); err != nil {
+			return err
+		}
+		c.Nullable = nullable == "YES"
+		c.AutoIncrement = strings.Contains(strings.ToLower(extra), "auto_increment")
+		if t, ok := tables[table]; ok {
+			t.Columns = append(t.Columns, c)
+		}
+		return nil
+	}, `
+	SELECT TABLE_NAME, COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT, EXTRA, COLUMN_COMMENT
+	FROM information_schema.COLUMNS
+	WHERE TABLE_SCHEMA = ?
+	ORDER BY TABLE_NAME, ORDINAL_POSITION`, live.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to inspect columns: %w", err)
+	}
+
+	indexes := map[string]*LiveIndex{}
+	err = query(ctx, db, func(rows *sql.Rows) error {
+		var table, name, column string
+		var nonUnique bool
+		if err := rows.Scan(&table, &name, &nonUnique, &column); err != nil {
+			return err
+		}
+		t, ok := tables[table]
+		if !ok {
+			return nil
+		}
+		index, ok := indexes[table+"."+name]
+		if !ok {
+			index = &LiveIndex{Name: name, Unique: !nonUnique, Primary: name == "PRIMARY"}
+			indexes[table+"."+name] = index
+			t.Indexes = append(t.Indexes, index)
+		}
+		index.Columns = append(index.Columns, column)
+		return nil
+	}, `
+	SELECT TABLE_NAME, INDEX_NAME, NON_UNIQUE, COLUMN_NAME
+	FROM information_schema.STATISTICS
+	WHERE TABLE_SCHEMA = ?
+	ORDER BY TABLE_NAME, INDEX_NAME, SEQ_IN_INDEX`, live.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to inspect indexes: %w", err)
+	}
+
+	return nil
+}
+
+func inspectPostgres(ctx context.Context, db *sql.DB, live *Live) error {
+	tables := map[string]*LiveTable{}
+
+	err := query(ctx, db, func(rows *sql.Rows) error {
+		t := &LiveTable{}
+		if err := rows.Scan(&t.Name, &t.Comment, &t.Rows, &t.Bytes); err != nil {
+			return err
+		}
+		tables[t.Name] = t
+		live.Tables = append(live.Tables, t)
+		return nil
+	}, `
+	SELECT c.relname, COALESCE(obj_description(c.oid, 'pg_class'), ''),
+		GREATEST(c.reltuples, 0)::bigint, pg_total_relation_size(c.oid)
+	FROM pg_class c
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname = $1 AND c.relkind IN ('r', 'p')
+	ORDER BY c.relname`, live.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to inspect tables: %w", err)
+	}
+
+	err = query(ctx, db, func(rows *sql.Rows) error {
+		var table string
+		c := &LiveColumn{}
+		if err := rows.Scan(&table, &c.Name, &c.Type, &c.Nullable, &c.Default, &c.Comment); err != nil {
+			return err
+		}
+		c.AutoIncrement = c.Default != nil && strings.HasPrefix(*c.Default, "nextval(")
+		if t, ok := tables[table]; ok {
+			t.Columns = append(t.Columns, c)
+		}
+		return nil
+	}, `
+	SELECT c.relname, a.attname, format_type(a.atttypid, a.atttypmod), NOT a.attnotnull,
+		pg_get_expr(d.adbin, d.adrelid), COALESCE(col_description(c.oid, a.attnum), '')
+	FROM pg_attribute a
+	JOIN pg_class c ON c.oid = a.attrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+	WHERE n.nspname = $1 AND c.relkind IN ('r', 'p') AND a.attnum > 0 AND NOT a.attisdropped
+	ORDER BY c.relname, a.attnum`, live.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to inspect columns: %w", err)
+	}
+
+	indexes := map[string]*LiveIndex{}
+	err = query(ctx, db, func(rows *sql.Rows) error {
+		var table, name, column string
+		var unique, primary bool
+		if err := rows.Scan(&table, &name, &unique, &primary, &column); err != nil {
+			return err
+		}
+		t, ok := tables[table]
+		if !ok {
+			return nil
+		}
+		index, ok := indexes[name]
+		if !ok {
+			index = &LiveIndex{Name: name, Unique: unique, Primary: primary}
+			indexes[name] = index
+			t.Indexes = append(t.Indexes, index)
+		}
+		index.Columns = append(index.Columns, column)
+		return nil
+	}, `
+	SELECT t.relname, i.relname, ix.indisunique, ix.indisprimary, a.attname
+	FROM pg_index ix
+	JOIN pg_class t ON t.oid = ix.indrelid
+	JOIN pg_class i ON i.oid = ix.indexrelid
+	JOIN pg_namespace n ON n.oid = t.relnamespace
+	JOIN LATERAL unnest(ix.indkey::smallint[]) WITH ORDINALITY AS k(attnum, ord) ON true
+	JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+	WHERE n.nspname = $1
+	ORDER BY t.relname, i.relname, k.ord`, live.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to inspect indexes: %w", err)
+	}
+
+	err = query(ctx, db, func(rows *sql.Rows) error {
+		var table string
+		check := &LiveCheck{}
+		if err := rows.Scan(&table, &check.Name, &check.Definition); err != nil {
+			return err
+		}
+		if t, ok := tables[table]; ok {
+			t.Checks = append(t.Checks, check)
+		}
+		return nil
+	}, `
+	SELECT t.relname, con.conname, pg_get_constraintdef(con.oid)
+	FROM pg_constraint con
+	JOIN pg_class t ON t.oid = con.conrelid
+	JOIN pg_namespace n ON n.oid = t.relnamespace
+	WHERE n.nspname = $1 AND con.contype = 'c'
+	ORDER BY t.relname, con.conname`, live.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to inspect checks: %w", err)
+	}
+
+	return nil
+}
+
+func query(ctx context.Context, db *sql.DB, scan func(rows *sql.Rows) error, statement string, args ...interface{}) error {
+	rows, err := db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
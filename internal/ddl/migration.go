@@ -0,0 +1,30 @@
+package ddl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrDrift 表示依序執行 migrations 後的資料表與 schema 定義不同
+var ErrDrift = errors.New("migrations drifted from schema definition")
+
+// Check 以 Replay 依序套用 dialect 的 up migrations, 與 schema 定義比較, 不一致時回傳 ErrDrift 並列出每個差異
+// 已發布的 migration 不可修改, 定義改變時必須新增 migration, known 為 migrations 建立但不在定義中的資料表
+func Check(schema *Schema, dialect Dialect, ups []string, known []string) error {
+	live, err := Replay(dialect.Driver(), ups)
+	if err != nil {
+		return err
+	}
+
+	drifts := Compare(schema, dialect, live, known)
+	if len(drifts) == 0 {
+		return nil
+	}
+
+	problems := make([]string, len(drifts))
+	for i, drift := range drifts {
+		problems[i] = drift.String()
+	}
+	return fmt.Errorf("%w, please add a migration to %v:\n  %v", ErrDrift, dialect.Dir(), strings.Join(problems, "\n  "))
+}
@@ -0,0 +1,766 @@
+package ddl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Replay 依序將 up migrations 套用到空的資料庫結構, 不需要連線即可得到 migrate up 後的資料表, 欄位型別與 Inspect 讀出的格式相同
+// 只支援 migrations 中使用的 DDL, 遇到無法解析的語句時回傳錯誤, 避免忽略語句後誤判為一致
+func Replay(driver string, ups []string) (*Live, error) {
+	if driver != "mysql" && driver != "postgresql" {
+		return nil, fmt.Errorf("driver %v is undifined", driver)
+	}
+
+	r := &replayer{live: &Live{Driver: driver}}
+	for i, up := range ups {
+		for _, statement := range splitStatements(up) {
+			if err := r.apply(statement); err != nil {
+				return nil, fmt.Errorf("failed to replay migration %d: %w", i+1, err)
+			}
+		}
+	}
+	return r.live, nil
+}
+
+type replayer struct {
+	live *Live
+}
+
+func (r *replayer) apply(statement string) error {
+	p := &parser{tokens: lex(statement)}
+
+	var err error
+	switch {
+	case p.accept("CREATE", "DATABASE"), p.accept("CREATE", "SCHEMA"), p.accept("ALTER", "SEQUENCE"), p.accept("CREATE", "SEQUENCE"):
+		return nil
+	case p.accept("CREATE", "TABLE"):
+		err = r.createTable(p)
+	case p.accept("DROP", "TABLE"):
+		p.accept("IF", "EXISTS")
+		for _, names := range p.split() {
+			r.dropTable(names.word())
+		}
+	case p.accept("ALTER", "TABLE"):
+		err = r.alterTable(p)
+	case p.accept("COMMENT", "ON", "TABLE"):
+		err = r.commentTable(p)
+	case p.accept("COMMENT", "ON", "COLUMN"):
+		err = r.commentColumn(p)
+	case p.accept("CREATE", "UNIQUE", "INDEX"):
+		err = r.createIndex(p, true)
+	case p.accept("CREATE", "INDEX"):
+		err = r.createIndex(p, false)
+	case p.accept("DROP", "INDEX"):
+		err = r.dropIndex(p)
+	default:
+		err = fmt.Errorf("statement is undifined")
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", err, firstLine(statement))
+	}
+	return nil
+}
+
+func (r *replayer) table(name string) (*LiveTable, error) {
+	table, ok := r.live.Table(name)
+	if !ok {
+		return nil, fmt.Errorf("table %v does not exist", name)
+	}
+	return table, nil
+}
+
+func (r *replayer) createTable(p *parser) error {
+	p.accept("IF", "NOT", "EXISTS")
+	name := p.word()
+	if _, ok := r.live.Table(name); ok {
+		return fmt.Errorf("table %v already exists", name)
+	}
+	body, ok := p.group()
+	if !ok {
+		return fmt.Errorf("definition of table %v is missing", name)
+	}
+
+	table := &LiveTable{Name: name}
+	r.live.Tables = append(r.live.Tables, table)
+	for _, definition := range (&parser{tokens: lex(body)}).split() {
+		if err := r.addDefinition(table, definition); err != nil {
+			return err
+		}
+	}
+
+	// MySQL 的資料表選項, e.g. ENGINE=InnoDB COMMENT='用戶資訊'
+	for !p.done() {
+		if p.accept("COMMENT") {
+			p.accept("=")
+			table.Comment = p.string()
+			continue
+		}
+		p.next()
+	}
+	return nil
+}
+
+func (r *replayer) dropTable(name string) {
+	for i, table := range r.live.Tables {
+		if table.Name == name {
+			r.live.Tables = append(r.live.Tables[:i], r.live.Tables[i+1:]...)
+			return
+		}
+	}
+}
+
+// addDefinition 加入 CREATE TABLE 或 ALTER TABLE ADD 中的欄位或限制
+func (r *replayer) addDefinition(table *LiveTable, p *parser) error {
+	if p.accept("CONSTRAINT") {
+		return r.addConstraint(table, p, p.word())
+	}
+	switch {
+	case p.peek("PRIMARY"), p.peek("UNIQUE"), p.peek("KEY"), p.peek("INDEX"), p.peek("CHECK"), p.peek("FOREIGN"):
+		return r.addConstraint(table, p, "")
+	}
+
+	p.accept("COLUMN")
+	p.accept("IF", "NOT", "EXISTS")
+	column, err := r.column(table, p)
+	if err != nil {
+		return err
+	}
+	if _, ok := table.Column(column.Name); ok {
+		return fmt.Errorf("column %v.%v already exists", table.Name, column.Name)
+	}
+	table.Columns = append(table.Columns, column)
+	return nil
+}
+
+// columnAttributes 為欄位型別之後的屬性關鍵字
+var columnAttributes = map[string]bool{
+	"NOT": true, "NULL": true, "AUTO_INCREMENT": true, "PRIMARY": true, "UNIQUE": true, "CHECK": true,
+	"COMMENT": true, "DEFAULT": true, "REFERENCES": true, "CONSTRAINT": true, "COLLATE": true, "CHARACTER": true,
+	"FIRST": true, "AFTER": true, "USING": true,
+}
+
+// column 解析欄位定義, 欄位上的主鍵, 唯一與 CHECK 限制直接加入 table
+func (r *replayer) column(table *LiveTable, p *parser) (*LiveColumn, error) {
+	column := &LiveColumn{Name: p.word(), Nullable: true}
+	if column.Name == "" {
+		return nil, fmt.Errorf("column of table %v has no name", table.Name)
+	}
+	column.Type, column.AutoIncrement = r.columnType(p)
+	if column.Type == "" {
+		return nil, fmt.Errorf("column %v.%v has no type", table.Name, column.Name)
+	}
+
+	for !p.done() {
+		switch {
+		case p.accept("NOT", "NULL"):
+			column.Nullable = false
+		case p.accept("NULL"):
+			column.Nullable = true
+		case p.accept("AUTO_INCREMENT"):
+			column.AutoIncrement = true
+		case p.accept("PRIMARY", "KEY"):
+			column.Nullable = false
+			r.addIndex(table, "", []string{column.Name}, true, true)
+		case p.accept("UNIQUE"):
+			p.accept("KEY")
+			r.addIndex(table, "", []string{column.Name}, true, false)
+		case p.accept("CHECK"):
+			expression, _ := p.group()
+			table.Checks = append(table.Checks, &LiveCheck{Name: table.Name + "_" + column.Name + "_check", Definition: "CHECK (" + expression + ")"})
+		case p.accept("COMMENT"):
+			column.Comment = p.string()
+		case p.accept("DEFAULT"):
+			value := p.next().text
+			column.Default = &value
+		default:
+			p.next()
+		}
+	}
+	return column, nil
+}
+
+// columnType 讀取到第一個屬性關鍵字為止的型別, 轉換成 Inspect 讀出的格式, e.g. SERIAL -> integer 並自動遞增
+func (r *replayer) columnType(p *parser) (string, bool) {
+	words := []string{}
+	for !p.done() && !(len(words) > 0 && p.tokens[0].kind == tokenWord && columnAttributes[strings.ToUpper(p.tokens[0].text)]) {
+		t := p.next()
+		if t.kind == tokenGroup && len(words) > 0 {
+			words[len(words)-1] += "(" + strings.ReplaceAll(t.text, " ", "") + ")"
+			continue
+		}
+		words = append(words, strings.ToLower(t.text))
+	}
+	columnType := strings.Join(words, " ")
+
+	if r.live.Driver == "mysql" {
+		if columnType == "integer" {
+			return "int", false
+		}
+		if columnType == "bool" || columnType == "boolean" {
+			return "tinyint(1)", false
+		}
+		return columnType, false
+	}
+
+	base, args := columnType, ""
+	if i := strings.Index(columnType, "("); i >= 0 {
+		base, args = columnType[:i], columnType[i:]
+	}
+	switch base {
+	case "serial", "serial4":
+		return "integer", true
+	case "bigserial", "serial8":
+		return "bigint", true
+	case "smallserial", "serial2":
+		return "smallint", true
+	case "int", "int4", "integer":
+		return "integer", false
+	case "int8", "bigint":
+		return "bigint", false
+	case "int2", "smallint":
+		return "smallint", false
+	case "varchar", "character varying":
+		return "character varying" + args, false
+	case "char", "character":
+		return "character" + args, false
+	case "decimal", "numeric":
+		return "numeric" + args, false
+	case "float8", "double precision":
+		return "double precision", false
+	case "bool", "boolean":
+		return "boolean", false
+	case "timestamp", "timestamp without time zone":
+		return "timestamp without time zone", false
+	case "timestamptz", "timestamp with time zone":
+		return "timestamp with time zone", false
+	}
+	return columnType, false
+}
+
+// addConstraint 加入資料表層級的主鍵, 索引或 CHECK 限制, name 為空時依照資料庫的規則命名
+func (r *replayer) addConstraint(table *LiveTable, p *parser, name string) error {
+	switch {
+	case p.accept("PRIMARY", "KEY"):
+		columns, _ := p.group()
+		r.addIndex(table, name, splitColumns(columns), true, true)
+	case p.accept("UNIQUE"):
+		if !p.accept("KEY") {
+			p.accept("INDEX")
+		}
+		if !p.done() && p.tokens[0].kind == tokenWord {
+			name = p.word()
+		}
+		columns, _ := p.group()
+		r.addIndex(table, name, splitColumns(columns), true, false)
+	case p.accept("KEY"), p.accept("INDEX"):
+		if !p.done() && p.tokens[0].kind == tokenWord {
+			name = p.word()
+		}
+		columns, _ := p.group()
+		r.addIndex(table, name, splitColumns(columns), false, false)
+	case p.accept("CHECK"):
+		expression, _ := p.group()
+		if name == "" {
+			name = table.Name + "_check"
+		}
+		table.Checks = append(table.Checks, &LiveCheck{Name: name, Definition: "CHECK (" + expression + ")"})
+	case p.accept("FOREIGN", "KEY"):
+		// 比對不包含外鍵
+	default:
+		return fmt.Errorf("constraint of table %v is undifined", table.Name)
+	}
+	return nil
+}
+
+// addIndex 以資料庫預設的規則命名, MySQL 為 PRIMARY 或第一個欄位, PostgreSQL 為 <table>_pkey, <table>_<columns>_key 或 _idx
+func (r *replayer) addIndex(table *LiveTable, name string, columns []string, unique, primary bool) {
+	if name == "" {
+		switch {
+		case r.live.Driver == "mysql" && primary:
+			name = "PRIMARY"
+		case r.live.Driver == "mysql":
+			name = columns[0]
+		case primary:
+			name = table.Name + "_pkey"
+		case unique:
+			name = table.Name + "_" + strings.Join(columns, "_") + "_key"
+		default:
+			name = table.Name + "_" + strings.Join(columns, "_") + "_idx"
+		}
+	}
+	if primary {
+		for _, column := range columns {
+			if c, ok := table.Column(column); ok {
+				c.Nullable = false
+			}
+		}
+	}
+	table.Indexes = append(table.Indexes, &LiveIndex{Name: name, Columns: columns, Unique: unique, Primary: primary})
+}
+
+func (r *replayer) alterTable(p *parser) error {
+	p.accept("IF", "EXISTS")
+	p.accept("ONLY")
+	table, err := r.table(p.word())
+	if err != nil {
+		return err
+	}
+
+	for _, action := range p.split() {
+		if err := r.alterAction(table, action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *replayer) alterAction(table *LiveTable, p *parser) error {
+	switch {
+	case p.accept("ADD"):
+		return r.addDefinition(table, p)
+	case p.accept("DROP", "CONSTRAINT"):
+		p.accept("IF", "EXISTS")
+		name := p.word()
+		removeIndexes(table, func(index *LiveIndex) bool { return index.Name == name })
+		removeChecks(table, func(check *LiveCheck) bool { return check.Name == name })
+	case p.accept("DROP", "PRIMARY", "KEY"):
+		removeIndexes(table, func(index *LiveIndex) bool { return index.Primary })
+	case p.accept("DROP", "INDEX"), p.accept("DROP", "KEY"):
+		name := p.word()
+		removeIndexes(table, func(index *LiveIndex) bool { return index.Name == name })
+	case p.accept("DROP"):
+		p.accept("COLUMN")
+		p.accept("IF", "EXISTS")
+		return r.dropColumn(table, p.word())
+	case p.accept("ALTER"):
+		p.accept("COLUMN")
+		return r.alterColumn(table, p)
+	case p.accept("MODIFY"):
+		p.accept("COLUMN")
+		if p.done() {
+			return fmt.Errorf("column of table %v is missing", table.Name)
+		}
+		return r.replaceColumn(table, p.tokens[0].text, p)
+	case p.accept("CHANGE"):
+		p.accept("COLUMN")
+		return r.replaceColumn(table, p.word(), p)
+	case p.accept("RENAME", "COLUMN"):
+		from := p.word()
+		p.accept("TO")
+		return r.renameColumn(table, from, p.word())
+	case p.accept("RENAME"):
+		if !p.accept("TO") {
+			p.accept("AS")
+		}
+		table.Name = p.word()
+	case p.accept("COMMENT"):
+		p.accept("=")
+		table.Comment = p.string()
+	default:
+		return fmt.Errorf("alter action on table %v is undifined", table.Name)
+	}
+	return nil
+}
+
+func (r *replayer) alterColumn(table *LiveTable, p *parser) error {
+	name := p.word()
+	column, ok := table.Column(name)
+	if !ok {
+		return fmt.Errorf("column %v.%v does not exist", table.Name, name)
+	}
+
+	switch {
+	case p.accept("TYPE"), p.accept("SET", "DATA", "TYPE"):
+		// 變更型別不影響 SERIAL 的 nextval 預設值
+		columnType, _ := r.columnType(p)
+		column.Type = columnType
+	case p.accept("SET", "NOT", "NULL"):
+		column.Nullable = false
+	case p.accept("DROP", "NOT", "NULL"):
+		column.Nullable = true
+	case p.accept("SET", "DEFAULT"):
+		value := p.next().text
+		column.Default = &value
+	case p.accept("DROP", "DEFAULT"):
+		column.Default = nil
+		column.AutoIncrement = false
+	default:
+		return fmt.Errorf("alter column %v.%v is undifined", table.Name, name)
+	}
+	return nil
+}
+
+// replaceColumn 以新的定義取代欄位並保留位置, 與 MySQL MODIFY 相同, 未指定的屬性 (e.g. COMMENT) 不會保留
+func (r *replayer) replaceColumn(table *LiveTable, name string, p *parser) error {
+	for i, current := range table.Columns {
+		if current.Name != name {
+			continue
+		}
+		column, err := r.column(table, p)
+		if err != nil {
+			return err
+		}
+		table.Columns[i] = column
+		if column.Name != name {
+			renameIndexColumns(table, name, column.Name)
+		}
+		return nil
+	}
+	return fmt.Errorf("column %v.%v does not exist", table.Name, name)
+}
+
+func (r *replayer) renameColumn(table *LiveTable, from, to string) error {
+	column, ok := table.Column(from)
+	if !ok {
+		return fmt.Errorf("column %v.%v does not exist", table.Name, from)
+	}
+	column.Name = to
+	renameIndexColumns(table, from, to)
+	return nil
+}
+
+// dropColumn 與資料庫相同, 一併移除包含此欄位的索引與 CHECK 限制
+func (r *replayer) dropColumn(table *LiveTable, name string) error {
+	for i, column := range table.Columns {
+		if column.Name != name {
+			continue
+		}
+		table.Columns = append(table.Columns[:i], table.Columns[i+1:]...)
+		removeIndexes(table, func(index *LiveIndex) bool {
+			for _, c := range index.Columns {
+				if c == name {
+					return true
+				}
+			}
+			return false
+		})
+		reference := regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\b`)
+		removeChecks(table, func(check *LiveCheck) bool { return reference.MatchString(check.Definition) })
+		return nil
+	}
+	return fmt.Errorf("column %v.%v does not exist", table.Name, name)
+}
+
+func (r *replayer) commentTable(p *parser) error {
+	table, err := r.table(p.word())
+	if err != nil {
+		return err
+	}
+	if !p.accept("IS") {
+		return fmt.Errorf("comment of table %v is missing", table.Name)
+	}
+	table.Comment = p.string()
+	return nil
+}
+
+func (r *replayer) commentColumn(p *parser) error {
+	target := p.word()
+	i := strings.LastIndex(target, ".")
+	if i < 0 {
+		return fmt.Errorf("column %v has no table", target)
+	}
+	table, err := r.table(target[:i])
+	if err != nil {
+		return err
+	}
+	column, ok := table.Column(target[i+1:])
+	if !ok {
+		return fmt.Errorf("column %v does not exist", target)
+	}
+	if !p.accept("IS") {
+		return fmt.Errorf("comment of column %v is missing", target)
+	}
+	column.Comment = p.string()
+	return nil
+}
+
+// createIndex 解析 CREATE [UNIQUE] INDEX [name] ON table (columns)
+func (r *replayer) createIndex(p *parser, unique bool) error {
+	p.accept("CONCURRENTLY")
+	p.accept("IF", "NOT", "EXISTS")
+	name := ""
+	if !p.peek("ON") {
+		name = p.word()
+	}
+	if !p.accept("ON") {
+		return fmt.Errorf("table of index %v is missing", name)
+	}
+	table, err := r.table(p.word())
+	if err != nil {
+		return err
+	}
+	if p.accept("USING") {
+		p.next()
+	}
+	columns, _ := p.group()
+	r.addIndex(table, name, splitColumns(columns), unique, false)
+	return nil
+}
+
+// dropIndex 解析 DROP INDEX [IF EXISTS] name [ON table], PostgreSQL 的索引名稱在 schema 中唯一
+func (r *replayer) dropIndex(p *parser) error {
+	p.accept("CONCURRENTLY")
+	p.accept("IF", "EXISTS")
+	name := p.word()
+	for _, table := range r.live.Tables {
+		removeIndexes(table, func(index *LiveIndex) bool { return index.Name == name })
+	}
+	return nil
+}
+
+func removeIndexes(table *LiveTable, match func(index *LiveIndex) bool) {
+	kept := []*LiveIndex{}
+	for _, index := range table.Indexes {
+		if !match(index) {
+			kept = append(kept, index)
+		}
+	}
+	table.Indexes = kept
+}
+
+func removeChecks(table *LiveTable, match func(check *LiveCheck) bool) {
+	kept := []*LiveCheck{}
+	for _, check := range table.Checks {
+		if !match(check) {
+			kept = append(kept, check)
+		}
+	}
+	table.Checks = kept
+}
+
+func renameIndexColumns(table *LiveTable, from, to string) {
+	for _, index := range table.Indexes {
+		for i, column := range index.Columns {
+			if column == from {
+				index.Columns[i] = to
+			}
+		}
+	}
+}
+
+// splitColumns 解析索引的欄位, 移除引號, 長度與排序, e.g. `account`(16) DESC -> account
+func splitColumns(group string) []string {
+	columns := []string{}
+	for _, column := range (&parser{tokens: lex(group)}).split() {
+		columns = append(columns, column.word())
+	}
+	return columns
+}
+
+func firstLine(statement string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(statement), "\n")
+	return line
+}
+
+// splitStatements 以分號切開 SQL, 忽略字串與識別字中的分號及 -- 註解
+func splitStatements(sql string) []string {
+	statements := []string{}
+	b := &strings.Builder{}
+	var quote rune
+	runes := []rune(sql)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote != 0:
+			b.WriteRune(c)
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			b.WriteRune(c)
+		case c == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			b.WriteRune('\n')
+		case c == ';':
+			statements = append(statements, b.String())
+			b.Reset()
+		default:
+			b.WriteRune(c)
+		}
+	}
+	statements = append(statements, b.String())
+
+	nonEmpty := []string{}
+	for _, statement := range statements {
+		if strings.TrimSpace(statement) != "" {
+			nonEmpty = append(nonEmpty, statement)
+		}
+	}
+	return nonEmpty
+}
+
+const (
+	tokenWord   = iota // 關鍵字或識別字, 引號包住的識別字已移除引號
+	tokenString        // 字串, 已移除引號
+	tokenGroup         // 括號中的內容, 不含最外層的括號
+	tokenSymbol        // 逗號, 等號等符號
+)
+
+type token struct {
+	kind int
+	text string
+}
+
+// lex 將語句切成 token, 括號中的內容視為單一 token, 由呼叫端再次 lex
+func lex(statement string) []*token {
+	tokens := []*token{}
+	runes := []rune(statement)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'':
+			b := &strings.Builder{}
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						b.WriteRune('\'')
+						i++
+						continue
+					}
+					i++
+					break
+				}
+				b.WriteRune(runes[i])
+			}
+			tokens = append(tokens, &token{kind: tokenString, text: b.String()})
+		case c == '"' || c == '`':
+			end := i + 1
+			for end < len(runes) && runes[end] != c {
+				end++
+			}
+			text := string(runes[i+1 : end])
+			// 接在引號識別字之後的 .column 屬於同一個識別字
+			for end+1 < len(runes) && runes[end+1] == '.' {
+				next := end + 2
+				for next < len(runes) && isWordRune(runes[next]) {
+					next++
+				}
+				text += string(runes[end+1 : next])
+				end = next - 1
+			}
+			tokens = append(tokens, &token{kind: tokenWord, text: text})
+			i = end + 1
+		case c == '(':
+			depth, end := 1, i+1
+			var quote rune
+			for ; end < len(runes) && depth > 0; end++ {
+				switch {
+				case quote != 0:
+					if runes[end] == quote {
+						quote = 0
+					}
+				case runes[end] == '\'' || runes[end] == '"' || runes[end] == '`':
+					quote = runes[end]
+				case runes[end] == '(':
+					depth++
+				case runes[end] == ')':
+					depth--
+				}
+			}
+			tokens = append(tokens, &token{kind: tokenGroup, text: strings.TrimSpace(string(runes[i+1 : end-1]))})
+			i = end
+		case isWordRune(c):
+			end := i
+			for end < len(runes) && (isWordRune(runes[end]) || runes[end] == '.' || runes[end] == ':') {
+				end++
+			}
+			tokens = append(tokens, &token{kind: tokenWord, text: string(runes[i:end])})
+			i = end
+		default:
+			tokens = append(tokens, &token{kind: tokenSymbol, text: string(c)})
+			i++
+		}
+	}
+	return tokens
+}
+
+func isWordRune(c rune) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c > 0x7f
+}
+
+type parser struct {
+	tokens []*token
+}
+
+func (p *parser) done() bool {
+	return len(p.tokens) == 0
+}
+
+func (p *parser) next() *token {
+	if p.done() {
+		return &token{}
+	}
+	t := p.tokens[0]
+	p.tokens = p.tokens[1:]
+	return t
+}
+
+// peek 回傳下一個 token 是否為關鍵字 keyword
+func (p *parser) peek(keyword string) bool {
+	return !p.done() && (p.tokens[0].kind == tokenWord || p.tokens[0].kind == tokenSymbol) && strings.EqualFold(p.tokens[0].text, keyword)
+}
+
+// accept 接下來的 token 依序為 keywords 時消耗它們並回傳 true, 否則不消耗任何 token
+func (p *parser) accept(keywords ...string) bool {
+	if len(p.tokens) < len(keywords) {
+		return false
+	}
+	for i, keyword := range keywords {
+		t := p.tokens[i]
+		if (t.kind != tokenWord && t.kind != tokenSymbol) || !strings.EqualFold(t.text, keyword) {
+			return false
+		}
+	}
+	p.tokens = p.tokens[len(keywords):]
+	return true
+}
+
+func (p *parser) word() string {
+	if p.done() || p.tokens[0].kind != tokenWord {
+		return ""
+	}
+	return p.next().text
+}
+
+func (p *parser) string() string {
+	if p.done() || p.tokens[0].kind != tokenString {
+		return ""
+	}
+	return p.next().text
+}
+
+func (p *parser) group() (string, bool) {
+	if p.done() || p.tokens[0].kind != tokenGroup {
+		return "", false
+	}
+	return p.next().text, true
+}
+
+// split 以最外層的逗號切開剩餘的 token
+func (p *parser) split() []*parser {
+	parts := []*parser{{}}
+	for _, t := range p.tokens {
+		if t.kind == tokenSymbol && t.text == "," {
+			parts = append(parts, &parser{})
+			continue
+		}
+		last := parts[len(parts)-1]
+		last.tokens = append(last.tokens, t)
+	}
+	p.tokens = nil
+
+	nonEmpty := []*parser{}
+	for _, part := range parts {
+		if !part.done() {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return nonEmpty
+}
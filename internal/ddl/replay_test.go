@@ -0,0 +1,129 @@
+package ddl
+
+import (
+	"errors"
+	"practice/deployments"
+	"practice/internal/migration"
+	"strings"
+	"testing"
+)
+
+func embedded(t *testing.T, dialect Dialect) ([]string, []string) {
+	t.Helper()
+	dir, err := deployments.MigrationDir(dialect.Driver())
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := migration.Load(deployments.Migrations, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ups, tables := []string{}, []string{}
+	for _, m := range migrations {
+		ups = append(ups, m.Up)
+		tables = append(tables, m.Tables()...)
+	}
+	return ups, tables
+}
+
+func TestEmbeddedMigrationsMatchDefinition(t *testing.T) {
+	for _, dialect := range Dialects {
+		ups, known := embedded(t, dialect)
+		if err := Check(Initial, dialect, ups, known); err != nil {
+			t.Errorf("%v: %v", dialect.Driver(), err)
+		}
+	}
+}
+
+func TestGeneratedDDLReplaysToDefinition(t *testing.T) {
+	for _, dialect := range Dialects {
+		if err := Check(Initial, dialect, []string{dialect.Up(Initial)}, nil); err != nil {
+			t.Errorf("%v: %v", dialect.Driver(), err)
+		}
+
+		live, err := Replay(dialect.Driver(), []string{dialect.Up(Initial), dialect.Down(Initial)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(live.Tables) != 0 {
+			t.Errorf("%v: down left %d tables", dialect.Driver(), len(live.Tables))
+		}
+	}
+}
+
+func TestCheckReportsDrift(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		extra   []string // 接在 embedded migrations 之後的 up migrations
+		first   bool     // 只重播第一個 migration
+		want    []string
+	}{
+		{
+			name:    "postgres initial migration before type alignment",
+			dialect: Postgres{},
+			first:   true,
+			want:    []string{"wallets column amount type is integer, expected bigint", "logs column created_at type is date"},
+		},
+		{
+			name:    "postgres narrowed type",
+			dialect: Postgres{},
+			extra:   []string{"ALTER TABLE wallets ALTER COLUMN amount TYPE INTEGER;"},
+			want:    []string{"wallets column amount type is integer, expected bigint"},
+		},
+		{
+			name:    "postgres dropped check and comment",
+			dialect: Postgres{},
+			extra:   []string{"ALTER TABLE logs DROP CONSTRAINT logs_amount_check;\nCOMMENT ON COLUMN users.email IS '信箱';"},
+			want:    []string{"logs check amount is missing", "users column email comment is 信箱, expected 用戶信箱"},
+		},
+		{
+			name:    "postgres extra index and column",
+			dialect: Postgres{},
+			extra:   []string{"ALTER TABLE users ADD COLUMN phone VARCHAR(32) NULL;\nCREATE INDEX ON logs (deposit_user_id);"},
+			want:    []string{"users column phone is not defined", "logs index logs_deposit_user_id_idx is not defined"},
+		},
+		{
+			name:    "mysql modified column",
+			dialect: Mysql{},
+			extra:   []string{"ALTER TABLE `users` MODIFY `email` varchar(128) NOT NULL;"},
+			want:    []string{"users column email type is varchar(128), expected varchar(255)", "users column email comment is , expected 用戶信箱"},
+		},
+		{
+			name:    "mysql dropped unique key",
+			dialect: Mysql{},
+			extra:   []string{"ALTER TABLE `wallets` DROP INDEX `user_id`, ADD KEY (`user_id`);"},
+			want:    []string{"wallets index user_id is INDEX (user_id), expected UNIQUE (user_id)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ups, known := embedded(t, tt.dialect)
+			if tt.first {
+				ups = ups[:1]
+			}
+			err := Check(Initial, tt.dialect, append(ups, tt.extra...), known)
+			if !errors.Is(err, ErrDrift) {
+				t.Fatalf("expected ErrDrift, got %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected %q in:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayRejectsUnknownStatements(t *testing.T) {
+	ups := []string{"CREATE TABLE t (id BIGINT NOT NULL);", "GRANT SELECT ON t TO reader;"}
+	if _, err := Replay("postgresql", ups); err == nil || !strings.Contains(err.Error(), "GRANT SELECT") {
+		t.Errorf("expected the unknown statement to fail, got %v", err)
+	}
+
+	if _, err := Replay("postgresql", []string{"ALTER TABLE missing ADD COLUMN id BIGINT;"}); err == nil {
+		t.Error("expected altering a missing table to fail")
+	}
+}
@@ -0,0 +1,70 @@
+package etl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strconv"
+	"time"
+)
+
+// Canonical 將兩種資料庫讀出的欄位內容轉換成相同的文字表示, 用來計算可以互相比較的 checksum
+// 時間只比較牆上時間到微秒, 不比較時區, 因為 datetime 與 timestamp without time zone 都不保存時區
+func Canonical(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "\x00"
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Checksum 依照主鍵順序累加資料列的內容
+type Checksum struct {
+	h    hash.Hash
+	rows int
+}
+
+func NewChecksum() *Checksum {
+	return &Checksum{h: sha256.New()}
+}
+
+// Add 加入一筆資料列, values 依照欄位順序排列
+func (c *Checksum) Add(values []interface{}) {
+	for _, value := range values {
+		c.h.Write([]byte(Canonical(value)))
+		c.h.Write([]byte{0x1f})
+	}
+	c.h.Write([]byte{0x1e})
+	c.rows++
+}
+
+// Rows 回傳已加入的資料列數量
+func (c *Checksum) Rows() int {
+	return c.rows
+}
+
+// Sum 回傳 checksum 的前 16 個十六進位字元
+func (c *Checksum) Sum() string {
+	return hex.EncodeToString(c.h.Sum(nil))[:16]
+}
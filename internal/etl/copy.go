@@ -0,0 +1,415 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"practice/internal/storage/dialect"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// ChunkReport 為單一分段複製後兩端的資料列數量與 checksum
+type ChunkReport struct {
+	Table          string
+	Index          int
+	First, Last    string // 分段的主鍵範圍
+	SourceRows     int
+	TargetRows     int
+	SourceChecksum string
+	TargetChecksum string
+}
+
+// Match 判斷分段在兩端的內容是否一致
+func (c *ChunkReport) Match() bool {
+	return c.SourceRows == c.TargetRows && c.SourceChecksum == c.TargetChecksum
+}
+
+// Report 為單一資料表的複製結果
+type Report struct {
+	Table   string
+	Rows    int
+	Chunks  []*ChunkReport
+	Elapsed time.Duration
+}
+
+// Mismatched 回傳內容不一致的分段
+func (r *Report) Mismatched() []*ChunkReport {
+	chunks := []*ChunkReport{}
+	for _, chunk := range r.Chunks {
+		if !chunk.Match() {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks
+}
+
+// Copier 將 MySQL 的資料表分段複製至 PostgreSQL, 並在每個分段寫入後比對兩端的資料列數量與 checksum
+type Copier struct {
+	source    *sql.DB
+	target    *sql.DB
+	database  string
+	batchSize int
+	truncate  bool
+}
+
+// NewCopier New MySQL To PostgreSQL Table Copier
+// @param source     mysql database
+// @param target     postgresql database, tables must exist with compatible column types
+// @param database   mysql database name
+// @param batchSize  rows copied per chunk
+// @param truncate   truncates target tables before copying, otherwise existing rows are upserted
+func NewCopier(source, target *sql.DB, database string, batchSize int, truncate bool) *Copier {
+	return &Copier{
+		source:    source,
+		target:    target,
+		database:  database,
+		batchSize: batchSize,
+		truncate:  truncate,
+	}
+}
+
+// Copy 在同一個一致性快照中依序複製資料表, 每個分段完成後呼叫 progress
+func (c *Copier) Copy(ctx context.Context, tables []string, progress func(*ChunkReport)) ([]*Report, error) {
+	conn, err := c.source.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source connection: %w", err)
+	}
+	defer conn.Close()
+
+	// 所有資料表在同一個快照中讀取, 複製期間來源的寫入不會造成資料表之間不一致
+	if _, err := conn.ExecContext(ctx, "SET TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+		return nil, fmt.Errorf("failed to set snapshot isolation level: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "START TRANSACTION WITH CONSISTENT SNAPSHOT, READ ONLY"); err != nil {
+		return nil, fmt.Errorf("failed to start consistent snapshot: %w", err)
+	}
+	defer func() { _, _ = conn.ExecContext(context.Background(), "ROLLBACK") }()
+
+	// 目標資料表不指定 schema, 與寫入時相同依照連線的 search_path 解析
+	var schema string
+	if err := c.target.QueryRowContext(ctx, "SELECT "+dialect.Postgres{}.CurrentSchema()).Scan(&schema); err != nil {
+		return nil, fmt.Errorf("failed to query current schema of target: %w", err)
+	}
+
+	reports := []*Report{}
+	for _, name := range tables {
+		report, err := c.copyTable(ctx, conn, schema, name, progress)
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+func (c *Copier) copyTable(ctx context.Context, conn *sql.Conn, schema, name string, progress func(*ChunkReport)) (*Report, error) {
+	started := time.Now()
+
+	src, err := LoadMysqlTable(ctx, c.source, c.database, name)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := LoadPostgresTable(ctx, c.target, schema, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.check(src, dst); err != nil {
+		return nil, err
+	}
+
+	if c.truncate {
+		if _, err := c.target.ExecContext(ctx, "TRUNCATE TABLE "+pq.QuoteIdentifier(name)); err != nil {
+			return nil, fmt.Errorf("failed to truncate %v: %w", name, err)
+		}
+	}
+
+	report := &Report{Table: name}
+	var after []interface{}
+	for index := 0; ; index++ {
+		rows, err := c.read(ctx, conn, src, after)
+		if err != nil {
+			return report, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		if err := c.load(ctx, src, dst, rows); err != nil {
+			return report, err
+		}
+
+		first, last := c.key(src, rows[0]), c.key(src, rows[len(rows)-1])
+		chunk, err := c.verify(ctx, src, rows, first, last)
+		if err != nil {
+			return report, err
+		}
+		chunk.Table, chunk.Index = name, index
+		report.Chunks = append(report.Chunks, chunk)
+		report.Rows += len(rows)
+		if progress != nil {
+			progress(chunk)
+		}
+
+		if len(rows) < c.batchSize {
+			break
+		}
+		after = last
+	}
+
+	c.resetSequence(ctx, src)
+
+	report.Elapsed = time.Since(started)
+	return report, nil
+}
+
+// check 確認目標資料表的欄位可以無損保存來源欄位, e.g. datetime 不可寫入 date 欄位
+func (c *Copier) check(src, dst *Table) error {
+	if len(src.PrimaryKey) == 0 {
+		return fmt.Errorf("table %v has no primary key, chunked copy is not supported", src.Name)
+	}
+	if strings.Join(src.PrimaryKey, ",") != strings.Join(dst.PrimaryKey, ",") {
+		return fmt.Errorf("primary key of %v differs: source (%v), target (%v)",
+			src.Name, strings.Join(src.PrimaryKey, ", "), strings.Join(dst.PrimaryKey, ", "))
+	}
+
+	problems := []string{}
+	for _, column := range src.Columns {
+		expected := MapMysqlToPostgres(column.Type)
+		target, ok := dst.Column(column.Name)
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("column %v is missing in target", column.Name))
+		case !Compatible(expected, target.Type):
+			problems = append(problems, fmt.Sprintf("column %v is %v in target, expected %v for %v", column.Name, target.Type, expected, column.Type))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("target table %v is incompatible, please run the postgres migrations: %v", src.Name, strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// read 以 keyset 讀出主鍵大於 after 的下一段資料列
+func (c *Copier) read(ctx context.Context, conn *sql.Conn, t *Table, after []interface{}) ([][]interface{}, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s", quoteMysqlAll(t.ColumnNames()), quoteMysql(t.Name))
+	if after != nil {
+		holders := strings.TrimSuffix(strings.Repeat("?, ", len(after)), ", ")
+		query += fmt.Sprintf(" WHERE (%s) > (%s)", quoteMysqlAll(t.PrimaryKey), holders)
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %d", quoteMysqlAll(t.PrimaryKey), c.batchSize)
+
+	rows, err := conn.QueryContext(ctx, query, after...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %v: %w", t.Name, err)
+	}
+	defer rows.Close()
+
+	return scanAll(rows, t)
+}
+
+// load 在單一目標交易中以 COPY 將分段寫入暫存資料表, 再 upsert 至目標資料表
+// COPY 不支援衝突處理, 透過暫存資料表讓重複執行時可以覆寫既有資料列
+func (c *Copier) load(ctx context.Context, src, dst *Table, rows [][]interface{}) error {
+	tx, err := c.target.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start target transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	staging := "etl_" + src.Name
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("CREATE TEMP TABLE %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP",
+		pq.QuoteIdentifier(staging), pq.QuoteIdentifier(dst.Name))); err != nil {
+		return fmt.Errorf("failed to create staging table of %v: %w", dst.Name, err)
+	}
+
+	columns := src.ColumnNames()
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(staging, columns...))
+	if err != nil {
+		return fmt.Errorf("failed to start copy into %v: %w", staging, err)
+	}
+	for _, row := range rows {
+		values := make([]interface{}, len(row))
+		for i, value := range row {
+			values[i] = c.targetValue(dst, columns[i], value)
+		}
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			_ = stmt.Close()
+			return fmt.Errorf("failed to copy into %v: %w", staging, err)
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		_ = stmt.Close()
+		return fmt.Errorf("failed to flush copy into %v: %w", staging, err)
+	}
+	if err := stmt.Close(); err != nil {
+		return fmt.Errorf("failed to finish copy into %v: %w", staging, err)
+	}
+
+	isKey := map[string]bool{}
+	for _, column := range src.PrimaryKey {
+		isKey[column] = true
+	}
+	sets := []string{}
+	for _, column := range columns {
+		if !isKey[column] {
+			sets = append(sets, fmt.Sprintf("%s = EXCLUDED.%s", pq.QuoteIdentifier(column), pq.QuoteIdentifier(column)))
+		}
+	}
+	conflict := "DO NOTHING"
+	if len(sets) > 0 {
+		conflict = "DO UPDATE SET " + strings.Join(sets, ", ")
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s ON CONFLICT (%s) %s",
+		pq.QuoteIdentifier(dst.Name), quotePostgresAll(columns), quotePostgresAll(columns), pq.QuoteIdentifier(staging),
+		quotePostgresAll(src.PrimaryKey), conflict)); err != nil {
+		return fmt.Errorf("failed to upsert into %v: %w", dst.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit chunk of %v: %w", dst.Name, err)
+	}
+	return nil
+}
+
+// verify 讀回目標資料表相同主鍵範圍的資料列, 比對數量與 checksum
+func (c *Copier) verify(ctx context.Context, t *Table, rows [][]interface{}, first, last []interface{}) (*ChunkReport, error) {
+	source := NewChecksum()
+	for _, row := range rows {
+		source.Add(row)
+	}
+
+	keys := quotePostgresAll(t.PrimaryKey)
+	n := len(t.PrimaryKey)
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE (%s) >= (%s) AND (%s) <= (%s) ORDER BY %s",
+		quotePostgresAll(t.ColumnNames()), pq.QuoteIdentifier(t.Name),
+		keys, postgresHolders(1, n), keys, postgresHolders(n+1, n), keys)
+
+	args := []interface{}{}
+	for _, value := range append(append([]interface{}{}, first...), last...) {
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		args = append(args, value)
+	}
+
+	result, err := c.target.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read back %v: %w", t.Name, err)
+	}
+	defer result.Close()
+
+	copied, err := scanAll(result, t)
+	if err != nil {
+		return nil, err
+	}
+	target := NewChecksum()
+	for _, row := range copied {
+		target.Add(row)
+	}
+
+	return &ChunkReport{
+		First:          canonicalKey(first),
+		Last:           canonicalKey(last),
+		SourceRows:     source.Rows(),
+		TargetRows:     target.Rows(),
+		SourceChecksum: source.Sum(),
+		TargetChecksum: target.Sum(),
+	}, nil
+}
+
+// resetSequence 複製時寫入了指定的主鍵, 將 serial 欄位的 sequence 調整至目前最大值, 避免之後新增時主鍵衝突
+func (c *Copier) resetSequence(ctx context.Context, t *Table) {
+	if len(t.PrimaryKey) != 1 {
+		return
+	}
+
+	key := t.PrimaryKey[0]
+	query := fmt.Sprintf("SELECT setval(pg_get_serial_sequence($1, $2), GREATEST((SELECT MAX(%s) FROM %s), 1))",
+		pq.QuoteIdentifier(key), pq.QuoteIdentifier(t.Name))
+	if _, err := c.target.ExecContext(ctx, query, pq.QuoteIdentifier(t.Name), key); err != nil {
+		logrus.Warnf("failed to reset sequence of %v.%v: %v", t.Name, key, err)
+	}
+}
+
+// targetValue 將來源讀出的內容轉換成 COPY 可以寫入的型別, 文字欄位的 []byte 必須轉成 string, 否則會以 bytea 格式寫入
+func (c *Copier) targetValue(dst *Table, column string, value interface{}) interface{} {
+	b, ok := value.([]byte)
+	if !ok {
+		return value
+	}
+	if target, found := dst.Column(column); found && target.Type == "bytea" {
+		return b
+	}
+	return string(b)
+}
+
+func (c *Copier) key(t *Table, row []interface{}) []interface{} {
+	key := make([]interface{}, 0, len(t.PrimaryKey))
+	for _, column := range t.PrimaryKey {
+		for i, name := range t.ColumnNames() {
+			if name == column {
+				key = append(key, row[i])
+			}
+		}
+	}
+	return key
+}
+
+func scanAll(rows *sql.Rows, t *Table) ([][]interface{}, error) {
+	result := [][]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(t.Columns))
+		pointers := make([]interface{}, len(values))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("failed to scan %v: %w", t.Name, err)
+		}
+		result = append(result, values)
+	}
+	return result, rows.Err()
+}
+
+func canonicalKey(key []interface{}) string {
+	values := make([]string, len(key))
+	for i, value := range key {
+		values[i] = Canonical(value)
+	}
+	return strings.Join(values, ",")
+}
+
+func quoteMysql(identifier string) string {
+	return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
+}
+
+func quoteMysqlAll(identifiers []string) string {
+	quoted := make([]string, len(identifiers))
+	for i, identifier := range identifiers {
+		quoted[i] = quoteMysql(identifier)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func quotePostgresAll(identifiers []string) string {
+	quoted := make([]string, len(identifiers))
+	for i, identifier := range identifiers {
+		quoted[i] = pq.QuoteIdentifier(identifier)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func postgresHolders(start, n int) string {
+	holders := make([]string, n)
+	for i := range holders {
+		holders[i] = fmt.Sprintf("$%d", start+i)
+	}
+	return strings.Join(holders, ", ")
+}
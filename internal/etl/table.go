@@ -0,0 +1,173 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"practice/internal/pipeline/schema"
+	"regexp"
+	"strings"
+)
+
+// Column 為資料表欄位的名稱與型別
+type Column struct {
+	Name string
+	Type string // MySQL 為 COLUMN_TYPE (e.g. int(11) unsigned), PostgreSQL 為 data_type (e.g. character varying)
+}
+
+// Table 為資料表的欄位與主鍵
+type Table struct {
+	Name       string
+	Columns    []Column
+	PrimaryKey []string
+}
+
+// ColumnNames 回傳依照欄位順序排列的欄位名稱
+func (t *Table) ColumnNames() []string {
+	names := make([]string, len(t.Columns))
+	for i, column := range t.Columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// Column 以名稱取得欄位
+func (t *Table) Column(name string) (Column, bool) {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return Column{}, false
+}
+
+// LoadMysqlTable 由 information_schema 讀取 MySQL 資料表的欄位與主鍵
+func LoadMysqlTable(ctx context.Context, db *sql.DB, database, name string) (*Table, error) {
+	rows, err := db.QueryContext(ctx, `
+	SELECT COLUMN_NAME, COLUMN_TYPE, COLUMN_KEY
+	FROM information_schema.COLUMNS
+	WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?
+	ORDER BY ORDINAL_POSITION`, database, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns of %v.%v: %w", database, name, err)
+	}
+	defer rows.Close()
+
+	t := &Table{Name: name}
+	for rows.Next() {
+		var column, columnType, columnKey string
+		if err := rows.Scan(&column, &columnType, &columnKey); err != nil {
+			return nil, fmt.Errorf("failed to scan columns of %v.%v: %w", database, name, err)
+		}
+
+		t.Columns = append(t.Columns, Column{Name: column, Type: columnType})
+		if columnKey == "PRI" {
+			t.PrimaryKey = append(t.PrimaryKey, column)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(t.Columns) == 0 {
+		return nil, fmt.Errorf("table %v.%v not found", database, name)
+	}
+	return t, nil
+}
+
+// LoadPostgresTable 由 information_schema 讀取 PostgreSQL 資料表的欄位與主鍵
+func LoadPostgresTable(ctx context.Context, db *sql.DB, namespace, name string) (*Table, error) {
+	rows, err := db.QueryContext(ctx, `
+	SELECT c.column_name, c.data_type, (k.column_name IS NOT NULL)
+	FROM information_schema.columns c
+	LEFT JOIN information_schema.table_constraints tc
+		ON tc.table_schema = c.table_schema AND tc.table_name = c.table_name AND tc.constraint_type = 'PRIMARY KEY'
+	LEFT JOIN information_schema.key_column_usage k
+		ON k.constraint_name = tc.constraint_name AND k.table_schema = c.table_schema AND k.column_name = c.column_name
+	WHERE c.table_schema = $1 AND c.table_name = $2
+	ORDER BY c.ordinal_position`, namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns of %v.%v: %w", namespace, name, err)
+	}
+	defer rows.Close()
+
+	t := &Table{Name: name}
+	for rows.Next() {
+		var column, dataType string
+		var primary bool
+		if err := rows.Scan(&column, &dataType, &primary); err != nil {
+			return nil, fmt.Errorf("failed to scan columns of %v.%v: %w", namespace, name, err)
+		}
+
+		t.Columns = append(t.Columns, Column{Name: column, Type: dataType})
+		if primary {
+			t.PrimaryKey = append(t.PrimaryKey, column)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(t.Columns) == 0 {
+		return nil, fmt.Errorf("table %v.%v not found", namespace, name)
+	}
+	return t, nil
+}
+
+var typeArgs = regexp.MustCompile(`\([^)]*\)`)
+
+// postgresDataTypes 為 schema.ConvertType 產生的 DDL 型別在 information_schema.columns.data_type 中的名稱
+var postgresDataTypes = map[string]string{
+	"SMALLINT":         "smallint",
+	"INTEGER":          "integer",
+	"BIGINT":           "bigint",
+	"NUMERIC":          "numeric",
+	"REAL":             "real",
+	"DOUBLE PRECISION": "double precision",
+	"VARCHAR":          "character varying",
+	"CHAR":             "character",
+	"TEXT":             "text",
+	"TIMESTAMP":        "timestamp without time zone",
+	"DATE":             "date",
+	"TIME":             "time without time zone",
+	"JSONB":            "jsonb",
+	"BYTEA":            "bytea",
+}
+
+// 可以無損保存較小型別的型別, 數字越大範圍越大
+var widths = map[string]int{
+	"smallint":          1,
+	"integer":           2,
+	"bigint":            3,
+	"numeric":           4,
+	"character":         1,
+	"character varying": 2,
+	"text":              3,
+}
+
+// MapMysqlToPostgres 回傳 MySQL 欄位型別在 PostgreSQL 中對應的 data_type
+// e.g. int(11) unsigned -> bigint, datetime -> timestamp without time zone
+func MapMysqlToPostgres(columnType string) string {
+	ddl := schema.ConvertType("mysql", "postgresql", columnType)
+	base := strings.TrimSpace(typeArgs.ReplaceAllString(ddl, ""))
+	if dataType, ok := postgresDataTypes[base]; ok {
+		return dataType
+	}
+	return strings.ToLower(base)
+}
+
+// Compatible 判斷目標欄位的型別是否可以無損保存來源欄位, 同一類型中較大的型別也視為相容
+func Compatible(expected, actual string) bool {
+	if expected == actual {
+		return true
+	}
+
+	we, okE := widths[expected]
+	wa, okA := widths[actual]
+	if !okE || !okA {
+		return false
+	}
+
+	numeric := func(t string) bool { return t == "smallint" || t == "integer" || t == "bigint" || t == "numeric" }
+	return numeric(expected) == numeric(actual) && wa >= we
+}
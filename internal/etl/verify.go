@@ -0,0 +1,493 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// Endpoint 為比對的其中一端, 兩端可以是相同種類的資料庫 (e.g. primary 與 replica)
+type Endpoint struct {
+	Name      string // 錯誤訊息中顯示的名稱
+	Driver    string // mysql, postgresql
+	DB        *sql.DB
+	Namespace string // MySQL 為資料庫名稱, PostgreSQL 為 schema
+	Prefix    string // 資料表名稱的前綴, e.g. pipeline rdb sink 的 table_prefix
+}
+
+func (e Endpoint) loadTable(ctx context.Context, name string) (*Table, error) {
+	if e.Driver == "mysql" {
+		return LoadMysqlTable(ctx, e.DB, e.Namespace, e.Prefix+name)
+	}
+	return LoadPostgresTable(ctx, e.DB, e.Namespace, e.Prefix+name)
+}
+
+func (e Endpoint) quote(identifier string) string {
+	if e.Driver == "mysql" {
+		return quoteMysql(identifier)
+	}
+	return pq.QuoteIdentifier(identifier)
+}
+
+func (e Endpoint) holder(i int) string {
+	if e.Driver == "mysql" {
+		return "?"
+	}
+	return fmt.Sprintf("$%d", i)
+}
+
+// Difference 種類
+const (
+	Missing   = "missing"   // 資料列只存在來源
+	Extra     = "extra"     // 資料列只存在目標
+	Different = "different" // 兩端都有資料列但內容不同
+)
+
+// Difference 為單一資料列的差異
+type Difference struct {
+	Kind    string
+	Key     int64
+	Columns []string      // 內容不同的欄位, 只有 Different 會設定
+	Source  []interface{} // 來源資料列, 依照 Diff.Columns 排列
+	Target  []interface{} // 目標資料列, 依照 Diff.Columns 排列
+}
+
+// Diff 為單一資料表的比對結果
+type Diff struct {
+	Table       string
+	Key         string   // 主鍵欄位
+	Columns     []string // 比對的欄位, 兩端都存在的欄位
+	Chunks      int      // 比對的分段數量
+	Narrowed    int      // 因 checksum 不同而再切分的次數
+	Differences []*Difference
+	Elapsed     time.Duration
+}
+
+// Count 回傳指定種類的差異數量
+func (d *Diff) Count(kind string) int {
+	n := 0
+	for _, difference := range d.Differences {
+		if difference.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// Verifier 依照主鍵將資料表切分成固定資料列數量的分段, 兩端各自在資料庫中計算分段的 checksum,
+// 不一致時以資料列數量的中位數切分, 直到分段小到可以讀出資料列逐列比對
+type Verifier struct {
+	source    Endpoint
+	target    Endpoint
+	chunkSize int64
+	rowsLimit int64
+}
+
+// NewVerifier New Source Target Table Verifier
+// @param source     the endpoint holding the expected rows
+// @param target     the endpoint compared against source
+// @param chunkSize  rows of each side hashed at once
+// @param rowsLimit  mismatched chunks holding at most this many rows are compared row by row
+func NewVerifier(source, target Endpoint, chunkSize, rowsLimit int64) *Verifier {
+	return &Verifier{
+		source:    source,
+		target:    target,
+		chunkSize: chunkSize,
+		rowsLimit: rowsLimit,
+	}
+}
+
+// side 為比對中其中一端的資料表
+type side struct {
+	Endpoint
+	table *Table
+}
+
+// Verify 比對資料表在兩端的內容, 來源在比對期間持續寫入時, 差異可能只是尚未同步的異動
+func (v *Verifier) Verify(ctx context.Context, name string) (*Diff, error) {
+	started := time.Now()
+
+	src, dst, diff, err := v.prepare(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	first, last, ok, err := v.bounds(ctx, src, dst)
+	if err != nil {
+		return nil, err
+	}
+
+	for lo := first; ok && lo <= last; {
+		hi, err := v.boundary(ctx, diff, lo, v.chunkSize, src, dst)
+		if err != nil {
+			return nil, err
+		}
+		if hi > last {
+			hi = last
+		}
+
+		diff.Chunks++
+		if err := v.compare(ctx, src, dst, diff, lo, hi); err != nil {
+			return nil, err
+		}
+		if hi == last {
+			break
+		}
+		lo = hi + 1
+	}
+
+	diff.Elapsed = time.Since(started)
+	return diff, nil
+}
+
+func (v *Verifier) prepare(ctx context.Context, name string) (*side, *side, *Diff, error) {
+	source, err := v.source.loadTable(ctx, name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	target, err := v.target.loadTable(ctx, name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if len(source.PrimaryKey) != 1 || strings.Join(source.PrimaryKey, ",") != strings.Join(target.PrimaryKey, ",") {
+		return nil, nil, nil, fmt.Errorf("primary key of %v differs or is not a single column: source (%v), target (%v)",
+			name, strings.Join(source.PrimaryKey, ", "), strings.Join(target.PrimaryKey, ", "))
+	}
+	key := source.PrimaryKey[0]
+	if column, _ := source.Column(key); !strings.Contains(strings.ToLower(column.Type), "int") {
+		return nil, nil, nil, fmt.Errorf("primary key %v.%v is %v, range splitting needs an integer primary key", name, key, column.Type)
+	}
+
+	diff := &Diff{Table: name, Key: key}
+	for _, column := range source.Columns {
+		if _, ok := target.Column(column.Name); !ok {
+			logrus.Warnf("column %v.%v is missing in target, skipped", name, column.Name)
+			continue
+		}
+		diff.Columns = append(diff.Columns, column.Name)
+	}
+
+	return &side{Endpoint: v.source, table: source}, &side{Endpoint: v.target, table: target}, diff, nil
+}
+
+// bounds 回傳兩端主鍵的最小值與最大值, 兩端都沒有資料列時 ok 為 false
+func (v *Verifier) bounds(ctx context.Context, sides ...*side) (first, last int64, ok bool, err error) {
+	for _, s := range sides {
+		key := s.quote(s.table.PrimaryKey[0])
+		var lo, hi sql.NullInt64
+		query := fmt.Sprintf("SELECT MIN(%s), MAX(%s) FROM %s", key, key, s.quote(s.table.Name))
+		if err := s.DB.QueryRowContext(ctx, query).Scan(&lo, &hi); err != nil {
+			return 0, 0, false, fmt.Errorf("failed to query key range of %v on %v: %w", s.table.Name, s.Name, err)
+		}
+		if !lo.Valid {
+			continue
+		}
+		if !ok || lo.Int64 < first {
+			first = lo.Int64
+		}
+		if !ok || hi.Int64 > last {
+			last = hi.Int64
+		}
+		ok = true
+	}
+	return first, last, ok, nil
+}
+
+// boundary 以 keyset 分頁找出每一端由 lo 開始第 n 筆資料列的主鍵, 回傳最小者, 讓分段在任一端都不超過 n 筆資料列
+// lo 之後兩端都沒有資料列時回傳 math.MaxInt64, 由呼叫端限制在主鍵範圍內
+func (v *Verifier) boundary(ctx context.Context, diff *Diff, lo, n int64, sides ...*side) (int64, error) {
+	hi := int64(math.MaxInt64)
+	for _, s := range sides {
+		key := s.quote(diff.Key)
+		var boundary sql.NullInt64
+		query := fmt.Sprintf("SELECT MAX(%s) FROM (SELECT %s FROM %s WHERE %s >= %s ORDER BY %s LIMIT %d) AS chunk",
+			key, key, s.quote(s.table.Name), key, s.holder(1), key, n)
+		if err := s.DB.QueryRowContext(ctx, query, lo).Scan(&boundary); err != nil {
+			return 0, fmt.Errorf("failed to query chunk boundary of %v after %v on %v: %w", s.table.Name, lo, s.Name, err)
+		}
+		if boundary.Valid && boundary.Int64 < hi {
+			hi = boundary.Int64
+		}
+	}
+	return hi, nil
+}
+
+// compare 比對 [lo, hi] 範圍的資料列數量與 checksum, 不一致時以資料列較多一端的中位數主鍵切分,
+// 任一端的資料列都不超過 rowsLimit 時才讀出資料列逐列比對
+func (v *Verifier) compare(ctx context.Context, src, dst *side, diff *Diff, lo, hi int64) error {
+	sourceRows, sourceSum, err := src.checksum(ctx, diff, lo, hi)
+	if err != nil {
+		return err
+	}
+	targetRows, targetSum, err := dst.checksum(ctx, diff, lo, hi)
+	if err != nil {
+		return err
+	}
+	if sourceRows == targetRows && sourceSum == targetSum {
+		return nil
+	}
+
+	larger := src
+	rows := sourceRows
+	if targetRows > sourceRows {
+		larger, rows = dst, targetRows
+	}
+	if rows <= v.rowsLimit {
+		return v.compareRows(ctx, src, dst, diff, lo, hi)
+	}
+
+	diff.Narrowed++
+	mid, err := v.boundary(ctx, diff, lo, rows/2, larger)
+	if err != nil {
+		return err
+	}
+	if mid >= hi {
+		return v.compareRows(ctx, src, dst, diff, lo, hi)
+	}
+	if err := v.compare(ctx, src, dst, diff, lo, mid); err != nil {
+		return err
+	}
+	return v.compare(ctx, src, dst, diff, mid+1, hi)
+}
+
+// compareRows 讀出兩端 [lo, hi] 範圍的資料列逐列比對
+func (v *Verifier) compareRows(ctx context.Context, src, dst *side, diff *Diff, lo, hi int64) error {
+	sourceRows, err := src.rows(ctx, diff, lo, hi)
+	if err != nil {
+		return err
+	}
+	targetRows, err := dst.rows(ctx, diff, lo, hi)
+	if err != nil {
+		return err
+	}
+
+	keys := []int64{}
+	for key := range sourceRows {
+		keys = append(keys, key)
+	}
+	for key := range targetRows {
+		if _, ok := sourceRows[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	for _, key := range keys {
+		source, inSource := sourceRows[key]
+		target, inTarget := targetRows[key]
+		switch {
+		case !inTarget:
+			diff.Differences = append(diff.Differences, &Difference{Kind: Missing, Key: key, Source: source})
+		case !inSource:
+			diff.Differences = append(diff.Differences, &Difference{Kind: Extra, Key: key, Target: target})
+		default:
+			columns := []string{}
+			for i, column := range diff.Columns {
+				if Canonical(source[i]) != Canonical(target[i]) {
+					columns = append(columns, column)
+				}
+			}
+			if len(columns) > 0 {
+				diff.Differences = append(diff.Differences, &Difference{Kind: Different, Key: key, Columns: columns, Source: source, Target: target})
+			}
+		}
+	}
+
+	return nil
+}
+
+// checksum 在資料庫中計算 [lo, hi] 範圍的資料列數量, 以及依照主鍵順序串接每一列 canonical 文字後的 md5, 不需要傳回資料列
+// MySQL 的 GROUP_CONCAT 預設只保留 1024 bytes, 因此在同一個連線中先調高 group_concat_max_len
+func (s *side) checksum(ctx context.Context, diff *Diff, lo, hi int64) (int64, string, error) {
+	conn, err := s.DB.Conn(ctx)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to connect to %v: %w", s.Name, err)
+	}
+	defer conn.Close()
+
+	key := s.quote(diff.Key)
+	columns := make([]string, len(diff.Columns))
+	for i, name := range diff.Columns {
+		column, _ := s.table.Column(name)
+		columns[i] = s.canonical(column)
+	}
+
+	var query string
+	if s.Driver == "mysql" {
+		if _, err := conn.ExecContext(ctx, "SET SESSION group_concat_max_len = 4294967295"); err != nil {
+			return 0, "", fmt.Errorf("failed to raise group_concat_max_len on %v: %w", s.Name, err)
+		}
+		query = fmt.Sprintf("SELECT COUNT(*), COALESCE(MD5(GROUP_CONCAT(CONCAT_WS('%s', %s) ORDER BY %s SEPARATOR '%s')), '') FROM %s WHERE %s BETWEEN ? AND ?",
+			unitSeparator, strings.Join(columns, ", "), key, recordSeparator, s.quote(s.table.Name), key)
+	} else {
+		query = fmt.Sprintf("SELECT COUNT(*), COALESCE(md5(string_agg(concat_ws('%s', %s), '%s' ORDER BY %s)), '') FROM %s WHERE %s BETWEEN $1 AND $2",
+			unitSeparator, strings.Join(columns, ", "), recordSeparator, key, s.quote(s.table.Name), key)
+	}
+
+	var rows int64
+	var sum string
+	if err := conn.QueryRowContext(ctx, query, lo, hi).Scan(&rows, &sum); err != nil {
+		return 0, "", fmt.Errorf("failed to checksum %v [%v, %v] on %v: %w", s.table.Name, lo, hi, s.Name, err)
+	}
+	return rows, sum, nil
+}
+
+// 串接欄位與資料列的分隔字元 (ASCII unit separator 與 record separator)
+const (
+	unitSeparator   = "\x1f"
+	recordSeparator = "\x1e"
+)
+
+// canonical 回傳欄位在兩種資料庫中都會得到相同文字的 SQL 運算式, NULL 與字串 'n' 以前綴區分
+// 時間到微秒, 布林為 0 或 1, 浮點數轉成固定小數位數的 decimal, 二進位為大寫十六進位
+func (s *side) canonical(column Column) string {
+	name := s.quote(column.Name)
+	columnType := strings.ToLower(column.Type)
+
+	if s.Driver == "mysql" {
+		expression := fmt.Sprintf("CAST(%s AS CHAR)", name)
+		switch {
+		case strings.HasPrefix(columnType, "datetime"), strings.HasPrefix(columnType, "timestamp"):
+			expression = fmt.Sprintf("DATE_FORMAT(%s, '%%Y-%%m-%%d %%H:%%i:%%s.%%f')", name)
+		case columnType == "date":
+			expression = fmt.Sprintf("DATE_FORMAT(%s, '%%Y-%%m-%%d')", name)
+		case strings.HasPrefix(columnType, "float"), strings.HasPrefix(columnType, "double"), strings.HasPrefix(columnType, "real"):
+			expression = fmt.Sprintf("CAST(CAST(%s AS DECIMAL(65, 10)) AS CHAR)", name)
+		case strings.Contains(columnType, "binary"), strings.Contains(columnType, "blob"):
+			expression = fmt.Sprintf("HEX(%s)", name)
+		}
+		return fmt.Sprintf("COALESCE(CONCAT('v', %s), 'n')", expression)
+	}
+
+	expression := name + "::text"
+	switch {
+	case strings.HasPrefix(columnType, "timestamp"):
+		expression = fmt.Sprintf("to_char(%s, 'YYYY-MM-DD HH24:MI:SS.US')", name)
+	case columnType == "date":
+		expression = fmt.Sprintf("to_char(%s, 'YYYY-MM-DD')", name)
+	case columnType == "boolean":
+		expression = fmt.Sprintf("CASE WHEN %s THEN '1' ELSE '0' END", name)
+	case columnType == "double precision", columnType == "real":
+		expression = fmt.Sprintf("CAST(%s AS NUMERIC(65, 10))::text", name)
+	case columnType == "bytea":
+		expression = fmt.Sprintf("upper(encode(%s, 'hex'))", name)
+	}
+	return fmt.Sprintf("COALESCE('v' || %s, 'n')", expression)
+}
+
+// rows 讀出 [lo, hi] 範圍的資料列, 以主鍵為索引
+func (s *side) rows(ctx context.Context, diff *Diff, lo, hi int64) (map[int64][]interface{}, error) {
+	keyIndex := 0
+	for i, column := range diff.Columns {
+		if column == diff.Key {
+			keyIndex = i
+		}
+	}
+
+	rows, err := s.read(ctx, diff, lo, hi)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[int64][]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(diff.Columns))
+		pointers := make([]interface{}, len(values))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("failed to scan %v on %v: %w", s.table.Name, s.Name, err)
+		}
+
+		id, err := strconv.ParseInt(Canonical(values[keyIndex]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse primary key of %v: %w", s.table.Name, err)
+		}
+		result[id] = values
+	}
+	return result, rows.Err()
+}
+
+// read 依照主鍵順序查詢 [lo, hi] 範圍內比對欄位的內容
+func (s *side) read(ctx context.Context, diff *Diff, lo, hi int64) (*sql.Rows, error) {
+	key := s.quote(diff.Key)
+	columns := make([]string, len(diff.Columns))
+	for i, column := range diff.Columns {
+		columns[i] = s.quote(column)
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s BETWEEN %s AND %s ORDER BY %s",
+		strings.Join(columns, ", "), s.quote(s.table.Name), key, s.holder(1), s.holder(2), key)
+
+	rows, err := s.DB.QueryContext(ctx, query, lo, hi)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %v [%v, %v] on %v: %w", s.table.Name, lo, hi, s.Name, err)
+	}
+	return rows, nil
+}
+
+// Repair 回傳讓目標與來源一致的 SQL, 以目標的語法產生
+// missing 產生 INSERT, extra 產生 DELETE, different 只更新內容不同的欄位
+func (v *Verifier) Repair(diff *Diff) []string {
+	table := v.target.quote(v.target.Prefix + diff.Table)
+	key := v.target.quote(diff.Key)
+
+	statements := []string{}
+	for _, difference := range diff.Differences {
+		switch difference.Kind {
+		case Missing:
+			columns := make([]string, len(diff.Columns))
+			values := make([]string, len(diff.Columns))
+			for i, column := range diff.Columns {
+				columns[i] = v.target.quote(column)
+				values[i] = v.literal(difference.Source[i])
+			}
+			statements = append(statements, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);",
+				table, strings.Join(columns, ", "), strings.Join(values, ", ")))
+		case Extra:
+			statements = append(statements, fmt.Sprintf("DELETE FROM %s WHERE %s = %d;", table, key, difference.Key))
+		case Different:
+			sets := []string{}
+			for _, column := range difference.Columns {
+				for i, name := range diff.Columns {
+					if name == column {
+						sets = append(sets, fmt.Sprintf("%s = %s", v.target.quote(column), v.literal(difference.Source[i])))
+					}
+				}
+			}
+			statements = append(statements, fmt.Sprintf("UPDATE %s SET %s WHERE %s = %d;", table, strings.Join(sets, ", "), key, difference.Key))
+		}
+	}
+	return statements
+}
+
+// literal 將來源讀出的內容轉換成目標的 SQL 常值, 非數字一律以字串常值表示, 由目標資料庫轉換成欄位型別
+func (v *Verifier) literal(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return "NULL"
+	case int64, uint64, float64, float32:
+		return Canonical(value)
+	case bool:
+		if v.target.Driver == "mysql" {
+			return Canonical(value)
+		}
+		return strings.ToUpper(strconv.FormatBool(typed))
+	}
+
+	text := strings.ReplaceAll(Canonical(value), "'", "''")
+	if v.target.Driver == "mysql" {
+		text = strings.ReplaceAll(text, `\`, `\\`)
+	}
+	return "'" + text + "'"
+}
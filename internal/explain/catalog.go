@@ -0,0 +1,96 @@
+package explain
+
+import (
+	"fmt"
+	"practice/internal/storage/dialect"
+	"practice/internal/storage/rdb"
+	"time"
+)
+
+// Statement 為情境中執行的單一語句, 參數以常值寫在語句中, 與情境實際執行的內容相同
+type Statement struct {
+	Name  string
+	Query string
+	Write bool   // 會修改資料, EXPLAIN ANALYZE 只能在交易中執行後 rollback
+	Note  string // 計畫中需要注意的地方
+}
+
+// Scenario 為一個情境與其使用的語句, 名稱與情境的指令相同
+type Scenario struct {
+	Name       string
+	Statements []*Statement
+}
+
+// Scenarios 回傳 internal/storage/rdb/scenario.go 中每個情境使用的語句, 語句取自 rdb 與情境共用的定義, 上鎖子句依照 dialect 產生
+func Scenarios(d dialect.Dialect) ([]*Scenario, error) {
+	forShare, err := d.Lock(dialect.ForShare, dialect.Block)
+	if err != nil {
+		return nil, err
+	}
+	forUpdate, err := d.Lock(dialect.ForUpdate, dialect.Block)
+	if err != nil {
+		return nil, err
+	}
+
+	// 情境以執行當下的時間新增錢包, 分析時固定時間讓輸出的語句保持不變
+	insertWallet := rdb.InsertWallet(2, 100000, time.Date(2022, 12, 22, 20, 57, 47, 0, time.Local))
+
+	return []*Scenario{
+		{Name: "dirty_read", Statements: []*Statement{
+			{Name: "transaction 1 insert log", Write: true, Query: rdb.InsertLog},
+			{Name: "transaction 2 count logs", Query: rdb.CountLogs,
+				Note: "COUNT(*) 必須掃過整個資料表或最小的索引"},
+		}},
+		{Name: "read_skew", Statements: []*Statement{
+			{Name: "transaction 1 withdraw", Write: true, Query: rdb.Withdraw},
+			{Name: "transaction 2 select amount", Query: rdb.SelectAmount},
+		}},
+		{Name: "lost_update", Statements: []*Statement{
+			{Name: "select amount", Query: rdb.SelectAmount},
+			{Name: "update amount", Write: true, Query: rdb.SetAmount(40000)},
+		}},
+		{Name: "write_skew_1", Statements: []*Statement{
+			{Name: "transaction 2 count wallets", Query: rdb.CountWallets,
+				Note: "沒有條件的 COUNT 為全表掃描, serializable 下會鎖住整個範圍"},
+			{Name: "transaction 2 raise every wallet", Write: true, Query: rdb.RaiseWallets},
+			{Name: "transaction 1 insert wallet", Write: true, Query: insertWallet},
+			{Name: "count raised wallets", Query: rdb.CountRaisedWallets,
+				Note: "amount 沒有索引, 條件只能在掃描時過濾"},
+		}},
+		{Name: "write_skew_2", Statements: []*Statement{
+			{Name: "select amount", Query: rdb.SelectAmount},
+			{Name: "withdraw", Write: true, Query: rdb.Withdraw},
+		}},
+		{Name: "lock_failed_1", Statements: []*Statement{
+			{Name: "transaction 1 lock by user_id", Query: rdb.SelectIDByUser + " " + forShare,
+				Note: "MySQL 以 user_id 索引即可回答 (Using index), 共享鎖只加在 secondary index, 不會阻擋以主鍵更新的 transaction 2"},
+			{Name: "transaction 2 update by id", Write: true, Query: rdb.WithdrawSome},
+			{Name: "fix 1: select a column outside the index", Query: rdb.SelectAmountByUser + " " + forShare,
+				Note: "amount 不在 user_id 索引中, 必須回到 clustered index 讀取, 因此主鍵也會被上鎖"},
+			{Name: "fix 2: lock for update", Query: rdb.SelectIDByUser + " " + forUpdate,
+				Note: "計畫仍然只使用 user_id 索引, 但 FOR UPDATE 會同時鎖住 clustered index 中的資料列"},
+		}},
+	}, nil
+}
+
+// Find 以名稱取得情境, names 為空時回傳全部
+func Find(scenarios []*Scenario, names []string) ([]*Scenario, error) {
+	if len(names) == 0 {
+		return scenarios, nil
+	}
+
+	found := make([]*Scenario, 0, len(names))
+	for _, name := range names {
+		var scenario *Scenario
+		for _, s := range scenarios {
+			if s.Name == name {
+				scenario = s
+			}
+		}
+		if scenario == nil {
+			return nil, fmt.Errorf("scenario %v undifined", name)
+		}
+		found = append(found, scenario)
+	}
+	return found, nil
+}
@@ -0,0 +1,251 @@
+package explain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"practice/internal/storage/dialect"
+	"strconv"
+	"strings"
+)
+
+// Access 為計畫中讀取或寫入單一資料表的步驟
+type Access struct {
+	Table    string
+	Method   string   // MySQL 的 type (e.g. const, ref, ALL) 或 PostgreSQL 的 Node Type (e.g. Index Only Scan)
+	Index    string   // 使用的索引, 沒有使用索引時為空白
+	Rows     float64  // 估計的資料列數量
+	Actual   *float64 // EXPLAIN ANALYZE 實際的資料列數量, 只有 PostgreSQL 可以對應到步驟
+	FullScan bool     // 掃描整個資料表或整個索引
+	Covering bool     // 只讀取索引即可回答, 不需要回到資料表 (MySQL Using index, PostgreSQL Index Only Scan)
+	Extra    string   // MySQL 的 Extra 或 PostgreSQL 的 Filter 與 Heap Fetches
+}
+
+// Plan 為單一語句的執行計畫
+type Plan struct {
+	Statement *Statement
+	Accesses  []*Access
+	Analyzed  bool
+	Raw       string   // 資料庫回傳的原始計畫
+	Tree      string   // MySQL EXPLAIN ANALYZE 回傳的 TREE 格式文字, 實際的資料列數量與時間只能由此取得
+	Warnings  []string // 無法執行 EXPLAIN ANALYZE 等不影響計畫的問題
+}
+
+// Explainer 對語句執行 EXPLAIN, 並將 MySQL 與 PostgreSQL 的計畫轉換成相同的步驟
+type Explainer struct {
+	db      *sql.DB
+	dialect dialect.Dialect
+	analyze bool
+}
+
+// NewExplainer New Statement Explainer
+// @param db       connection of the explained database
+// @param d        dialect of the same database
+// @param analyze  also runs EXPLAIN ANALYZE inside a transaction that is rolled back
+func NewExplainer(db *sql.DB, d dialect.Dialect, analyze bool) *Explainer {
+	return &Explainer{db: db, dialect: d, analyze: analyze}
+}
+
+// Explain 回傳語句的執行計畫
+func (e *Explainer) Explain(ctx context.Context, statement *Statement) (*Plan, error) {
+	switch e.dialect.Driver() {
+	case "mysql":
+		return e.explainMysql(ctx, statement)
+	case "postgresql":
+		return e.explainPostgres(ctx, statement)
+	}
+	return nil, fmt.Errorf("driver %v is undifined", e.dialect.Driver())
+}
+
+// explainMysql 以傳統表格格式讀取計畫, EXPLAIN ANALYZE 只支援 SELECT 且只回傳 TREE 格式的文字
+func (e *Explainer) explainMysql(ctx context.Context, statement *Statement) (*Plan, error) {
+	plan := &Plan{Statement: statement}
+
+	rows, err := e.db.QueryContext(ctx, "EXPLAIN "+statement.Query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to explain %v: %w", statement.Name, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]sql.NullString, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	raw := []string{strings.Join(columns, " | ")}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		row := map[string]string{}
+		fields := make([]string, len(columns))
+		for i, column := range columns {
+			row[strings.ToLower(column)] = values[i].String
+			fields[i] = values[i].String
+		}
+		raw = append(raw, strings.Join(fields, " | "))
+		plan.Accesses = append(plan.Accesses, mysqlAccess(row))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	plan.Raw = strings.Join(raw, "\n")
+
+	if e.analyze {
+		if statement.Write {
+			plan.Warnings = append(plan.Warnings, "MySQL EXPLAIN ANALYZE only supports SELECT")
+			return plan, nil
+		}
+
+		var tree string
+		err := e.rollback(ctx, func(tx *sql.Tx) error {
+			return tx.QueryRowContext(ctx, "EXPLAIN ANALYZE "+statement.Query).Scan(&tree)
+		})
+		if err != nil {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("EXPLAIN ANALYZE failed: %v", err))
+			return plan, nil
+		}
+		plan.Analyzed = true
+		plan.Tree = tree
+	}
+	return plan, nil
+}
+
+// mysqlAccess 將 EXPLAIN 的一列轉換成步驟
+// type 為 ALL 是全表掃描, index 是掃描整個索引; Extra 的 Using index 表示覆蓋索引, Using index condition 則是 index condition pushdown
+func mysqlAccess(row map[string]string) *Access {
+	access := &Access{
+		Table:  row["table"],
+		Method: row["type"],
+		Index:  row["key"],
+		Extra:  row["extra"],
+	}
+	access.Rows, _ = strconv.ParseFloat(row["rows"], 64)
+
+	// INSERT 的 type 也是 ALL, 但不會讀取資料表
+	if row["select_type"] != "INSERT" {
+		access.FullScan = access.Method == "ALL" || access.Method == "index"
+	}
+	for _, extra := range strings.Split(access.Extra, "; ") {
+		if extra == "Using index" || strings.HasPrefix(extra, "Using index for ") {
+			access.Covering = true
+		}
+	}
+	return access
+}
+
+// explainPostgres 以 JSON 格式讀取計畫樹
+func (e *Explainer) explainPostgres(ctx context.Context, statement *Statement) (*Plan, error) {
+	plan := &Plan{Statement: statement}
+
+	var text string
+	if e.analyze {
+		// 實際執行可能因為資料而失敗 (e.g. 新增的資料列與既有資料衝突), 此時只顯示估計的計畫
+		err := e.rollback(ctx, func(tx *sql.Tx) error {
+			return tx.QueryRowContext(ctx, "EXPLAIN (ANALYZE, FORMAT JSON) "+statement.Query).Scan(&text)
+		})
+		if err != nil {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("EXPLAIN ANALYZE failed: %v", err))
+		}
+		plan.Analyzed = err == nil
+	}
+	if !plan.Analyzed {
+		if err := e.db.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+statement.Query).Scan(&text); err != nil {
+			return nil, fmt.Errorf("failed to explain %v: %w", statement.Name, err)
+		}
+	}
+	plan.Raw = text
+
+	var explained []struct {
+		Plan postgresNode `json:"Plan"`
+	}
+	if err := json.Unmarshal([]byte(text), &explained); err != nil {
+		return nil, fmt.Errorf("failed to parse the plan of %v: %w", statement.Name, err)
+	}
+	for _, root := range explained {
+		plan.Accesses = append(plan.Accesses, postgresAccesses(&root.Plan, "")...)
+	}
+	return plan, nil
+}
+
+// postgresNode 為 EXPLAIN (FORMAT JSON) 的計畫節點
+type postgresNode struct {
+	NodeType     string          `json:"Node Type"`
+	Operation    string          `json:"Operation"`
+	RelationName string          `json:"Relation Name"`
+	IndexName    string          `json:"Index Name"`
+	PlanRows     float64         `json:"Plan Rows"`
+	ActualRows   *float64        `json:"Actual Rows"`
+	ActualLoops  *float64        `json:"Actual Loops"`
+	HeapFetches  *float64        `json:"Heap Fetches"`
+	Filter       string          `json:"Filter"`
+	IndexCond    string          `json:"Index Cond"`
+	Plans        []*postgresNode `json:"Plans"`
+}
+
+// postgresAccesses 依照執行順序 (子節點優先) 取出讀取或寫入資料表的節點
+// Bitmap Index Scan 沒有資料表名稱, 使用上層 Bitmap Heap Scan 的資料表
+func postgresAccesses(node *postgresNode, relation string) []*Access {
+	if node.RelationName != "" {
+		relation = node.RelationName
+	}
+
+	accesses := []*Access{}
+	for _, child := range node.Plans {
+		accesses = append(accesses, postgresAccesses(child, relation)...)
+	}
+	if node.RelationName == "" && node.IndexName == "" {
+		return accesses
+	}
+
+	access := &Access{
+		Table:    relation,
+		Method:   node.NodeType,
+		Index:    node.IndexName,
+		Rows:     node.PlanRows,
+		FullScan: node.NodeType == "Seq Scan",
+		Covering: node.NodeType == "Index Only Scan",
+	}
+	if node.Operation != "" {
+		access.Method = node.NodeType + " (" + node.Operation + ")"
+	}
+	if node.ActualRows != nil {
+		actual := *node.ActualRows
+		if node.ActualLoops != nil {
+			actual *= *node.ActualLoops
+		}
+		access.Actual = &actual
+	}
+
+	extras := []string{}
+	if node.IndexCond != "" {
+		extras = append(extras, "Index Cond: "+node.IndexCond)
+	}
+	if node.Filter != "" {
+		extras = append(extras, "Filter: "+node.Filter)
+	}
+	// Index Only Scan 的 visibility map 不是最新時仍然需要讀取資料表
+	if node.HeapFetches != nil {
+		extras = append(extras, fmt.Sprintf("Heap Fetches: %v", *node.HeapFetches))
+	}
+	access.Extra = strings.Join(extras, "; ")
+	return append(accesses, access)
+}
+
+// rollback 在交易中執行後 rollback, EXPLAIN ANALYZE 會實際執行語句 (包含修改與上鎖)
+func (e *Explainer) rollback(ctx context.Context, run func(tx *sql.Tx) error) error {
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	return run(tx)
+}
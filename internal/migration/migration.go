@@ -0,0 +1,83 @@
+package migration
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// fileName 與 golang-migrate 相同的檔名格式, e.g. 20221220_initialize_schema.up.sql
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration 為單一版本的 up 與 down SQL
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load 讀取 dir 中的 migration, 依照版本由小到大排列, 每個版本都必須同時有 up 與 down
+func Load(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations in %v: %w", dir, err)
+	}
+
+	versions := map[uint64]*Migration{}
+	for _, entry := range entries {
+		matches := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || matches == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse version of %v: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %v: %w", entry.Name(), err)
+		}
+
+		m, ok := versions[version]
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2]}
+			versions[version] = m
+		}
+		if m.Name != matches[2] {
+			return nil, fmt.Errorf("version %v has two names: %v, %v", version, m.Name, matches[2])
+		}
+		if matches[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := []*Migration{}
+	for _, m := range versions {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %v_%v needs both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// createTable 為 migration 中建立的資料表名稱
+var createTable = regexp.MustCompile("(?i)CREATE\\s+TABLE\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?[`\"]?(\\w+)")
+
+// Tables 回傳 up migration 建立的資料表
+func (m *Migration) Tables() []string {
+	tables := []string{}
+	for _, matches := range createTable.FindAllStringSubmatch(m.Up, -1) {
+		tables = append(tables, matches[1])
+	}
+	return tables
+}
@@ -0,0 +1,325 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// VersionTable 與 golang-migrate 使用相同的資料表與欄位, 既有環境可以直接改用內建的 migrate 指令
+const VersionTable = "schema_migrations"
+
+// lockName 為避免同時執行 migration 的 advisory lock 名稱
+const lockName = "practice_schema_migrations"
+
+// ErrDirty 表示上一次 migration 執行到一半失敗, 需要人工修復資料庫後以 force 指定版本
+var ErrDirty = errors.New("database is dirty")
+
+// Status 為單一 migration 的套用狀態
+type Status struct {
+	*Migration
+	Applied bool
+}
+
+// Runner 透過設定的 RDB 連線套用 migration, 版本與 dirty flag 記錄在 VersionTable
+type Runner struct {
+	db          *sql.DB
+	driver      string
+	migrations  []*Migration
+	lockTimeout time.Duration
+}
+
+// NewRunner New Embedded Migration Runner
+// @param db           target database
+// @param driver       mysql, postgresql
+// @param migrations   migrations sorted by version, see Load
+// @param lockTimeout  time waiting for another running migration
+func NewRunner(db *sql.DB, driver string, migrations []*Migration, lockTimeout time.Duration) *Runner {
+	return &Runner{
+		db:          db,
+		driver:      driver,
+		migrations:  migrations,
+		lockTimeout: lockTimeout,
+	}
+}
+
+// Up 套用 n 個尚未套用的 migration, n 不大於 0 時套用全部
+func (r *Runner) Up(ctx context.Context, n int) error {
+	return r.run(ctx, func(current uint64) ([]*Migration, []*Migration, error) {
+		pending := r.pending(current, ^uint64(0))
+		if n > 0 && n < len(pending) {
+			pending = pending[:n]
+		}
+		return pending, nil, nil
+	})
+}
+
+// Down 依序還原 n 個已套用的 migration, n 不大於 0 時還原全部
+func (r *Runner) Down(ctx context.Context, n int) error {
+	return r.run(ctx, func(current uint64) ([]*Migration, []*Migration, error) {
+		applied, err := r.applied(current, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		if n > 0 && n < len(applied) {
+			applied = applied[:n]
+		}
+		return nil, applied, nil
+	})
+}
+
+// Goto 套用或還原 migration 直到指定的版本, 版本 0 表示還原全部
+func (r *Runner) Goto(ctx context.Context, version uint64) error {
+	if version != 0 && r.find(version) == nil {
+		return fmt.Errorf("migration version %v not found", version)
+	}
+
+	return r.run(ctx, func(current uint64) ([]*Migration, []*Migration, error) {
+		if version >= current {
+			return r.pending(current, version), nil, nil
+		}
+		applied, err := r.applied(current, version)
+		return nil, applied, err
+	})
+}
+
+// Force 不執行任何 SQL, 直接將版本設為 version 並清除 dirty flag, 版本 0 表示沒有套用任何 migration
+func (r *Runner) Force(ctx context.Context, version uint64) error {
+	if version != 0 && r.find(version) == nil {
+		return fmt.Errorf("migration version %v not found", version)
+	}
+
+	return r.locked(ctx, func(conn *sql.Conn) error {
+		return r.setVersion(ctx, conn, version, false)
+	})
+}
+
+// Status 回傳目前的版本、dirty flag 與每個 migration 是否已套用
+func (r *Runner) Status(ctx context.Context) (uint64, bool, []*Status, error) {
+	var current uint64
+	var dirty bool
+	err := r.locked(ctx, func(conn *sql.Conn) error {
+		var err error
+		current, dirty, err = r.version(ctx, conn)
+		return err
+	})
+	if err != nil {
+		return 0, false, nil, err
+	}
+
+	statuses := make([]*Status, len(r.migrations))
+	for i, m := range r.migrations {
+		statuses[i] = &Status{Migration: m, Applied: m.Version <= current}
+	}
+	return current, dirty, statuses, nil
+}
+
+// run 取得 lock 後依照 plan 回傳的順序套用 up 或還原 down
+func (r *Runner) run(ctx context.Context, plan func(current uint64) (up, down []*Migration, err error)) error {
+	return r.locked(ctx, func(conn *sql.Conn) error {
+		current, dirty, err := r.version(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("%w at version %v, please fix it manually and run `migrate force <version>`", ErrDirty, current)
+		}
+
+		up, down, err := plan(current)
+		if err != nil {
+			return err
+		}
+		if len(up) == 0 && len(down) == 0 {
+			logrus.Infof("no change, current version %v", current)
+			return nil
+		}
+
+		for _, m := range up {
+			if err := r.apply(ctx, conn, m.Version, m.Up, m.Version); err != nil {
+				return fmt.Errorf("failed to apply %v_%v: %w", m.Version, m.Name, err)
+			}
+			logrus.Infof("%v/u %v", m.Version, m.Name)
+		}
+		for _, m := range down {
+			if err := r.apply(ctx, conn, m.Version, m.Down, r.previous(m.Version)); err != nil {
+				return fmt.Errorf("failed to revert %v_%v: %w", m.Version, m.Name, err)
+			}
+			logrus.Infof("%v/d %v", m.Version, m.Name)
+		}
+		return nil
+	})
+}
+
+// apply 執行 SQL 前將版本標記為 dirty, 成功後才改為 next, 執行失敗時資料庫保持 dirty
+func (r *Runner) apply(ctx context.Context, conn *sql.Conn, version uint64, statements string, next uint64) error {
+	if err := r.setVersion(ctx, conn, version, true); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+	return r.setVersion(ctx, conn, next, false)
+}
+
+// pending 回傳版本在 (current, target] 之間的 migration, 由小到大排列
+func (r *Runner) pending(current, target uint64) []*Migration {
+	migrations := []*Migration{}
+	for _, m := range r.migrations {
+		if m.Version > current && m.Version <= target {
+			migrations = append(migrations, m)
+		}
+	}
+	return migrations
+}
+
+// applied 回傳版本在 (target, current] 之間的 migration, 由大到小排列
+func (r *Runner) applied(current, target uint64) ([]*Migration, error) {
+	if current != 0 && r.find(current) == nil {
+		return nil, fmt.Errorf("current version %v has no migration file, unable to revert", current)
+	}
+
+	migrations := []*Migration{}
+	for i := len(r.migrations) - 1; i >= 0; i-- {
+		if m := r.migrations[i]; m.Version <= current && m.Version > target {
+			migrations = append(migrations, m)
+		}
+	}
+	return migrations, nil
+}
+
+func (r *Runner) find(version uint64) *Migration {
+	for _, m := range r.migrations {
+		if m.Version == version {
+			return m
+		}
+	}
+	return nil
+}
+
+// previous 回傳 version 的前一個版本, 沒有時回傳 0
+func (r *Runner) previous(version uint64) uint64 {
+	var previous uint64
+	for _, m := range r.migrations {
+		if m.Version < version {
+			previous = m.Version
+		}
+	}
+	return previous
+}
+
+// version 讀取目前的版本, 尚未套用任何 migration 時回傳 0
+func (r *Runner) version(ctx context.Context, conn *sql.Conn) (uint64, bool, error) {
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)", VersionTable)); err != nil {
+		return 0, false, fmt.Errorf("failed to create %v: %w", VersionTable, err)
+	}
+
+	var version uint64
+	var dirty bool
+	err := conn.QueryRowContext(ctx, fmt.Sprintf("SELECT version, dirty FROM %s LIMIT 1", VersionTable)).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read %v: %w", VersionTable, err)
+	}
+	return version, dirty, nil
+}
+
+// setVersion 在同一個交易中取代 VersionTable 唯一的資料列, 版本 0 時清空資料表
+func (r *Runner) setVersion(ctx context.Context, conn *sql.Conn, version uint64, dirty bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM "+VersionTable); err != nil {
+		return fmt.Errorf("failed to clear %v: %w", VersionTable, err)
+	}
+	if version != 0 {
+		query := fmt.Sprintf("INSERT INTO %s (version, dirty) VALUES (?, ?)", VersionTable)
+		if r.driver == "postgresql" {
+			query = fmt.Sprintf("INSERT INTO %s (version, dirty) VALUES ($1, $2)", VersionTable)
+		}
+		if _, err := tx.ExecContext(ctx, query, version, dirty); err != nil {
+			return fmt.Errorf("failed to set version %v: %w", version, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit version %v: %w", version, err)
+	}
+	return nil
+}
+
+// locked 在同一條連線上取得 advisory lock 後執行 fn, 避免多個程序同時套用 migration
+func (r *Runner) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if err := r.lock(ctx, conn); err != nil {
+		return err
+	}
+	defer r.unlock(conn)
+
+	return fn(conn)
+}
+
+func (r *Runner) lock(ctx context.Context, conn *sql.Conn) error {
+	if r.driver == "mysql" {
+		var acquired sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(r.lockTimeout.Seconds())).Scan(&acquired); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if acquired.Int64 != 1 {
+			return fmt.Errorf("failed to acquire migration lock in %v, another migration is running", r.lockTimeout)
+		}
+		return nil
+	}
+
+	deadline := time.Now().Add(r.lockTimeout)
+	for {
+		var acquired bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", lockKey()).Scan(&acquired); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if acquired {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("failed to acquire migration lock in %v, another migration is running", r.lockTimeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func (r *Runner) unlock(conn *sql.Conn) {
+	query, arg := "SELECT RELEASE_LOCK(?)", interface{}(lockName)
+	if r.driver == "postgresql" {
+		query, arg = "SELECT pg_advisory_unlock($1)", lockKey()
+	}
+	if _, err := conn.ExecContext(context.Background(), query, arg); err != nil {
+		logrus.Warnf("failed to release migration lock: %v", err)
+	}
+}
+
+// lockKey 將 lockName 轉換成 PostgreSQL advisory lock 的 bigint key
+func lockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte(lockName))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// checkpoint 以本機檔案記錄非交易型 sink 的消費進度
+// 寫入 sink 與更新 checkpoint 無法在同一個交易內完成, 中斷時最多重送最後一個交易 (at-least-once)
+type checkpoint struct {
+	mu        sync.Mutex
+	path      string
+	positions map[string]string // sink name -> position
+}
+
+func newCheckpoint(dir, pipeline string) (*checkpoint, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory %v: %w", dir, err)
+	}
+
+	c := &checkpoint{
+		path:      filepath.Join(dir, pipeline+".json"),
+		positions: map[string]string{},
+	}
+
+	data, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint %v: %w", c.path, err)
+	}
+
+	if err := json.Unmarshal(data, &c.positions); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint %v: %w", c.path, err)
+	}
+
+	return c, nil
+}
+
+func (c *checkpoint) load(sink string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.positions[sink]
+}
+
+func (c *checkpoint) save(sink, position string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.positions[sink] = position
+
+	return writeJSON(c.path, c.positions)
+}
+
+// writeJSON 先寫入暫存檔再更名, 避免中斷時留下不完整的檔案
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %v: %w", path, err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %v: %w", tmp, err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace %v: %w", path, err)
+	}
+
+	return nil
+}
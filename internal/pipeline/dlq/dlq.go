@@ -0,0 +1,96 @@
+package dlq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"practice/internal/storage"
+	"practice/internal/storage/codec"
+	"time"
+)
+
+// ErrNotFound 為指定的 dead-letter 不存在
+var ErrNotFound = errors.New("dead letter not found")
+
+// Entry 為 sink 重試後仍然拒絕的 change event
+type Entry struct {
+	ID       string               // 以失敗時間與事件序號組成, 依時間排序
+	Pipeline string               // 產生此 dead-letter 的 pipeline
+	Sink     string               // 拒絕此事件的 sink
+	Position string               // 事件所屬交易的來源位置
+	Event    *storage.ChangeEvent //
+	Error    string               // 最後一次寫入失敗的錯誤
+	Attempts int                  // 已嘗試寫入的次數
+	FailedAt time.Time            // 最後一次寫入失敗的時間
+}
+
+// NewEntry New Dead-Letter Entry
+// @param pipeline  pipeline name
+// @param sink      name of the sink rejecting the event
+// @param position  source position of the transaction containing the event
+// @param seq       index of the event in the transaction, keeps ids unique within a transaction
+// @param event     rejected change event
+// @param err       error returned by the sink
+// @param attempts  number of attempts writing the event
+func NewEntry(pipeline, sink, position string, seq int, event *storage.ChangeEvent, err error, attempts int) *Entry {
+	now := time.Now()
+	return &Entry{
+		ID:       fmt.Sprintf("%d-%04d", now.UnixNano(), seq),
+		Pipeline: pipeline,
+		Sink:     sink,
+		Position: position,
+		Event:    event,
+		Error:    err.Error(),
+		Attempts: attempts,
+		FailedAt: now,
+	}
+}
+
+// Store 保存 dead-letter, 由 pipeline 寫入並由 CLI 檢視、重送或捨棄
+type Store interface {
+	// 新增或覆寫 dead-letter
+	Put(ctx context.Context, entry *Entry) error
+
+	// 依 ID 排序列出 pipeline 的所有 dead-letter
+	List(ctx context.Context, pipeline string) ([]*Entry, error)
+
+	// 取得指定的 dead-letter, 不存在時回傳 ErrNotFound
+	Get(ctx context.Context, pipeline, id string) (*Entry, error)
+
+	// 刪除指定的 dead-letter, 不存在時回傳 ErrNotFound
+	Delete(ctx context.Context, pipeline, id string) error
+
+	// 釋放 store 持有的資源
+	Shutdown(ctx context.Context)
+}
+
+// record 為 dead-letter 保存時的格式, change event 以 JSON codec 編碼以保留版本與數值精度
+type record struct {
+	ID       string          `json:"id"`
+	Pipeline string          `json:"pipeline"`
+	Sink     string          `json:"sink"`
+	Position string          `json:"position"`
+	Event    json.RawMessage `json:"event"`
+	Error    string          `json:"error"`
+	Attempts int             `json:"attempts"`
+	FailedAt time.Time       `json:"failed_at"`
+}
+
+var eventCodec = codec.NewJSONCodec()
+
+func encodeEvent(event *storage.ChangeEvent) ([]byte, error) {
+	data, err := eventCodec.Encode(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode dead-letter event: %w", err)
+	}
+	return data, nil
+}
+
+func decodeEvent(data []byte) (*storage.ChangeEvent, error) {
+	event, err := eventCodec.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode dead-letter event: %w", err)
+	}
+	return event, nil
+}
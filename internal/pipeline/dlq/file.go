@@ -0,0 +1,136 @@
+package dlq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type fileStore struct {
+	dir string
+}
+
+// NewFileStore New Dead-Letter Store Keeping One JSON File Per Entry
+// @param dir  directory of dead-letters, entries are written into {dir}/{pipeline}/{id}.json
+func NewFileStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter directory %v: %w", dir, err)
+	}
+	return &fileStore{dir: dir}, nil
+}
+
+func (s *fileStore) Put(ctx context.Context, entry *Entry) error {
+	event, err := encodeEvent(entry.Event)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(&record{
+		ID:       entry.ID,
+		Pipeline: entry.Pipeline,
+		Sink:     entry.Sink,
+		Position: entry.Position,
+		Event:    event,
+		Error:    entry.Error,
+		Attempts: entry.Attempts,
+		FailedAt: entry.FailedAt,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode dead-letter %v: %w", entry.ID, err)
+	}
+
+	dir := filepath.Join(s.dir, entry.Pipeline)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create dead-letter directory %v: %w", dir, err)
+	}
+
+	// 先寫入暫存檔再更名, 避免中斷時留下不完整的檔案
+	path := s.path(entry.Pipeline, entry.ID)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("failed to write dead-letter %v: %w", path, err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to replace dead-letter %v: %w", path, err)
+	}
+
+	return nil
+}
+
+func (s *fileStore) List(ctx context.Context, pipeline string) ([]*Entry, error) {
+	files, err := os.ReadDir(filepath.Join(s.dir, pipeline))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead-letters of pipeline %v: %w", pipeline, err)
+	}
+
+	entries := []*Entry{}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+
+		entry, err := s.Get(ctx, pipeline, strings.TrimSuffix(file.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+func (s *fileStore) Get(ctx context.Context, pipeline, id string) (*Entry, error) {
+	path := s.path(pipeline, id)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %v", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead-letter %v: %w", path, err)
+	}
+
+	r := &record{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("failed to decode dead-letter %v: %w", path, err)
+	}
+
+	event, err := decodeEvent(r.Event)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Entry{
+		ID:       r.ID,
+		Pipeline: r.Pipeline,
+		Sink:     r.Sink,
+		Position: r.Position,
+		Event:    event,
+		Error:    r.Error,
+		Attempts: r.Attempts,
+		FailedAt: r.FailedAt,
+	}, nil
+}
+
+func (s *fileStore) Delete(ctx context.Context, pipeline, id string) error {
+	err := os.Remove(s.path(pipeline, id))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %v", ErrNotFound, id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete dead-letter %v: %w", id, err)
+	}
+	return nil
+}
+
+func (s *fileStore) Shutdown(ctx context.Context) {}
+
+func (s *fileStore) path(pipeline, id string) string {
+	return filepath.Join(s.dir, pipeline, filepath.Base(id)+".json")
+}
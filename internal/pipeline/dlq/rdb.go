@@ -0,0 +1,187 @@
+package dlq
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"practice/internal/storage/rdb"
+)
+
+// 保存 dead-letter 的資料表
+const deadLetterTable = "pipeline_dead_letters"
+
+type rdbStore struct {
+	driver string
+	db     rdb.Rdb
+}
+
+// NewRdbStore New Dead-Letter Store Keeping Entries In A Table
+// @param ctx
+// @param driver  driver of the database (mysql or postgresql)
+// @param db      database instance, owned and closed by the caller
+func NewRdbStore(ctx context.Context, driver string, db rdb.Rdb) (Store, error) {
+	if driver != "mysql" && driver != "postgresql" {
+		return nil, fmt.Errorf("dead-letter store driver undifined: %v", driver)
+	}
+
+	s := &rdbStore{driver: driver, db: db}
+	if err := s.createTable(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter table: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *rdbStore) Put(ctx context.Context, entry *Entry) error {
+	event, err := encodeEvent(entry.Event)
+	if err != nil {
+		return err
+	}
+
+	var query string
+	if s.driver == "mysql" {
+		query = "INSERT INTO `" + deadLetterTable + "` " +
+			"(`pipeline`, `id`, `sink`, `position`, `event`, `error`, `attempts`, `failed_at`) VALUES (?, ?, ?, ?, ?, ?, ?, ?) " +
+			"ON DUPLICATE KEY UPDATE `error` = VALUES(`error`), `attempts` = VALUES(`attempts`), `failed_at` = VALUES(`failed_at`)"
+	} else {
+		query = "INSERT INTO " + deadLetterTable + " " +
+			"(pipeline, id, sink, position, event, error, attempts, failed_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) " +
+			"ON CONFLICT (pipeline, id) DO UPDATE SET error = EXCLUDED.error, attempts = EXCLUDED.attempts, failed_at = EXCLUDED.failed_at"
+	}
+
+	if _, err := s.db.DB().ExecContext(ctx, query,
+		entry.Pipeline, entry.ID, entry.Sink, entry.Position, string(event), entry.Error, entry.Attempts, entry.FailedAt,
+	); err != nil {
+		return fmt.Errorf("failed to save dead-letter %v: %w", entry.ID, err)
+	}
+
+	return nil
+}
+
+func (s *rdbStore) List(ctx context.Context, pipeline string) ([]*Entry, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = %s ORDER BY %s",
+		s.columns(), s.quote(deadLetterTable), s.quote("pipeline"), s.placeholder(1), s.quote("id"))
+
+	rows, err := s.db.DB().QueryContext(ctx, query, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead-letters of pipeline %v: %w", pipeline, err)
+	}
+	defer rows.Close()
+
+	entries := []*Entry{}
+	for rows.Next() {
+		entry, err := s.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (s *rdbStore) Get(ctx context.Context, pipeline, id string) (*Entry, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = %s AND %s = %s",
+		s.columns(), s.quote(deadLetterTable), s.quote("pipeline"), s.placeholder(1), s.quote("id"), s.placeholder(2))
+
+	entry, err := s.scan(s.db.DB().QueryRowContext(ctx, query, pipeline, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %v", ErrNotFound, id)
+	}
+	return entry, err
+}
+
+func (s *rdbStore) Delete(ctx context.Context, pipeline, id string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = %s AND %s = %s",
+		s.quote(deadLetterTable), s.quote("pipeline"), s.placeholder(1), s.quote("id"), s.placeholder(2))
+
+	result, err := s.db.DB().ExecContext(ctx, query, pipeline, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete dead-letter %v: %w", id, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: %v", ErrNotFound, id)
+	}
+
+	return nil
+}
+
+func (s *rdbStore) Shutdown(ctx context.Context) {}
+
+func (s *rdbStore) createTable(ctx context.Context) error {
+	var statement string
+	if s.driver == "mysql" {
+		statement = "CREATE TABLE IF NOT EXISTS `" + deadLetterTable + "` (" +
+			"`pipeline` varchar(255) NOT NULL COMMENT 'pipeline 名稱', " +
+			"`id` varchar(64) NOT NULL COMMENT 'dead-letter id', " +
+			"`sink` varchar(64) NOT NULL COMMENT '拒絕事件的 sink', " +
+			"`position` varchar(255) NOT NULL COMMENT '事件所屬交易的來源位置', " +
+			"`event` longtext NOT NULL COMMENT 'JSON 編碼的 change event', " +
+			"`error` text NOT NULL COMMENT '最後一次寫入失敗的錯誤', " +
+			"`attempts` int NOT NULL COMMENT '已嘗試寫入的次數', " +
+			"`failed_at` datetime NOT NULL COMMENT '最後一次寫入失敗的時間', " +
+			"PRIMARY KEY (`pipeline`, `id`)" +
+			") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='pipeline dead-letter queue'"
+	} else {
+		statement = "CREATE TABLE IF NOT EXISTS " + deadLetterTable + " (" +
+			"pipeline VARCHAR(255) NOT NULL, " +
+			"id VARCHAR(64) NOT NULL, " +
+			"sink VARCHAR(64) NOT NULL, " +
+			"position VARCHAR(255) NOT NULL, " +
+			"event TEXT NOT NULL, " +
+			"error TEXT NOT NULL, " +
+			"attempts INTEGER NOT NULL, " +
+			"failed_at TIMESTAMP NOT NULL, " +
+			"PRIMARY KEY (pipeline, id)" +
+			")"
+	}
+
+	_, err := s.db.DB().ExecContext(ctx, statement)
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func (s *rdbStore) scan(row scanner) (*Entry, error) {
+	entry := &Entry{}
+	var event string
+	if err := row.Scan(&entry.Pipeline, &entry.ID, &entry.Sink, &entry.Position, &event, &entry.Error, &entry.Attempts, &entry.FailedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan dead-letter: %w", err)
+	}
+
+	var err error
+	if entry.Event, err = decodeEvent([]byte(event)); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (s *rdbStore) columns() string {
+	columns := ""
+	for i, column := range []string{"pipeline", "id", "sink", "position", "event", "error", "attempts", "failed_at"} {
+		if i > 0 {
+			columns += ", "
+		}
+		columns += s.quote(column)
+	}
+	return columns
+}
+
+func (s *rdbStore) quote(identifier string) string {
+	if s.driver == "mysql" {
+		return "`" + identifier + "`"
+	}
+	return `"` + identifier + `"`
+}
+
+func (s *rdbStore) placeholder(n int) string {
+	if s.driver == "mysql" {
+		return "?"
+	}
+	return fmt.Sprintf("$%d", n)
+}
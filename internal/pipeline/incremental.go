@@ -0,0 +1,289 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"practice/internal/pipeline/source"
+	"practice/internal/storage"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SignalTable 為來源資料庫中用來寫入 watermark 與快照請求的資料表, 由 migration 建立
+const SignalTable = "pipeline_signals"
+
+// 訊號類型
+const (
+	SignalExecuteSnapshot = "execute-snapshot" // 請求快照資料表, data 為以逗號分隔的資料表名稱
+	SignalWatermark       = "watermark"        // 增量快照分段的 low / high watermark
+)
+
+// incremental 以 DBLog 的 watermark 演算法分段快照資料表, 快照期間串流不中斷, 也不需要長交易
+//
+//  1. 在 signal table 寫入 low watermark
+//  2. 讀出下一段主鍵範圍的資料列, 暫存在 window
+//  3. 在 signal table 寫入 high watermark
+//  4. 繼續處理串流: 在 low 與 high 之間出現的異動比快照更新, 將 window 中相同主鍵的資料列移除
+//  5. 串流讀到 high watermark 時, 將 window 剩下的資料列接在該交易之後寫入 sink
+//
+// 每段完成後將進度寫入 {checkpointDir}/{pipeline}.snapshot.json, 重啟後從最後完成的主鍵繼續
+type incremental struct {
+	reader    source.ChunkReader
+	pipeline  string
+	chunkSize int
+	path      string
+
+	progress snapshotProgress
+	window   *window
+}
+
+// snapshotProgress 為增量快照的進度
+type snapshotProgress struct {
+	Tables []string               `json:"tables"` // 尚未完成的資料表, 第一個為快照中的資料表
+	After  map[string]interface{} `json:"after"`  // 快照中的資料表最後完成的主鍵
+}
+
+// window 為進行中的分段
+type window struct {
+	table   string
+	low     string
+	high    string
+	opened  bool                            // 串流已經讀到 low watermark
+	rows    map[string]*storage.ChangeEvent // 主鍵 -> 快照讀出的資料列
+	order   []string                        // 依主鍵排序的資料列, 寫入 sink 時保持順序
+	last    map[string]interface{}          // 分段最後一筆資料列的主鍵
+	lastOne bool                            // 分段資料列不足 chunkSize, 為資料表的最後一段
+}
+
+func newIncremental(src source.Source, pipeline, checkpointDir string, chunkSize int) (*incremental, error) {
+	reader, ok := src.(source.ChunkReader)
+	if !ok {
+		return nil, fmt.Errorf("pipeline %v source does not support incremental snapshot", pipeline)
+	}
+
+	i := &incremental{
+		reader:    reader,
+		pipeline:  pipeline,
+		chunkSize: chunkSize,
+		path:      filepath.Join(checkpointDir, pipeline+".snapshot.json"),
+	}
+
+	data, err := os.ReadFile(i.path)
+	if os.IsNotExist(err) {
+		return i, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot progress %v: %w", i.path, err)
+	}
+
+	// 主鍵以 json.Number 保留原始數值, 避免大整數轉成 float64 失去精度
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&i.progress); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot progress %v: %w", i.path, err)
+	}
+
+	if len(i.progress.Tables) > 0 {
+		logrus.Infof("pipeline %v resumes incremental snapshot of %v after %v", pipeline, i.progress.Tables, i.progress.After)
+	}
+	return i, nil
+}
+
+// requestAll 排入所有擷取的資料表
+func (i *incremental) requestAll(ctx context.Context) error {
+	tables, err := i.reader.SnapshotTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	filtered := make([]string, 0, len(tables))
+	for _, name := range tables {
+		if name != SignalTable {
+			filtered = append(filtered, name)
+		}
+	}
+	return i.request(filtered)
+}
+
+// request 排入資料表, 已在佇列中的資料表會從頭重新快照
+func (i *incremental) request(tables []string) error {
+	for _, name := range tables {
+		for idx, pending := range i.progress.Tables {
+			if pending != name {
+				continue
+			}
+
+			if idx == 0 {
+				i.progress.After = nil
+				i.window = nil
+			}
+			i.progress.Tables = append(i.progress.Tables[:idx], i.progress.Tables[idx+1:]...)
+			break
+		}
+
+		if len(i.progress.Tables) == 0 {
+			i.progress.After = nil
+		}
+		i.progress.Tables = append(i.progress.Tables, name)
+		logrus.Infof("pipeline %v incremental snapshot of %v requested.", i.pipeline, name)
+	}
+
+	return writeJSON(i.path, i.progress)
+}
+
+// next 沒有進行中的分段時, 以 watermark 包夾讀出下一段資料列
+func (i *incremental) next(ctx context.Context) error {
+	if i.window != nil || len(i.progress.Tables) == 0 {
+		return nil
+	}
+
+	table := i.progress.Tables[0]
+	id := fmt.Sprintf("%d", time.Now().UnixNano())
+	w := &window{
+		table: table,
+		low:   id + "-low",
+		high:  id + "-high",
+		rows:  map[string]*storage.ChangeEvent{},
+	}
+
+	if err := i.reader.Signal(ctx, SignalTable, i.watermarkID(), SignalWatermark, w.low); err != nil {
+		return err
+	}
+
+	events, err := i.reader.ReadChunk(ctx, table, i.progress.After, i.chunkSize)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		key := rowKey(event.PrimaryKey)
+		w.rows[key] = event
+		w.order = append(w.order, key)
+	}
+	if len(events) > 0 {
+		w.last = events[len(events)-1].PrimaryKey
+	}
+	w.lastOne = len(events) < i.chunkSize
+
+	if err := i.reader.Signal(ctx, SignalTable, i.watermarkID(), SignalWatermark, w.high); err != nil {
+		return err
+	}
+
+	i.window = w
+	return nil
+}
+
+// process 依照 watermark 處理交易內的異動, 移除 signal table 的異動並在 high watermark 之後補上快照資料列
+// done 表示分段已完成, 寫入 sink 之後必須呼叫 advance 記錄進度
+func (i *incremental) process(ctx context.Context, events []*storage.ChangeEvent) (out []*storage.ChangeEvent, done bool, err error) {
+	out = make([]*storage.ChangeEvent, 0, len(events))
+
+	for _, event := range events {
+		if event.Table != SignalTable {
+			if w := i.window; w != nil && w.opened && event.Table == w.table {
+				delete(w.rows, rowKey(event.PrimaryKey))
+			}
+			out = append(out, event)
+			continue
+		}
+
+		if event.After == nil {
+			continue
+		}
+		id, kind, data := fmt.Sprint(event.After["id"]), fmt.Sprint(event.After["type"]), fmt.Sprint(event.After["data"])
+
+		switch {
+		case kind == SignalExecuteSnapshot && strings.HasPrefix(id, i.pipeline+"-"):
+			tables := []string{}
+			for _, name := range strings.Split(data, ",") {
+				if name = strings.TrimSpace(name); name != "" && name != SignalTable {
+					tables = append(tables, name)
+				}
+			}
+			if err := i.request(tables); err != nil {
+				return nil, false, err
+			}
+
+		case kind == SignalWatermark && id == i.watermarkID() && i.window != nil:
+			w := i.window
+			switch data {
+			case w.low:
+				w.opened = true
+			case w.high:
+				for _, key := range w.order {
+					if row, ok := w.rows[key]; ok {
+						row.Position = event.Position
+						out = append(out, row)
+					}
+				}
+				done = true
+			}
+		}
+	}
+
+	return out, done, nil
+}
+
+// advance 記錄已完成的分段, 資料表的最後一段完成後換下一張資料表
+func (i *incremental) advance() error {
+	w := i.window
+	i.window = nil
+	if w == nil || len(i.progress.Tables) == 0 || i.progress.Tables[0] != w.table {
+		return nil
+	}
+
+	if w.lastOne {
+		logrus.Infof("pipeline %v incremental snapshot of %v finished.", i.pipeline, w.table)
+		i.progress.Tables = i.progress.Tables[1:]
+		i.progress.After = nil
+	} else {
+		i.progress.After = w.last
+	}
+
+	return writeJSON(i.path, i.progress)
+}
+
+func (i *incremental) watermarkID() string {
+	return i.pipeline + "-watermark"
+}
+
+// RequestSnapshot 在 signal table 寫入快照請求, 執行中的 pipeline 由串流讀到請求後開始增量快照
+// @param ctx
+// @param src       source of the pipeline, must support incremental snapshot
+// @param pipeline  pipeline name
+// @param tables    tables to be (re)snapshotted
+func RequestSnapshot(ctx context.Context, src source.Source, pipeline string, tables []string) error {
+	reader, ok := src.(source.ChunkReader)
+	if !ok {
+		return fmt.Errorf("pipeline %v source does not support incremental snapshot", pipeline)
+	}
+
+	id := fmt.Sprintf("%s-snapshot-%d", pipeline, time.Now().UnixNano())
+	if err := reader.Signal(ctx, SignalTable, id, SignalExecuteSnapshot, strings.Join(tables, ",")); err != nil {
+		return err
+	}
+
+	logrus.Infof("snapshot of %v requested for pipeline %v (signal %v)", tables, pipeline, id)
+	return nil
+}
+
+// rowKey 將主鍵轉換成可比較的字串, 串流與快照的數值型別可能不同 (e.g. uint32 and uint64), 因此以文字比較
+func rowKey(primaryKey map[string]interface{}) string {
+	columns := make([]string, 0, len(primaryKey))
+	for column := range primaryKey {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	parts := make([]string, len(columns))
+	for idx, column := range columns {
+		parts[idx] = fmt.Sprintf("%s=%v", column, primaryKey[column])
+	}
+	return strings.Join(parts, ",")
+}
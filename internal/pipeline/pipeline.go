@@ -0,0 +1,537 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"practice/internal/pipeline/dlq"
+	"practice/internal/pipeline/schema"
+	"practice/internal/pipeline/sink"
+	"practice/internal/pipeline/source"
+	"practice/internal/pipeline/transform"
+	"practice/internal/storage"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrCrashInjected 為故障注入時模擬程序中斷所回傳的錯誤
+var ErrCrashInjected = errors.New("crash injected between apply and ack")
+
+type Pipeline struct {
+	name       string
+	source     source.Source
+	sinks      []sink.Sink
+	checkpoint *checkpoint
+
+	checkpointDir string
+
+	positions map[string]string // 各 sink 已寫入的來源位置
+	startFrom string            // sink 沒有紀錄時 source 的起點, 空字串表示來源目前最新的位置
+	chunkSize int               // 初始快照每個分段的資料列數量, 0 表示不做初始快照
+
+	incrementalChunkSize int          // 增量快照每個分段的資料列數量, 0 表示不做增量快照
+	incremental          *incremental // 增量快照的狀態, 於 Run 時建立
+
+	registry *schema.Registry // 來源資料表的結構版本, nil 表示不處理結構異動
+	schemas  map[string]int   // {database}.{table} -> 已通知 sink 的結構版本
+
+	transforms transform.Chain // 寫入 sink 之前依序套用的轉換
+
+	retries     int           // sink 寫入失敗時的重試次數
+	backoff     time.Duration // 第一次重試前的等待時間, 之後每次加倍
+	deadLetters dlq.Store     // 重試後仍被 sink 拒絕的事件, nil 表示直接停止 pipeline
+
+	// 故障注入: 第 crashAfterApply 個交易寫入 sink 之後、ack 之前中斷, 0 表示不注入
+	crashAfterApply int
+	// 連續 idle 超過此次數時結束 Run, 0 表示持續執行直到 ctx 結束
+	stopAfterIdle int
+	applied       int
+}
+
+// New New Change Data Capture Pipeline
+// @param name           pipeline name, also used as the key of stored positions
+// @param src            change event source
+// @param sinks          change event sinks
+// @param checkpointDir  directory storing positions of non-transactional sinks
+func New(name string, src source.Source, sinks []sink.Sink, checkpointDir string) (*Pipeline, error) {
+	if len(sinks) == 0 {
+		return nil, fmt.Errorf("pipeline %v has no sink", name)
+	}
+
+	c, err := newCheckpoint(checkpointDir, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pipeline{
+		name:       name,
+		source:     src,
+		sinks:      sinks,
+		checkpoint: c,
+		positions:  map[string]string{},
+
+		checkpointDir: checkpointDir,
+		schemas:       map[string]int{},
+	}, nil
+}
+
+// StartFrom 設定 sink 沒有消費紀錄時 source 的起點
+func (p *Pipeline) StartFrom(position string) {
+	p.startFrom = position
+}
+
+// InitialSnapshot 在 sink 沒有消費紀錄時, 先以一致性快照分段複製既有資料, 再由快照的位置開始串流
+// source 不支援快照時會略過, 直接由 StartFrom 設定的起點開始
+func (p *Pipeline) InitialSnapshot(chunkSize int) {
+	p.chunkSize = chunkSize
+}
+
+// IncrementalSnapshot 以 watermark 分段快照資料表, 快照與串流同時進行且不需要長交易
+// sink 沒有消費紀錄時快照所有資料表, 執行中也可以透過 RequestSnapshot 重新快照指定的資料表
+func (p *Pipeline) IncrementalSnapshot(chunkSize int) {
+	p.incrementalChunkSize = chunkSize
+}
+
+// SchemaRegistry 設定來源資料表的結構版本, 異動的結構版本改變時先讓 sink 套用結構異動再寫入
+func (p *Pipeline) SchemaRegistry(registry *schema.Registry) {
+	p.registry = registry
+}
+
+// Transforms 設定寫入 sink 之前依序套用的轉換, 快照與串流的異動都會套用
+func (p *Pipeline) Transforms(chain transform.Chain) {
+	p.transforms = chain
+}
+
+// Retry 設定 sink 寫入失敗時的重試次數與第一次重試前的等待時間, 之後每次等待時間加倍
+func (p *Pipeline) Retry(retries int, backoff time.Duration) {
+	p.retries = retries
+	p.backoff = backoff
+}
+
+// DeadLetter 設定 dead-letter store, 重試後仍被 sink 拒絕 (sink.ErrRejected) 的事件移至 store 後繼續消費
+// 其他錯誤 (e.g. 下游無法連線) 仍然會停止 pipeline, 重啟後由已寫入的位置繼續
+func (p *Pipeline) DeadLetter(store dlq.Store) {
+	p.deadLetters = store
+}
+
+// CrashAfterApply 在第 n 個交易寫入 sink 之後、ack 之前中斷 Run, 用來驗證重啟後不會重複或遺漏
+func (p *Pipeline) CrashAfterApply(n int) {
+	p.crashAfterApply = n
+}
+
+// StopAfterIdle 在連續 n 次讀取不到新交易時結束 Run
+func (p *Pipeline) StopAfterIdle(n int) {
+	p.stopAfterIdle = n
+}
+
+// Applied 回傳本次 Run 寫入 sink 的異動數量
+func (p *Pipeline) Applied() int {
+	return p.applied
+}
+
+func (p *Pipeline) Run(ctx context.Context) error {
+	if err := p.restore(ctx); err != nil {
+		return err
+	}
+
+	fresh := false
+	for _, position := range p.positions {
+		if position == "" {
+			fresh = true
+		}
+	}
+
+	if p.chunkSize > 0 {
+		if err := p.snapshot(ctx); err != nil {
+			return err
+		}
+	}
+
+	start := p.start()
+
+	if err := p.source.Start(ctx, start); err != nil {
+		return err
+	}
+
+	if p.incrementalChunkSize > 0 {
+		inc, err := newIncremental(p.source, p.name, p.checkpointDir, p.incrementalChunkSize)
+		if err != nil {
+			return err
+		}
+		if fresh && len(inc.progress.Tables) == 0 {
+			if err := inc.requestAll(ctx); err != nil {
+				return err
+			}
+		}
+		p.incremental = inc
+	}
+
+	idle, batches := 0, 0
+	for {
+		if p.incremental != nil {
+			if err := p.incremental.next(ctx); err != nil {
+				return err
+			}
+		}
+
+		batch, err := p.source.Read(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if batch == nil {
+			idle++
+			if p.stopAfterIdle > 0 && idle >= p.stopAfterIdle {
+				logrus.Infof("pipeline %v is idle, stopped.", p.name)
+				return nil
+			}
+			continue
+		}
+		idle = 0
+
+		done := false
+		if p.incremental != nil {
+			if batch.Events, done, err = p.incremental.process(ctx, batch.Events); err != nil {
+				return err
+			}
+		}
+
+		if batch.Events, err = p.transforms.Apply(batch.Events); err != nil {
+			return err
+		}
+
+		if len(batch.Events) > 0 {
+			if err := p.apply(ctx, batch); err != nil {
+				return err
+			}
+
+			batches++
+			if p.crashAfterApply > 0 && batches == p.crashAfterApply {
+				return ErrCrashInjected
+			}
+		}
+
+		if done {
+			if err := p.incremental.advance(); err != nil {
+				return err
+			}
+		}
+
+		if err := p.source.Ack(ctx, batch.Position); err != nil {
+			return err
+		}
+	}
+}
+
+// restore 取得各 sink 已寫入的來源位置
+func (p *Pipeline) restore(ctx context.Context) error {
+	for _, s := range p.sinks {
+		position := p.checkpoint.load(s.Name())
+		if t, ok := s.(sink.Transactional); ok {
+			var err error
+			if position, err = t.Position(ctx, p.name); err != nil {
+				return err
+			}
+		}
+
+		p.positions[s.Name()] = position
+		logrus.Infof("pipeline %v restored %v sink at position %q", p.name, s.Name(), position)
+	}
+
+	return nil
+}
+
+// start 回傳各 sink 已寫入位置中最早的位置作為 source 的起點
+func (p *Pipeline) start() string {
+	start := ""
+
+	for i, s := range p.sinks {
+		position := p.positions[s.Name()]
+
+		// 任何一個 sink 沒有紀錄時, 只能從預設的起點開始
+		if position == "" {
+			return p.startFrom
+		}
+		if i == 0 || p.source.Compare(position, start) < 0 {
+			start = position
+		}
+	}
+
+	return start
+}
+
+// snapshot 將既有資料複製到沒有消費紀錄的 sink, 完成後才記錄快照的位置
+// 中途中斷時 sink 仍然沒有紀錄, 重啟後會重新快照; 快照資料以 upsert 寫入, 重複寫入不會產生重複資料
+func (p *Pipeline) snapshot(ctx context.Context) error {
+	pending := []sink.Sink{}
+	for _, s := range p.sinks {
+		if p.positions[s.Name()] == "" {
+			pending = append(pending, s)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	snapshotter, ok := p.source.(source.Snapshotter)
+	if !ok {
+		logrus.Warnf("pipeline %v source does not support snapshot, skipped.", p.name)
+		return nil
+	}
+
+	rows := 0
+	position, err := snapshotter.Snapshot(ctx, p.chunkSize, func(batch *source.Batch) error {
+		if len(batch.Events) > 0 && batch.Events[0].Table == SignalTable {
+			return nil
+		}
+
+		events, err := p.transforms.Apply(batch.Events)
+		if err != nil {
+			return err
+		}
+		batch.Events = events
+
+		if err := p.evolve(ctx, batch.Events, pending); err != nil {
+			return err
+		}
+
+		for _, s := range pending {
+			sk := s
+			if _, err := p.deliver(ctx, s, "snapshot", batch.Events, func(events []*storage.ChangeEvent) error {
+				return sk.Write(ctx, events)
+			}); err != nil {
+				return fmt.Errorf("failed to write snapshot into %v sink: %w", s.Name(), err)
+			}
+		}
+		rows += len(batch.Events)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, s := range pending {
+		if err := p.commit(ctx, s, position, nil); err != nil {
+			return err
+		}
+	}
+
+	logrus.Infof("pipeline %v snapshot finished, %v rows copied, streaming from %v", p.name, rows, position)
+	return nil
+}
+
+// apply 將交易寫入每個 sink, 已寫入過這個位置的 sink 直接略過
+func (p *Pipeline) apply(ctx context.Context, batch *source.Batch) error {
+	if err := p.evolve(ctx, batch.Events, p.sinks); err != nil {
+		return err
+	}
+
+	for _, s := range p.sinks {
+		if position := p.positions[s.Name()]; position != "" && p.source.Compare(batch.Position, position) <= 0 {
+			continue
+		}
+
+		if err := p.commit(ctx, s, batch.Position, batch.Events); err != nil {
+			return err
+		}
+	}
+
+	p.applied += len(batch.Events)
+	return nil
+}
+
+// evolve 在寫入異動之前, 將尚未通知的結構版本異動交給 sink 套用
+// 重啟後無法得知 sink 已套用到哪個版本, 因此從前一個版本開始重新套用, sink 必須保證重複套用不會出錯
+func (p *Pipeline) evolve(ctx context.Context, events []*storage.ChangeEvent, sinks []sink.Sink) error {
+	if p.registry == nil {
+		return nil
+	}
+
+	for _, event := range events {
+		key := event.Database + "." + event.Table
+		notified, ok := p.schemas[key]
+		if event.SchemaVersion <= notified {
+			continue
+		}
+		if !ok {
+			notified = event.SchemaVersion - 1
+		}
+
+		for version := notified + 1; version <= event.SchemaVersion; version++ {
+			previous := p.registry.Get(event.Database, event.Table, version-1)
+			current := p.registry.Get(event.Database, event.Table, version)
+			if previous == nil || current == nil {
+				continue
+			}
+
+			// sink 收到的是轉換後的資料列, 結構異動也要套用相同的重新命名, 移除與型別轉換
+			change := schema.Diff(p.transforms.Reshape(previous), p.transforms.Reshape(current))
+			for _, s := range sinks {
+				aware, ok := s.(sink.SchemaAware)
+				if !ok {
+					continue
+				}
+				if err := aware.ApplySchema(ctx, change); err != nil {
+					return fmt.Errorf("failed to apply schema of %v.%v version %v into %v sink: %w", event.Database, event.Table, version, s.Name(), err)
+				}
+			}
+		}
+
+		p.schemas[key] = event.SchemaVersion
+	}
+
+	return nil
+}
+
+// commit 將異動寫入 sink 並記錄寫入後的來源位置, 交易型 sink 兩者在同一個交易內完成
+func (p *Pipeline) commit(ctx context.Context, s sink.Sink, position string, events []*storage.ChangeEvent) error {
+	if t, ok := s.(sink.Transactional); ok {
+		if err := p.deliverAt(ctx, t, position, events); err != nil {
+			return fmt.Errorf("failed to write into %v sink: %w", s.Name(), err)
+		}
+	} else {
+		if len(events) > 0 {
+			if _, err := p.deliver(ctx, s, position, events, func(events []*storage.ChangeEvent) error {
+				return s.Write(ctx, events)
+			}); err != nil {
+				return fmt.Errorf("failed to write into %v sink: %w", s.Name(), err)
+			}
+		}
+		if err := p.checkpoint.save(s.Name(), position); err != nil {
+			return err
+		}
+	}
+
+	p.positions[s.Name()] = position
+	return nil
+}
+
+// deliverAt 將異動與來源位置寫入交易型 sink, 失敗時依照 Retry 的設定重試
+// 重試後仍被拒絕且設定了 dead-letter store 時, 在同一個目標交易中逐筆寫入, 被拒絕的異動在 commit 之前移至 store,
+// 其餘異動與來源位置一起 commit; 中途中斷時異動與位置都沒有寫入, 重啟後重新處理同一個交易
+func (p *Pipeline) deliverAt(ctx context.Context, t sink.Transactional, position string, events []*storage.ChangeEvent) error {
+	err := p.retry(ctx, t, func() error { return t.WriteAt(ctx, p.name, position, events) })
+	if err == nil || p.deadLetters == nil || len(events) == 0 || !errors.Is(err, sink.ErrRejected) || ctx.Err() != nil {
+		return err
+	}
+
+	logrus.Warnf("pipeline %v %v sink rejected a batch at %v, isolating rejected events: %v", p.name, t.Name(), position, err)
+
+	// 重試時同一筆異動沿用相同的 dead-letter, 以覆寫取代新增, 不會重複
+	entries := map[int]*dlq.Entry{}
+	rejected := map[int]bool{}
+	err = p.retry(ctx, t, func() error {
+		rejected = map[int]bool{}
+		return t.WriteIsolatedAt(ctx, p.name, position, events, func(i int, err error) error {
+			entry, ok := entries[i]
+			if !ok {
+				entry = dlq.NewEntry(p.name, t.Name(), position, i, events[i], err, p.retries+1)
+				entries[i] = entry
+			}
+			if err := p.deadLetters.Put(ctx, entry); err != nil {
+				return err
+			}
+			rejected[i] = true
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		if rejected[i] {
+			logrus.Errorf("pipeline %v moved %v event on %v.%v into dead-letter %v: %v",
+				p.name, entry.Event.Operation, entry.Event.Database, entry.Event.Table, entry.ID, entry.Error)
+			continue
+		}
+
+		// 先前被拒絕、最後一次重試時寫入成功的異動, 移除先前移至 store 的 dead-letter
+		if err := p.deadLetters.Delete(ctx, p.name, entry.ID); err != nil && !errors.Is(err, dlq.ErrNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deliver 以 write 寫入非交易型 sink, 失敗時依照 Retry 的設定重試
+// 重試後仍被拒絕且設定了 dead-letter store 時, 逐筆寫入找出被拒絕的異動移至 store, 其餘異動照常寫入,
+// 呼叫端在 deliver 之後才記錄來源位置, 中斷時重送整個交易 (at-least-once)
+// 回傳移至 dead-letter store 的異動數量
+func (p *Pipeline) deliver(ctx context.Context, s sink.Sink, position string, events []*storage.ChangeEvent, write func([]*storage.ChangeEvent) error) (int, error) {
+	err := p.retry(ctx, s, func() error { return write(events) })
+	if err == nil || p.deadLetters == nil || len(events) == 0 || !errors.Is(err, sink.ErrRejected) || ctx.Err() != nil {
+		return 0, err
+	}
+
+	logrus.Warnf("pipeline %v %v sink rejected a batch at %v, isolating rejected events: %v", p.name, s.Name(), position, err)
+
+	rejected := 0
+	for i, event := range events {
+		single := []*storage.ChangeEvent{event}
+		err := p.retry(ctx, s, func() error {
+			// 整批已經重試過, 被拒絕的異動不再重試, 只重試連線等暫時性錯誤
+			err := write(single)
+			if errors.Is(err, sink.ErrRejected) {
+				return &permanent{err}
+			}
+			return err
+		})
+
+		var rejection *permanent
+		if !errors.As(err, &rejection) || ctx.Err() != nil {
+			if err != nil {
+				return rejected, err
+			}
+			continue
+		}
+
+		entry := dlq.NewEntry(p.name, s.Name(), position, i, event, rejection.err, p.retries+1)
+		if err := p.deadLetters.Put(ctx, entry); err != nil {
+			return rejected, err
+		}
+		rejected++
+
+		logrus.Errorf("pipeline %v moved %v event on %v.%v into dead-letter %v: %v",
+			p.name, event.Operation, event.Database, event.Table, entry.ID, rejection.err)
+	}
+
+	return rejected, nil
+}
+
+// permanent 標記不需要重試的錯誤
+type permanent struct {
+	err error
+}
+
+func (e *permanent) Error() string { return e.err.Error() }
+func (e *permanent) Unwrap() error { return e.err }
+
+// retry 執行 fn 直到成功、回傳 permanent 錯誤或超過重試次數, 每次重試前的等待時間加倍
+func (p *Pipeline) retry(ctx context.Context, s sink.Sink, fn func() error) error {
+	backoff := p.backoff
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+
+		var stop *permanent
+		if err == nil || errors.As(err, &stop) || attempt >= p.retries || ctx.Err() != nil {
+			return err
+		}
+
+		logrus.Warnf("pipeline %v failed to write into %v sink (attempt %v/%v), retry in %v: %v",
+			p.name, s.Name(), attempt+1, p.retries+1, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
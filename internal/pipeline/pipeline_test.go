@@ -0,0 +1,302 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"practice/internal/pipeline/dlq"
+	"practice/internal/pipeline/sink"
+	"practice/internal/pipeline/source"
+	"practice/internal/storage"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// fakeSource 依序回傳固定的交易, 位置為交易的序號, 每次 Start 由指定位置之後開始
+type fakeSource struct {
+	batches []*source.Batch
+	next    int
+	acked   string
+}
+
+func newFakeSource(transactions int) *fakeSource {
+	s := &fakeSource{}
+	for i := 1; i <= transactions; i++ {
+		position := strconv.Itoa(i)
+		s.batches = append(s.batches, &source.Batch{
+			Position: position,
+			Events: []*storage.ChangeEvent{
+				{Table: "logs", Operation: storage.OperationInsert, PrimaryKey: map[string]interface{}{"id": i}, Position: position},
+				{Table: "wallets", Operation: storage.OperationUpdate, PrimaryKey: map[string]interface{}{"id": 1}, After: map[string]interface{}{"amount": i}, Position: position},
+			},
+		})
+	}
+	return s
+}
+
+// expected 回傳每個交易預期寫入 sink 的異動, 格式與 recorder 相同
+func (s *fakeSource) expected() []string {
+	expected := []string{}
+	for i := range s.batches {
+		expected = append(expected,
+			deliveryKey("logs", storage.OperationInsert, i+1),
+			deliveryKey("wallets", storage.OperationUpdate, fmt.Sprintf("%v@%v", 1, i+1)),
+		)
+	}
+	return expected
+}
+
+func (s *fakeSource) Start(ctx context.Context, position string) error {
+	s.next = 0
+	for s.next < len(s.batches) && position != "" && s.Compare(s.batches[s.next].Position, position) <= 0 {
+		s.next++
+	}
+	return nil
+}
+
+func (s *fakeSource) Position(ctx context.Context) (string, error) {
+	return "0", nil
+}
+
+func (s *fakeSource) Read(ctx context.Context) (*source.Batch, error) {
+	if s.next >= len(s.batches) {
+		return nil, nil
+	}
+	batch := s.batches[s.next]
+	s.next++
+
+	// pipeline 會改寫 batch.Events, 回傳複本讓重啟後讀到原本的交易
+	events := make([]*storage.ChangeEvent, len(batch.Events))
+	copy(events, batch.Events)
+	return &source.Batch{Position: batch.Position, Events: events}, nil
+}
+
+func (s *fakeSource) Ack(ctx context.Context, position string) error {
+	s.acked = position
+	return nil
+}
+
+func (s *fakeSource) Compare(a, b string) int {
+	x, _ := strconv.Atoi(a)
+	y, _ := strconv.Atoi(b)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func (s *fakeSource) Shutdown(ctx context.Context) {}
+
+// fakeSink 為非交易型 sink, 消費進度由 pipeline 的 checkpoint 檔案記錄
+type fakeSink struct {
+	name string
+
+	mu     sync.Mutex
+	reject func(event *storage.ChangeEvent) bool // 回傳 true 的異動以 sink.ErrRejected 拒絕
+}
+
+func (s *fakeSink) Name() string { return s.name }
+
+func (s *fakeSink) Write(ctx context.Context, events []*storage.ChangeEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, event := range events {
+		if s.reject != nil && s.reject(event) {
+			return fmt.Errorf("%w: %v event on %v", sink.ErrRejected, event.Operation, event.Table)
+		}
+	}
+	return nil
+}
+
+func (s *fakeSink) Shutdown(ctx context.Context) {}
+
+// fakeTransactionalSink 將異動與位置一起 commit, 任何一筆異動失敗時兩者都不寫入
+type fakeTransactionalSink struct {
+	fakeSink
+	positions map[string]string
+	rows      []*storage.ChangeEvent
+}
+
+func newFakeTransactionalSink(name string) *fakeTransactionalSink {
+	return &fakeTransactionalSink{fakeSink: fakeSink{name: name}, positions: map[string]string{}}
+}
+
+func (s *fakeTransactionalSink) WriteAt(ctx context.Context, pipeline, position string, events []*storage.ChangeEvent) error {
+	if err := s.Write(ctx, events); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rows = append(s.rows, events...)
+	s.positions[pipeline] = position
+	return nil
+}
+
+func (s *fakeTransactionalSink) WriteIsolatedAt(ctx context.Context, pipeline, position string, events []*storage.ChangeEvent, rejected func(int, error) error) error {
+	written := []*storage.ChangeEvent{}
+	for i, event := range events {
+		err := s.Write(ctx, []*storage.ChangeEvent{event})
+		if errors.Is(err, sink.ErrRejected) {
+			if err := rejected(i, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		written = append(written, event)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rows = append(s.rows, written...)
+	s.positions[pipeline] = position
+	return nil
+}
+
+func (s *fakeTransactionalSink) Position(ctx context.Context, pipeline string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.positions[pipeline], nil
+}
+
+func TestCrashRecovery(t *testing.T) {
+	tests := []struct {
+		name         string
+		transactions int
+		crashAfter   int
+	}{
+		{name: "crash after first transaction", transactions: 10, crashAfter: 1},
+		{name: "crash in the middle", transactions: 10, crashAfter: 5},
+		{name: "crash after last transaction", transactions: 10, crashAfter: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+
+			fake := newFakeTransactionalSink("transactional")
+			transactional := &transactionalRecorder{recorder: &recorder{Sink: fake, delivered: map[string]int{}}, transactional: fake}
+			checkpointed := &recorder{Sink: &fakeSink{name: "checkpointed"}, delivered: map[string]int{}}
+			sinks := []sink.Sink{transactional, checkpointed}
+
+			src := newFakeSource(tt.transactions)
+			first, err := New("test", src, sinks, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			first.StartFrom("0")
+			first.CrashAfterApply(tt.crashAfter)
+			first.StopAfterIdle(1)
+
+			if err := first.Run(ctx); !errors.Is(err, ErrCrashInjected) {
+				t.Fatalf("expected injected crash, got %v", err)
+			}
+			// 中斷的交易已經寫入 sink 但沒有 ack
+			acked := ""
+			if tt.crashAfter > 1 {
+				acked = strconv.Itoa(tt.crashAfter - 1)
+			}
+			if src.acked != acked {
+				t.Fatalf("expected ack of %q before the crash, got %q", acked, src.acked)
+			}
+
+			// 重啟時以新的 pipeline 與 source 狀態開始, 只保留 sink 與 checkpoint 檔案中的位置
+			restarted := newFakeSource(tt.transactions)
+			second, err := New("test", restarted, sinks, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			second.StartFrom("0")
+			second.StopAfterIdle(1)
+
+			if err := second.Run(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, r := range []*recorder{transactional.recorder, checkpointed} {
+				if duplicates, gaps := r.verify(src.expected()); duplicates != 0 || gaps != 0 {
+					t.Errorf("%v sink has %v duplicates and %v gaps", r.Name(), duplicates, gaps)
+				}
+			}
+			// 中斷在最後一個交易時, 重啟後沒有新的交易, ack 延後到下一個交易
+			if tt.crashAfter < tt.transactions && restarted.acked != strconv.Itoa(tt.transactions) {
+				t.Errorf("expected ack of the last transaction %v, got %q", tt.transactions, restarted.acked)
+			}
+		})
+	}
+}
+
+// positionStore 記錄每個 dead-letter 保存當下 sink 已 commit 的位置
+type positionStore struct {
+	dlq.Store
+	sink      *fakeTransactionalSink
+	positions []string
+}
+
+func (s *positionStore) Put(ctx context.Context, entry *dlq.Entry) error {
+	position, _ := s.sink.Position(ctx, entry.Pipeline)
+	s.positions = append(s.positions, position)
+	return s.Store.Put(ctx, entry)
+}
+
+func TestDeadLetterIsolation(t *testing.T) {
+	ctx := context.Background()
+
+	fake := newFakeTransactionalSink("transactional")
+	fake.reject = func(event *storage.ChangeEvent) bool {
+		return event.Table == "logs" && event.PrimaryKey["id"] == 3
+	}
+
+	store, err := dlq.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadLetters := &positionStore{Store: store, sink: fake}
+
+	p, err := New("test", newFakeSource(5), []sink.Sink{fake}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.StartFrom("0")
+	p.StopAfterIdle(1)
+	p.DeadLetter(deadLetters)
+
+	if err := p.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := store.List(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Position != "3" || entries[0].Event.Table != "logs" {
+		t.Fatalf("expected the logs event of transaction 3 in dead-letter, got %+v", entries)
+	}
+
+	// dead-letter 必須在被拒絕的交易 commit 位置之前保存
+	if len(deadLetters.positions) != 1 || deadLetters.positions[0] != "2" {
+		t.Errorf("expected dead-letter saved while the sink is at position 2, got %v", deadLetters.positions)
+	}
+
+	// 同一個交易中其餘的異動與位置一起寫入
+	if len(fake.rows) != 2*5-1 {
+		t.Errorf("expected %v rows written, got %v", 2*5-1, len(fake.rows))
+	}
+	for _, row := range fake.rows {
+		if row.Table == "logs" && row.PrimaryKey["id"] == 3 {
+			t.Errorf("rejected event written into sink")
+		}
+	}
+	if position, _ := fake.Position(ctx, "test"); position != "5" {
+		t.Errorf("expected sink at position 5, got %q", position)
+	}
+}
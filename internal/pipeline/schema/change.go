@@ -0,0 +1,92 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrIncompatible 為 sink 無法自動套用的結構異動
+var ErrIncompatible = errors.New("incompatible schema change")
+
+// Change 為資料表兩個相鄰版本之間的結構異動
+type Change struct {
+	Previous *Table // 異動前的結構
+	Current  *Table // 異動後的結構
+
+	Added    []Column       // 新增的欄位
+	Dropped  []Column       // 移除的欄位
+	Modified []ColumnChange // 型別改變的欄位
+	Keys     bool           // 主鍵改變
+}
+
+type ColumnChange struct {
+	Name string
+	From string
+	To   string
+}
+
+// Diff 比較資料表兩個版本的結構
+func Diff(previous, current *Table) *Change {
+	c := &Change{Previous: previous, Current: current}
+
+	for _, column := range current.Columns {
+		old, ok := previous.Column(column.Name)
+		switch {
+		case !ok:
+			c.Added = append(c.Added, column)
+		case old.Type != column.Type:
+			c.Modified = append(c.Modified, ColumnChange{Name: column.Name, From: old.Type, To: column.Type})
+		}
+	}
+
+	for _, column := range previous.Columns {
+		if _, ok := current.Column(column.Name); !ok {
+			c.Dropped = append(c.Dropped, column)
+		}
+	}
+
+	c.Keys = strings.Join(previous.PrimaryKey, ",") != strings.Join(current.PrimaryKey, ",")
+	return c
+}
+
+// Widened 為只放寬型別的欄位, 目標資料表改用新的型別後既有資料不受影響
+func (c *Change) Widened() []ColumnChange {
+	columns := []ColumnChange{}
+	for _, column := range c.Modified {
+		if Widening(c.Current.Source, column.From, column.To) {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// Compatible 只新增欄位或放寬欄位型別的異動可以由 sink 自動套用, 既有資料與異動的寫入方式都不受影響
+func (c *Change) Compatible() bool {
+	return len(c.Dropped) == 0 && len(c.Widened()) == len(c.Modified) && !c.Keys
+}
+
+// Err 說明無法自動套用的原因, 相容的異動回傳 nil
+func (c *Change) Err() error {
+	if c.Compatible() {
+		return nil
+	}
+
+	reasons := []string{}
+	for _, column := range c.Dropped {
+		reasons = append(reasons, fmt.Sprintf("column %v dropped", column.Name))
+	}
+	for _, column := range c.Modified {
+		if Widening(c.Current.Source, column.From, column.To) {
+			continue
+		}
+		reasons = append(reasons, fmt.Sprintf("column %v changed from %v to %v", column.Name, column.From, column.To))
+	}
+	if c.Keys {
+		reasons = append(reasons, fmt.Sprintf("primary key changed from (%v) to (%v)",
+			strings.Join(c.Previous.PrimaryKey, ", "), strings.Join(c.Current.PrimaryKey, ", ")))
+	}
+
+	return fmt.Errorf("%w on %v.%v version %v -> %v: %v, please migrate the sink manually",
+		ErrIncompatible, c.Current.Database, c.Current.Name, c.Previous.Version, c.Current.Version, strings.Join(reasons, "; "))
+}
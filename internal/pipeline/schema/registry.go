@@ -0,0 +1,164 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // 來源資料庫的欄位型別 (e.g. int(11) unsigned, character varying(255))
+}
+
+// Table 為來源資料表在某個版本的結構
+type Table struct {
+	Source     string    `json:"source"`   // 來源資料庫種類 (mysql or postgresql)
+	Database   string    `json:"database"` // 來源資料庫名稱
+	Name       string    `json:"name"`     // 來源資料表名稱
+	Version    int       `json:"version"`  // 結構版本, 由 1 開始, 結構異動時遞增
+	Columns    []Column  `json:"columns"`  // 依照欄位順序排列
+	PrimaryKey []string  `json:"primary_key"`
+	CreatedAt  time.Time `json:"created_at"` // 首次觀察到此版本的時間
+}
+
+// Column 以名稱取得欄位
+func (t *Table) Column(name string) (Column, bool) {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return Column{}, false
+}
+
+// sameShape 判斷兩個版本的欄位與主鍵是否完全相同
+func (t *Table) sameShape(other *Table) bool {
+	if len(t.Columns) != len(other.Columns) || len(t.PrimaryKey) != len(other.PrimaryKey) {
+		return false
+	}
+	for i := range t.Columns {
+		if t.Columns[i] != other.Columns[i] {
+			return false
+		}
+	}
+	for i := range t.PrimaryKey {
+		if t.PrimaryKey[i] != other.PrimaryKey[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Registry 保存每個來源資料表的所有結構版本
+// CDC source 由 DDL 事件或系統目錄得知資料表結構後註冊, 結構與最新版本不同時產生新版本
+type Registry struct {
+	mu     sync.RWMutex
+	path   string
+	tables map[string][]*Table // {database}.{table} -> 依版本排列的結構
+}
+
+// NewRegistry New Schema Registry
+// @param path  json file persisting all versions, empty keeps versions in memory only
+func NewRegistry(path string) (*Registry, error) {
+	r := &Registry{
+		path:   path,
+		tables: map[string][]*Table{},
+	}
+	if path == "" {
+		return r, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create schema registry directory: %w", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema registry %v: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, &r.tables); err != nil {
+		return nil, fmt.Errorf("failed to decode schema registry %v: %w", path, err)
+	}
+
+	return r, nil
+}
+
+// Register 註冊資料表目前的結構, 與最新版本相同時回傳最新版本, 否則建立並回傳新版本 (created 為 true)
+func (r *Registry) Register(t *Table) (registered *Table, created bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := t.Database + "." + t.Name
+	versions := r.tables[key]
+	if n := len(versions); n > 0 && versions[n-1].sameShape(t) {
+		return versions[n-1], false, nil
+	}
+
+	registered = &Table{}
+	*registered = *t
+	registered.Version = len(versions) + 1
+	registered.CreatedAt = time.Now()
+	r.tables[key] = append(versions, registered)
+
+	if err := r.save(); err != nil {
+		r.tables[key] = versions
+		return nil, false, err
+	}
+
+	return registered, true, nil
+}
+
+// Get 取得資料表指定版本的結構, 不存在時回傳 nil
+func (r *Registry) Get(database, name string, version int) *Table {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := r.tables[database+"."+name]
+	if version < 1 || version > len(versions) {
+		return nil
+	}
+	return versions[version-1]
+}
+
+// Latest 取得資料表最新版本的結構, 不存在時回傳 nil
+func (r *Registry) Latest(database, name string) *Table {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := r.tables[database+"."+name]
+	if len(versions) == 0 {
+		return nil
+	}
+	return versions[len(versions)-1]
+}
+
+// save 先寫入暫存檔再更名, 避免中斷時留下不完整的檔案
+func (r *Registry) save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(r.tables, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode schema registry: %w", err)
+	}
+
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write schema registry %v: %w", tmp, err)
+	}
+
+	if err := os.Rename(tmp, r.path); err != nil {
+		return fmt.Errorf("failed to replace schema registry %v: %w", r.path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,278 @@
+package schema
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var typeArgs = regexp.MustCompile(`\(([^)]*)\)`)
+
+// ConvertType 將來源資料庫的欄位型別轉換成目標資料庫可用於 DDL 的型別, 無法對應時使用文字型別
+// @param from  driver of the source database (mysql or postgresql)
+// @param to    driver of the target database (mysql or postgresql)
+// @param typ   column type in the source database
+func ConvertType(from, to, typ string) string {
+	if from == to {
+		return typ
+	}
+
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	args := ""
+	if m := typeArgs.FindStringSubmatch(typ); m != nil {
+		args = m[1]
+	}
+	base := strings.Join(strings.Fields(typeArgs.ReplaceAllString(typ, " ")), " ")
+
+	switch from + "->" + to {
+	case "mysql->postgresql":
+		return mysqlToPostgres(base, args)
+	case "postgresql->mysql":
+		return postgresToMysql(base, args)
+	}
+	return typ
+}
+
+func mysqlToPostgres(base, args string) string {
+	unsigned := strings.Contains(base, "unsigned")
+	base = strings.TrimSpace(strings.NewReplacer("unsigned", "", "zerofill", "").Replace(base))
+
+	switch base {
+	case "tinyint", "smallint":
+		return "SMALLINT"
+	case "mediumint":
+		return "INTEGER"
+	case "int", "integer":
+		// unsigned int 的上限超過 INTEGER, 以 BIGINT 保存
+		if unsigned {
+			return "BIGINT"
+		}
+		return "INTEGER"
+	case "bigint":
+		if unsigned {
+			return "NUMERIC(20)"
+		}
+		return "BIGINT"
+	case "decimal", "numeric":
+		if args != "" {
+			return "NUMERIC(" + args + ")"
+		}
+		return "NUMERIC"
+	case "float":
+		return "REAL"
+	case "double", "real":
+		return "DOUBLE PRECISION"
+	case "varchar":
+		return "VARCHAR(" + args + ")"
+	case "char":
+		return "CHAR(" + args + ")"
+	case "datetime", "timestamp":
+		return "TIMESTAMP"
+	case "date":
+		return "DATE"
+	case "time":
+		return "TIME"
+	case "boolean", "bool":
+		return "BOOLEAN"
+	case "json":
+		return "JSONB"
+	case "tinyblob", "blob", "mediumblob", "longblob", "binary", "varbinary":
+		return "BYTEA"
+	}
+	return "TEXT"
+}
+
+func postgresToMysql(base, args string) string {
+	switch base {
+	case "smallint":
+		return "SMALLINT"
+	case "integer":
+		return "INT"
+	case "bigint":
+		return "BIGINT"
+	case "numeric":
+		if args != "" {
+			return "DECIMAL(" + args + ")"
+		}
+		return "DECIMAL(65,30)"
+	case "real":
+		return "FLOAT"
+	case "double precision":
+		return "DOUBLE"
+	case "boolean":
+		return "TINYINT(1)"
+	case "character varying":
+		if args != "" {
+			return "VARCHAR(" + args + ")"
+		}
+		return "TEXT"
+	case "character":
+		return "CHAR(" + args + ")"
+	case "timestamp without time zone", "timestamp with time zone":
+		return "DATETIME(6)"
+	case "date":
+		return "DATE"
+	case "time without time zone":
+		return "TIME"
+	case "json", "jsonb":
+		return "JSON"
+	case "bytea":
+		return "LONGBLOB"
+	}
+	return "TEXT"
+}
+
+// columnType 為解析後的欄位型別
+type columnType struct {
+	base     string // 去除參數與 unsigned 的型別名稱 (e.g. int, character varying)
+	args     []int  // 型別參數 (e.g. 長度, 精度)
+	unsigned bool
+}
+
+func parseType(typ string) columnType {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	t := columnType{}
+	if m := typeArgs.FindStringSubmatch(typ); m != nil {
+		for _, arg := range strings.Split(m[1], ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(arg))
+			if err != nil {
+				break
+			}
+			t.args = append(t.args, n)
+		}
+	}
+
+	words := []string{}
+	for _, word := range strings.Fields(typeArgs.ReplaceAllString(typ, " ")) {
+		switch word {
+		case "unsigned":
+			t.unsigned = true
+		case "zerofill":
+		default:
+			words = append(words, word)
+		}
+	}
+	t.base = strings.Join(words, " ")
+	return t
+}
+
+// arg 取得第 i 個型別參數, 未指定時使用 def
+func (t columnType) arg(i, def int) int {
+	if i < len(t.args) {
+		return t.args[i]
+	}
+	return def
+}
+
+// integerRanks 為整數型別的大小順序
+var integerRanks = map[string]int{"tinyint": 1, "smallint": 2, "mediumint": 3, "int": 4, "integer": 4, "bigint": 5}
+
+// textLengths 為文字與二進位型別可保存的最大長度, 0 為無上限
+var textLengths = map[string]int{
+	"tinytext": 255, "text": 65535, "mediumtext": 16777215, "longtext": 4294967295,
+	"tinyblob": 255, "blob": 65535, "mediumblob": 16777215, "longblob": 4294967295,
+}
+
+// Widening 判斷欄位型別的異動是否只放寬可保存的範圍, 既有的資料都能轉換成新的型別
+// e.g. int -> bigint, varchar(64) -> varchar(255), decimal(10,2) -> decimal(12,4), float -> double
+// @param source  driver of the source database (mysql or postgresql)
+// @param from    column type before the change
+// @param to      column type after the change
+func Widening(source, from, to string) bool {
+	f, t := parseType(from), parseType(to)
+
+	if fr, ok := integerRanks[f.base]; ok {
+		tr, ok := integerRanks[t.base]
+		switch {
+		case ok && f.unsigned == t.unsigned:
+			return tr >= fr
+		case ok && f.unsigned:
+			return tr > fr
+		}
+		return false
+	}
+
+	switch f.base {
+	case "decimal", "numeric":
+		if t.base != "decimal" && t.base != "numeric" {
+			return false
+		}
+		// postgresql 未指定精度的 numeric 沒有上限
+		if source == "postgresql" && len(t.args) == 0 {
+			return true
+		}
+		if source == "postgresql" && len(f.args) == 0 {
+			return false
+		}
+		fs, ts := f.arg(1, 0), t.arg(1, 0)
+		return ts >= fs && t.arg(0, 10)-ts >= f.arg(0, 10)-fs
+	case "float", "real", "double", "double precision":
+		return floatRank(source, t.base) >= floatRank(source, f.base) && floatRank(source, f.base) > 0
+	case "date":
+		return t.base == "date" || t.base == "datetime" || strings.HasPrefix(t.base, "timestamp")
+	case "datetime", "time", "timestamp", "timestamp without time zone", "timestamp with time zone", "time without time zone":
+		// 只允許提高秒數的小數精度
+		def := 0
+		if source == "postgresql" {
+			def = 6
+		}
+		return t.base == f.base && t.arg(0, def) >= f.arg(0, def)
+	}
+
+	fl, ok := textLength(source, f)
+	if !ok {
+		return false
+	}
+	tl, ok := textLength(source, t)
+	if !ok || binaryType(f.base) != binaryType(t.base) {
+		return false
+	}
+	// 固定長度的型別會補上空白, 只能由固定長度轉換為固定長度
+	if fixedType(t.base) && !fixedType(f.base) {
+		return false
+	}
+	return tl == 0 || (fl != 0 && tl >= fl)
+}
+
+func floatRank(source, base string) int {
+	switch base {
+	case "float":
+		return 1
+	case "real":
+		// mysql 的 real 為 double 的別名
+		if source == "mysql" {
+			return 2
+		}
+		return 1
+	case "double", "double precision":
+		return 2
+	}
+	return 0
+}
+
+// textLength 取得文字與二進位型別可保存的最大長度, 0 為無上限
+func textLength(source string, t columnType) (int, bool) {
+	switch t.base {
+	case "char", "character", "binary":
+		return t.arg(0, 1), true
+	case "varchar", "character varying", "varbinary":
+		if source == "postgresql" && len(t.args) == 0 {
+			return 0, true
+		}
+		return t.arg(0, 0), len(t.args) > 0
+	case "text", "bytea":
+		if source == "postgresql" {
+			return 0, true
+		}
+	}
+	length, ok := textLengths[t.base]
+	return length, ok
+}
+
+func binaryType(base string) bool {
+	return strings.Contains(base, "blob") || strings.Contains(base, "binary") || base == "bytea"
+}
+
+func fixedType(base string) bool {
+	return base == "char" || base == "character" || base == "binary"
+}
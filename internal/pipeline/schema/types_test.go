@@ -0,0 +1,75 @@
+package schema
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWidening(t *testing.T) {
+	tests := []struct {
+		source string
+		from   string
+		to     string
+		want   bool
+	}{
+		{"mysql", "int(11)", "bigint(20)", true},
+		{"mysql", "int(11) unsigned", "bigint(20)", true},
+		{"mysql", "int(11) unsigned", "int(11)", false},
+		{"mysql", "int(11)", "int(11) unsigned", false},
+		{"mysql", "bigint(20)", "int(11)", false},
+		{"mysql", "varchar(64)", "varchar(255)", true},
+		{"mysql", "varchar(255)", "varchar(64)", false},
+		{"mysql", "varchar(255)", "text", true},
+		{"mysql", "text", "varchar(255)", false},
+		{"mysql", "char(8)", "varchar(8)", true},
+		{"mysql", "varchar(8)", "char(8)", false},
+		{"mysql", "varchar(255)", "blob", false},
+		{"mysql", "decimal(10,2)", "decimal(12,4)", true},
+		{"mysql", "decimal(10,2)", "decimal(10,4)", false},
+		{"mysql", "float", "double", true},
+		{"mysql", "double", "float", false},
+		{"mysql", "date", "datetime", true},
+		{"mysql", "datetime", "datetime(6)", true},
+		{"mysql", "datetime(6)", "datetime", false},
+		{"mysql", "varchar(255)", "int(11)", false},
+		{"postgresql", "integer", "bigint", true},
+		{"postgresql", "character varying(64)", "text", true},
+		{"postgresql", "character varying(64)", "character varying", true},
+		{"postgresql", "numeric(10,2)", "numeric", true},
+		{"postgresql", "numeric", "numeric(10,2)", false},
+		{"postgresql", "real", "double precision", true},
+		{"postgresql", "timestamp(3) without time zone", "timestamp without time zone", true},
+		{"postgresql", "timestamp without time zone", "timestamp with time zone", false},
+	}
+
+	for _, tt := range tests {
+		if got := Widening(tt.source, tt.from, tt.to); got != tt.want {
+			t.Errorf("%v %v -> %v: expected %v, got %v", tt.source, tt.from, tt.to, tt.want, got)
+		}
+	}
+}
+
+func TestChangeAllowsWidening(t *testing.T) {
+	previous := &Table{Source: "mysql", Database: "practice", Name: "wallets", Version: 1, PrimaryKey: []string{"id"},
+		Columns: []Column{{Name: "id", Type: "int(11) unsigned"}, {Name: "amount", Type: "int(11)"}, {Name: "note", Type: "varchar(255)"}}}
+	current := &Table{Source: "mysql", Database: "practice", Name: "wallets", Version: 2, PrimaryKey: []string{"id"},
+		Columns: []Column{{Name: "id", Type: "bigint(20) unsigned"}, {Name: "amount", Type: "bigint(20)"}, {Name: "note", Type: "text"}}}
+
+	change := Diff(previous, current)
+	if err := change.Err(); err != nil {
+		t.Fatalf("expected widening to be compatible, got %v", err)
+	}
+	if len(change.Widened()) != 3 {
+		t.Errorf("expected 3 widened columns, got %v", change.Widened())
+	}
+
+	current.Columns[2].Type = "int(11)"
+	err := Diff(previous, current).Err()
+	if !errors.Is(err, ErrIncompatible) {
+		t.Fatalf("expected ErrIncompatible, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "column note changed from varchar(255) to int(11)") || strings.Contains(err.Error(), "column amount") {
+		t.Errorf("expected only the note column to be incompatible, got %v", err)
+	}
+}
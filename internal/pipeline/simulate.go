@@ -0,0 +1,302 @@
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"practice/internal/pipeline/sink"
+	"practice/internal/pipeline/source"
+	"practice/internal/storage"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SimulateCrashRecovery 模擬 pipeline 在寫入 sink 之後、ack 之前中斷, 並驗證重啟後每個 sink 沒有重複也沒有遺漏
+//
+//	Source DB                      Pipeline (1st run)                      Sink
+//	    |   transfer 1 ... n             |                                   |
+//	    | -----------------------------> |   apply tx 1 ... k (+ position)   |
+//	    |                                | --------------------------------> |
+//	    |                                X   crash before ack of tx k        |
+//	    |                                                                    |
+//	    |                            Pipeline (2nd run)                      |
+//	    |                                |   restore position of tx k        |
+//	    |                                | <-------------------------------- |
+//	    |   tx k+1 ... n                 |                                   |
+//	    | -----------------------------> |   apply tx k+1 ... n              |
+//	    |                                | --------------------------------> |
+//
+// 交易型 sink (e.g. rdb) 的來源位置與資料寫在同一個交易, 因此重啟後會從 tx k+1 繼續, 每筆異動恰好寫入一次
+//
+// 注意: 會在來源資料庫新增 wallets 與 logs, 並使用設定中的 replication slot, 請勿在正式環境執行
+// @param ctx
+// @param db             source database
+// @param driver         driver of the source database (mysql or postgresql)
+// @param name           pipeline name prefix, a unique suffix is appended for every simulation
+// @param newSource      builds a fresh source, called once before and once after the crash
+// @param sinks          sinks under test
+// @param checkpointDir  directory storing positions of non-transactional sinks
+// @param transfers      number of transfers generated in the source database
+func SimulateCrashRecovery(ctx context.Context, db *sql.DB, driver, name string, newSource func() source.Source, sinks []sink.Sink, checkpointDir string, transfers int) error {
+	logrus.Info("========== start ==========")
+	defer logrus.Info("=========== end ===========")
+
+	name = fmt.Sprintf("%s-crash-recovery-%d", name, time.Now().Unix())
+
+	recorders := make([]*recorder, len(sinks))
+	wrapped := make([]sink.Sink, len(sinks))
+	for i, s := range sinks {
+		recorders[i] = &recorder{Sink: s, delivered: map[string]int{}}
+		wrapped[i] = recorders[i]
+		if t, ok := s.(sink.Transactional); ok {
+			wrapped[i] = &transactionalRecorder{recorder: recorders[i], transactional: t}
+		} else {
+			logrus.Warnf("%v sink is not transactional, it is only expected to be at-least-once.", s.Name())
+		}
+	}
+
+	// 記錄產生異動前的來源位置, 兩次執行都由此處開始
+	src := newSource()
+	head, err := src.Position(ctx)
+	if err != nil {
+		return err
+	}
+
+	expected, err := generateTransfers(ctx, db, driver, transfers)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("generated %v transfers (%v change events) from position %v", transfers, len(expected), head)
+
+	// 第一次執行: 在一半的交易寫入之後中斷
+	first, err := New(name, src, wrapped, checkpointDir)
+	if err != nil {
+		return err
+	}
+	first.StartFrom(head)
+	first.CrashAfterApply(transfers / 2)
+	first.StopAfterIdle(3)
+
+	if err := first.Run(ctx); !errors.Is(err, ErrCrashInjected) {
+		return fmt.Errorf("expected injected crash, got: %v", err)
+	}
+	src.Shutdown(ctx)
+	logrus.Warnf("pipeline crashed after applying %v change events.", first.Applied())
+
+	// 第二次執行: 以新的 source 重新啟動, 從 sink 紀錄的位置繼續
+	src = newSource()
+	defer src.Shutdown(ctx)
+
+	second, err := New(name, src, wrapped, checkpointDir)
+	if err != nil {
+		return err
+	}
+	second.StartFrom(head)
+	second.StopAfterIdle(3)
+
+	if err := second.Run(ctx); err != nil {
+		return err
+	}
+	logrus.Infof("pipeline restarted and applied %v change events.", second.Applied())
+
+	// 驗證每個 sink 收到的異動
+	failed := []string{}
+	for _, r := range recorders {
+		duplicates, gaps := r.verify(expected)
+		if duplicates == 0 && gaps == 0 {
+			logrus.Infof("%v sink: %v change events delivered exactly once.", r.Name(), len(expected))
+			continue
+		}
+
+		logrus.Errorf("%v sink: %v duplicates, %v gaps in %v change events.", r.Name(), duplicates, gaps, len(expected))
+		failed = append(failed, fmt.Sprintf("%v sink has %v duplicates and %v gaps", r.Name(), duplicates, gaps))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("crash recovery of pipeline %v failed: %v", name, strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// generateTransfers 新增兩個錢包並在其間來回轉帳, 每筆轉帳為一個交易, 回傳預期產生的異動
+// 第 i 筆轉帳的金額為 i+1, 讓每一次 update 後的餘額都不相同, 藉此區分同一個錢包的多次 update
+func generateTransfers(ctx context.Context, db *sql.DB, driver string, transfers int) ([]string, error) {
+	bind := func(query string) string {
+		if driver != "postgresql" {
+			return query
+		}
+		for i := 1; strings.Contains(query, "?"); i++ {
+			query = strings.Replace(query, "?", fmt.Sprintf("$%d", i), 1)
+		}
+		return query
+	}
+
+	insert := func(tx *sql.Tx, query string, args ...interface{}) (int64, error) {
+		if driver == "postgresql" {
+			var id int64
+			err := tx.QueryRowContext(ctx, bind(query)+" RETURNING id", args...).Scan(&id)
+			return id, err
+		}
+
+		result, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return 0, err
+		}
+		return result.LastInsertId()
+	}
+
+	expected := []string{}
+	timeNow := time.Now().Format("2006-01-02 15:04:05")
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var userID int64
+	if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(user_id), 0) FROM wallets").Scan(&userID); err != nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("failed to query wallets: %w", err)
+	}
+
+	wallets := make([]int64, 2)
+	amounts := map[int64]int64{}
+	for i := range wallets {
+		wallets[i], err = insert(tx, "INSERT INTO wallets (user_id, amount, created_at, modified_at) VALUES (?, ?, ?, ?)", userID+int64(i)+1, 100000, timeNow, timeNow)
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, fmt.Errorf("failed to insert wallet: %w", err)
+		}
+		amounts[wallets[i]] = 100000
+		expected = append(expected, deliveryKey("wallets", storage.OperationInsert, wallets[i]))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < transfers; i++ {
+		from, to, amount := wallets[i%2], wallets[(i+1)%2], int64(i+1)
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		logID, err := insert(tx, "INSERT INTO logs (deposit_user_id, withdraw_user_id, amount, created_at) VALUES (?, ?, ?, ?)", to, from, amount, timeNow)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, bind("UPDATE wallets SET amount = amount - ? WHERE id = ?"), amount, from)
+		}
+		if err == nil {
+			_, err = tx.ExecContext(ctx, bind("UPDATE wallets SET amount = amount + ? WHERE id = ?"), amount, to)
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, fmt.Errorf("failed to transfer: %w", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+
+		amounts[from] -= amount
+		amounts[to] += amount
+		expected = append(expected,
+			deliveryKey("logs", storage.OperationInsert, logID),
+			deliveryKey("wallets", storage.OperationUpdate, fmt.Sprintf("%v@%v", from, amounts[from])),
+			deliveryKey("wallets", storage.OperationUpdate, fmt.Sprintf("%v@%v", to, amounts[to])),
+		)
+	}
+
+	return expected, nil
+}
+
+func deliveryKey(table, operation string, id interface{}) string {
+	return fmt.Sprintf("%s/%s/%v", table, operation, id)
+}
+
+// recorder 記錄每個異動寫入 sink 的次數, update 以主鍵與異動後的餘額區分
+type recorder struct {
+	sink.Sink
+
+	mu        sync.Mutex
+	delivered map[string]int
+}
+
+func (r *recorder) Write(ctx context.Context, events []*storage.ChangeEvent) error {
+	if err := r.Sink.Write(ctx, events); err != nil {
+		return err
+	}
+	r.record(events)
+	return nil
+}
+
+// verify 比對預期的異動, 回傳重複寫入的次數與沒有寫入的異動數量
+func (r *recorder) verify(expected []string) (duplicates, gaps int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range expected {
+		switch count := r.delivered[key]; {
+		case count == 0:
+			gaps++
+		case count > 1:
+			duplicates += count - 1
+		}
+	}
+	return duplicates, gaps
+}
+
+func (r *recorder) record(events []*storage.ChangeEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, event := range events {
+		id := event.PrimaryKey["id"]
+		if event.Operation == storage.OperationUpdate {
+			id = fmt.Sprintf("%v@%v", id, event.After["amount"])
+		}
+		r.delivered[deliveryKey(event.Table, event.Operation, id)]++
+	}
+}
+
+type transactionalRecorder struct {
+	*recorder
+	transactional sink.Transactional
+}
+
+func (r *transactionalRecorder) WriteAt(ctx context.Context, pipeline, position string, events []*storage.ChangeEvent) error {
+	if err := r.transactional.WriteAt(ctx, pipeline, position, events); err != nil {
+		return err
+	}
+	r.record(events)
+	return nil
+}
+
+func (r *transactionalRecorder) WriteIsolatedAt(ctx context.Context, pipeline, position string, events []*storage.ChangeEvent, rejected func(int, error) error) error {
+	skipped := map[int]bool{}
+	err := r.transactional.WriteIsolatedAt(ctx, pipeline, position, events, func(i int, err error) error {
+		skipped[i] = true
+		return rejected(i, err)
+	})
+	if err != nil {
+		return err
+	}
+
+	written := make([]*storage.ChangeEvent, 0, len(events))
+	for i, event := range events {
+		if !skipped[i] {
+			written = append(written, event)
+		}
+	}
+	r.record(written)
+	return nil
+}
+
+func (r *transactionalRecorder) Position(ctx context.Context, pipeline string) (string, error) {
+	return r.transactional.Position(ctx, pipeline)
+}
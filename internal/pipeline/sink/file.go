@@ -0,0 +1,159 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"practice/internal/storage"
+	"practice/internal/storage/codec"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type file struct {
+	mu         sync.Mutex
+	dir        string
+	prefix     string
+	maxSize    int64
+	maxBackups int
+	codec      codec.Codec
+
+	current *os.File
+	size    int64
+}
+
+// NewFileSink New Sink Writing Change Events To Rotating Local Files
+// @param ctx
+// @param dir         output directory
+// @param prefix      file name prefix, files are named as {prefix}-{timestamp}.{jsonl|pb}
+// @param format      change event encoding (json or protobuf)
+// @param maxSize     rotates to a new file once the current one exceeds maxSize megabytes.
+// @param maxBackups  keeps at most maxBackups files in dir, 0 keeps all of them.
+func NewFileSink(ctx context.Context, dir, prefix, format string, maxSize, maxBackups int) Sink {
+	c, err := codec.New(format)
+	if err != nil {
+		logrus.Panicf("failed to create file sink: %v", err)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		logrus.Panicf("failed to create sink directory %v: %v", dir, err)
+	}
+
+	f := &file{
+		dir:        dir,
+		prefix:     prefix,
+		maxSize:    int64(maxSize) * 1024 * 1024,
+		maxBackups: maxBackups,
+		codec:      c,
+	}
+
+	if err := f.rotate(); err != nil {
+		logrus.Panicf("failed to open sink file: %v", err)
+	}
+
+	return f
+}
+
+func (f *file) Name() string {
+	return "file"
+}
+
+func (f *file) Write(ctx context.Context, events []*storage.ChangeEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, event := range events {
+		line, err := f.encode(event)
+		if err != nil {
+			return reject(err)
+		}
+
+		if f.maxSize > 0 && f.size+int64(len(line)) > f.maxSize && f.size > 0 {
+			if err := f.rotate(); err != nil {
+				return err
+			}
+		}
+
+		n, err := f.current.Write(line)
+		f.size += int64(n)
+		if err != nil {
+			return fmt.Errorf("failed to write change event into %v: %w", f.current.Name(), err)
+		}
+	}
+
+	return f.current.Sync()
+}
+
+func (f *file) Shutdown(ctx context.Context) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.current.Close(); err != nil {
+		logrus.Errorf("failed to close sink file: %v", err)
+	}
+}
+
+// rotate 關閉目前的檔案並開啟新檔案, 同時清除超過保留數量的舊檔案
+func (f *file) rotate() error {
+	if f.current != nil {
+		if err := f.current.Close(); err != nil {
+			return fmt.Errorf("failed to close sink file: %w", err)
+		}
+	}
+
+	name := filepath.Join(f.dir, fmt.Sprintf("%s-%s%s", f.prefix, time.Now().Format("20060102T150405.000000000"), f.extension()))
+	current, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open sink file %v: %w", name, err)
+	}
+
+	f.current = current
+	f.size = 0
+
+	if f.maxBackups <= 0 {
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(f.dir, f.prefix+"-*"+f.extension()))
+	if err != nil {
+		return fmt.Errorf("failed to list sink files: %w", err)
+	}
+
+	// 檔名中的時間戳記可直接依字典序排序
+	sort.Strings(files)
+	for len(files) > f.maxBackups {
+		if err := os.Remove(files[0]); err != nil {
+			return fmt.Errorf("failed to remove expired sink file %v: %w", files[0], err)
+		}
+		files = files[1:]
+	}
+
+	return nil
+}
+
+// encode 將 change event 編碼為一筆紀錄, json 以換行分隔 (JSONL), protobuf 則在前方加上 varint 長度
+func (f *file) encode(event *storage.ChangeEvent) ([]byte, error) {
+	payload, err := f.codec.Encode(event)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.codec.Name() == "protobuf" {
+		record := protowire.AppendVarint(nil, uint64(len(payload)))
+		return append(record, payload...), nil
+	}
+
+	return append(payload, '\n'), nil
+}
+
+func (f *file) extension() string {
+	if f.codec.Name() == "protobuf" {
+		return ".pb"
+	}
+	return ".jsonl"
+}
@@ -0,0 +1,124 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"practice/internal/storage"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoSink struct {
+	client     *mongo.Client
+	database   string
+	collection string
+}
+
+// NewMongoSink New Sink Mirroring Change Events Into MongoDB Collections
+// @param ctx
+// @param uri         mongodb connection string
+// @param database    target database
+// @param collection  target collection, uses the source table name when empty
+func NewMongoSink(ctx context.Context, uri, database, collection string) Sink {
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		logrus.Panicf("failed to connect mongodb: %v", err)
+	}
+
+	if err := client.Ping(ctx, nil); err != nil {
+		logrus.Panicf("failed to ping mongodb: %v", err)
+	}
+
+	return &mongoSink{
+		client:     client,
+		database:   database,
+		collection: collection,
+	}
+}
+
+func (s *mongoSink) Name() string {
+	return "mongodb"
+}
+
+func (s *mongoSink) Write(ctx context.Context, events []*storage.ChangeEvent) error {
+	// 依 collection 分組, 每個 collection 執行一次 ordered bulk write 以維持異動順序
+	models := map[string][]mongo.WriteModel{}
+	order := []string{}
+
+	for _, event := range events {
+		collection := s.collection
+		if collection == "" {
+			collection = event.Table
+		}
+		if _, ok := models[collection]; !ok {
+			order = append(order, collection)
+		}
+
+		id := documentID(event.PrimaryKey)
+		filter := bson.M{"_id": id}
+
+		switch event.Operation {
+		case storage.OperationInsert, storage.OperationUpdate, storage.OperationRead:
+			document := bson.M{}
+			for column, value := range event.After {
+				document[column] = value
+			}
+			document["_id"] = id
+
+			models[collection] = append(models[collection], mongo.NewReplaceOneModel().
+				SetFilter(filter).
+				SetReplacement(document).
+				SetUpsert(true))
+
+		case storage.OperationDelete:
+			models[collection] = append(models[collection], mongo.NewDeleteOneModel().SetFilter(filter))
+
+		default:
+			return reject(fmt.Errorf("unsupported operation %q on table %v", event.Operation, event.Table))
+		}
+	}
+
+	for _, collection := range order {
+		_, err := s.client.Database(s.database).Collection(collection).BulkWrite(ctx, models[collection], options.BulkWrite().SetOrdered(true))
+		if err != nil {
+			err = fmt.Errorf("failed to bulk write into collection %v: %w", collection, err)
+
+			// 個別文件的寫入錯誤 (e.g. duplicate key) 為資料內容造成, 其他錯誤視為連線問題
+			var bulkErr mongo.BulkWriteException
+			if errors.As(err, &bulkErr) && len(bulkErr.WriteErrors) > 0 && bulkErr.WriteConcernError == nil {
+				return reject(err)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *mongoSink) Shutdown(ctx context.Context) {
+	if err := s.client.Disconnect(ctx); err != nil {
+		logrus.Panicf("failed to disconnect mongodb: %v", err)
+	}
+}
+
+// documentID 以來源資料表的主鍵作為 document 的 _id, 複合主鍵時以子文件表示
+func documentID(primaryKey map[string]interface{}) interface{} {
+	if len(primaryKey) == 1 {
+		for _, value := range primaryKey {
+			return value
+		}
+	}
+
+	id := bson.D{}
+	for column, value := range primaryKey {
+		id = append(id, bson.E{Key: column, Value: value})
+	}
+	sort.Slice(id, func(i, j int) bool { return id[i].Key < id[j].Key })
+
+	return id
+}
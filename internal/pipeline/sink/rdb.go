@@ -0,0 +1,413 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"practice/internal/pipeline/schema"
+	"practice/internal/storage"
+	"practice/internal/storage/rdb"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// 記錄各 pipeline 已寫入的來源位置的資料表
+const offsetTable = "pipeline_offsets"
+
+type rdbSink struct {
+	driver      string
+	target      rdb.Rdb
+	tablePrefix string
+}
+
+// NewRdbSink New Sink Applying Change Events Into Another Relational Database
+// @param ctx
+// @param driver       driver of the target database (mysql or postgresql)
+// @param target       target database instance, owned and closed by the sink
+// @param tablePrefix  prefix added to the source table name when writing into target
+func NewRdbSink(ctx context.Context, driver string, target rdb.Rdb, tablePrefix string) Sink {
+	if driver != "mysql" && driver != "postgresql" {
+		logrus.Panicf("RDB sink driver undifined: %v", driver)
+	}
+
+	s := &rdbSink{
+		driver:      driver,
+		target:      target,
+		tablePrefix: tablePrefix,
+	}
+
+	if err := s.createOffsetTable(ctx); err != nil {
+		logrus.Panicf("failed to create offset table: %v", err)
+	}
+
+	return s
+}
+
+func (s *rdbSink) Name() string {
+	return "rdb"
+}
+
+func (s *rdbSink) Write(ctx context.Context, events []*storage.ChangeEvent) error {
+	return s.write(ctx, events, nil)
+}
+
+func (s *rdbSink) WriteAt(ctx context.Context, pipeline, position string, events []*storage.ChangeEvent) error {
+	return s.write(ctx, events, func(tx *sql.Tx) error {
+		return s.savePosition(ctx, tx, pipeline, position)
+	})
+}
+
+// WriteIsolatedAt 每筆異動之前建立 savepoint, 被拒絕時只回滾到該筆異動之前
+func (s *rdbSink) WriteIsolatedAt(ctx context.Context, pipeline, position string, events []*storage.ChangeEvent, rejected func(index int, err error) error) error {
+	return s.transaction(ctx, func(tx *sql.Tx) error {
+		for i, event := range events {
+			if _, err := tx.ExecContext(ctx, "SAVEPOINT sink_event"); err != nil {
+				return fmt.Errorf("failed to create savepoint: %w", err)
+			}
+
+			err := s.apply(ctx, tx, event)
+			if err == nil {
+				if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT sink_event"); err != nil {
+					return fmt.Errorf("failed to release savepoint: %w", err)
+				}
+				continue
+			}
+			if !errors.Is(err, ErrRejected) {
+				return err
+			}
+
+			// PostgreSQL 在錯誤之後整個交易無法繼續, 回滾到 savepoint 後才能寫入其餘異動
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT sink_event"); err != nil {
+				return fmt.Errorf("failed to rollback to savepoint: %w", err)
+			}
+			if err := rejected(i, err); err != nil {
+				return err
+			}
+		}
+
+		return s.savePosition(ctx, tx, pipeline, position)
+	})
+}
+
+// savePosition 在目標交易中記錄 pipeline 已寫入的來源位置
+func (s *rdbSink) savePosition(ctx context.Context, tx *sql.Tx, pipeline, position string) error {
+	var query string
+	if s.driver == "mysql" {
+		query = fmt.Sprintf("INSERT INTO %s (`pipeline`, `position`, `modified_at`) VALUES (?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE `position` = VALUES(`position`), `modified_at` = VALUES(`modified_at`)", s.quote(offsetTable))
+	} else {
+		query = fmt.Sprintf(`INSERT INTO %s ("pipeline", "position", "modified_at") VALUES ($1, $2, $3) `+
+			`ON CONFLICT ("pipeline") DO UPDATE SET "position" = EXCLUDED."position", "modified_at" = EXCLUDED."modified_at"`, s.quote(offsetTable))
+	}
+
+	if _, err := tx.ExecContext(ctx, query, pipeline, position, time.Now()); err != nil {
+		return fmt.Errorf("failed to save position of pipeline %v: %w", pipeline, err)
+	}
+	return nil
+}
+
+func (s *rdbSink) Position(ctx context.Context, pipeline string) (string, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = %s", s.quote("position"), s.quote(offsetTable), s.quote("pipeline"), s.placeholder(1))
+
+	var position string
+	err := s.target.DB().QueryRowContext(ctx, query, pipeline).Scan(&position)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load position of pipeline %v: %w", pipeline, err)
+	}
+
+	return position, nil
+}
+
+func (s *rdbSink) ApplySchema(ctx context.Context, change *schema.Change) error {
+	table := s.tablePrefix + change.Current.Name
+	existing, err := s.columns(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	if err := change.Err(); err != nil {
+		// 不相容的部分只有移除欄位且目標資料表已經手動移除時, 視為已經套用
+		migrated := len(change.Widened()) == len(change.Modified) && !change.Keys
+		for _, column := range change.Dropped {
+			_, ok := existing[column.Name]
+			migrated = migrated && !ok
+		}
+		if !migrated {
+			return err
+		}
+	}
+
+	for _, column := range change.Added {
+		if _, ok := existing[column.Name]; ok {
+			continue
+		}
+
+		// 新增的欄位一律允許 NULL, 目標資料表中既有的資料列不會有此欄位的內容
+		typ := schema.ConvertType(change.Current.Source, s.driver, column.Type)
+		statement := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", s.quote(table), s.quote(column.Name), typ)
+		if _, err := s.target.DB().ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to add column %v to %v: %w", column.Name, table, err)
+		}
+
+		logrus.Infof("rdb sink added column %v %v to %v (schema version %v)", column.Name, typ, table, change.Current.Version)
+	}
+
+	for _, column := range change.Widened() {
+		nullable, ok := existing[column.Name]
+		if !ok {
+			continue
+		}
+
+		typ := schema.ConvertType(change.Current.Source, s.driver, column.To)
+		statement := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s", s.quote(table), s.quote(column.Name), typ)
+		if s.driver == "mysql" {
+			// MODIFY 需要完整的欄位定義, 保留目標欄位原本是否允許 NULL
+			null := "NOT NULL"
+			if nullable {
+				null = "NULL"
+			}
+			statement = fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s %s", s.quote(table), s.quote(column.Name), typ, null)
+		}
+		if _, err := s.target.DB().ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to change type of column %v in %v: %w", column.Name, table, err)
+		}
+
+		logrus.Infof("rdb sink changed column %v of %v from %v to %v (schema version %v)", column.Name, table, column.From, typ, change.Current.Version)
+	}
+
+	return nil
+}
+
+func (s *rdbSink) Shutdown(ctx context.Context) {
+	s.target.Shutdown(ctx)
+}
+
+// write 在同一個目標交易中套用所有異動, 並在提交前執行 beforeCommit
+func (s *rdbSink) write(ctx context.Context, events []*storage.ChangeEvent, beforeCommit func(*sql.Tx) error) error {
+	return s.transaction(ctx, func(tx *sql.Tx) error {
+		for _, event := range events {
+			if err := s.apply(ctx, tx, event); err != nil {
+				return err
+			}
+		}
+
+		if beforeCommit != nil {
+			return beforeCommit(tx)
+		}
+		return nil
+	})
+}
+
+// transaction 在目標交易中執行 fn, fn 回傳錯誤時回滾
+func (s *rdbSink) transaction(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := s.target.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// columns 取得目標資料表現有的欄位, 值為欄位是否允許 NULL
+func (s *rdbSink) columns(ctx context.Context, table string) (map[string]bool, error) {
+	query := "SELECT COLUMN_NAME, IS_NULLABLE = 'YES' FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?"
+	if s.driver == "postgresql" {
+		query = "SELECT column_name, is_nullable = 'YES' FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1"
+	}
+
+	rows, err := s.target.DB().QueryContext(ctx, query, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns of %v: %w", table, err)
+	}
+	defer rows.Close()
+
+	columns := map[string]bool{}
+	for rows.Next() {
+		var column string
+		var nullable bool
+		if err := rows.Scan(&column, &nullable); err != nil {
+			return nil, fmt.Errorf("failed to scan columns of %v: %w", table, err)
+		}
+		columns[column] = nullable
+	}
+
+	return columns, rows.Err()
+}
+
+func (s *rdbSink) createOffsetTable(ctx context.Context) error {
+	var statement string
+	if s.driver == "mysql" {
+		statement = "CREATE TABLE IF NOT EXISTS `" + offsetTable + "` (" +
+			"`pipeline` varchar(255) NOT NULL COMMENT 'pipeline 名稱', " +
+			"`position` varchar(255) NOT NULL COMMENT '已寫入的來源位置', " +
+			"`modified_at` datetime NOT NULL COMMENT '修改日期', " +
+			"PRIMARY KEY (`pipeline`)" +
+			") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='pipeline 消費進度'"
+	} else {
+		statement = "CREATE TABLE IF NOT EXISTS " + offsetTable + " (" +
+			"pipeline VARCHAR(255) PRIMARY KEY, " +
+			"position VARCHAR(255) NOT NULL, " +
+			"modified_at TIMESTAMP NOT NULL" +
+			")"
+	}
+
+	_, err := s.target.DB().ExecContext(ctx, statement)
+	return err
+}
+
+// apply 將單筆 change event 套用至目標資料表, insert 與 update 皆以 upsert 處理以保持冪等
+func (s *rdbSink) apply(ctx context.Context, tx *sql.Tx, event *storage.ChangeEvent) error {
+	table := s.quote(s.tablePrefix + event.Table)
+
+	keys := make([]string, 0, len(event.PrimaryKey))
+	for column := range event.PrimaryKey {
+		keys = append(keys, column)
+	}
+	sort.Strings(keys)
+
+	if len(keys) == 0 {
+		return reject(fmt.Errorf("missing primary key of %v event on table %v", event.Operation, event.Table))
+	}
+
+	switch event.Operation {
+	case storage.OperationInsert, storage.OperationUpdate, storage.OperationRead:
+		columns := make([]string, 0, len(event.After))
+		for column := range event.After {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+
+		quoted := make([]string, len(columns))
+		holders := make([]string, len(columns))
+		args := make([]interface{}, len(columns))
+		for i, column := range columns {
+			quoted[i] = s.quote(column)
+			holders[i] = s.placeholder(i + 1)
+			args[i] = event.After[column]
+		}
+
+		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) %s",
+			table,
+			strings.Join(quoted, ", "),
+			strings.Join(holders, ", "),
+			s.upsertClause(keys, columns),
+		)
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return rejectIfInvalid(fmt.Errorf("failed to upsert into %v: %w", table, err))
+		}
+
+	case storage.OperationDelete:
+		conditions := make([]string, len(keys))
+		args := make([]interface{}, len(keys))
+		for i, column := range keys {
+			conditions[i] = fmt.Sprintf("%s = %s", s.quote(column), s.placeholder(i+1))
+			args[i] = event.PrimaryKey[column]
+		}
+
+		query := fmt.Sprintf("DELETE FROM %s WHERE %s", table, strings.Join(conditions, " AND "))
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return rejectIfInvalid(fmt.Errorf("failed to delete from %v: %w", table, err))
+		}
+
+	default:
+		return reject(fmt.Errorf("unsupported operation %q on table %v", event.Operation, event.Table))
+	}
+
+	return nil
+}
+
+// rejectIfInvalid 將資料內容造成的錯誤 (違反限制、型別或長度不符) 標記為 ErrRejected
+func rejectIfInvalid(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// class 22: data exception, class 23: integrity constraint violation
+		if class := pqErr.Code.Class(); class == "22" || class == "23" {
+			return reject(err)
+		}
+		return err
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1048, // column cannot be null
+			1062,       // duplicate entry
+			1264,       // out of range value
+			1292,       // incorrect datetime value
+			1364,       // field doesn't have a default value
+			1366,       // incorrect integer value
+			1406,       // data too long
+			1451, 1452, // foreign key constraint fails
+			3819: // check constraint is violated
+			return reject(err)
+		}
+	}
+
+	return err
+}
+
+func (s *rdbSink) quote(identifier string) string {
+	if s.driver == "mysql" {
+		return "`" + identifier + "`"
+	}
+	return `"` + identifier + `"`
+}
+
+func (s *rdbSink) placeholder(n int) string {
+	if s.driver == "mysql" {
+		return "?"
+	}
+	return fmt.Sprintf("$%d", n)
+}
+
+func (s *rdbSink) upsertClause(keys, columns []string) string {
+	isKey := map[string]bool{}
+	quotedKeys := make([]string, len(keys))
+	for i, column := range keys {
+		isKey[column] = true
+		quotedKeys[i] = s.quote(column)
+	}
+
+	sets := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if isKey[column] {
+			continue
+		}
+
+		if s.driver == "mysql" {
+			sets = append(sets, fmt.Sprintf("%s = VALUES(%s)", s.quote(column), s.quote(column)))
+		} else {
+			sets = append(sets, fmt.Sprintf("%s = EXCLUDED.%s", s.quote(column), s.quote(column)))
+		}
+	}
+
+	if s.driver == "mysql" {
+		if len(sets) == 0 {
+			return fmt.Sprintf("ON DUPLICATE KEY UPDATE %s = %s", quotedKeys[0], quotedKeys[0])
+		}
+		return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+	}
+
+	if len(sets) == 0 {
+		return fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", strings.Join(quotedKeys, ", "))
+	}
+	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(quotedKeys, ", "), strings.Join(sets, ", "))
+}
@@ -0,0 +1,61 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"practice/internal/pipeline/schema"
+	"practice/internal/storage"
+)
+
+// ErrRejected 表示下游拒絕特定的異動 (e.g. 違反限制、無法編碼), 重試也不會成功, 應移至 dead-letter queue
+// 其他錯誤 (e.g. 連線中斷) 視為下游暫時無法使用
+var ErrRejected = errors.New("change event rejected by sink")
+
+type rejectedError struct {
+	err error
+}
+
+func (e *rejectedError) Error() string        { return e.err.Error() }
+func (e *rejectedError) Unwrap() error        { return e.err }
+func (e *rejectedError) Is(target error) bool { return target == ErrRejected }
+
+// reject 將錯誤標記為 ErrRejected, 並保留原本的錯誤
+func reject(err error) error {
+	return &rejectedError{err: err}
+}
+
+type Sink interface {
+	// sink 名稱, 用來區分各 sink 的消費進度
+	Name() string
+
+	// 將一批 change events 寫入下游
+	Write(ctx context.Context, events []*storage.ChangeEvent) error
+
+	// 釋放 sink 持有的資源 (file handle, connection, etc.)
+	Shutdown(ctx context.Context)
+}
+
+// Transactional 由本身即為資料庫的 sink 實作, 異動與來源位置寫在同一個目標交易中,
+// 重啟時由目標資料庫中的來源位置繼續消費, 藉此達成 exactly-once
+type Transactional interface {
+	Sink
+
+	// 在同一個目標交易中寫入異動以及 pipeline 已消費的來源位置
+	WriteAt(ctx context.Context, pipeline, position string, events []*storage.ChangeEvent) error
+
+	// 與 WriteAt 相同, 但被拒絕 (ErrRejected) 的異動只回滾該筆異動並交給 rejected, 其餘異動與來源位置照常寫入
+	// rejected 在目標交易 commit 之前呼叫, 回傳錯誤時整個交易回滾, 異動與來源位置都不會寫入
+	WriteIsolatedAt(ctx context.Context, pipeline, position string, events []*storage.ChangeEvent, rejected func(index int, err error) error) error
+
+	// 取得 pipeline 已寫入的來源位置, 尚未寫入過時回傳空字串
+	Position(ctx context.Context, pipeline string) (string, error)
+}
+
+// SchemaAware 由具有固定結構的 sink 實作, 來源資料表結構異動時由 pipeline 在寫入異動之前通知
+// 相容的異動 (e.g. 新增欄位, 放寬欄位型別) 自動套用至下游, 不相容的異動回傳 schema.ErrIncompatible 讓 pipeline 停止
+type SchemaAware interface {
+	Sink
+
+	// 套用來源資料表相鄰兩個版本之間的結構異動, 重複套用同一個異動不會產生錯誤
+	ApplySchema(ctx context.Context, change *schema.Change) error
+}
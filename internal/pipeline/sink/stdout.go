@@ -0,0 +1,47 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"practice/internal/storage"
+	"practice/internal/storage/codec"
+	"sync"
+)
+
+type stdout struct {
+	mu    sync.Mutex
+	codec codec.Codec
+}
+
+// NewStdoutSink New JSONL Sink Writing To Standard Output
+// @param ctx
+func NewStdoutSink(ctx context.Context) Sink {
+	return &stdout{
+		codec: codec.NewJSONCodec(),
+	}
+}
+
+func (s *stdout) Name() string {
+	return "stdout"
+}
+
+func (s *stdout) Write(ctx context.Context, events []*storage.ChangeEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, event := range events {
+		line, err := s.codec.Encode(event)
+		if err != nil {
+			return reject(err)
+		}
+
+		if _, err := os.Stdout.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("failed to write change event into stdout: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *stdout) Shutdown(ctx context.Context) {}
@@ -0,0 +1,549 @@
+package source
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"practice/internal/pipeline/schema"
+	"practice/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/sirupsen/logrus"
+)
+
+// 單次 Read 等待新交易的時間上限
+const readIdleTimeout = time.Second
+
+type binlog struct {
+	db       *sql.DB
+	database string
+	captured []string // 擷取的資料表, 為空時擷取所有資料表
+	include  func(string) bool
+	registry *schema.Registry
+	syncer   *replication.BinlogSyncer
+	streamer *replication.BinlogStreamer
+
+	file   string            // 目前讀取中的 binlog 檔案
+	tables map[string]*table // 資料表欄位資訊快取
+	events []*storage.ChangeEvent
+	gtid   string
+}
+
+// NewBinlogSource New MySQL Binlog Source
+// @param ctx
+// @param db        connection for reading table metadata from information_schema
+// @param host      mysql host
+// @param port      mysql port
+// @param user      replication user
+// @param password  replication password
+// @param database  only captures tables in this database
+// @param serverID  unique server id used to register as a replica
+// @param tables    captured tables, empty captures all tables in database
+// @param registry  schema registry recording every version of the captured tables
+func NewBinlogSource(ctx context.Context, db *sql.DB, host string, port uint16, user, password, database string, serverID uint32, tables []string, registry *schema.Registry) Source {
+	syncer := replication.NewBinlogSyncer(replication.BinlogSyncerConfig{
+		ServerID:  serverID,
+		Flavor:    gomysql.MySQLFlavor,
+		Host:      host,
+		Port:      port,
+		User:      user,
+		Password:  password,
+		ParseTime: true,
+		Logger:    logrus.StandardLogger(),
+	})
+
+	return &binlog{
+		db:       db,
+		database: database,
+		captured: tables,
+		include:  tableFilter(tables),
+		registry: registry,
+		syncer:   syncer,
+		tables:   map[string]*table{},
+	}
+}
+
+func (b *binlog) Start(ctx context.Context, position string) error {
+	var pos gomysql.Position
+	var err error
+
+	if position == "" {
+		pos, err = b.current(ctx, b.db)
+	} else {
+		pos, err = parseBinlogPosition(position)
+	}
+	if err != nil {
+		return err
+	}
+
+	b.streamer, err = b.syncer.StartSync(pos)
+	if err != nil {
+		return fmt.Errorf("failed to start binlog sync from %v: %w", pos, err)
+	}
+	b.file = pos.Name
+
+	logrus.Infof("binlog source started from %v:%v", pos.Name, pos.Pos)
+	return nil
+}
+
+func (b *binlog) Position(ctx context.Context) (string, error) {
+	pos, err := b.current(ctx, b.db)
+	if err != nil {
+		return "", err
+	}
+	return formatBinlogPosition(pos), nil
+}
+
+func (b *binlog) Read(ctx context.Context) (*Batch, error) {
+	for {
+		c, cancel := context.WithTimeout(ctx, readIdleTimeout)
+		ev, err := b.streamer.GetEvent(c)
+		cancel()
+
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read binlog event: %w", err)
+		}
+
+		switch e := ev.Event.(type) {
+		case *replication.RotateEvent:
+			b.file = string(e.NextLogName)
+
+		case *replication.GTIDEvent:
+			b.gtid = gtidString(e)
+
+		case *replication.RowsEvent:
+			if err := b.appendRows(ctx, ev.Header, e); err != nil {
+				return nil, err
+			}
+
+		case *replication.XIDEvent:
+			// XID event 代表交易提交, 位置為下一個 event 的起點
+			batch := b.commit(ev.Header, strconv.FormatUint(e.XID, 10))
+			if batch != nil {
+				return batch, nil
+			}
+
+		case *replication.QueryEvent:
+			// 非 InnoDB 的交易不會產生 XID event, 而是以 COMMIT 語句結束
+			switch query := strings.ToUpper(strings.TrimSpace(string(e.Query))); {
+			case query == "COMMIT":
+				if batch := b.commit(ev.Header, ""); batch != nil {
+					return batch, nil
+				}
+			case query != "BEGIN":
+				// DDL 以 query event 記錄, 清除欄位資訊快取, 下一筆資料列異動時重新取得並註冊新的結構版本
+				logrus.Infof("binlog DDL detected: %v", string(e.Query))
+				b.tables = map[string]*table{}
+			}
+		}
+	}
+}
+
+func (b *binlog) Ack(ctx context.Context, position string) error {
+	// binlog 由 MySQL 依照 binlog_expire_logs_seconds 自行清除, 不需要回報消費進度
+	return nil
+}
+
+func (b *binlog) Compare(x, y string) int {
+	px, errX := parseBinlogPosition(x)
+	py, errY := parseBinlogPosition(y)
+	if errX != nil || errY != nil {
+		return strings.Compare(x, y)
+	}
+	return px.Compare(py)
+}
+
+func (b *binlog) Shutdown(ctx context.Context) {
+	b.syncer.Close()
+}
+
+// Snapshot 以 START TRANSACTION WITH CONSISTENT SNAPSHOT 讀出既有資料
+// 開啟快照與取得 binlog 位置期間以 FLUSH TABLES WITH READ LOCK 暫停寫入, 確保快照內容恰好對應該 binlog 位置
+func (b *binlog) Snapshot(ctx context.Context, chunkSize int, emit func(*Batch) error) (string, error) {
+	conn, err := b.db.Conn(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get connection for snapshot: %w", err)
+	}
+	defer conn.Close()
+
+	// 交易結束或失敗時都必須釋放全域讀鎖, 否則來源資料庫會無法寫入
+	locked := false
+	defer func() {
+		if locked {
+			_, _ = conn.ExecContext(context.Background(), "UNLOCK TABLES")
+		}
+		_, _ = conn.ExecContext(context.Background(), "ROLLBACK")
+	}()
+
+	// 只影響下一個交易, 不會改變連線池中這個連線的預設隔離等級
+	if _, err := conn.ExecContext(ctx, "SET TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+		return "", fmt.Errorf("failed to set snapshot isolation level: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "FLUSH TABLES WITH READ LOCK"); err != nil {
+		return "", fmt.Errorf("failed to acquire global read lock: %w", err)
+	}
+	locked = true
+
+	if _, err := conn.ExecContext(ctx, "START TRANSACTION WITH CONSISTENT SNAPSHOT, READ ONLY"); err != nil {
+		return "", fmt.Errorf("failed to start consistent snapshot: %w", err)
+	}
+	pos, err := b.current(ctx, conn)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := conn.ExecContext(ctx, "UNLOCK TABLES"); err != nil {
+		return "", fmt.Errorf("failed to release global read lock: %w", err)
+	}
+	locked = false
+
+	position := formatBinlogPosition(pos)
+	logrus.Infof("consistent snapshot started at binlog position %v", position)
+
+	tables, err := b.SnapshotTables(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	d := newDumper(conn, "mysql", b.database, position, chunkSize)
+	for _, name := range tables {
+		t, err := b.table(ctx, name, nil)
+		if err != nil {
+			return "", err
+		}
+
+		total, err := d.dump(ctx, name, t, emit)
+		if err != nil {
+			return "", err
+		}
+		logrus.Infof("snapshot of %v.%v finished, %v rows.", b.database, name, total)
+	}
+
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		return "", fmt.Errorf("failed to commit consistent snapshot: %w", err)
+	}
+
+	return position, nil
+}
+
+func (b *binlog) SnapshotTables(ctx context.Context) ([]string, error) {
+	return listMysqlTables(ctx, b.db, b.database, b.captured)
+}
+
+func (b *binlog) ReadChunk(ctx context.Context, name string, after map[string]interface{}, chunkSize int) ([]*storage.ChangeEvent, error) {
+	t, err := b.table(ctx, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	return newDumper(b.db, "mysql", b.database, "", chunkSize).chunk(ctx, name, t, after)
+}
+
+func (b *binlog) Signal(ctx context.Context, signalTable, id, kind, data string) error {
+	return signal(ctx, b.db, "mysql", signalTable, id, kind, data)
+}
+
+// current 取得 MySQL 目前寫入中的 binlog 位置
+func (b *binlog) current(ctx context.Context, q querier) (gomysql.Position, error) {
+	rows, err := q.QueryContext(ctx, "SHOW MASTER STATUS")
+	if err != nil {
+		return gomysql.Position{}, fmt.Errorf("failed to show master status: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return gomysql.Position{}, err
+	}
+
+	if !rows.Next() {
+		return gomysql.Position{}, fmt.Errorf("binlog is not enabled on mysql")
+	}
+
+	// SHOW MASTER STATUS 的欄位數量依版本而不同, 只取前兩個欄位
+	values := make([]interface{}, len(columns))
+	var file string
+	var pos uint32
+	values[0], values[1] = &file, &pos
+	for i := 2; i < len(values); i++ {
+		values[i] = new(sql.RawBytes)
+	}
+	if err := rows.Scan(values...); err != nil {
+		return gomysql.Position{}, fmt.Errorf("failed to scan master status: %w", err)
+	}
+
+	return gomysql.Position{Name: file, Pos: pos}, nil
+}
+
+func (b *binlog) appendRows(ctx context.Context, header *replication.EventHeader, e *replication.RowsEvent) error {
+	if string(e.Table.Schema) != b.database || !b.include(string(e.Table.Table)) {
+		return nil
+	}
+
+	t, err := b.table(ctx, string(e.Table.Table), e.Table)
+	if err != nil {
+		return err
+	}
+
+	var operation string
+	switch header.EventType {
+	case replication.WRITE_ROWS_EVENTv0, replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2:
+		operation = storage.OperationInsert
+	case replication.UPDATE_ROWS_EVENTv0, replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2:
+		operation = storage.OperationUpdate
+	case replication.DELETE_ROWS_EVENTv0, replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2:
+		operation = storage.OperationDelete
+	default:
+		return nil
+	}
+
+	// update 事件的 rows 為 (before, after) 成對排列
+	step := 1
+	if operation == storage.OperationUpdate {
+		step = 2
+	}
+
+	for i := 0; i+step-1 < len(e.Rows); i += step {
+		event := &storage.ChangeEvent{
+			Version:       storage.ChangeEventVersion,
+			Source:        "mysql",
+			Database:      b.database,
+			Table:         string(e.Table.Table),
+			Operation:     operation,
+			SchemaVersion: t.version(),
+		}
+
+		switch operation {
+		case storage.OperationInsert:
+			event.After = t.rowOf(e.Rows[i])
+			event.PrimaryKey = t.primaryKeyOf(event.After)
+		case storage.OperationUpdate:
+			event.Before = t.rowOf(e.Rows[i])
+			event.After = t.rowOf(e.Rows[i+1])
+			event.PrimaryKey = t.primaryKeyOf(event.After)
+		case storage.OperationDelete:
+			event.Before = t.rowOf(e.Rows[i])
+			event.PrimaryKey = t.primaryKeyOf(event.Before)
+		}
+
+		b.events = append(b.events, event)
+	}
+
+	return nil
+}
+
+// commit 結束目前的交易, 並為交易內所有異動補上來源位置與提交時間
+func (b *binlog) commit(header *replication.EventHeader, xid string) *Batch {
+	position := formatBinlogPosition(gomysql.Position{Name: b.file, Pos: header.LogPos})
+	events := b.events
+	transactionID := b.gtid
+	if transactionID == "" {
+		transactionID = xid
+	}
+
+	b.events = nil
+	b.gtid = ""
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	for _, event := range events {
+		event.Position = position
+		event.TransactionID = transactionID
+		event.CommitTimestamp = time.Unix(int64(header.Timestamp), 0)
+	}
+
+	return &Batch{Events: events, Position: position}
+}
+
+// table 取得資料表欄位資訊, 快取與 table map event 的欄位不一致時重新取得並註冊新的結構版本
+func (b *binlog) table(ctx context.Context, name string, tm *replication.TableMapEvent) (*table, error) {
+	if t, ok := b.tables[name]; ok && t.matches(tm) {
+		return t, nil
+	}
+
+	t, err := loadMysqlTable(ctx, b.db, b.database, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// binlog_row_metadata = FULL 時以 table map event 的欄位為準
+	// 消費落後於 DDL 時, information_schema 已經是較新的結構, 不能用來解析較舊的資料列
+	if tm != nil && len(tm.ColumnName) > 0 {
+		t = t.fromTableMap(tm)
+	}
+
+	if _, err := t.register(b.registry, "mysql", b.database, name); err != nil {
+		return nil, err
+	}
+
+	b.tables[name] = t
+	return t, nil
+}
+
+// matches 判斷欄位資訊是否與 table map event 一致
+func (t *table) matches(tm *replication.TableMapEvent) bool {
+	if tm == nil {
+		return true
+	}
+
+	if len(tm.ColumnName) == 0 {
+		return int(tm.ColumnCount) == len(t.columns)
+	}
+
+	if len(tm.ColumnName) != len(t.columns) {
+		return false
+	}
+	for i, column := range tm.ColumnName {
+		if string(column) != t.columns[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// fromTableMap 以 table map event 的欄位名稱、unsigned 與主鍵資訊重建欄位資訊, 欄位型別盡量沿用 information_schema
+func (t *table) fromTableMap(tm *replication.TableMapEvent) *table {
+	types := map[string]string{}
+	for i, column := range t.columns {
+		types[column] = t.types[i]
+	}
+
+	rebuilt := &table{unsigned: map[string]bool{}}
+	unsigned := tm.UnsignedMap()
+	for i, name := range tm.ColumnNameString() {
+		typ, ok := types[name]
+		if !ok {
+			typ = mysqlTypeName(tm.ColumnType[i])
+			if unsigned[i] {
+				typ += " unsigned"
+			}
+		}
+
+		rebuilt.columns = append(rebuilt.columns, name)
+		rebuilt.types = append(rebuilt.types, typ)
+		if unsigned != nil && unsigned[i] || unsigned == nil && t.unsigned[name] {
+			rebuilt.unsigned[name] = true
+		}
+	}
+
+	if len(tm.PrimaryKey) > 0 {
+		for _, idx := range tm.PrimaryKey {
+			rebuilt.primaryKey = append(rebuilt.primaryKey, rebuilt.columns[idx])
+		}
+	} else {
+		rebuilt.primaryKey = t.primaryKey
+	}
+
+	return rebuilt
+}
+
+// mysqlTypeName 將 binlog 的欄位型別代碼轉換成型別名稱, 只在 information_schema 找不到欄位時使用
+func mysqlTypeName(typ byte) string {
+	switch typ {
+	case gomysql.MYSQL_TYPE_TINY:
+		return "tinyint"
+	case gomysql.MYSQL_TYPE_SHORT:
+		return "smallint"
+	case gomysql.MYSQL_TYPE_INT24:
+		return "mediumint"
+	case gomysql.MYSQL_TYPE_LONG:
+		return "int"
+	case gomysql.MYSQL_TYPE_LONGLONG:
+		return "bigint"
+	case gomysql.MYSQL_TYPE_FLOAT:
+		return "float"
+	case gomysql.MYSQL_TYPE_DOUBLE:
+		return "double"
+	case gomysql.MYSQL_TYPE_DECIMAL, gomysql.MYSQL_TYPE_NEWDECIMAL:
+		return "decimal"
+	case gomysql.MYSQL_TYPE_DATE, gomysql.MYSQL_TYPE_NEWDATE:
+		return "date"
+	case gomysql.MYSQL_TYPE_DATETIME, gomysql.MYSQL_TYPE_DATETIME2:
+		return "datetime"
+	case gomysql.MYSQL_TYPE_TIMESTAMP, gomysql.MYSQL_TYPE_TIMESTAMP2:
+		return "timestamp"
+	case gomysql.MYSQL_TYPE_TIME, gomysql.MYSQL_TYPE_TIME2:
+		return "time"
+	case gomysql.MYSQL_TYPE_JSON:
+		return "json"
+	case gomysql.MYSQL_TYPE_BLOB, gomysql.MYSQL_TYPE_TINY_BLOB, gomysql.MYSQL_TYPE_MEDIUM_BLOB, gomysql.MYSQL_TYPE_LONG_BLOB:
+		return "blob"
+	case gomysql.MYSQL_TYPE_VARCHAR, gomysql.MYSQL_TYPE_VAR_STRING:
+		return "varchar(255)"
+	}
+	return "text"
+}
+
+// rowOf 依照欄位順序將 binlog 資料列轉換成欄位內容, 並修正 unsigned 與字串欄位的型別
+func (t *table) rowOf(values []interface{}) map[string]interface{} {
+	row := make(map[string]interface{}, len(values))
+
+	for i, value := range values {
+		if i >= len(t.columns) {
+			break
+		}
+		column := t.columns[i]
+
+		switch v := value.(type) {
+		case []byte:
+			value = string(v)
+		case int8:
+			if t.unsigned[column] {
+				value = uint8(v)
+			}
+		case int16:
+			if t.unsigned[column] {
+				value = uint16(v)
+			}
+		case int32:
+			if t.unsigned[column] {
+				value = uint32(v)
+			}
+		case int64:
+			if t.unsigned[column] {
+				value = uint64(v)
+			}
+		}
+
+		row[column] = value
+	}
+
+	return row
+}
+
+func gtidString(e *replication.GTIDEvent) string {
+	if len(e.SID) != 16 {
+		return ""
+	}
+
+	sid := fmt.Sprintf("%x", e.SID)
+	return fmt.Sprintf("%s-%s-%s-%s-%s:%d", sid[0:8], sid[8:12], sid[12:16], sid[16:20], sid[20:], e.GNO)
+}
+
+// formatBinlogPosition 將 binlog 位置格式化為 {file}:{pos}
+func formatBinlogPosition(pos gomysql.Position) string {
+	return fmt.Sprintf("%s:%d", pos.Name, pos.Pos)
+}
+
+func parseBinlogPosition(position string) (gomysql.Position, error) {
+	idx := strings.LastIndex(position, ":")
+	if idx < 0 {
+		return gomysql.Position{}, fmt.Errorf("invalid binlog position %q", position)
+	}
+
+	pos, err := strconv.ParseUint(position[idx+1:], 10, 32)
+	if err != nil {
+		return gomysql.Position{}, fmt.Errorf("invalid binlog position %q: %w", position, err)
+	}
+
+	return gomysql.Position{Name: position[:idx], Pos: uint32(pos)}, nil
+}
@@ -0,0 +1,585 @@
+package source
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"practice/internal/pipeline/schema"
+	"practice/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 單次從 replication slot 取出的異動數量上限 (實際會以完整交易為單位回傳)
+const logicalPeekChanges = 1000
+
+type logical struct {
+	db             *sql.DB
+	replicationDSN string
+	database       string
+	slot           string
+	captured       []string // 擷取的資料表, 為空時擷取所有資料表
+	include        func(string) bool
+	registry       *schema.Registry
+
+	tables  map[string]*table
+	pending []*Batch // 已從 slot 取出但尚未回傳的交易
+	last    string   // 最後一個回傳交易的 LSN, 用來略過尚未 ack 而被重複取出的交易
+}
+
+// NewLogicalSource New PostgreSQL Logical Decoding Source
+// Changes are consumed from a replication slot with the built-in test_decoding plugin through the SQL interface,
+// so it requires wal_level = logical on the server.
+// @param ctx
+// @param db              postgres connection
+// @param replicationDSN  dsn opening a replication connection (replication=database), used to export the initial snapshot
+// @param database        database name recorded in change events
+// @param slot            logical replication slot name, created when missing
+// @param tables          captured tables, empty captures all tables
+// @param registry        schema registry recording every version of the captured tables
+func NewLogicalSource(ctx context.Context, db *sql.DB, replicationDSN, database, slot string, tables []string, registry *schema.Registry) Source {
+	return &logical{
+		db:             db,
+		replicationDSN: replicationDSN,
+		database:       database,
+		slot:           slot,
+		captured:       tables,
+		include:        tableFilter(tables),
+		registry:       registry,
+		tables:         map[string]*table{},
+	}
+}
+
+func (l *logical) Start(ctx context.Context, position string) error {
+	if err := l.ensureSlot(ctx); err != nil {
+		return err
+	}
+
+	// 下游已經寫入但 slot 尚未 ack 的交易 (e.g. 寫入後、ack 前中斷), 直接將 slot 推進至下游紀錄的位置
+	if position != "" {
+		if err := l.Ack(ctx, position); err != nil {
+			return err
+		}
+		l.last = position
+	}
+
+	logrus.Infof("logical decoding source started from slot %v at %v", l.slot, position)
+	return nil
+}
+
+func (l *logical) Position(ctx context.Context) (string, error) {
+	// 先建立 slot, 確保之後的異動都會保留在 slot 中
+	if err := l.ensureSlot(ctx); err != nil {
+		return "", err
+	}
+
+	var lsn string
+	if err := l.db.QueryRowContext(ctx, "SELECT pg_current_wal_lsn()::text").Scan(&lsn); err != nil {
+		return "", fmt.Errorf("failed to query current wal lsn: %w", err)
+	}
+	return lsn, nil
+}
+
+func (l *logical) Read(ctx context.Context) (*Batch, error) {
+	if len(l.pending) == 0 {
+		if err := l.peek(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(l.pending) == 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(readIdleTimeout):
+			return nil, nil
+		}
+	}
+
+	batch := l.pending[0]
+	l.pending = l.pending[1:]
+	l.last = batch.Position
+
+	return batch, nil
+}
+
+func (l *logical) Ack(ctx context.Context, position string) error {
+	var confirmed sql.NullString
+	err := l.db.QueryRowContext(ctx, "SELECT confirmed_flush_lsn::text FROM pg_replication_slots WHERE slot_name = $1", l.slot).Scan(&confirmed)
+	if err != nil {
+		return fmt.Errorf("failed to query replication slot %v: %w", l.slot, err)
+	}
+
+	if confirmed.Valid && l.Compare(confirmed.String, position) >= 0 {
+		return nil
+	}
+
+	if _, err := l.db.ExecContext(ctx, "SELECT pg_replication_slot_advance($1, $2::pg_lsn)", l.slot, position); err != nil {
+		return fmt.Errorf("failed to advance replication slot %v to %v: %w", l.slot, position, err)
+	}
+
+	return nil
+}
+
+func (l *logical) Compare(x, y string) int {
+	lx, errX := parseLSN(x)
+	ly, errY := parseLSN(y)
+	if errX != nil || errY != nil {
+		return strings.Compare(x, y)
+	}
+
+	switch {
+	case lx < ly:
+		return -1
+	case lx > ly:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (l *logical) Shutdown(ctx context.Context) {}
+
+// Snapshot 重新建立 replication slot 並匯出建立當下的快照, 以該快照讀出既有資料
+// slot 的 consistent point 恰好對應匯出的快照, 串流時由 slot 取出的異動都發生在快照之後
+func (l *logical) Snapshot(ctx context.Context, chunkSize int, emit func(*Batch) error) (string, error) {
+	// 匯出的快照只在建立 slot 的 replication 連線保持開啟且閒置時有效, 讀取完成前不可關閉
+	replication, err := sql.Open("postgres", l.replicationDSN)
+	if err != nil {
+		return "", fmt.Errorf("failed to open replication connection: %w", err)
+	}
+	defer replication.Close()
+
+	conn, err := replication.Conn(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to open replication connection: %w", err)
+	}
+	defer conn.Close()
+
+	// 新的消費者必須由快照開始, 既有 slot 保留的異動早於快照, 因此重新建立
+	var exists bool
+	err = l.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_replication_slots WHERE slot_name = $1)", l.slot).Scan(&exists)
+	if err != nil {
+		return "", fmt.Errorf("failed to query replication slot %v: %w", l.slot, err)
+	}
+	if exists {
+		logrus.Warnf("replication slot %v already exists, dropped for the initial snapshot.", l.slot)
+		if _, err := l.db.ExecContext(ctx, "SELECT pg_drop_replication_slot($1)", l.slot); err != nil {
+			return "", fmt.Errorf("failed to drop replication slot %v: %w", l.slot, err)
+		}
+	}
+
+	var slotName, position, snapshotName, plugin string
+	err = conn.QueryRowContext(ctx, fmt.Sprintf("CREATE_REPLICATION_SLOT %s LOGICAL test_decoding EXPORT_SNAPSHOT", quoteIdentifier(l.slot))).
+		Scan(&slotName, &position, &snapshotName, &plugin)
+	if err != nil {
+		return "", fmt.Errorf("failed to create replication slot %v with exported snapshot: %w", l.slot, err)
+	}
+	logrus.Infof("replication slot %v created, snapshot %v exported at %v", l.slot, snapshotName, position)
+
+	tx, err := l.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return "", fmt.Errorf("failed to start snapshot transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// SET TRANSACTION SNAPSHOT 不接受參數, snapshot 名稱由伺服器產生 (e.g. 00000003-00000002-1)
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET TRANSACTION SNAPSHOT '%s'", strings.ReplaceAll(snapshotName, "'", "''"))); err != nil {
+		return "", fmt.Errorf("failed to import snapshot %v: %w", snapshotName, err)
+	}
+
+	tables, err := l.SnapshotTables(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	d := newDumper(tx, "postgresql", l.database, position, chunkSize)
+	for _, name := range tables {
+		t, err := l.table(ctx, "public", name, nil)
+		if err != nil {
+			return "", err
+		}
+
+		total, err := d.dump(ctx, name, t, emit)
+		if err != nil {
+			return "", err
+		}
+		logrus.Infof("snapshot of %v.%v finished, %v rows.", l.database, name, total)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit snapshot transaction: %w", err)
+	}
+
+	return position, nil
+}
+
+func (l *logical) SnapshotTables(ctx context.Context) ([]string, error) {
+	return listPostgresTables(ctx, l.db, "public", l.captured)
+}
+
+func (l *logical) ReadChunk(ctx context.Context, name string, after map[string]interface{}, chunkSize int) ([]*storage.ChangeEvent, error) {
+	t, err := l.table(ctx, "public", name, nil)
+	if err != nil {
+		return nil, err
+	}
+	return newDumper(l.db, "postgresql", l.database, "", chunkSize).chunk(ctx, name, t, after)
+}
+
+func (l *logical) Signal(ctx context.Context, signalTable, id, kind, data string) error {
+	return signal(ctx, l.db, "postgresql", signalTable, id, kind, data)
+}
+
+func (l *logical) ensureSlot(ctx context.Context) error {
+	var exists bool
+	err := l.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_replication_slots WHERE slot_name = $1)", l.slot).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to query replication slot %v: %w", l.slot, err)
+	}
+
+	if !exists {
+		if _, err := l.db.ExecContext(ctx, "SELECT pg_create_logical_replication_slot($1, 'test_decoding')", l.slot); err != nil {
+			return fmt.Errorf("failed to create replication slot %v: %w", l.slot, err)
+		}
+		logrus.Infof("replication slot %v created.", l.slot)
+	}
+
+	return nil
+}
+
+// peek 從 replication slot 取出尚未 ack 的交易, 並略過已經回傳過的部分
+func (l *logical) peek(ctx context.Context) error {
+	rows, err := l.db.QueryContext(ctx, `
+	SELECT lsn::text, xid::text, data
+	FROM pg_logical_slot_peek_changes($1, NULL, $2, 'include-xids', '1', 'include-timestamp', '1')`,
+		l.slot, logicalPeekChanges)
+	if err != nil {
+		return fmt.Errorf("failed to peek changes from slot %v: %w", l.slot, err)
+	}
+	defer rows.Close()
+
+	var events []*storage.ChangeEvent
+	for rows.Next() {
+		var lsn, xid, data string
+		if err := rows.Scan(&lsn, &xid, &data); err != nil {
+			return fmt.Errorf("failed to scan changes from slot %v: %w", l.slot, err)
+		}
+
+		switch {
+		case strings.HasPrefix(data, "BEGIN"):
+			events = nil
+
+		case strings.HasPrefix(data, "COMMIT"):
+			// COMMIT 的 LSN 為交易結束的位置, 推進至此位置後不會再收到這筆交易
+			// 不含擷取資料表的交易仍以空的 batch 回傳, 讓 slot 可以跟著推進而不會累積 WAL
+			if l.last != "" && l.Compare(lsn, l.last) <= 0 {
+				events = nil
+				continue
+			}
+
+			committedAt := parseCommitTimestamp(data)
+			for _, event := range events {
+				event.Position = lsn
+				event.TransactionID = xid
+				event.CommitTimestamp = committedAt
+			}
+			l.pending = append(l.pending, &Batch{Events: events, Position: lsn})
+			events = nil
+
+		case strings.HasPrefix(data, "table "):
+			event, err := l.parseChange(ctx, data)
+			if err != nil {
+				return err
+			}
+			if event != nil {
+				events = append(events, event)
+			}
+		}
+	}
+
+	return rows.Err()
+}
+
+// parseChange 解析 test_decoding 的資料列異動, 格式為:
+//
+//	table public.wallets: UPDATE: old-key: id[integer]:1 ... new-tuple: id[integer]:1 amount[integer]:40000 ...
+func (l *logical) parseChange(ctx context.Context, data string) (*storage.ChangeEvent, error) {
+	header := strings.SplitN(strings.TrimPrefix(data, "table "), ": ", 3)
+	if len(header) < 2 {
+		return nil, fmt.Errorf("malformed logical decoding change: %v", data)
+	}
+
+	namespace, name := "public", header[0]
+	if idx := strings.Index(name, "."); idx >= 0 {
+		namespace, name = name[:idx], name[idx+1:]
+	}
+	namespace, name = unquoteIdentifier(namespace), unquoteIdentifier(name)
+	if !l.include(name) {
+		return nil, nil
+	}
+
+	body := ""
+	if len(header) == 3 {
+		body = header[2]
+	}
+
+	event := &storage.ChangeEvent{
+		Version:  storage.ChangeEventVersion,
+		Source:   "postgresql",
+		Database: l.database,
+		Table:    name,
+	}
+
+	// 資料列內容帶有欄位名稱與型別, 用來偵測資料表結構是否已經異動
+	var types map[string]string
+	var err error
+
+	switch header[1] {
+	case "INSERT":
+		event.Operation = storage.OperationInsert
+		if event.After, types, err = parseTuple(body); err != nil {
+			return nil, err
+		}
+
+	case "UPDATE":
+		event.Operation = storage.OperationUpdate
+		before, after := "", body
+		if strings.HasPrefix(body, "old-key: ") {
+			parts := strings.SplitN(strings.TrimPrefix(body, "old-key: "), " new-tuple: ", 2)
+			before, after = parts[0], ""
+			if len(parts) == 2 {
+				after = parts[1]
+			}
+		}
+
+		if before != "" {
+			if event.Before, _, err = parseTuple(before); err != nil {
+				return nil, err
+			}
+		}
+		if event.After, types, err = parseTuple(after); err != nil {
+			return nil, err
+		}
+
+	case "DELETE":
+		event.Operation = storage.OperationDelete
+		// delete 預設只包含主鍵欄位 (REPLICA IDENTITY DEFAULT), 不能用來判斷結構
+		if event.Before, _, err = parseTuple(body); err != nil {
+			return nil, err
+		}
+
+	default:
+		// TRUNCATE 等非資料列異動不轉換成 change event
+		return nil, nil
+	}
+
+	t, err := l.table(ctx, namespace, name, types)
+	if err != nil {
+		return nil, err
+	}
+
+	event.SchemaVersion = t.version()
+	if event.After != nil {
+		event.PrimaryKey = t.primaryKeyOf(event.After)
+	} else {
+		event.PrimaryKey = t.primaryKeyOf(event.Before)
+	}
+
+	return event, nil
+}
+
+// table 取得資料表欄位資訊, 資料列的欄位與快取不一致時 (e.g. ALTER TABLE) 重新取得並註冊新的結構版本
+func (l *logical) table(ctx context.Context, namespace, name string, types map[string]string) (*table, error) {
+	key := namespace + "." + name
+	if t, ok := l.tables[key]; ok && t.matchesTuple(types) {
+		return t, nil
+	}
+
+	t, err := loadPostgresTable(ctx, l.db, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := t.register(l.registry, "postgresql", l.database, name); err != nil {
+		return nil, err
+	}
+
+	l.tables[key] = t
+	return t, nil
+}
+
+// matchesTuple 判斷 test_decoding 資料列的欄位是否與欄位資訊一致
+// test_decoding 輸出的型別不含長度等修飾 (e.g. character varying), 因此只比較基本型別
+func (t *table) matchesTuple(types map[string]string) bool {
+	if types == nil {
+		return true
+	}
+	if len(types) != len(t.columns) {
+		return false
+	}
+
+	for i, column := range t.columns {
+		typ, ok := types[column]
+		if !ok || typ != typeModifier.ReplaceAllString(t.types[i], "") {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTuple 解析 test_decoding 的欄位內容, 格式為 name[type]:value 並以空白分隔, 同時回傳各欄位的型別
+func parseTuple(data string) (map[string]interface{}, map[string]string, error) {
+	row := map[string]interface{}{}
+	types := map[string]string{}
+
+	for data = strings.TrimSpace(data); data != ""; data = strings.TrimSpace(data) {
+		if strings.HasPrefix(data, "(no-tuple-data)") {
+			return nil, nil, nil
+		}
+
+		open := strings.Index(data, "[")
+		if open < 0 {
+			return nil, nil, fmt.Errorf("malformed logical decoding tuple: %v", data)
+		}
+		column := unquoteIdentifier(data[:open])
+
+		// 型別名稱可能包含空白與陣列括號, e.g. character varying, integer[]
+		depth, end := 0, -1
+		for i := open; i < len(data) && end < 0; i++ {
+			switch data[i] {
+			case '[':
+				depth++
+			case ']':
+				depth--
+				if depth == 0 {
+					end = i
+				}
+			}
+		}
+		if end < 0 || end+1 >= len(data) || data[end+1] != ':' {
+			return nil, nil, fmt.Errorf("malformed logical decoding tuple: %v", data)
+		}
+		typ := data[open+1 : end]
+		types[column] = typ
+		data = data[end+2:]
+
+		var raw string
+		quoted := strings.HasPrefix(data, "'")
+		if quoted {
+			// 字串內容中的單引號以兩個單引號表示
+			var b strings.Builder
+			i := 1
+			for ; i < len(data); i++ {
+				if data[i] == '\'' {
+					if i+1 < len(data) && data[i+1] == '\'' {
+						b.WriteByte('\'')
+						i++
+						continue
+					}
+					break
+				}
+				b.WriteByte(data[i])
+			}
+			raw = b.String()
+			if i < len(data) {
+				i++
+			}
+			data = data[i:]
+		} else {
+			idx := strings.Index(data, " ")
+			if idx < 0 {
+				idx = len(data)
+			}
+			raw, data = data[:idx], data[idx:]
+		}
+
+		if !quoted && raw == "null" {
+			row[column] = nil
+			continue
+		}
+		if raw == "unchanged-toast-datum" {
+			continue
+		}
+
+		row[column] = convertPostgresValue(typ, raw)
+	}
+
+	return row, types, nil
+}
+
+func convertPostgresValue(typ, raw string) interface{} {
+	switch typ {
+	case "smallint", "integer", "bigint":
+		if v, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return v
+		}
+	case "real", "double precision", "numeric":
+		if v, err := strconv.ParseFloat(raw, 64); err == nil {
+			return v
+		}
+	case "boolean":
+		return raw == "true"
+	case "date":
+		if v, err := time.Parse("2006-01-02", raw); err == nil {
+			return v
+		}
+	case "timestamp without time zone":
+		if v, err := time.Parse("2006-01-02 15:04:05.999999", raw); err == nil {
+			return v
+		}
+	case "timestamp with time zone":
+		if v, err := time.Parse("2006-01-02 15:04:05.999999-07", raw); err == nil {
+			return v
+		}
+	}
+
+	return raw
+}
+
+// parseCommitTimestamp 解析 COMMIT 1234 (at 2022-12-30 10:00:00.123456+00) 中的提交時間
+func parseCommitTimestamp(data string) time.Time {
+	idx := strings.Index(data, "(at ")
+	if idx < 0 {
+		return time.Time{}
+	}
+
+	value := strings.TrimSuffix(data[idx+4:], ")")
+	committedAt, err := time.Parse("2006-01-02 15:04:05.999999-07", value)
+	if err != nil {
+		return time.Time{}
+	}
+	return committedAt
+}
+
+func quoteIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+func unquoteIdentifier(identifier string) string {
+	if len(identifier) >= 2 && strings.HasPrefix(identifier, `"`) && strings.HasSuffix(identifier, `"`) {
+		return strings.ReplaceAll(identifier[1:len(identifier)-1], `""`, `"`)
+	}
+	return identifier
+}
+
+// parseLSN 將 LSN 文字格式 (e.g. 0/16B6C50) 轉換為數值
+func parseLSN(lsn string) (uint64, error) {
+	parts := strings.SplitN(lsn, "/", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid lsn %q", lsn)
+	}
+
+	hi, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid lsn %q: %w", lsn, err)
+	}
+	lo, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid lsn %q: %w", lsn, err)
+	}
+
+	return hi<<32 | lo, nil
+}
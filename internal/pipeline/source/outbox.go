@@ -0,0 +1,202 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"practice/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OutboxTable 為預設的 outbox 資料表
+const OutboxTable = "outbox_events"
+
+// outbox 讀取應用程式在業務交易中一併寫入 outbox 資料表的事件 (transactional outbox)
+// 每筆事件描述一個 aggregate 的狀態異動, 送出後於 ack 時刪除
+// 以遞增的 id 依序讀取, id 較小的交易較晚提交時會被略過, 寫入 outbox 的交易應保持簡短
+type outbox struct {
+	db        *sql.DB
+	driver    string
+	database  string
+	table     string
+	batchSize int
+	interval  time.Duration
+
+	last int64 // 最後讀出的事件 id
+}
+
+// NewOutboxSource New Transactional Outbox Source
+// @param ctx
+// @param db         source database
+// @param driver     driver of the source database (mysql or postgresql)
+// @param database   source database name
+// @param table      outbox table, see deployments/*/migration/20230110_create_outbox_events.up.sql
+// @param batchSize  events read in one poll
+// @param interval   wait between polls once the outbox is drained
+func NewOutboxSource(ctx context.Context, db *sql.DB, driver, database, table string, batchSize int, interval time.Duration) Source {
+	return &outbox{
+		db:        db,
+		driver:    driver,
+		database:  database,
+		table:     table,
+		batchSize: batchSize,
+		interval:  interval,
+	}
+}
+
+// Start position 為空時從 outbox 中第一筆事件開始, 留在 outbox 中的事件都尚未送出
+func (o *outbox) Start(ctx context.Context, position string) error {
+	if position != "" {
+		last, err := strconv.ParseInt(position, 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse outbox position %q: %w", position, err)
+		}
+		o.last = last
+	}
+
+	logrus.Infof("outbox source started after event %v of %v", o.last, o.table)
+	return nil
+}
+
+func (o *outbox) Position(ctx context.Context) (string, error) {
+	var last sql.NullInt64
+	query := fmt.Sprintf("SELECT MAX(%s) FROM %s", o.quote("id"), o.quote(o.table))
+	if err := o.db.QueryRowContext(ctx, query).Scan(&last); err != nil {
+		return "", fmt.Errorf("failed to query latest event of %v: %w", o.table, err)
+	}
+	return strconv.FormatInt(last.Int64, 10), nil
+}
+
+func (o *outbox) Read(ctx context.Context) (*Batch, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s > %s ORDER BY %s LIMIT %d",
+		strings.Join([]string{o.quote("id"), o.quote("aggregate_type"), o.quote("aggregate_id"), o.quote("operation"), o.quote("payload"), o.quote("created_at")}, ", "),
+		o.quote(o.table), o.quote("id"), o.placeholder(1), o.quote("id"), o.batchSize)
+
+	rows, err := o.db.QueryContext(ctx, query, o.last)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events from %v: %w", o.table, err)
+	}
+	defer rows.Close()
+
+	batch := &Batch{}
+	for rows.Next() {
+		var id int64
+		var aggregateType, aggregateID, operation, payload string
+		var createdAt time.Time
+		if err := rows.Scan(&id, &aggregateType, &aggregateID, &operation, &payload, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan events from %v: %w", o.table, err)
+		}
+
+		event, err := o.event(id, aggregateType, aggregateID, operation, payload, createdAt)
+		if err != nil {
+			return nil, err
+		}
+		batch.Events = append(batch.Events, event)
+		o.last = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(batch.Events) == 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(o.interval):
+		}
+		return nil, nil
+	}
+
+	batch.Position = strconv.FormatInt(o.last, 10)
+	return batch, nil
+}
+
+// Ack 刪除已經送出的事件, 讓 outbox 不會無限成長
+func (o *outbox) Ack(ctx context.Context, position string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s <= %s", o.quote(o.table), o.quote("id"), o.placeholder(1))
+	if _, err := o.db.ExecContext(ctx, query, position); err != nil {
+		return fmt.Errorf("failed to delete delivered events from %v: %w", o.table, err)
+	}
+	return nil
+}
+
+func (o *outbox) Compare(x, y string) int {
+	nx, errX := strconv.ParseInt(x, 10, 64)
+	ny, errY := strconv.ParseInt(y, 10, 64)
+	if errX != nil || errY != nil {
+		return strings.Compare(x, y)
+	}
+
+	switch {
+	case nx < ny:
+		return -1
+	case nx > ny:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (o *outbox) Shutdown(ctx context.Context) {}
+
+// event 將 outbox 事件轉換成 change event, aggregate 對應資料表, payload 為 aggregate 異動後的內容
+func (o *outbox) event(id int64, aggregateType, aggregateID, operation, payload string, createdAt time.Time) (*storage.ChangeEvent, error) {
+	row := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(payload)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&row); err != nil {
+		return nil, fmt.Errorf("failed to decode payload of outbox event %v: %w", id, err)
+	}
+	for column, value := range row {
+		if number, ok := value.(json.Number); ok {
+			if n, err := number.Int64(); err == nil {
+				row[column] = n
+			} else if f, err := number.Float64(); err == nil {
+				row[column] = f
+			}
+		}
+	}
+
+	event := &storage.ChangeEvent{
+		Version:         storage.ChangeEventVersion,
+		Source:          o.driver,
+		Database:        o.database,
+		Table:           aggregateType,
+		Operation:       operation,
+		PrimaryKey:      map[string]interface{}{"id": aggregateID},
+		Position:        strconv.FormatInt(id, 10),
+		TransactionID:   strconv.FormatInt(id, 10),
+		CommitTimestamp: createdAt,
+	}
+
+	switch operation {
+	case storage.OperationInsert, storage.OperationUpdate:
+		event.After = row
+	case storage.OperationDelete:
+		event.Before = row
+	default:
+		return nil, fmt.Errorf("unsupported operation %q of outbox event %v", operation, id)
+	}
+
+	return event, nil
+}
+
+func (o *outbox) quote(identifier string) string {
+	if o.driver == "mysql" {
+		return "`" + identifier + "`"
+	}
+	return quoteIdentifier(identifier)
+}
+
+func (o *outbox) placeholder(n int) string {
+	if o.driver == "mysql" {
+		return "?"
+	}
+	return fmt.Sprintf("$%d", n)
+}
@@ -0,0 +1,374 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"practice/internal/pipeline/schema"
+	"practice/internal/storage"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// polling 以查詢追蹤欄位 (e.g. modified_at) 的方式擷取異動, 不需要 binlog 或 replication slot
+// 無法分辨新增與修改, 一律以 update 表示; 刪除的資料列不會被擷取
+// 追蹤欄位必須在每次寫入時更新, 且提交順序與欄位順序一致, 否則較晚提交的舊時間資料列會被略過
+type polling struct {
+	db        *sql.DB
+	driver    string
+	database  string
+	captured  []string
+	column    string
+	batchSize int
+	interval  time.Duration
+	registry  *schema.Registry
+
+	tables map[string]*table // 資料表欄位資訊快取, 沒有新資料時清除以取得結構異動
+	state  pollingPosition
+	next   int // 下一次輪詢的資料表順序
+}
+
+// pollingPosition 為 polling source 的來源位置
+type pollingPosition struct {
+	Seq     uint64                   `json:"seq"`     // 每讀出一批資料遞增, 用來比較位置先後
+	Cursors map[string][]interface{} `json:"cursors"` // 資料表 -> 最後讀出資料列的追蹤欄位與主鍵內容
+}
+
+// NewPollingSource New Query-Based Polling Source
+// @param ctx
+// @param db         source database
+// @param driver     driver of the source database (mysql or postgresql)
+// @param database   source database name
+// @param tables     captured tables, empty captures all tables in database
+// @param column     tracking column updated on every write, tables without it are tracked by primary key for inserts only
+// @param batchSize  rows read per table in one poll
+// @param interval   wait between polls once every table is drained
+// @param registry   schema registry recording every version of the captured tables
+func NewPollingSource(ctx context.Context, db *sql.DB, driver, database string, tables []string, column string, batchSize int, interval time.Duration, registry *schema.Registry) Source {
+	return &polling{
+		db:        db,
+		driver:    driver,
+		database:  database,
+		captured:  tables,
+		column:    column,
+		batchSize: batchSize,
+		interval:  interval,
+		registry:  registry,
+		tables:    map[string]*table{},
+		state:     pollingPosition{Cursors: map[string][]interface{}{}},
+	}
+}
+
+func (p *polling) Start(ctx context.Context, position string) error {
+	if position == "" {
+		var err error
+		if position, err = p.Position(ctx); err != nil {
+			return err
+		}
+	}
+
+	state, err := parsePollingPosition(position)
+	if err != nil {
+		return err
+	}
+	p.state = state
+
+	logrus.Infof("polling source started from %v", position)
+	return nil
+}
+
+// Position 回傳每個資料表目前最後一筆資料列的位置
+func (p *polling) Position(ctx context.Context) (string, error) {
+	names, err := p.list(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	state := pollingPosition{Seq: p.state.Seq, Cursors: map[string][]interface{}{}}
+	for _, name := range names {
+		t, err := p.table(ctx, name)
+		if err != nil {
+			return "", err
+		}
+
+		cursor := p.cursorColumns(t)
+		quoted := make([]string, len(cursor))
+		for i, column := range cursor {
+			quoted[i] = p.quote(column) + " DESC"
+		}
+
+		query := fmt.Sprintf("SELECT %s FROM %s ORDER BY %s LIMIT 1",
+			p.quoteAll(t.columns), p.quote(name), strings.Join(quoted, ", "))
+		events, err := newDumper(p.db, p.driver, p.database, "", 1).query(ctx, name, t, query)
+		if err != nil {
+			return "", err
+		}
+		if len(events) == 0 {
+			continue
+		}
+
+		values := make([]interface{}, len(cursor))
+		for i, column := range cursor {
+			values[i] = events[0].After[column]
+		}
+		state.Cursors[name] = values
+	}
+
+	return state.String(), nil
+}
+
+func (p *polling) Read(ctx context.Context) (*Batch, error) {
+	names, err := p.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range names {
+		idx := (p.next + i) % len(names)
+		events, err := p.poll(ctx, names[idx], p.batchSize, storage.OperationUpdate)
+		if err != nil {
+			return nil, err
+		}
+		if len(events) == 0 {
+			continue
+		}
+
+		p.next = idx + 1
+		return &Batch{Events: events, Position: p.state.String()}, nil
+	}
+
+	// 所有資料表都沒有新資料時重新取得資料表結構, 讓之後的查詢反映結構異動
+	p.tables = map[string]*table{}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(p.interval):
+	}
+	return nil, nil
+}
+
+func (p *polling) Ack(ctx context.Context, position string) error {
+	return nil
+}
+
+func (p *polling) Compare(x, y string) int {
+	px, errX := parsePollingPosition(x)
+	py, errY := parsePollingPosition(y)
+	if errX != nil || errY != nil {
+		return strings.Compare(x, y)
+	}
+
+	switch {
+	case px.Seq < py.Seq:
+		return -1
+	case px.Seq > py.Seq:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (p *polling) Shutdown(ctx context.Context) {}
+
+// Snapshot 由每個資料表的第一筆資料列開始輪詢直到讀完, 之後由讀完的位置繼續輪詢
+// 快照期間被修改的資料列追蹤欄位會大於快照位置, 串流時會再讀出一次
+func (p *polling) Snapshot(ctx context.Context, chunkSize int, emit func(*Batch) error) (string, error) {
+	names, err := p.list(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	p.state = pollingPosition{Cursors: map[string][]interface{}{}}
+	for _, name := range names {
+		total := 0
+		for {
+			events, err := p.poll(ctx, name, chunkSize, storage.OperationRead)
+			if err != nil {
+				return "", err
+			}
+			if len(events) == 0 {
+				break
+			}
+
+			if err := emit(&Batch{Events: events, Position: p.state.String()}); err != nil {
+				return "", err
+			}
+			total += len(events)
+
+			if len(events) < chunkSize {
+				break
+			}
+		}
+		logrus.Infof("snapshot of %v.%v finished, %v rows.", p.database, name, total)
+	}
+
+	return p.state.String(), nil
+}
+
+// poll 讀出資料表中位置在 cursor 之後的下一段資料列, 並推進 cursor
+func (p *polling) poll(ctx context.Context, name string, limit int, operation string) ([]*storage.ChangeEvent, error) {
+	t, err := p.table(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor := p.cursorColumns(t)
+	after := p.state.Cursors[name]
+
+	query := fmt.Sprintf("SELECT %s FROM %s", p.quoteAll(t.columns), p.quote(name))
+	var args []interface{}
+	if len(after) == len(cursor) {
+		holders := make([]string, len(cursor))
+		for i := range cursor {
+			holders[i] = p.placeholder(i + 1)
+		}
+		query += fmt.Sprintf(" WHERE (%s) > (%s)", p.quoteAll(cursor), strings.Join(holders, ", "))
+		args = after
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %d", p.quoteAll(cursor), limit)
+
+	events, err := newDumper(p.db, p.driver, p.database, "", limit).query(ctx, name, t, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	last := events[len(events)-1].After
+	values := make([]interface{}, len(cursor))
+	for i, column := range cursor {
+		values[i] = last[column]
+	}
+	p.state.Cursors[name] = values
+	p.state.Seq++
+
+	position := p.state.String()
+	for _, event := range events {
+		event.Operation = operation
+		event.Position = position
+	}
+
+	return events, nil
+}
+
+// cursorColumns 回傳排序與比較位置用的欄位, 追蹤欄位相同時以主鍵區分
+func (p *polling) cursorColumns(t *table) []string {
+	for _, column := range t.columns {
+		if column == p.column {
+			return append([]string{column}, t.primaryKey...)
+		}
+	}
+	return t.primaryKey
+}
+
+func (p *polling) list(ctx context.Context) ([]string, error) {
+	var names []string
+	var err error
+	if p.driver == "mysql" {
+		names, err = listMysqlTables(ctx, p.db, p.database, p.captured)
+	} else {
+		names, err = listPostgresTables(ctx, p.db, "public", p.captured)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("polling source has no table to capture in %v", p.database)
+	}
+	return names, nil
+}
+
+func (p *polling) table(ctx context.Context, name string) (*table, error) {
+	if t, ok := p.tables[name]; ok {
+		return t, nil
+	}
+
+	var t *table
+	var err error
+	if p.driver == "mysql" {
+		t, err = loadMysqlTable(ctx, p.db, p.database, name)
+	} else {
+		t, err = loadPostgresTable(ctx, p.db, "public", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(t.primaryKey) == 0 {
+		return nil, fmt.Errorf("table %v has no primary key, polling is not supported", name)
+	}
+
+	if p.registry != nil {
+		if _, err := t.register(p.registry, p.driver, p.database, name); err != nil {
+			return nil, err
+		}
+	}
+
+	p.tables[name] = t
+	return t, nil
+}
+
+func (p *polling) quote(identifier string) string {
+	if p.driver == "mysql" {
+		return "`" + identifier + "`"
+	}
+	return quoteIdentifier(identifier)
+}
+
+func (p *polling) quoteAll(identifiers []string) string {
+	quoted := make([]string, len(identifiers))
+	for i, identifier := range identifiers {
+		quoted[i] = p.quote(identifier)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func (p *polling) placeholder(n int) string {
+	if p.driver == "mysql" {
+		return "?"
+	}
+	return fmt.Sprintf("$%d", n)
+}
+
+func (s pollingPosition) String() string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// parsePollingPosition 還原來源位置, JSON 無法保留的型別 (時間、整數) 依照內容轉換回來
+func parsePollingPosition(position string) (pollingPosition, error) {
+	state := pollingPosition{}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(position)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&state); err != nil {
+		return state, fmt.Errorf("failed to parse polling position %q: %w", position, err)
+	}
+	if state.Cursors == nil {
+		state.Cursors = map[string][]interface{}{}
+	}
+
+	for _, values := range state.Cursors {
+		for i, value := range values {
+			switch v := value.(type) {
+			case json.Number:
+				if n, err := v.Int64(); err == nil {
+					values[i] = n
+				} else {
+					values[i] = v.String()
+				}
+			case string:
+				if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+					values[i] = t
+				}
+			}
+		}
+	}
+
+	return state, nil
+}
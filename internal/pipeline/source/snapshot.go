@@ -0,0 +1,216 @@
+package source
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"practice/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Snapshotter 為可以在串流之前提供一致性初始快照的 source
+type Snapshotter interface {
+	Source
+
+	// 在一致性快照中分段讀出擷取的資料表, 每個分段以 batch 交給 emit, 並回傳快照對應的來源位置
+	// 由回傳的位置開始串流時, 快照期間的異動不會遺漏也不會與快照重複
+	Snapshot(ctx context.Context, chunkSize int, emit func(*Batch) error) (string, error)
+}
+
+// ChunkReader 為支援 watermark 增量快照 (DBLog) 的 source, 分段讀取資料表時不需要長交易
+type ChunkReader interface {
+	Source
+
+	// 回傳需要快照的資料表
+	SnapshotTables(ctx context.Context) ([]string, error)
+
+	// 讀出主鍵大於 after 的下一段資料列, after 為 nil 時從第一筆開始
+	ReadChunk(ctx context.Context, table string, after map[string]interface{}, chunkSize int) ([]*storage.ChangeEvent, error)
+
+	// 在來源資料庫的 signal table 寫入訊號, 訊號會依照提交順序出現在串流中
+	Signal(ctx context.Context, signalTable, id, kind, data string) error
+}
+
+// querier 為快照期間持有一致性視圖的連線 (*sql.Conn or *sql.Tx)
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// dumper 以主鍵順序分段讀出資料表, 每個分段為一次 keyset 查詢, 不需要 OFFSET 也不會重複讀取
+type dumper struct {
+	q           querier
+	source      string // 來源資料庫種類 (mysql or postgresql)
+	database    string
+	position    string // 快照對應的來源位置, 記錄在每個 change event 上
+	chunkSize   int
+	quote       func(string) string
+	placeholder func(int) string
+}
+
+func newDumper(q querier, source, database, position string, chunkSize int) *dumper {
+	d := &dumper{
+		q:         q,
+		source:    source,
+		database:  database,
+		position:  position,
+		chunkSize: chunkSize,
+	}
+
+	if source == "mysql" {
+		d.quote = func(identifier string) string { return "`" + identifier + "`" }
+		d.placeholder = func(int) string { return "?" }
+	} else {
+		d.quote = func(identifier string) string { return `"` + identifier + `"` }
+		d.placeholder = func(n int) string { return fmt.Sprintf("$%d", n) }
+	}
+
+	return d
+}
+
+// dump 分段讀出整張資料表, 回傳讀出的資料列數量
+func (d *dumper) dump(ctx context.Context, name string, t *table, emit func(*Batch) error) (int, error) {
+	total := 0
+	var after map[string]interface{} // 上一個分段最後一筆資料列的主鍵
+	for {
+		events, err := d.chunk(ctx, name, t, after)
+		if err != nil {
+			return total, err
+		}
+		if len(events) == 0 {
+			return total, nil
+		}
+
+		if err := emit(&Batch{Events: events}); err != nil {
+			return total, err
+		}
+		total += len(events)
+
+		if len(events) < d.chunkSize {
+			return total, nil
+		}
+		after = events[len(events)-1].PrimaryKey
+	}
+}
+
+// chunk 讀出主鍵大於 after 的下一段資料列, after 為 nil 時從第一筆開始
+func (d *dumper) chunk(ctx context.Context, name string, t *table, after map[string]interface{}) ([]*storage.ChangeEvent, error) {
+	if len(t.primaryKey) == 0 {
+		return nil, fmt.Errorf("table %v has no primary key, chunked snapshot is not supported", name)
+	}
+
+	columns := make([]string, len(t.columns))
+	for i, column := range t.columns {
+		columns[i] = d.quote(column)
+	}
+	keys := make([]string, len(t.primaryKey))
+	holders := make([]string, len(t.primaryKey))
+	args := make([]interface{}, len(t.primaryKey))
+	for i, column := range t.primaryKey {
+		keys[i] = d.quote(column)
+		holders[i] = d.placeholder(i + 1)
+		args[i] = after[column]
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), d.quote(name))
+	if after == nil {
+		args = nil
+	} else {
+		query += fmt.Sprintf(" WHERE (%s) > (%s)", strings.Join(keys, ", "), strings.Join(holders, ", "))
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %d", strings.Join(keys, ", "), d.chunkSize)
+
+	return d.query(ctx, name, t, query, args...)
+}
+
+// signal 以 upsert 寫入訊號, 同一個 id 重複寫入時只更新內容, 讓 signal table 不會無限成長
+func signal(ctx context.Context, db *sql.DB, driver, signalTable, id, kind, data string) error {
+	var query string
+	if driver == "mysql" {
+		query = fmt.Sprintf("INSERT INTO `%s` (`id`, `type`, `data`, `created_at`) VALUES (?, ?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE `type` = VALUES(`type`), `data` = VALUES(`data`), `created_at` = VALUES(`created_at`)", signalTable)
+	} else {
+		query = fmt.Sprintf(`INSERT INTO "%s" ("id", "type", "data", "created_at") VALUES ($1, $2, $3, $4) `+
+			`ON CONFLICT ("id") DO UPDATE SET "type" = EXCLUDED."type", "data" = EXCLUDED."data", "created_at" = EXCLUDED."created_at"`, signalTable)
+	}
+
+	if _, err := db.ExecContext(ctx, query, id, kind, data, time.Now()); err != nil {
+		return fmt.Errorf("failed to write %v signal into %v: %w", kind, signalTable, err)
+	}
+	return nil
+}
+
+func (d *dumper) query(ctx context.Context, name string, t *table, query string, args ...interface{}) ([]*storage.ChangeEvent, error) {
+	rows, err := d.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot of %v: %w", name, err)
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	events := []*storage.ChangeEvent{}
+	for rows.Next() {
+		values := make([]interface{}, len(t.columns))
+		pointers := make([]interface{}, len(values))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot of %v: %w", name, err)
+		}
+
+		row := make(map[string]interface{}, len(values))
+		for i, column := range t.columns {
+			row[column] = normalizeValue(types[i].DatabaseTypeName(), t.unsigned[column], values[i])
+		}
+
+		events = append(events, &storage.ChangeEvent{
+			Version:       storage.ChangeEventVersion,
+			Source:        d.source,
+			Database:      d.database,
+			Table:         name,
+			Operation:     storage.OperationRead,
+			SchemaVersion: t.version(),
+			PrimaryKey:    t.primaryKeyOf(row),
+			After:         row,
+			Position:      d.position,
+		})
+	}
+
+	return events, rows.Err()
+}
+
+// normalizeValue 將 driver 掃描出的欄位內容轉換成與串流異動一致的型別
+// MySQL 未帶參數的查詢走 text protocol, 數值欄位會以 []byte 回傳
+func normalizeValue(typeName string, unsigned bool, value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		switch typeName {
+		case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "INT2", "INT4", "INT8":
+			if unsigned {
+				if n, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+					return n
+				}
+			}
+			if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+				return n
+			}
+		case "FLOAT", "DOUBLE", "FLOAT4", "FLOAT8":
+			if n, err := strconv.ParseFloat(string(v), 64); err == nil {
+				return n
+			}
+		}
+		return string(v)
+	case int64:
+		if unsigned && v >= 0 {
+			return uint64(v)
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,32 @@
+package source
+
+import (
+	"context"
+	"practice/internal/storage"
+)
+
+// Batch 為來源資料庫中單一交易產生的所有異動
+type Batch struct {
+	Events   []*storage.ChangeEvent // 交易內的異動, 依照發生順序排列, 可能為空
+	Position string                 // 交易結束後的來源位置, 由此位置重新開始讀取不會重複收到這筆交易
+}
+
+type Source interface {
+	// 從指定位置開始讀取異動, position 為空時從來源目前最新的位置開始
+	Start(ctx context.Context, position string) error
+
+	// 取得來源目前最新的位置
+	Position(ctx context.Context) (string, error)
+
+	// 讀取下一個完整的交易, 一段時間內沒有新的交易時回傳 nil
+	Read(ctx context.Context) (*Batch, error)
+
+	// 確認 position 之前的異動都已經寫入下游, 來源可以釋放對應的資源 (e.g. replication slot)
+	Ack(ctx context.Context, position string) error
+
+	// 比較兩個來源位置的先後, 回傳 -1, 0 或 1
+	Compare(a, b string) int
+
+	// 釋放 source 持有的資源
+	Shutdown(ctx context.Context)
+}
@@ -0,0 +1,208 @@
+package source
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"practice/internal/pipeline/schema"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// typeModifier 為型別的長度或精度修飾, e.g. character varying(255) 的 (255)
+var typeModifier = regexp.MustCompile(`\([^)]*\)`)
+
+// table 為來源資料表的欄位資訊, 用來將 binlog 或 logical decoding 的資料列轉換成 change event
+type table struct {
+	columns    []string        // 依照欄位順序排列的欄位名稱
+	types      []string        // 依照欄位順序排列的欄位型別
+	primaryKey []string        // 主鍵欄位
+	unsigned   map[string]bool // MySQL unsigned 整數欄位
+
+	schema *schema.Table // 在 schema registry 註冊的結構版本
+}
+
+func loadMysqlTable(ctx context.Context, db *sql.DB, database, name string) (*table, error) {
+	rows, err := db.QueryContext(ctx, `
+	SELECT COLUMN_NAME, COLUMN_TYPE, COLUMN_KEY
+	FROM information_schema.COLUMNS
+	WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?
+	ORDER BY ORDINAL_POSITION`, database, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns of %v.%v: %w", database, name, err)
+	}
+	defer rows.Close()
+
+	t := &table{unsigned: map[string]bool{}}
+	for rows.Next() {
+		var column, columnType, columnKey string
+		if err := rows.Scan(&column, &columnType, &columnKey); err != nil {
+			return nil, fmt.Errorf("failed to scan columns of %v.%v: %w", database, name, err)
+		}
+
+		t.columns = append(t.columns, column)
+		t.types = append(t.types, columnType)
+		if columnKey == "PRI" {
+			t.primaryKey = append(t.primaryKey, column)
+		}
+		if strings.Contains(columnType, "unsigned") {
+			t.unsigned[column] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(t.columns) == 0 {
+		return nil, fmt.Errorf("table %v.%v not found", database, name)
+	}
+
+	return t, nil
+}
+
+func loadPostgresTable(ctx context.Context, db *sql.DB, namespace, name string) (*table, error) {
+	rows, err := db.QueryContext(ctx, `
+	SELECT a.attname, format_type(a.atttypid, a.atttypmod), COALESCE(i.indisprimary, false)
+	FROM pg_attribute a
+	JOIN pg_class c ON c.oid = a.attrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	LEFT JOIN pg_index i ON i.indrelid = c.oid AND i.indisprimary AND a.attnum = ANY(i.indkey)
+	WHERE n.nspname = $1 AND c.relname = $2 AND a.attnum > 0 AND NOT a.attisdropped
+	ORDER BY a.attnum`, namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns of %v.%v: %w", namespace, name, err)
+	}
+	defer rows.Close()
+
+	t := &table{unsigned: map[string]bool{}}
+	for rows.Next() {
+		var column, columnType string
+		var primary bool
+		if err := rows.Scan(&column, &columnType, &primary); err != nil {
+			return nil, fmt.Errorf("failed to scan columns of %v.%v: %w", namespace, name, err)
+		}
+
+		t.columns = append(t.columns, column)
+		t.types = append(t.types, columnType)
+		if primary {
+			t.primaryKey = append(t.primaryKey, column)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(t.columns) == 0 {
+		return nil, fmt.Errorf("table %v.%v not found", namespace, name)
+	}
+
+	return t, nil
+}
+
+// listMysqlTables 回傳需要快照的資料表, tables 為空時回傳資料庫中所有資料表
+func listMysqlTables(ctx context.Context, db *sql.DB, database string, tables []string) ([]string, error) {
+	if len(tables) > 0 {
+		return tables, nil
+	}
+
+	return queryTables(ctx, db, `
+	SELECT TABLE_NAME
+	FROM information_schema.TABLES
+	WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'BASE TABLE'
+	ORDER BY TABLE_NAME`, database)
+}
+
+// listPostgresTables 回傳需要快照的資料表, tables 為空時回傳 schema 中所有資料表
+func listPostgresTables(ctx context.Context, db *sql.DB, schema string, tables []string) ([]string, error) {
+	if len(tables) > 0 {
+		return tables, nil
+	}
+
+	return queryTables(ctx, db, `
+	SELECT tablename
+	FROM pg_tables
+	WHERE schemaname = $1
+	ORDER BY tablename`, schema)
+}
+
+func queryTables(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+
+	tables := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan tables: %w", err)
+		}
+		tables = append(tables, name)
+	}
+
+	return tables, rows.Err()
+}
+
+// register 將資料表目前的結構註冊至 registry, 並回傳結構版本
+func (t *table) register(registry *schema.Registry, source, database, name string) (int, error) {
+	if t.schema != nil {
+		return t.schema.Version, nil
+	}
+
+	current := &schema.Table{
+		Source:     source,
+		Database:   database,
+		Name:       name,
+		PrimaryKey: t.primaryKey,
+	}
+	for i, column := range t.columns {
+		current.Columns = append(current.Columns, schema.Column{Name: column, Type: t.types[i]})
+	}
+
+	registered, created, err := registry.Register(current)
+	if err != nil {
+		return 0, err
+	}
+	if created && registered.Version > 1 {
+		logrus.Infof("schema of %v.%v changed to version %v", database, name, registered.Version)
+	}
+
+	t.schema = registered
+	return registered.Version, nil
+}
+
+func (t *table) version() int {
+	if t.schema == nil {
+		return 0
+	}
+	return t.schema.Version
+}
+
+// primaryKeyOf 從資料列中取出主鍵欄位內容
+func (t *table) primaryKeyOf(row map[string]interface{}) map[string]interface{} {
+	if row == nil {
+		return nil
+	}
+
+	key := make(map[string]interface{}, len(t.primaryKey))
+	for _, column := range t.primaryKey {
+		key[column] = row[column]
+	}
+	return key
+}
+
+// tableFilter 回傳判斷資料表是否需要擷取的函式, tables 為空時擷取所有資料表
+func tableFilter(tables []string) func(string) bool {
+	if len(tables) == 0 {
+		return func(string) bool { return true }
+	}
+
+	included := map[string]bool{}
+	for _, name := range tables {
+		included[name] = true
+	}
+	return func(name string) bool { return included[name] }
+}
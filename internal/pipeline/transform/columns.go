@@ -0,0 +1,264 @@
+package transform
+
+import (
+	"fmt"
+	"practice/internal/pipeline/schema"
+	"practice/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clone 複製 change event 與欄位內容, 轉換不會影響其他 sink 或快照視窗持有的資料
+func clone(event *storage.ChangeEvent) *storage.ChangeEvent {
+	c := *event
+	c.PrimaryKey = cloneRow(event.PrimaryKey)
+	c.Before = cloneRow(event.Before)
+	c.After = cloneRow(event.After)
+	return &c
+}
+
+func cloneRow(row map[string]interface{}) map[string]interface{} {
+	if row == nil {
+		return nil
+	}
+	c := make(map[string]interface{}, len(row))
+	for k, v := range row {
+		c[k] = v
+	}
+	return c
+}
+
+func cloneTable(table *schema.Table) *schema.Table {
+	c := *table
+	c.Columns = append([]schema.Column(nil), table.Columns...)
+	c.PrimaryKey = append([]string(nil), table.PrimaryKey...)
+	return &c
+}
+
+// retype 將欄位型別改為轉換後內容在來源資料庫中對應的型別, sink 再依自己的資料庫轉換
+// @param typ  string, int, float or bool
+func retype(table *schema.Table, columns []string, typ string) *schema.Table {
+	types := map[string]map[string]string{
+		"mysql":      {"string": "text", "int": "bigint", "float": "double", "bool": "boolean"},
+		"postgresql": {"string": "text", "int": "bigint", "float": "double precision", "bool": "boolean"},
+	}
+
+	table = cloneTable(table)
+	for i, column := range table.Columns {
+		for _, c := range columns {
+			if column.Name == c {
+				table.Columns[i].Type = types[table.Source][typ]
+			}
+		}
+	}
+	return table
+}
+
+// eachRow 對 before 與 after 中存在的欄位套用 fn
+func eachRow(event *storage.ChangeEvent, column string, fn func(interface{}) (interface{}, error)) error {
+	for _, row := range []map[string]interface{}{event.Before, event.After} {
+		value, ok := row[column]
+		if !ok {
+			continue
+		}
+
+		converted, err := fn(value)
+		if err != nil {
+			return fmt.Errorf("column %v of %v.%v: %w", column, event.Database, event.Table, err)
+		}
+		row[column] = converted
+	}
+	return nil
+}
+
+type drop struct {
+	columns []string
+}
+
+// NewDrop New Transform Dropping Columns
+// @param columns  dropped columns, primary key columns can not be dropped
+func NewDrop(columns []string) (Transform, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("drop transform requires columns")
+	}
+	return &drop{columns: columns}, nil
+}
+
+func (d *drop) Name() string {
+	return "drop"
+}
+
+func (d *drop) Apply(event *storage.ChangeEvent) (*storage.ChangeEvent, error) {
+	event = clone(event)
+	for _, column := range d.columns {
+		if _, ok := event.PrimaryKey[column]; ok {
+			return nil, fmt.Errorf("primary key column %v of %v.%v can not be dropped", column, event.Database, event.Table)
+		}
+		delete(event.Before, column)
+		delete(event.After, column)
+	}
+	return event, nil
+}
+
+// Reshape 移除欄位, 主鍵欄位不會被移除
+func (d *drop) Reshape(table *schema.Table) *schema.Table {
+	dropped := map[string]bool{}
+	for _, column := range d.columns {
+		dropped[column] = true
+	}
+	for _, key := range table.PrimaryKey {
+		delete(dropped, key)
+	}
+
+	table = cloneTable(table)
+	columns := table.Columns[:0]
+	for _, column := range table.Columns {
+		if !dropped[column.Name] {
+			columns = append(columns, column)
+		}
+	}
+	table.Columns = columns
+	return table
+}
+
+type rename struct {
+	mapping map[string]string
+}
+
+// NewRename New Transform Renaming Columns
+// @param mapping  original column -> new column, primary key columns are renamed as well
+func NewRename(mapping map[string]string) (Transform, error) {
+	if len(mapping) == 0 {
+		return nil, fmt.Errorf("rename transform requires mapping")
+	}
+	return &rename{mapping: mapping}, nil
+}
+
+func (r *rename) Name() string {
+	return "rename"
+}
+
+func (r *rename) Apply(event *storage.ChangeEvent) (*storage.ChangeEvent, error) {
+	event = clone(event)
+	for from, to := range r.mapping {
+		for _, row := range []map[string]interface{}{event.PrimaryKey, event.Before, event.After} {
+			value, ok := row[from]
+			if !ok {
+				continue
+			}
+			delete(row, from)
+			row[to] = value
+		}
+	}
+	return event, nil
+}
+
+// Reshape 重新命名欄位與主鍵
+func (r *rename) Reshape(table *schema.Table) *schema.Table {
+	table = cloneTable(table)
+	for i, column := range table.Columns {
+		if to, ok := r.mapping[column.Name]; ok {
+			table.Columns[i].Name = to
+		}
+	}
+	for i, key := range table.PrimaryKey {
+		if to, ok := r.mapping[key]; ok {
+			table.PrimaryKey[i] = to
+		}
+	}
+	return table
+}
+
+type cast struct {
+	types map[string]string
+}
+
+// NewCast New Transform Converting Column Values
+// @param types  column -> target type (string, int, float or bool), NULL stays NULL
+func NewCast(types map[string]string) (Transform, error) {
+	if len(types) == 0 {
+		return nil, fmt.Errorf("cast transform requires mapping")
+	}
+	for column, typ := range types {
+		switch typ {
+		case "string", "int", "float", "bool":
+		default:
+			return nil, fmt.Errorf("cast type %q of column %v undefined, expected string, int, float or bool", typ, column)
+		}
+	}
+	return &cast{types: types}, nil
+}
+
+func (c *cast) Name() string {
+	return "cast"
+}
+
+func (c *cast) Apply(event *storage.ChangeEvent) (*storage.ChangeEvent, error) {
+	event = clone(event)
+	for column, typ := range c.types {
+		typ := typ
+		if err := eachRow(event, column, func(value interface{}) (interface{}, error) {
+			return castValue(value, typ)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return event, nil
+}
+
+// Reshape 將欄位型別改為轉換的目標型別
+func (c *cast) Reshape(table *schema.Table) *schema.Table {
+	for column, typ := range c.types {
+		table = retype(table, []string{column}, typ)
+	}
+	return table
+}
+
+func castValue(value interface{}, typ string) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	text := toString(value)
+	switch typ {
+	case "string":
+		return text, nil
+	case "int":
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n, nil
+		}
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("can not cast %q to int", text)
+		}
+		return int64(f), nil
+	case "float":
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("can not cast %q to float", text)
+		}
+		return f, nil
+	case "bool":
+		b, err := strconv.ParseBool(strings.TrimSpace(text))
+		if err != nil {
+			return nil, fmt.Errorf("can not cast %q to bool", text)
+		}
+		return b, nil
+	}
+	return value, nil
+}
+
+// toString 將欄位內容轉換成文字, 時間以 RFC3339 表示
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,381 @@
+package transform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// expr 為 filter 的條件運算式, 在建立時解析, 套用時只需要求值
+//
+//	expr    = or
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | compare
+//	compare = operand [ ( "==" | "!=" | ">" | ">=" | "<" | "<=" ) operand ]
+//	operand = number | string | true | false | null | identifier | "(" expr ")"
+//
+// identifier 為欄位名稱, $operation, $table 與 $database 為 change event 的屬性
+type expr interface {
+	eval(env func(string) interface{}) (interface{}, error)
+}
+
+type literal struct{ value interface{} }
+
+type identifier struct{ name string }
+
+type not struct{ operand expr }
+
+type logical struct {
+	op          string
+	left, right expr
+}
+
+type compare struct {
+	op          string
+	left, right expr
+}
+
+func (l *literal) eval(env func(string) interface{}) (interface{}, error) {
+	return l.value, nil
+}
+
+func (i *identifier) eval(env func(string) interface{}) (interface{}, error) {
+	return env(i.name), nil
+}
+
+func (n *not) eval(env func(string) interface{}) (interface{}, error) {
+	value, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	b, err := truth(value)
+	return !b, err
+}
+
+func (l *logical) eval(env func(string) interface{}) (interface{}, error) {
+	value, err := l.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	left, err := truth(value)
+	if err != nil {
+		return nil, err
+	}
+
+	// 短路求值
+	if (l.op == "&&" && !left) || (l.op == "||" && left) {
+		return left, nil
+	}
+
+	if value, err = l.right.eval(env); err != nil {
+		return nil, err
+	}
+	return truth(value)
+}
+
+func (c *compare) eval(env func(string) interface{}) (interface{}, error) {
+	left, err := c.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := c.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// NULL 只能比較是否相等
+	if left == nil || right == nil {
+		switch c.op {
+		case "==":
+			return left == nil && right == nil, nil
+		case "!=":
+			return (left == nil) != (right == nil), nil
+		default:
+			return false, nil
+		}
+	}
+
+	var order int
+	lf, lok := number(left)
+	rf, rok := number(right)
+	switch {
+	case lok && rok:
+		switch {
+		case lf < rf:
+			order = -1
+		case lf > rf:
+			order = 1
+		}
+	default:
+		lb, lbool := left.(bool)
+		rb, rbool := right.(bool)
+		if lbool && rbool {
+			if c.op != "==" && c.op != "!=" {
+				return nil, fmt.Errorf("operator %v is not supported by booleans", c.op)
+			}
+			if lb != rb {
+				order = 1
+			}
+		} else {
+			order = strings.Compare(toString(left), toString(right))
+		}
+	}
+
+	switch c.op {
+	case "==":
+		return order == 0, nil
+	case "!=":
+		return order != 0, nil
+	case ">":
+		return order > 0, nil
+	case ">=":
+		return order >= 0, nil
+	case "<":
+		return order < 0, nil
+	default:
+		return order <= 0, nil
+	}
+}
+
+// truth 將運算結果轉換成布林值, NULL 視為 false
+func truth(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	default:
+		return false, fmt.Errorf("%v is not a boolean", value)
+	}
+}
+
+// number 將數值型別的欄位內容轉換成 float64, 文字與時間不會轉換
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// parseExpr 解析 filter 的條件運算式
+func parseExpr(source string) (expr, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q at the end of expression", p.tokens[p.pos].text)
+	}
+	return e, nil
+}
+
+type token struct {
+	kind string // number, string, ident, op
+	text string
+}
+
+func tokenize(source string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(source)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '"' || r == '\'':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string starting at %v", i)
+			}
+			tokens = append(tokens, token{kind: "string", text: sb.String()})
+			i = j + 1
+
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{kind: "number", text: string(runes[i:j])})
+			i = j
+
+		case unicode.IsLetter(r) || r == '_' || r == '$':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, token{kind: "ident", text: string(runes[i:j])})
+			i = j
+
+		default:
+			op := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", ">=", "<=", ">", "<", "!", "(", ")"} {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at %v", r, i)
+			}
+			tokens = append(tokens, token{kind: "op", text: op})
+			i += len(op)
+		}
+	}
+
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek(text string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == "op" && p.tokens[p.pos].text == text
+}
+
+func (p *parser) or() (expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek("||") {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek("&&") {
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (expr, error) {
+	if p.peek("!") {
+		p.pos++
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &not{operand: operand}, nil
+	}
+	return p.compare()
+}
+
+func (p *parser) compare() (expr, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", ">=", "<=", ">", "<"} {
+		if p.peek(op) {
+			p.pos++
+			right, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			return &compare{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) operand() (expr, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	t := p.tokens[p.pos]
+	p.pos++
+
+	switch t.kind {
+	case "number":
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t.text)
+		}
+		return &literal{value: f}, nil
+	case "string":
+		return &literal{value: t.text}, nil
+	case "ident":
+		switch t.text {
+		case "true":
+			return &literal{value: true}, nil
+		case "false":
+			return &literal{value: false}, nil
+		case "null":
+			return &literal{value: nil}, nil
+		}
+		return &identifier{name: t.text}, nil
+	}
+
+	if t.text == "(" {
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(")") {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return e, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
@@ -0,0 +1,58 @@
+package transform
+
+import (
+	"fmt"
+	"practice/internal/storage"
+)
+
+type filter struct {
+	source string
+	expr   expr
+}
+
+// NewFilter New Transform Keeping Only Change Events Matching The Expression
+// 欄位內容取自異動後的資料列, delete 時取自異動前的資料列, 時間欄位以 RFC3339 文字比較
+// e.g. amount >= 1000 && $operation != "delete"
+// @param expression  condition, see expr for the syntax
+func NewFilter(expression string) (Transform, error) {
+	e, err := parseExpr(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter expression %q: %w", expression, err)
+	}
+	return &filter{source: expression, expr: e}, nil
+}
+
+func (f *filter) Name() string {
+	return "filter"
+}
+
+func (f *filter) Apply(event *storage.ChangeEvent) (*storage.ChangeEvent, error) {
+	row := event.After
+	if row == nil {
+		row = event.Before
+	}
+
+	value, err := f.expr.eval(func(name string) interface{} {
+		switch name {
+		case "$operation":
+			return event.Operation
+		case "$table":
+			return event.Table
+		case "$database":
+			return event.Database
+		}
+		return row[name]
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate %q on %v.%v: %w", f.source, event.Database, event.Table, err)
+	}
+
+	keep, err := truth(value)
+	if err != nil {
+		return nil, fmt.Errorf("filter %q: %w", f.source, err)
+	}
+	if !keep {
+		return nil, nil
+	}
+	return event, nil
+}
@@ -0,0 +1,134 @@
+package transform
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"practice/internal/pipeline/schema"
+	"practice/internal/storage"
+	"strings"
+)
+
+// placeholders 為範例設定中常見的金鑰, 以此為金鑰的雜湊可以被任何人重新計算
+var placeholders = map[string]bool{
+	"change-me": true, "changeme": true, "change_me": true, "changeit": true,
+	"secret": true, "password": true, "default": true, "todo": true, "xxx": true,
+}
+
+type hash struct {
+	columns []string
+	secret  []byte
+}
+
+// NewHash New Transform Replacing Column Values With HMAC-SHA256
+// 相同的內容產生相同的雜湊, 下游仍然可以比對與關聯, 但無法還原原始內容
+// @param columns  hashed columns, hashed primary key columns keep upserts of the same row consistent
+// @param secret   key of HMAC, without it hashes of guessable values (e.g. emails) can be brute-forced
+func NewHash(columns []string, secret string) (Transform, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("hash transform requires columns")
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("hash transform requires secret")
+	}
+	if placeholders[strings.ToLower(strings.TrimSpace(secret))] {
+		return nil, fmt.Errorf("hash transform secret %q is a placeholder, generate a random one", secret)
+	}
+	return &hash{columns: columns, secret: []byte(secret)}, nil
+}
+
+func (h *hash) Name() string {
+	return "hash"
+}
+
+func (h *hash) Apply(event *storage.ChangeEvent) (*storage.ChangeEvent, error) {
+	event = clone(event)
+	for _, column := range h.columns {
+		if value, ok := event.PrimaryKey[column]; ok {
+			event.PrimaryKey[column] = h.sum(value)
+		}
+		if err := eachRow(event, column, func(value interface{}) (interface{}, error) {
+			return h.sum(value), nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return event, nil
+}
+
+// Reshape 雜湊後的內容為文字
+func (h *hash) Reshape(table *schema.Table) *schema.Table {
+	return retype(table, h.columns, "string")
+}
+
+func (h *hash) sum(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write([]byte(toString(value)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type mask struct {
+	columns []string
+	keep    int
+}
+
+// NewMask New Transform Masking Column Values With '*'
+// 信箱只遮蔽 @ 之前的部分, e.g. alice@example.com -> a****@example.com
+// @param columns  masked columns, primary key columns can not be masked
+// @param keep     number of leading characters kept in clear text
+func NewMask(columns []string, keep int) (Transform, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("mask transform requires columns")
+	}
+	if keep < 0 {
+		return nil, fmt.Errorf("mask transform keep must not be negative")
+	}
+	return &mask{columns: columns, keep: keep}, nil
+}
+
+func (m *mask) Name() string {
+	return "mask"
+}
+
+func (m *mask) Apply(event *storage.ChangeEvent) (*storage.ChangeEvent, error) {
+	event = clone(event)
+	for _, column := range m.columns {
+		if _, ok := event.PrimaryKey[column]; ok {
+			return nil, fmt.Errorf("primary key column %v of %v.%v can not be masked", column, event.Database, event.Table)
+		}
+		if err := eachRow(event, column, func(value interface{}) (interface{}, error) {
+			if value == nil {
+				return nil, nil
+			}
+
+			text := toString(value)
+			if at := strings.LastIndex(text, "@"); at > 0 {
+				return m.mask(text[:at]) + text[at:], nil
+			}
+			return m.mask(text), nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return event, nil
+}
+
+// Reshape 遮蔽後的內容為文字
+func (m *mask) Reshape(table *schema.Table) *schema.Table {
+	return retype(table, m.columns, "string")
+}
+
+func (m *mask) mask(text string) string {
+	runes := []rune(text)
+	// 內容不長於保留的字元數時全部遮蔽, 避免短內容以明文輸出
+	keep := m.keep
+	if keep >= len(runes) {
+		keep = 0
+	}
+	return string(runes[:keep]) + strings.Repeat("*", len(runes)-keep)
+}
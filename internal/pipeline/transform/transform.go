@@ -0,0 +1,94 @@
+package transform
+
+import (
+	"fmt"
+	"practice/internal/pipeline/schema"
+	"practice/internal/storage"
+)
+
+// Transform 在寫入 sink 之前處理單筆 change event
+type Transform interface {
+	// 轉換名稱, 用於錯誤訊息與日誌
+	Name() string
+
+	// 回傳處理後的 change event, 回傳 nil 表示略過此事件, 不可修改傳入的 event 以外的共用資料
+	Apply(event *storage.ChangeEvent) (*storage.ChangeEvent, error)
+}
+
+// Reshaper 由會改變欄位名稱或型別的轉換實作, sink 依照轉換後的欄位套用結構異動
+type Reshaper interface {
+	// 回傳轉換後的資料表結構, 不可修改傳入的 table
+	Reshape(table *schema.Table) *schema.Table
+}
+
+// Chain 依照設定的順序套用轉換
+type Chain []Transform
+
+// Apply 將每個 change event 依序套用所有轉換, 被任何一個轉換略過的事件不會出現在結果中
+func (c Chain) Apply(events []*storage.ChangeEvent) ([]*storage.ChangeEvent, error) {
+	if len(c) == 0 {
+		return events, nil
+	}
+
+	out := make([]*storage.ChangeEvent, 0, len(events))
+	for _, event := range events {
+		var err error
+		for _, t := range c {
+			if event, err = t.Apply(event); err != nil {
+				return nil, fmt.Errorf("failed to apply %v transform: %w", t.Name(), err)
+			}
+			if event == nil {
+				break
+			}
+		}
+
+		if event != nil {
+			out = append(out, event)
+		}
+	}
+
+	return out, nil
+}
+
+// Reshape 依序套用轉換對欄位的影響, 結構異動才會與實際寫入 sink 的資料列一致
+func (c Chain) Reshape(table *schema.Table) *schema.Table {
+	for _, t := range c {
+		if r, ok := t.(Reshaper); ok {
+			table = r.Reshape(table)
+		}
+	}
+	return table
+}
+
+type scoped struct {
+	Transform
+	tables map[string]bool
+}
+
+// ForTables 只將轉換套用至指定的資料表, tables 為空時套用至所有資料表
+func ForTables(t Transform, tables []string) Transform {
+	if len(tables) == 0 {
+		return t
+	}
+
+	s := &scoped{Transform: t, tables: map[string]bool{}}
+	for _, table := range tables {
+		s.tables[table] = true
+	}
+	return s
+}
+
+func (s *scoped) Apply(event *storage.ChangeEvent) (*storage.ChangeEvent, error) {
+	if !s.tables[event.Table] {
+		return event, nil
+	}
+	return s.Transform.Apply(event)
+}
+
+func (s *scoped) Reshape(table *schema.Table) *schema.Table {
+	r, ok := s.Transform.(Reshaper)
+	if !ok || !s.tables[table.Name] {
+		return table
+	}
+	return r.Reshape(table)
+}
@@ -0,0 +1,224 @@
+package transform
+
+import (
+	"practice/internal/pipeline/schema"
+	"practice/internal/storage"
+	"reflect"
+	"testing"
+)
+
+type row = map[string]interface{}
+
+func event(table, operation string, before, after row) *storage.ChangeEvent {
+	return &storage.ChangeEvent{
+		Database:   "practice",
+		Table:      table,
+		Operation:  operation,
+		PrimaryKey: row{"id": 1},
+		Before:     before,
+		After:      after,
+	}
+}
+
+func must(t Transform, err error) Transform {
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestTransforms(t *testing.T) {
+	hashed := must(NewHash([]string{"email"}, "6f1d3c9a0e2b4f87")).(*hash)
+
+	tests := []struct {
+		name      string
+		transform Transform
+		event     *storage.ChangeEvent
+		want      *storage.ChangeEvent // nil 表示事件被略過
+		wantErr   bool
+	}{
+		{
+			name:      "drop removes columns from before and after",
+			transform: must(NewDrop([]string{"password"})),
+			event:     event("users", storage.OperationUpdate, row{"name": "a", "password": "x"}, row{"name": "b", "password": "y"}),
+			want:      event("users", storage.OperationUpdate, row{"name": "a"}, row{"name": "b"}),
+		},
+		{
+			name:      "drop rejects primary key",
+			transform: must(NewDrop([]string{"id"})),
+			event:     event("users", storage.OperationInsert, nil, row{"id": 1}),
+			wantErr:   true,
+		},
+		{
+			name:      "rename renames primary key and columns",
+			transform: must(NewRename(map[string]string{"id": "user_id", "name": "nickname"})),
+			event:     event("users", storage.OperationUpdate, row{"id": 1, "name": "a"}, row{"id": 1, "name": "b"}),
+			want: &storage.ChangeEvent{
+				Database: "practice", Table: "users", Operation: storage.OperationUpdate,
+				PrimaryKey: row{"user_id": 1},
+				Before:     row{"user_id": 1, "nickname": "a"},
+				After:      row{"user_id": 1, "nickname": "b"},
+			},
+		},
+		{
+			name:      "cast converts values and keeps null",
+			transform: must(NewCast(map[string]string{"amount": "int", "rate": "float", "active": "bool", "code": "string"})),
+			event:     event("wallets", storage.OperationInsert, nil, row{"amount": "12.7", "rate": "0.5", "active": "true", "code": 42, "note": nil}),
+			want:      event("wallets", storage.OperationInsert, nil, row{"amount": int64(12), "rate": 0.5, "active": true, "code": "42", "note": nil}),
+		},
+		{
+			name:      "cast fails on invalid value",
+			transform: must(NewCast(map[string]string{"amount": "int"})),
+			event:     event("wallets", storage.OperationInsert, nil, row{"amount": "abc"}),
+			wantErr:   true,
+		},
+		{
+			name:      "filter keeps matching event",
+			transform: must(NewFilter(`amount >= 1000 && $operation != "delete"`)),
+			event:     event("logs", storage.OperationInsert, nil, row{"amount": 1500}),
+			want:      event("logs", storage.OperationInsert, nil, row{"amount": 1500}),
+		},
+		{
+			name:      "filter skips event not matching",
+			transform: must(NewFilter(`amount >= 1000 && $operation != "delete"`)),
+			event:     event("logs", storage.OperationInsert, nil, row{"amount": 999}),
+		},
+		{
+			name:      "filter evaluates delete on before",
+			transform: must(NewFilter(`amount >= 1000 && $operation != "delete"`)),
+			event:     event("logs", storage.OperationDelete, row{"amount": 1500}, nil),
+		},
+		{
+			name:      "hash replaces values with hmac",
+			transform: hashed,
+			event:     event("users", storage.OperationUpdate, row{"email": "a@example.com"}, row{"email": nil}),
+			want:      event("users", storage.OperationUpdate, row{"email": hashed.sum("a@example.com")}, row{"email": nil}),
+		},
+		{
+			name:      "mask keeps leading characters and email domain",
+			transform: must(NewMask([]string{"email", "phone", "pin"}, 2)),
+			event:     event("users", storage.OperationInsert, nil, row{"email": "alice@example.com", "phone": "0912345678", "pin": "12"}),
+			want:      event("users", storage.OperationInsert, nil, row{"email": "al***@example.com", "phone": "09********", "pin": "**"}),
+		},
+		{
+			name:      "mask rejects primary key",
+			transform: must(NewMask([]string{"id"}, 0)),
+			event:     event("users", storage.OperationInsert, nil, row{"id": 1}),
+			wantErr:   true,
+		},
+		{
+			name:      "scoped transform ignores other tables",
+			transform: ForTables(must(NewDrop([]string{"password"})), []string{"users"}),
+			event:     event("admins", storage.OperationInsert, nil, row{"password": "x"}),
+			want:      event("admins", storage.OperationInsert, nil, row{"password": "x"}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := clone(tt.event)
+
+			got, err := tt.transform.Apply(tt.event)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+			if !reflect.DeepEqual(tt.event, original) {
+				t.Errorf("input event modified to %+v", tt.event)
+			}
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	chain := Chain{
+		must(NewFilter(`$table != "sessions"`)),
+		must(NewRename(map[string]string{"mail": "email"})),
+		// 順序在重新命名之後, 以新的欄位名稱設定
+		must(NewMask([]string{"email"}, 1)),
+		ForTables(must(NewDrop([]string{"password"})), []string{"users"}),
+		must(NewCast(map[string]string{"amount": "int"})),
+	}
+
+	events := []*storage.ChangeEvent{
+		event("users", storage.OperationInsert, nil, row{"mail": "bob@example.com", "password": "x"}),
+		event("sessions", storage.OperationInsert, nil, row{"token": "t"}),
+		event("wallets", storage.OperationUpdate, row{"amount": "1", "password": "y"}, row{"amount": "2", "password": "y"}),
+	}
+
+	got, err := chain.Apply(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*storage.ChangeEvent{
+		event("users", storage.OperationInsert, nil, row{"email": "b**@example.com"}),
+		event("wallets", storage.OperationUpdate, row{"amount": int64(1), "password": "y"}, row{"amount": int64(2), "password": "y"}),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	if _, err := (Chain{must(NewCast(map[string]string{"amount": "int"}))}).Apply([]*storage.ChangeEvent{
+		event("wallets", storage.OperationInsert, nil, row{"amount": "abc"}),
+	}); err == nil {
+		t.Errorf("expected error of the failed transform")
+	}
+}
+
+func TestChainReshape(t *testing.T) {
+	chain := Chain{
+		must(NewRename(map[string]string{"id": "user_id", "mail": "email"})),
+		must(NewHash([]string{"email"}, "6f1d3c9a0e2b4f87")),
+		ForTables(must(NewDrop([]string{"password"})), []string{"users"}),
+		must(NewCast(map[string]string{"amount": "bool"})),
+		must(NewFilter(`amount != null`)),
+	}
+
+	tests := []struct {
+		name  string
+		table *schema.Table
+		want  *schema.Table
+	}{
+		{
+			name: "mysql users",
+			table: &schema.Table{Source: "mysql", Name: "users", PrimaryKey: []string{"id"}, Columns: []schema.Column{
+				{Name: "id", Type: "int(11) unsigned"}, {Name: "mail", Type: "varchar(255)"}, {Name: "password", Type: "varchar(64)"}, {Name: "amount", Type: "int(11)"},
+			}},
+			want: &schema.Table{Source: "mysql", Name: "users", PrimaryKey: []string{"user_id"}, Columns: []schema.Column{
+				{Name: "user_id", Type: "int(11) unsigned"}, {Name: "email", Type: "text"}, {Name: "amount", Type: "boolean"},
+			}},
+		},
+		{
+			name: "postgresql admins keep password",
+			table: &schema.Table{Source: "postgresql", Name: "admins", PrimaryKey: []string{"id"}, Columns: []schema.Column{
+				{Name: "id", Type: "integer"}, {Name: "password", Type: "text"}, {Name: "amount", Type: "integer"},
+			}},
+			want: &schema.Table{Source: "postgresql", Name: "admins", PrimaryKey: []string{"user_id"}, Columns: []schema.Column{
+				{Name: "user_id", Type: "integer"}, {Name: "password", Type: "text"}, {Name: "amount", Type: "boolean"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := cloneTable(tt.table)
+
+			if got := chain.Reshape(tt.table); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+			if !reflect.DeepEqual(tt.table, original) {
+				t.Errorf("input table modified to %+v", tt.table)
+			}
+		})
+	}
+}
+
+func TestHashRejectsPlaceholderSecrets(t *testing.T) {
+	for _, secret := range []string{"", "change-me", " Secret "} {
+		if _, err := NewHash([]string{"email"}, secret); err == nil {
+			t.Errorf("expected secret %q to fail", secret)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package bulk
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"practice/internal/storage"
+	"practice/internal/storage/dialect"
+	"practice/internal/storage/generator"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Options 為大量載入的設定
+type Options struct {
+	ChunkRows      int64 // 每個 LOAD DATA 或 COPY statement 載入的資料列數量, 每個 chunk 各自提交
+	Resume         bool  // 保留已載入的資料, 由各資料表最大的 ID 之後繼續載入
+	RebuildIndexes bool  // 載入前移除次要索引, 全部載入後再重建
+}
+
+// Progress 為單一資料表的載入進度, 每個 chunk 提交後回報
+type Progress struct {
+	Table   string
+	Rows    int64 // 本次已載入的資料列數量, 不包含續傳前已存在的資料列
+	Skipped int64 // 續傳時略過的已存在資料列
+	Total   int64 // 預計的資料列總數, 包含略過的資料列
+	Elapsed time.Duration
+}
+
+// Throughput 回傳每秒載入的資料列數量
+func (p *Progress) Throughput() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Rows) / p.Elapsed.Seconds()
+}
+
+// sink 以資料庫原生的大量載入語法寫入單一 chunk
+type sink interface {
+	// 開始一個 chunk, 回傳的 chunk 關閉後才會提交
+	open(ctx context.Context, table string, columns []string) (chunk, error)
+}
+
+type chunk interface {
+	write(values []interface{}) error
+
+	// 結束 chunk 並提交, 失敗時整個 chunk 都不會寫入
+	close() error
+
+	// 放棄 chunk, 已寫入的資料列都不會提交
+	abort(err error)
+}
+
+// Loader 將 generator 產生的資料以 MySQL LOAD DATA LOCAL INFILE 或 PostgreSQL COPY FROM STDIN 載入
+// 產生的資料列帶有固定的 ID, 因此中斷後可以重新產生相同的資料, 並略過已提交的 chunk
+type Loader struct {
+	db      *sql.DB
+	driver  string
+	dialect dialect.Dialect
+	sink    sink
+	opts    Options
+}
+
+// NewLoader New Bulk Loader
+// @param db      connection of the loaded database
+// @param driver  mysql or postgresql
+// @param opts    chunk size, resume and index rebuilding
+func NewLoader(db *sql.DB, driver string, opts Options) (*Loader, error) {
+	if opts.ChunkRows <= 0 {
+		return nil, fmt.Errorf("chunk rows must be positive")
+	}
+
+	d, err := dialect.New(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Loader{db: db, driver: driver, dialect: d, opts: opts}
+	switch driver {
+	case "mysql":
+		l.sink = &mysqlSink{db: db, dialect: d}
+	case "postgresql":
+		l.sink = &postgresSink{db: db}
+	default:
+		return nil, fmt.Errorf("driver %v undifined", driver)
+	}
+	return l, nil
+}
+
+// Load 產生並載入 users, logs 與 wallets, 每個 chunk 提交後呼叫 progress
+func (l *Loader) Load(ctx context.Context, genOpts generator.Options, progress func(*Progress)) error {
+	g, err := generator.New(genOpts)
+	if err != nil {
+		return err
+	}
+
+	tables := []string{"users", "wallets", "logs"}
+	loaded := map[string]int64{}
+	if l.opts.Resume {
+		for _, table := range tables {
+			if loaded[table], err = l.maxID(ctx, table); err != nil {
+				return err
+			}
+		}
+		logrus.Infof("resuming after users %v, logs %v and wallets %v", loaded["users"], loaded["logs"], loaded["wallets"])
+	} else {
+		for _, statement := range l.dialect.Truncate(tables...) {
+			if _, err := l.db.ExecContext(ctx, statement); err != nil {
+				return fmt.Errorf("failed to truncate: %w", err)
+			}
+		}
+	}
+
+	if l.opts.RebuildIndexes {
+		if err := l.dropIndexes(ctx, tables); err != nil {
+			return err
+		}
+	}
+
+	// 產生的順序與 GenerateData 相同, logs 必須在 wallets 之前產生才能累計轉帳後的餘額
+	users := l.table(ctx, "users", []string{"id", "account", "password", "nickname", "email", "created_at", "modified_at"},
+		loaded["users"], int64(genOpts.Users), progress)
+	if err := g.Users(func(batch []*storage.User) error {
+		for _, user := range batch {
+			if err := users.add(user.ID, user.Account, user.Password, user.Nickname, user.Email, user.CreatedAt, user.ModifiedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := users.finish(); err != nil {
+		return err
+	}
+
+	// 轉帳記錄包含每個錢包的初始入金, 略過的轉帳讓實際筆數可能略少於預計
+	expected := int64(0)
+	if genOpts.Logs > 0 {
+		expected = int64(genOpts.Logs + genOpts.Wallets)
+	}
+	logs := l.table(ctx, "logs", []string{"id", "deposit_user_id", "withdraw_user_id", "amount", "created_at"},
+		loaded["logs"], expected, progress)
+	if err := g.Logs(func(batch []*storage.TransferLog) error {
+		for _, log := range batch {
+			if err := logs.add(log.ID, log.DepositUserID, log.WithdrawUserID, log.Amount, log.CreatedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := logs.finish(); err != nil {
+		return err
+	}
+
+	wallets := l.table(ctx, "wallets", []string{"id", "user_id", "amount", "created_at", "modified_at"},
+		loaded["wallets"], int64(genOpts.Wallets), progress)
+	if err := g.Wallets(func(batch []*storage.Wallet) error {
+		for _, wallet := range batch {
+			if err := wallets.add(wallet.ID, wallet.UserID, wallet.Amount, wallet.CreatedAt, wallet.ModifiedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := wallets.finish(); err != nil {
+		return err
+	}
+
+	// 資料列以指定的 ID 載入, PostgreSQL 的 sequence 不會跟著前進
+	if l.driver == "postgresql" {
+		for _, table := range tables {
+			if _, err := l.db.ExecContext(ctx, fmt.Sprintf(
+				"SELECT setval(pg_get_serial_sequence('%s', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM %s", table, table)); err != nil {
+				return fmt.Errorf("failed to reset the sequence of %v: %w", table, err)
+			}
+		}
+	}
+
+	if l.opts.RebuildIndexes {
+		return l.rebuildIndexes(ctx, tables)
+	}
+	return nil
+}
+
+// maxID 回傳資料表中最大的 ID, 空資料表為 0
+func (l *Loader) maxID(ctx context.Context, table string) (int64, error) {
+	var id int64
+	err := l.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COALESCE(MAX(id), 0) FROM %s", l.dialect.Quote(table))).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query the loaded rows of %v: %w", table, err)
+	}
+	return id, nil
+}
+
+func (l *Loader) table(ctx context.Context, name string, columns []string, skip, total int64, progress func(*Progress)) *tableLoader {
+	return &tableLoader{
+		ctx:      ctx,
+		sink:     l.sink,
+		chunk:    l.opts.ChunkRows,
+		columns:  columns,
+		progress: progress,
+		state:    &Progress{Table: name, Skipped: skip, Total: total},
+		start:    time.Now(),
+	}
+}
+
+// tableLoader 將單一資料表的資料列切成 chunk 載入, ID 不大於 Skipped 的資料列已在先前載入
+type tableLoader struct {
+	ctx      context.Context
+	sink     sink
+	chunk    int64
+	columns  []string
+	progress func(*Progress)
+	state    *Progress
+	start    time.Time
+
+	current chunk
+	rows    int64 // 目前 chunk 已寫入的資料列數量
+}
+
+func (t *tableLoader) add(id int64, values ...interface{}) error {
+	if id <= t.state.Skipped {
+		return nil
+	}
+
+	if t.current == nil {
+		current, err := t.sink.open(t.ctx, t.state.Table, t.columns)
+		if err != nil {
+			return fmt.Errorf("failed to start loading %v: %w", t.state.Table, err)
+		}
+		t.current = current
+	}
+
+	if err := t.current.write(append([]interface{}{id}, values...)); err != nil {
+		t.current.abort(err)
+		t.current = nil
+		return fmt.Errorf("failed to load %v: %w", t.state.Table, err)
+	}
+
+	t.rows++
+	if t.rows == t.chunk {
+		return t.commit()
+	}
+	return nil
+}
+
+// finish 提交最後一個未滿的 chunk
+func (t *tableLoader) finish() error {
+	if t.current == nil {
+		return nil
+	}
+	return t.commit()
+}
+
+func (t *tableLoader) commit() error {
+	err := t.current.close()
+	t.current = nil
+	if err != nil {
+		return fmt.Errorf("failed to load %v: %w", t.state.Table, err)
+	}
+
+	t.state.Rows += t.rows
+	t.state.Elapsed = time.Since(t.start)
+	t.rows = 0
+
+	if t.progress != nil {
+		t.progress(t.state)
+	}
+	return nil
+}
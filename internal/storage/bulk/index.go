@@ -0,0 +1,130 @@
+package bulk
+
+import (
+	"context"
+	"fmt"
+	"practice/internal/ddl"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// secondary 回傳資料表中與 ddl.Initial 定義相同的次要索引, 只處理定義中的索引, 其他索引維持不變
+func (l *Loader) secondary(ctx context.Context, tables []string) (map[string][]*ddl.Index, map[*ddl.Index]*ddl.LiveIndex, error) {
+	var namespace string
+	if err := l.db.QueryRowContext(ctx, "SELECT "+l.dialect.CurrentSchema()).Scan(&namespace); err != nil {
+		return nil, nil, fmt.Errorf("failed to query the current schema: %w", err)
+	}
+
+	live, err := ddl.Inspect(ctx, l.db, l.driver, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expected := map[string][]*ddl.Index{}
+	found := map[*ddl.Index]*ddl.LiveIndex{}
+	for _, name := range tables {
+		table, ok := ddl.Initial.Table(name)
+		if !ok {
+			continue
+		}
+		expected[name] = table.Indexes
+
+		actual, ok := live.Table(name)
+		if !ok {
+			return nil, nil, fmt.Errorf("table %v not found in %v", name, namespace)
+		}
+		for _, index := range table.Indexes {
+			for _, candidate := range actual.Indexes {
+				if !candidate.Primary && candidate.Unique == index.Unique &&
+					strings.Join(candidate.Columns, ",") == strings.Join(index.Columns, ",") {
+					found[index] = candidate
+					break
+				}
+			}
+		}
+	}
+	return expected, found, nil
+}
+
+// dropIndexes 移除次要索引, 載入時不需要逐列維護索引與檢查唯一性
+func (l *Loader) dropIndexes(ctx context.Context, tables []string) error {
+	expected, found, err := l.secondary(ctx, tables)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		for _, index := range expected[table] {
+			live, ok := found[index]
+			if !ok {
+				continue
+			}
+
+			var statements []string
+			if l.driver == "mysql" {
+				statements = []string{fmt.Sprintf("ALTER TABLE %s DROP INDEX %s", l.dialect.Quote(table), l.dialect.Quote(live.Name))}
+			} else {
+				// UNIQUE 限制擁有自己的索引, 必須移除限制才會一併移除索引
+				statements = []string{
+					fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s", l.dialect.Quote(table), l.dialect.Quote(live.Name)),
+					fmt.Sprintf("DROP INDEX IF EXISTS %s", l.dialect.Quote(live.Name)),
+				}
+			}
+
+			for _, statement := range statements {
+				if _, err := l.db.ExecContext(ctx, statement); err != nil {
+					return fmt.Errorf("failed to drop index %v of %v: %w", live.Name, table, err)
+				}
+			}
+			logrus.Infof("dropped index %v of %v", live.Name, table)
+		}
+	}
+	return nil
+}
+
+// rebuildIndexes 建立 ddl.Initial 定義中缺少的次要索引, 中斷後重新執行也會補回先前移除的索引
+// 索引名稱與 initialize_schema migration 建立時資料庫預設的名稱相同
+func (l *Loader) rebuildIndexes(ctx context.Context, tables []string) error {
+	expected, found, err := l.secondary(ctx, tables)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		for _, index := range expected[table] {
+			if _, ok := found[index]; ok {
+				continue
+			}
+
+			quoted := make([]string, len(index.Columns))
+			for i, column := range index.Columns {
+				quoted[i] = l.dialect.Quote(column)
+			}
+			columns := strings.Join(quoted, ", ")
+
+			var name, statement string
+			switch {
+			case l.driver == "mysql":
+				name = index.Columns[0]
+				kind := "KEY"
+				if index.Unique {
+					kind = "UNIQUE KEY"
+				}
+				statement = fmt.Sprintf("ALTER TABLE %s ADD %s %s (%s)", l.dialect.Quote(table), kind, l.dialect.Quote(name), columns)
+			case index.Unique:
+				name = fmt.Sprintf("%s_%s_key", table, strings.Join(index.Columns, "_"))
+				statement = fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s UNIQUE (%s)", l.dialect.Quote(table), l.dialect.Quote(name), columns)
+			default:
+				name = fmt.Sprintf("%s_%s_idx", table, strings.Join(index.Columns, "_"))
+				statement = fmt.Sprintf("CREATE INDEX %s ON %s (%s)", l.dialect.Quote(name), l.dialect.Quote(table), columns)
+			}
+
+			if _, err := l.db.ExecContext(ctx, statement); err != nil {
+				return fmt.Errorf("failed to rebuild index %v of %v: %w", name, table, err)
+			}
+			logrus.Infof("rebuilt index %v of %v", name, table)
+		}
+	}
+	return nil
+}
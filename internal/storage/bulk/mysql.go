@@ -0,0 +1,127 @@
+package bulk
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"practice/internal/storage/dialect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	driver "github.com/go-sql-driver/mysql"
+)
+
+// handlers 讓同時進行的 LOAD DATA 各自註冊不同名稱的 reader handler
+var handlers uint64
+
+// mysqlSink 以 LOAD DATA LOCAL INFILE 載入, 資料列經由 driver 的 reader handler 串流給伺服器, 不需要暫存檔
+// 伺服器必須開啟 local_infile
+type mysqlSink struct {
+	db      *sql.DB
+	dialect dialect.Dialect
+}
+
+func (s *mysqlSink) open(ctx context.Context, table string, columns []string) (chunk, error) {
+	name := fmt.Sprintf("bulk_%v_%v", table, atomic.AddUint64(&handlers, 1))
+	reader, writer := io.Pipe()
+	driver.RegisterReaderHandler(name, func() io.Reader { return reader })
+
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = s.dialect.Quote(column)
+	}
+	query := fmt.Sprintf(`LOAD DATA LOCAL INFILE 'Reader::%s' INTO TABLE %s
+	CHARACTER SET utf8mb4
+	FIELDS TERMINATED BY '\t' ESCAPED BY '\\'
+	LINES TERMINATED BY '\n'
+	(%s)`, name, s.dialect.Quote(table), strings.Join(quoted, ", "))
+
+	c := &mysqlChunk{name: name, writer: writer, buf: bufio.NewWriterSize(writer, 1<<20), done: make(chan error, 1)}
+	go func() {
+		// statement 結束時關閉 reader, 讓提早失敗的 LOAD DATA 不會卡住寫入端
+		_, err := s.db.ExecContext(ctx, query)
+		reader.CloseWithError(fmt.Errorf("load data finished: %v", err))
+		c.done <- err
+	}()
+	return c, nil
+}
+
+// mysqlChunk 將資料列以 LOAD DATA 預設的 tab 分隔格式寫入 pipe
+type mysqlChunk struct {
+	name   string
+	writer *io.PipeWriter
+	buf    *bufio.Writer
+	done   chan error
+	line   []byte
+}
+
+func (c *mysqlChunk) write(values []interface{}) error {
+	c.line = c.line[:0]
+	for i, value := range values {
+		if i > 0 {
+			c.line = append(c.line, '\t')
+		}
+		c.line = appendField(c.line, value)
+	}
+	c.line = append(c.line, '\n')
+
+	_, err := c.buf.Write(c.line)
+	return err
+}
+
+func (c *mysqlChunk) close() error {
+	defer driver.DeregisterReaderHandler(c.name)
+
+	if err := c.buf.Flush(); err != nil {
+		c.writer.CloseWithError(err)
+		<-c.done
+		return err
+	}
+	c.writer.Close()
+	return <-c.done
+}
+
+func (c *mysqlChunk) abort(err error) {
+	defer driver.DeregisterReaderHandler(c.name)
+
+	// 未完整的檔案內容會讓 LOAD DATA 失敗, 整個 statement 不會寫入
+	c.writer.CloseWithError(err)
+	<-c.done
+}
+
+// appendField 以 LOAD DATA 的跳脫規則寫入欄位, NULL 為 \N
+func appendField(line []byte, value interface{}) []byte {
+	switch v := value.(type) {
+	case nil:
+		return append(line, '\\', 'N')
+	case int64:
+		return strconv.AppendInt(line, v, 10)
+	case int:
+		return strconv.AppendInt(line, int64(v), 10)
+	case time.Time:
+		return v.AppendFormat(line, "2006-01-02 15:04:05.999999")
+	case string:
+		for i := 0; i < len(v); i++ {
+			switch v[i] {
+			case '\\':
+				line = append(line, '\\', '\\')
+			case '\t':
+				line = append(line, '\\', 't')
+			case '\n':
+				line = append(line, '\\', 'n')
+			case '\r':
+				line = append(line, '\\', 'r')
+			case 0:
+				line = append(line, '\\', '0')
+			default:
+				line = append(line, v[i])
+			}
+		}
+		return line
+	}
+	return appendField(line, fmt.Sprint(value))
+}
@@ -0,0 +1,56 @@
+package bulk
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// postgresSink 以 COPY FROM STDIN 載入, 每個 chunk 為一個交易
+type postgresSink struct {
+	db *sql.DB
+}
+
+func (s *postgresSink) open(ctx context.Context, table string, columns []string) (chunk, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return &postgresChunk{tx: tx, stmt: stmt}, nil
+}
+
+// postgresChunk 由 lib/pq 緩衝資料列並以 COPY 資料訊息傳給伺服器
+type postgresChunk struct {
+	tx   *sql.Tx
+	stmt *sql.Stmt
+}
+
+func (c *postgresChunk) write(values []interface{}) error {
+	_, err := c.stmt.Exec(values...)
+	return err
+}
+
+func (c *postgresChunk) close() error {
+	// 不帶參數的 Exec 送出剩餘的資料並結束 COPY
+	if _, err := c.stmt.Exec(); err != nil {
+		c.abort(err)
+		return err
+	}
+	if err := c.stmt.Close(); err != nil {
+		c.tx.Rollback()
+		return err
+	}
+	return c.tx.Commit()
+}
+
+func (c *postgresChunk) abort(err error) {
+	c.stmt.Close()
+	c.tx.Rollback()
+}
@@ -0,0 +1,36 @@
+package codec
+
+import (
+	"fmt"
+	"practice/internal/storage"
+)
+
+type Codec interface {
+	// 編碼格式名稱 (json or protobuf)
+	Name() string
+
+	// 將 change event 編碼為單筆 payload
+	Encode(event *storage.ChangeEvent) ([]byte, error)
+
+	// 將單筆 payload 解碼為 change event, 並檢查 payload 的版本是否相容
+	Decode(data []byte) (*storage.ChangeEvent, error)
+}
+
+// New 依照格式名稱建立對應的 codec
+func New(format string) (Codec, error) {
+	switch format {
+	case "", "json":
+		return NewJSONCodec(), nil
+	case "protobuf":
+		return NewProtobufCodec(), nil
+	default:
+		return nil, fmt.Errorf("change event format undefined: %v", format)
+	}
+}
+
+func checkVersion(version int) error {
+	if version != storage.ChangeEventVersion {
+		return fmt.Errorf("unsupported change event version %v, expected %v", version, storage.ChangeEventVersion)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"practice/internal/storage"
+)
+
+type jsonCodec struct{}
+
+// NewJSONCodec New JSON Change Event Codec
+func NewJSONCodec() Codec {
+	return &jsonCodec{}
+}
+
+func (c *jsonCodec) Name() string {
+	return "json"
+}
+
+func (c *jsonCodec) Encode(event *storage.ChangeEvent) ([]byte, error) {
+	tagged := *event
+	tagged.Version = storage.ChangeEventVersion
+
+	data, err := json.Marshal(&tagged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode change event into json: %w", err)
+	}
+
+	return data, nil
+}
+
+func (c *jsonCodec) Decode(data []byte) (*storage.ChangeEvent, error) {
+	// 使用 json.Number 保留整數精度, 避免主鍵等欄位被轉為 float64
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	event := &storage.ChangeEvent{}
+	if err := decoder.Decode(event); err != nil {
+		return nil, fmt.Errorf("failed to decode change event from json: %w", err)
+	}
+
+	if err := checkVersion(event.Version); err != nil {
+		return nil, err
+	}
+
+	for _, row := range []map[string]interface{}{event.PrimaryKey, event.Before, event.After} {
+		for column, value := range row {
+			if number, ok := value.(json.Number); ok {
+				row[column] = fromNumber(number)
+			}
+		}
+	}
+
+	return event, nil
+}
+
+func fromNumber(number json.Number) interface{} {
+	if i, err := number.Int64(); err == nil {
+		return i
+	}
+	if f, err := number.Float64(); err == nil {
+		return f
+	}
+	return number.String()
+}
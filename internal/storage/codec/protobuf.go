@@ -0,0 +1,215 @@
+package codec
+
+import (
+	"fmt"
+	"practice/internal/storage"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// operations 為 storage.ChangeEvent 的異動類型與 change_event.proto 中 Operation 的對應
+var operations = map[string]Operation{
+	storage.OperationInsert: Operation_OPERATION_INSERT,
+	storage.OperationUpdate: Operation_OPERATION_UPDATE,
+	storage.OperationDelete: Operation_OPERATION_DELETE,
+	storage.OperationRead:   Operation_OPERATION_READ,
+}
+
+type protobufCodec struct {
+	// map 欄位的 entry 依照 key 排序, 相同的事件每次編碼的結果都相同
+	marshal proto.MarshalOptions
+}
+
+// NewProtobufCodec New Protobuf Change Event Codec
+func NewProtobufCodec() Codec {
+	return &protobufCodec{marshal: proto.MarshalOptions{Deterministic: true}}
+}
+
+func (c *protobufCodec) Name() string {
+	return "protobuf"
+}
+
+func (c *protobufCodec) Encode(event *storage.ChangeEvent) ([]byte, error) {
+	operation, ok := operations[event.Operation]
+	if !ok {
+		return nil, fmt.Errorf("unsupported operation %q on table %v", event.Operation, event.Table)
+	}
+
+	message := &ChangeEvent{
+		Version:       uint32(storage.ChangeEventVersion),
+		Source:        event.Source,
+		Database:      event.Database,
+		Table:         event.Table,
+		Operation:     operation,
+		Position:      event.Position,
+		TransactionId: event.TransactionID,
+		SchemaVersion: uint32(event.SchemaVersion),
+	}
+	if !event.CommitTimestamp.IsZero() {
+		message.CommitTimestamp = event.CommitTimestamp.UnixNano()
+	}
+
+	var err error
+	if message.PrimaryKey, err = toValues(event.PrimaryKey); err != nil {
+		return nil, err
+	}
+	if message.Before, err = toValues(event.Before); err != nil {
+		return nil, err
+	}
+	if message.After, err = toValues(event.After); err != nil {
+		return nil, err
+	}
+
+	return c.marshal.Marshal(message)
+}
+
+func (c *protobufCodec) Decode(data []byte) (*storage.ChangeEvent, error) {
+	message := &ChangeEvent{}
+	if err := proto.Unmarshal(data, message); err != nil {
+		return nil, fmt.Errorf("failed to decode change event: %w", err)
+	}
+
+	if err := checkVersion(int(message.Version)); err != nil {
+		return nil, err
+	}
+
+	event := &storage.ChangeEvent{
+		Version:       int(message.Version),
+		Source:        message.Source,
+		Database:      message.Database,
+		Table:         message.Table,
+		Position:      message.Position,
+		TransactionID: message.TransactionId,
+		SchemaVersion: int(message.SchemaVersion),
+	}
+	for name, operation := range operations {
+		if operation == message.Operation {
+			event.Operation = name
+		}
+	}
+	if event.Operation == "" {
+		return nil, fmt.Errorf("unsupported operation %v", message.Operation)
+	}
+	if message.CommitTimestamp != 0 {
+		event.CommitTimestamp = time.Unix(0, message.CommitTimestamp)
+	}
+
+	var err error
+	if event.PrimaryKey, err = fromValues(message.PrimaryKey); err != nil {
+		return nil, err
+	}
+	if event.Before, err = fromValues(message.Before); err != nil {
+		return nil, err
+	}
+	if event.After, err = fromValues(message.After); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// toValues 將欄位內容轉換為 map<string, Value>, 沒有欄位時回傳 nil
+func toValues(row map[string]interface{}) (map[string]*Value, error) {
+	if len(row) == 0 {
+		return nil, nil
+	}
+
+	values := make(map[string]*Value, len(row))
+	for column, value := range row {
+		v, err := toValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode column %v: %w", column, err)
+		}
+		values[column] = v
+	}
+	return values, nil
+}
+
+func toValue(value interface{}) (*Value, error) {
+	switch v := value.(type) {
+	case nil:
+		return &Value{Kind: &Value_NullValue{NullValue: true}}, nil
+	case int:
+		return intValue(int64(v)), nil
+	case int8:
+		return intValue(int64(v)), nil
+	case int16:
+		return intValue(int64(v)), nil
+	case int32:
+		return intValue(int64(v)), nil
+	case int64:
+		return intValue(v), nil
+	case uint:
+		return uintValue(uint64(v)), nil
+	case uint8:
+		return uintValue(uint64(v)), nil
+	case uint16:
+		return uintValue(uint64(v)), nil
+	case uint32:
+		return uintValue(uint64(v)), nil
+	case uint64:
+		return uintValue(v), nil
+	case float32:
+		return &Value{Kind: &Value_DoubleValue{DoubleValue: float64(v)}}, nil
+	case float64:
+		return &Value{Kind: &Value_DoubleValue{DoubleValue: v}}, nil
+	case string:
+		return &Value{Kind: &Value_StringValue{StringValue: v}}, nil
+	case []byte:
+		return &Value{Kind: &Value_BytesValue{BytesValue: v}}, nil
+	case bool:
+		return &Value{Kind: &Value_BoolValue{BoolValue: v}}, nil
+	case time.Time:
+		return &Value{Kind: &Value_TimestampValue{TimestampValue: v.UnixNano()}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", value)
+	}
+}
+
+func intValue(v int64) *Value {
+	return &Value{Kind: &Value_IntValue{IntValue: v}}
+}
+
+func uintValue(v uint64) *Value {
+	return &Value{Kind: &Value_UintValue{UintValue: v}}
+}
+
+// fromValues 將 map<string, Value> 轉換為欄位內容, 整數解碼為 int64 或 uint64, 沒有欄位時回傳 nil
+func fromValues(values map[string]*Value) (map[string]interface{}, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	row := make(map[string]interface{}, len(values))
+	for column, value := range values {
+		v, err := fromValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode column %v: %w", column, err)
+		}
+		row[column] = v
+	}
+	return row, nil
+}
+
+func fromValue(value *Value) (interface{}, error) {
+	switch kind := value.GetKind().(type) {
+	case *Value_NullValue:
+		return nil, nil
+	case *Value_IntValue:
+		return kind.IntValue, nil
+	case *Value_UintValue:
+		return kind.UintValue, nil
+	case *Value_DoubleValue:
+		return kind.DoubleValue, nil
+	case *Value_StringValue:
+		return kind.StringValue, nil
+	case *Value_BytesValue:
+		return kind.BytesValue, nil
+	case *Value_BoolValue:
+		return kind.BoolValue, nil
+	case *Value_TimestampValue:
+		return time.Unix(0, kind.TimestampValue), nil
+	}
+	return nil, fmt.Errorf("unsupported value kind %T", value.GetKind())
+}
@@ -0,0 +1,154 @@
+package codec
+
+import (
+	"bytes"
+	"math"
+	"practice/internal/storage"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func testEvent() *storage.ChangeEvent {
+	timestamp := time.Unix(0, time.Date(2023, 1, 15, 8, 30, 0, 123456789, time.UTC).UnixNano())
+
+	return &storage.ChangeEvent{
+		Version:       storage.ChangeEventVersion,
+		Source:        "mysql",
+		Database:      "practice",
+		Table:         "wallets",
+		Operation:     storage.OperationUpdate,
+		SchemaVersion: 3,
+		PrimaryKey:    map[string]interface{}{"id": int64(-7)},
+		Before: map[string]interface{}{
+			"null":      nil,
+			"int":       int64(-42),
+			"uint":      uint64(math.MaxUint64),
+			"double":    12.5,
+			"string":    "餘額",
+			"bytes":     []byte{0, 1, 2},
+			"bool":      true,
+			"timestamp": timestamp,
+		},
+		After:           map[string]interface{}{"amount": int64(100)},
+		Position:        "mysql-bin.000003:1234",
+		TransactionID:   "3E11FA47-71CA-11E1-9E33-C80AA9429562:23",
+		CommitTimestamp: timestamp,
+	}
+}
+
+func TestProtobufRoundTrip(t *testing.T) {
+	codec := NewProtobufCodec()
+	event := testEvent()
+
+	data, err := codec.Encode(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := codec.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, event) {
+		t.Errorf("expected %+v, got %+v", event, got)
+	}
+
+	// 以產生的型別解碼, 確認欄位依照 change_event.proto 的定義編碼
+	message := &ChangeEvent{}
+	if err := proto.Unmarshal(data, message); err != nil {
+		t.Fatal(err)
+	}
+	if len(message.ProtoReflect().GetUnknown()) > 0 {
+		t.Error("payload has fields undefined in change_event.proto")
+	}
+	if message.GetOperation() != Operation_OPERATION_UPDATE {
+		t.Errorf("expected OPERATION_UPDATE, got %v", message.GetOperation())
+	}
+	if v := message.GetBefore()["uint"].GetUintValue(); v != math.MaxUint64 {
+		t.Errorf("expected uint_value %v, got %v", uint64(math.MaxUint64), v)
+	}
+	if !message.GetBefore()["null"].GetNullValue() {
+		t.Error("expected null_value for nil column")
+	}
+}
+
+func TestProtobufEncodingIsDeterministic(t *testing.T) {
+	codec := NewProtobufCodec()
+	first, err := codec.Encode(testEvent())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// map 的走訪順序每次不同, 多次編碼都必須得到相同的位元組
+	for i := 0; i < 50; i++ {
+		data, err := codec.Encode(testEvent())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, first) {
+			t.Fatalf("encoding %d differs from the first encoding", i)
+		}
+	}
+}
+
+func TestProtobufOptionalFields(t *testing.T) {
+	codec := NewProtobufCodec()
+	event := &storage.ChangeEvent{
+		Version:    storage.ChangeEventVersion,
+		Source:     "postgresql",
+		Table:      "logs",
+		Operation:  storage.OperationDelete,
+		PrimaryKey: map[string]interface{}{"id": uint64(1)},
+		Before:     map[string]interface{}{},
+	}
+
+	data, err := codec.Encode(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := codec.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Before != nil || got.After != nil {
+		t.Errorf("expected empty rows to decode as nil, got %v and %v", got.Before, got.After)
+	}
+	if !got.CommitTimestamp.IsZero() {
+		t.Errorf("expected zero commit timestamp, got %v", got.CommitTimestamp)
+	}
+}
+
+func TestProtobufRejectsInvalidEvents(t *testing.T) {
+	codec := NewProtobufCodec()
+
+	event := testEvent()
+	event.Operation = "truncate"
+	if _, err := codec.Encode(event); err == nil || !strings.Contains(err.Error(), "unsupported operation") {
+		t.Errorf("expected unsupported operation, got %v", err)
+	}
+
+	event = testEvent()
+	event.After["amount"] = struct{}{}
+	if _, err := codec.Encode(event); err == nil || !strings.Contains(err.Error(), "column amount") {
+		t.Errorf("expected unsupported value of column amount, got %v", err)
+	}
+
+	data, err := proto.Marshal(&ChangeEvent{Version: uint32(storage.ChangeEventVersion), Operation: Operation_OPERATION_UNSPECIFIED})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := codec.Decode(data); err == nil {
+		t.Error("expected unspecified operation to fail")
+	}
+
+	data, err = proto.Marshal(&ChangeEvent{Version: uint32(storage.ChangeEventVersion) + 1, Operation: Operation_OPERATION_INSERT})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := codec.Decode(data); err == nil {
+		t.Error("expected newer version to fail")
+	}
+}
@@ -0,0 +1,107 @@
+package dialect
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupported 表示資料庫不支援的語法或行為, 由 dialect 明確拒絕, 避免資料庫默默改用其他語意
+var ErrUnsupported = errors.New("unsupported by dialect")
+
+// Strength 為上鎖讀取 (locking read) 的鎖強度
+type Strength int
+
+const (
+	ForShare       Strength = iota // 共享鎖, 其他交易仍可讀取與上共享鎖
+	ForUpdate                      // 排他鎖
+	ForKeyShare                    // PostgreSQL 限定, 只阻擋刪除與修改主鍵
+	ForNoKeyUpdate                 // PostgreSQL 限定, 不阻擋 ForKeyShare 的排他鎖
+)
+
+// WaitPolicy 為遇到其他交易持有的鎖時的處理方式
+type WaitPolicy int
+
+const (
+	Block      WaitPolicy = iota // 等待直到取得鎖或逾時
+	NoWait                       // 立即回傳錯誤
+	SkipLocked                   // 略過已上鎖的資料列
+)
+
+// Dialect 為不同資料庫在語法與語意上的差異, 讓同一份情境與 repository 可以在各資料庫上執行
+type Dialect interface {
+	// 對應 config.RdbOpts.Driver
+	Driver() string
+
+	// 第 n 個參數的 placeholder, n 由 1 開始
+	Placeholder(n int) string
+
+	// 將以 ? 撰寫的 SQL 轉換成此資料庫的 placeholder, 字串常值與引號中的 ? 不轉換
+	Rebind(query string) string
+
+	// 以識別字引號包住資料表或欄位名稱
+	Quote(identifier string) string
+
+	// 回傳目前資料庫 (MySQL) 或 schema (PostgreSQL) 名稱的 SQL 運算式
+	CurrentSchema() string
+
+	// 回傳附加在 SELECT 之後的上鎖子句
+	Lock(strength Strength, wait WaitPolicy) (string, error)
+
+	// 回傳新增單一資料列, 主鍵或唯一鍵衝突時以新值更新其餘欄位的 SQL, keys 為衝突判斷的欄位
+	Upsert(table string, columns, keys []string) (string, error)
+
+	// 回傳附加在 INSERT ... VALUES 之後的衝突處理子句, update 為 false 時略過衝突的資料列
+	OnConflict(columns, keys []string, update bool) (string, error)
+
+	// 回傳清空資料表並重設自動遞增主鍵的 SQL, 每個元素為單一 statement
+	Truncate(tables ...string) []string
+
+	// 檢查隔離等級, 資料庫會默默以其他等級執行時回傳 ErrUnsupported
+	Isolation(level sql.IsolationLevel) (sql.IsolationLevel, error)
+
+	// 判斷錯誤是否為序列化失敗或死結, 交易可以重試
+	Retryable(err error) bool
+
+	// 判斷錯誤是否為違反主鍵或唯一鍵
+	Duplicate(err error) bool
+}
+
+// New 以 driver 名稱建立 dialect
+func New(driver string) (Dialect, error) {
+	switch driver {
+	case "mysql":
+		return Mysql{}, nil
+	case "postgresql":
+		return Postgres{}, nil
+	}
+	return nil, fmt.Errorf("dialect of driver %v is undifined", driver)
+}
+
+// rebind 將引號外的 ? 依序替換成 placeholder
+func rebind(query string, placeholder func(n int) string) string {
+	out := make([]byte, 0, len(query)+16)
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			n++
+			out = append(out, placeholder(n)...)
+			continue
+		}
+		out = append(out, c)
+	}
+	return string(out)
+}
+
+func unsupported(driver, feature string) error {
+	return fmt.Errorf("%v %w: %v", driver, ErrUnsupported, feature)
+}
@@ -0,0 +1,122 @@
+package dialect
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	driver "github.com/go-sql-driver/mysql"
+)
+
+// Mysql 為 MySQL 8.0 的語法
+type Mysql struct{}
+
+func (Mysql) Driver() string { return "mysql" }
+
+func (Mysql) Placeholder(n int) string { return "?" }
+
+func (Mysql) Rebind(query string) string { return query }
+
+func (Mysql) Quote(identifier string) string {
+	return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
+}
+
+func (Mysql) CurrentSchema() string { return "DATABASE()" }
+
+func (d Mysql) Lock(strength Strength, wait WaitPolicy) (string, error) {
+	var clause string
+	switch strength {
+	case ForShare:
+		// LOCK IN SHARE MODE 不支援 NOWAIT 與 SKIP LOCKED, 需要時改用 8.0 的 FOR SHARE
+		clause = "LOCK IN SHARE MODE"
+		if wait != Block {
+			clause = "FOR SHARE"
+		}
+	case ForUpdate:
+		clause = "FOR UPDATE"
+	case ForKeyShare:
+		return "", unsupported(d.Driver(), "FOR KEY SHARE")
+	case ForNoKeyUpdate:
+		return "", unsupported(d.Driver(), "FOR NO KEY UPDATE")
+	default:
+		return "", unsupported(d.Driver(), fmt.Sprintf("lock strength %v", strength))
+	}
+
+	switch wait {
+	case NoWait:
+		clause += " NOWAIT"
+	case SkipLocked:
+		clause += " SKIP LOCKED"
+	}
+	return clause, nil
+}
+
+// Upsert 以 ON DUPLICATE KEY UPDATE 實作, MySQL 以任一主鍵或唯一鍵判斷衝突, keys 只用來決定不更新的欄位
+func (d Mysql) Upsert(table string, columns, keys []string) (string, error) {
+	conflict, err := d.OnConflict(columns, keys, true)
+	if err != nil {
+		return "", fmt.Errorf("upsert into %v: %w", table, err)
+	}
+
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = d.Quote(column)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) %s",
+		d.Quote(table), strings.Join(quoted, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "), conflict), nil
+}
+
+// OnConflict MySQL 沒有 DO NOTHING, 以不改變內容的更新略過衝突, 不使用 INSERT IGNORE 以免一併忽略型別與長度錯誤
+func (d Mysql) OnConflict(columns, keys []string, update bool) (string, error) {
+	if len(columns) == 0 {
+		return "", fmt.Errorf("conflict clause needs columns")
+	}
+
+	isKey := map[string]bool{}
+	for _, key := range keys {
+		isKey[key] = true
+	}
+	sets := []string{}
+	for _, column := range columns {
+		if update && !isKey[column] {
+			quoted := d.Quote(column)
+			sets = append(sets, fmt.Sprintf("%s = VALUES(%s)", quoted, quoted))
+		}
+	}
+	// 所有欄位皆為鍵時沒有可以更新的欄位, 以不改變內容的更新忽略衝突
+	if len(sets) == 0 {
+		quoted := d.Quote(columns[0])
+		sets = append(sets, fmt.Sprintf("%s = %s", quoted, quoted))
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", "), nil
+}
+
+// Truncate MySQL 的 TRUNCATE 一次只能清空一個資料表, 並會重設 AUTO_INCREMENT
+func (d Mysql) Truncate(tables ...string) []string {
+	statements := make([]string, len(tables))
+	for i, table := range tables {
+		statements[i] = "TRUNCATE TABLE " + d.Quote(table)
+	}
+	return statements
+}
+
+func (d Mysql) Isolation(level sql.IsolationLevel) (sql.IsolationLevel, error) {
+	switch level {
+	case sql.LevelDefault, sql.LevelReadUncommitted, sql.LevelReadCommitted, sql.LevelRepeatableRead, sql.LevelSerializable:
+		return level, nil
+	}
+	return level, unsupported(d.Driver(), level.String()+" isolation")
+}
+
+// Retryable 1213 為死結, 1205 為等待鎖逾時
+func (Mysql) Retryable(err error) bool {
+	var mysqlErr *driver.MySQLError
+	return errors.As(err, &mysqlErr) && (mysqlErr.Number == 1213 || mysqlErr.Number == 1205)
+}
+
+// Duplicate 1062 為主鍵或唯一鍵重複
+func (Mysql) Duplicate(err error) bool {
+	var mysqlErr *driver.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
@@ -0,0 +1,134 @@
+package dialect
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Postgres 為 PostgreSQL 12 的語法
+type Postgres struct{}
+
+func (Postgres) Driver() string { return "postgresql" }
+
+func (Postgres) Placeholder(n int) string { return fmt.Sprintf("$%d", n) }
+
+func (d Postgres) Rebind(query string) string { return rebind(query, d.Placeholder) }
+
+func (Postgres) Quote(identifier string) string { return pq.QuoteIdentifier(identifier) }
+
+func (Postgres) CurrentSchema() string { return "current_schema()" }
+
+func (d Postgres) Lock(strength Strength, wait WaitPolicy) (string, error) {
+	var clause string
+	switch strength {
+	case ForShare:
+		clause = "FOR SHARE"
+	case ForUpdate:
+		clause = "FOR UPDATE"
+	case ForKeyShare:
+		clause = "FOR KEY SHARE"
+	case ForNoKeyUpdate:
+		clause = "FOR NO KEY UPDATE"
+	default:
+		return "", unsupported(d.Driver(), fmt.Sprintf("lock strength %v", strength))
+	}
+
+	switch wait {
+	case NoWait:
+		clause += " NOWAIT"
+	case SkipLocked:
+		clause += " SKIP LOCKED"
+	}
+	return clause, nil
+}
+
+// Upsert 以 ON CONFLICT 實作, PostgreSQL 必須指定衝突判斷的欄位
+func (d Postgres) Upsert(table string, columns, keys []string) (string, error) {
+	conflict, err := d.OnConflict(columns, keys, true)
+	if err != nil {
+		return "", fmt.Errorf("upsert into %v: %w", table, err)
+	}
+
+	quoted := make([]string, len(columns))
+	holders := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = d.Quote(column)
+		holders[i] = d.Placeholder(i + 1)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) %s",
+		d.Quote(table), strings.Join(quoted, ", "), strings.Join(holders, ", "), conflict), nil
+}
+
+// OnConflict 略過衝突時可以不指定欄位, 以任一主鍵或唯一鍵判斷; 更新時必須指定衝突判斷的欄位
+func (d Postgres) OnConflict(columns, keys []string, update bool) (string, error) {
+	if len(columns) == 0 {
+		return "", fmt.Errorf("conflict clause needs columns")
+	}
+	if update && len(keys) == 0 {
+		return "", unsupported(d.Driver(), "upsert without conflict target")
+	}
+
+	isKey := map[string]bool{}
+	quotedKeys := make([]string, len(keys))
+	for i, key := range keys {
+		isKey[key] = true
+		quotedKeys[i] = d.Quote(key)
+	}
+	sets := []string{}
+	for _, column := range columns {
+		if update && !isKey[column] {
+			quoted := d.Quote(column)
+			sets = append(sets, fmt.Sprintf("%s = EXCLUDED.%s", quoted, quoted))
+		}
+	}
+
+	target := ""
+	if len(keys) > 0 {
+		target = fmt.Sprintf(" (%s)", strings.Join(quotedKeys, ", "))
+	}
+	action := "DO NOTHING"
+	if len(sets) > 0 {
+		action = "DO UPDATE SET " + strings.Join(sets, ", ")
+	}
+	return fmt.Sprintf("ON CONFLICT%s %s", target, action), nil
+}
+
+// Truncate PostgreSQL 的 TRUNCATE 預設不重設 sequence, 必須加上 RESTART IDENTITY 才會與 MySQL 相同由 1 開始
+func (d Postgres) Truncate(tables ...string) []string {
+	if len(tables) == 0 {
+		return nil
+	}
+
+	quoted := make([]string, len(tables))
+	for i, table := range tables {
+		quoted[i] = d.Quote(table)
+	}
+	return []string{fmt.Sprintf("TRUNCATE TABLE %s RESTART IDENTITY", strings.Join(quoted, ", "))}
+}
+
+// Isolation PostgreSQL 以 READ COMMITTED 執行 READ UNCOMMITTED, 不會發生 dirty read
+func (d Postgres) Isolation(level sql.IsolationLevel) (sql.IsolationLevel, error) {
+	switch level {
+	case sql.LevelDefault, sql.LevelReadCommitted, sql.LevelRepeatableRead, sql.LevelSerializable:
+		return level, nil
+	case sql.LevelReadUncommitted:
+		return sql.LevelReadCommitted, unsupported(d.Driver(), "READ UNCOMMITTED isolation, it behaves as READ COMMITTED")
+	}
+	return level, unsupported(d.Driver(), level.String()+" isolation")
+}
+
+// Retryable 40001 為序列化失敗 (e.g. repeatable read 下更新已被其他交易修改的資料列), 40P01 為死結
+func (Postgres) Retryable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && (pqErr.Code == "40001" || pqErr.Code == "40P01")
+}
+
+// Duplicate 23505 為 unique_violation
+func (Postgres) Duplicate(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,40 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"practice/internal/storage"
+)
+
+// Imbalance 為餘額與轉帳記錄不一致的錢包
+type Imbalance struct {
+	UserID   int64
+	Amount   int64 // 錢包餘額
+	Expected int64 // 存款總和減去出款總和
+}
+
+// CheckBalances 回傳餘額不等於轉帳記錄中存款總和減去出款總和的錢包
+// 只有以轉帳記錄入金的資料 (generate_data --logs 或 --rate) 才會一致
+func CheckBalances(ctx context.Context, q storage.Querier) ([]*Imbalance, error) {
+	rows, err := q.QueryContext(ctx, `
+	SELECT w.user_id, w.amount, COALESCE(d.total, 0) - COALESCE(o.total, 0)
+	FROM wallets w
+	LEFT JOIN (SELECT deposit_user_id AS user_id, SUM(amount) AS total FROM logs GROUP BY deposit_user_id) d ON d.user_id = w.user_id
+	LEFT JOIN (SELECT withdraw_user_id AS user_id, SUM(amount) AS total FROM logs GROUP BY withdraw_user_id) o ON o.user_id = w.user_id
+	WHERE w.amount <> COALESCE(d.total, 0) - COALESCE(o.total, 0)
+	ORDER BY w.user_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare wallets with logs: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*Imbalance
+	for rows.Next() {
+		imbalance := &Imbalance{}
+		if err := rows.Scan(&imbalance.UserID, &imbalance.Amount, &imbalance.Expected); err != nil {
+			return nil, err
+		}
+		result = append(result, imbalance)
+	}
+	return result, rows.Err()
+}
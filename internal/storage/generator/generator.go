@@ -0,0 +1,361 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"practice/internal/storage"
+	"strings"
+	"time"
+)
+
+// 錢包初始金額的分布
+const (
+	DistributionFixed   = "fixed"   // 每個錢包皆為 Amount
+	DistributionUniform = "uniform" // 介於 0 與 MaxAmount 之間的均勻分布
+	DistributionNormal  = "normal"  // 平均為 Amount, 標準差為 Amount / 3 的常態分布
+	DistributionPareto  = "pareto"  // 最小值為 Amount 的 80/20 柏拉圖分布, 少數錢包持有多數金額
+)
+
+// MaxPlaceholders 為單一 statement 可以使用的 placeholder 上限 (MySQL 與 PostgreSQL 皆為 65535)
+const MaxPlaceholders = 65535
+
+// userWidth 為 users 每列 INSERT 使用的 placeholder 數量, 為三張資料表中最多的
+const userWidth = 6
+
+// maxUnsigned 為 int(11) unsigned 可以保存的最大值
+const maxUnsigned = math.MaxUint32
+
+// paretoAlpha 為 80/20 法則對應的柏拉圖分布形狀參數
+const paretoAlpha = 1.16
+
+// Options 為產生測試資料的設定, 相同的設定與 Seed 一定產生相同的資料
+type Options struct {
+	Users        int           // 用戶數量
+	Wallets      int           // 錢包數量, 依序屬於前 Wallets 個用戶, 不可超過用戶數量
+	Logs         int           // 轉帳數量, 不包含初始入金, 0 表示不產生轉帳記錄
+	BatchSize    int           // 每個 INSERT 的資料列數量
+	Seed         int64         // 亂數種子
+	Distribution string        // 錢包初始金額的分布
+	Amount       int64         // fixed 的金額, normal 的平均, pareto 的最小值
+	MaxAmount    int64         // 錢包初始金額的上限
+	Since        time.Time     // 第一個用戶的註冊時間
+	Period       time.Duration // 用戶註冊與轉帳分布的期間
+	Activity     float64       // 用戶活躍度的柏拉圖分布形狀參數, 越小轉帳越集中在少數用戶
+}
+
+// DefaultOptions 回傳與過去固定產生的資料相同規模的設定
+func DefaultOptions() Options {
+	return Options{
+		Users:        10000,
+		Wallets:      10000,
+		BatchSize:    100,
+		Seed:         1,
+		Distribution: DistributionFixed,
+		Amount:       100000,
+		MaxAmount:    1000000,
+		Since:        time.Date(2022, 1, 1, 0, 0, 0, 0, time.Local),
+		Period:       365 * 24 * time.Hour,
+		Activity:     paretoAlpha,
+	}
+}
+
+// Validate 檢查設定是否可以產生資料
+func (o *Options) Validate() error {
+	if o.Users < 0 || o.Wallets < 0 || o.Logs < 0 {
+		return errors.New("row counts must not be negative")
+	}
+	if o.Wallets > o.Users {
+		return fmt.Errorf("wallets %v exceed users %v, every user has at most one wallet", o.Wallets, o.Users)
+	}
+	if o.Logs > 0 && o.Wallets < 2 {
+		return errors.New("transfer history needs at least 2 wallets")
+	}
+	if o.BatchSize <= 0 || o.BatchSize*userWidth > MaxPlaceholders {
+		return fmt.Errorf("batch size must be between 1 and %v", MaxPlaceholders/userWidth)
+	}
+	if o.Amount < 0 || o.MaxAmount < o.Amount || o.MaxAmount > maxUnsigned {
+		return fmt.Errorf("amounts must satisfy 0 <= amount (%v) <= max amount (%v) <= %v", o.Amount, o.MaxAmount, int64(maxUnsigned))
+	}
+	if o.Period <= 0 {
+		return errors.New("period must be positive")
+	}
+	if o.Activity <= 0 {
+		return errors.New("activity must be positive")
+	}
+
+	switch o.Distribution {
+	case DistributionFixed, DistributionUniform, DistributionNormal, DistributionPareto:
+	default:
+		return fmt.Errorf("distribution %v undifined", o.Distribution)
+	}
+	return nil
+}
+
+// Generator 依照設定以固定順序產生 users, wallets 與 logs, 所有亂數皆取自同一個 Seed
+type Generator struct {
+	opts     Options
+	rng      *rand.Rand
+	used     map[string]int // 各名稱已使用的次數
+	balances []int64
+}
+
+// New New Deterministic Test Data Generator
+// @param opts  row counts, seed and amount distribution
+func New(opts Options) (*Generator, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Generator{
+		opts: opts,
+		rng:  rand.New(rand.NewSource(opts.Seed)),
+		used: make(map[string]int),
+	}, nil
+}
+
+// Users 依照 ID 順序分批產生用戶, 註冊時間平均分布在 Since 之後的 Period 內
+// 用戶 ID 由 1 開始連續配置, 以 INSERT 新增時必須先清空資料表並重設自動遞增主鍵
+func (g *Generator) Users(batch func(users []*storage.User) error) error {
+	users := make([]*storage.User, 0, g.opts.BatchSize)
+	for i := 0; i < g.opts.Users; i++ {
+		first := firstNames[g.rng.Intn(len(firstNames))]
+		last := lastNames[g.rng.Intn(len(lastNames))]
+		registered := g.registeredAt(i)
+
+		users = append(users, &storage.User{
+			ID:         int64(i + 1),
+			Account:    fmt.Sprintf("user%v", i+1),
+			Password:   "password",
+			Nickname:   g.unique("nickname:", first+last, ""),
+			Email:      g.unique("email:", strings.ToLower(first+"."+last), "@"+domains[g.rng.Intn(len(domains))]),
+			CreatedAt:  registered,
+			ModifiedAt: registered,
+		})
+
+		if len(users) == g.opts.BatchSize {
+			if err := batch(users); err != nil {
+				return err
+			}
+			users = make([]*storage.User, 0, g.opts.BatchSize)
+		}
+	}
+
+	// 名稱只需要在用戶之間唯一, 釋放已使用名稱佔用的記憶體
+	g.used = make(map[string]int)
+
+	if len(users) > 0 {
+		return batch(users)
+	}
+	return nil
+}
+
+// Logs 依照時間順序分批產生轉帳記錄, 轉帳結果累計到錢包餘額, 必須在 Users 之後, Wallets 之前呼叫
+// 每個錢包在用戶註冊時先由 SystemUserID 轉入初始金額, 之後的 Logs 筆轉帳依照時段的季節性分布在 Period 內,
+// 出款與存款用戶依照柏拉圖分布的活躍度挑選, 出款用戶餘額不足時略過, 因此餘額不會成為負數
+func (g *Generator) Logs(batch func(logs []*storage.TransferLog) error) error {
+	g.initBalances()
+	if g.opts.Logs == 0 {
+		return nil
+	}
+
+	logs := make([]*storage.TransferLog, 0, g.opts.BatchSize)
+	var id int64
+	emit := func(log *storage.TransferLog) error {
+		id++
+		log.ID = id
+		logs = append(logs, log)
+		if len(logs) < g.opts.BatchSize {
+			return nil
+		}
+
+		err := batch(logs)
+		logs = make([]*storage.TransferLog, 0, g.opts.BatchSize)
+		return err
+	}
+
+	// 入金所有在 until 之前註冊的用戶, 入金後才會參與轉帳
+	active := newWeights(g.opts.Wallets)
+	fund := func(until time.Time) error {
+		for i := active.size; i < g.opts.Wallets && !g.registeredAt(i).After(until); i++ {
+			active.add(i, activity(g.rng, g.opts.Activity))
+			if err := emit(&storage.TransferLog{
+				DepositUserID:  int64(i + 1),
+				WithdrawUserID: SystemUserID,
+				Amount:         g.balances[i],
+				CreatedAt:      g.registeredAt(i),
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// 以每小時的季節性權重依序分配剩餘的轉帳數量, 最後一個小時可能不完整
+	end := g.opts.Since.Add(g.opts.Period)
+	hours := []time.Time{}
+	total := 0.0
+	for at := g.opts.Since; at.Before(end); at = at.Add(time.Hour) {
+		hours = append(hours, at)
+		total += g.hourWeight(at, end)
+	}
+
+	remaining := g.opts.Logs
+	for _, start := range hours {
+		weight := g.hourWeight(start, end)
+		k := binomial(g.rng, remaining, weight/total)
+		remaining -= k
+		total -= weight
+
+		span := time.Hour
+		if start.Add(span).After(end) {
+			span = end.Sub(start)
+		}
+
+		for _, offset := range offsets(g.rng, k, span) {
+			at := start.Add(offset).Truncate(time.Second)
+			if err := fund(at); err != nil {
+				return err
+			}
+
+			log, ok := g.transfer(active, at)
+			if !ok {
+				continue
+			}
+			if err := emit(log); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 在最後一筆轉帳之後註冊的用戶
+	if err := fund(end); err != nil {
+		return err
+	}
+
+	if len(logs) > 0 {
+		return batch(logs)
+	}
+	return nil
+}
+
+// hourWeight 回傳由 start 開始的一小時在 end 之前的轉帳權重
+func (g *Generator) hourWeight(start, end time.Time) float64 {
+	weight := Seasonality(start)
+	if remain := end.Sub(start); remain < time.Hour {
+		weight *= float64(remain) / float64(time.Hour)
+	}
+	return weight
+}
+
+// transfer 依照活躍度挑選出款與存款用戶並更新餘額, 挑不到有餘額的出款用戶時略過這筆轉帳
+func (g *Generator) transfer(active *weights, at time.Time) (*storage.TransferLog, bool) {
+	if active.size < 2 {
+		return nil, false
+	}
+
+	for attempt := 0; attempt < 8; attempt++ {
+		withdraw := active.sample(g.rng)
+		deposit := active.sample(g.rng)
+		if withdraw == deposit || g.balances[withdraw] == 0 {
+			continue
+		}
+
+		amount := transferAmount(g.balances[withdraw], g.rng.Float64())
+		if g.balances[deposit]+amount > maxUnsigned {
+			continue
+		}
+
+		g.balances[withdraw] -= amount
+		g.balances[deposit] += amount
+		return &storage.TransferLog{
+			DepositUserID:  int64(deposit + 1),
+			WithdrawUserID: int64(withdraw + 1),
+			Amount:         amount,
+			CreatedAt:      at,
+		}, true
+	}
+	return nil, false
+}
+
+// Wallets 依照 ID 順序分批產生錢包, 第 i 個錢包屬於第 i 個用戶, 餘額為初始金額加上轉帳記錄的結果
+func (g *Generator) Wallets(batch func(wallets []*storage.Wallet) error) error {
+	g.initBalances()
+
+	wallets := make([]*storage.Wallet, 0, g.opts.BatchSize)
+	for i, balance := range g.balances {
+		registered := g.registeredAt(i)
+		wallets = append(wallets, &storage.Wallet{
+			ID:         int64(i + 1),
+			UserID:     int64(i + 1),
+			Amount:     balance,
+			CreatedAt:  registered,
+			ModifiedAt: registered,
+		})
+
+		if len(wallets) == g.opts.BatchSize {
+			if err := batch(wallets); err != nil {
+				return err
+			}
+			wallets = make([]*storage.Wallet, 0, g.opts.BatchSize)
+		}
+	}
+
+	if len(wallets) > 0 {
+		return batch(wallets)
+	}
+	return nil
+}
+
+// registeredAt 回傳第 i 個用戶的註冊時間, 取到秒讓兩種資料庫保存的內容相同
+func (g *Generator) registeredAt(i int) time.Time {
+	offset := time.Duration(float64(g.opts.Period) * float64(i) / float64(g.opts.Users))
+	return g.opts.Since.Add(offset).Truncate(time.Second)
+}
+
+// initBalances 依照分布產生所有錢包的初始金額, 只執行一次
+func (g *Generator) initBalances() {
+	if g.balances != nil {
+		return
+	}
+
+	g.balances = make([]int64, g.opts.Wallets)
+	for i := range g.balances {
+		g.balances[i] = g.amount()
+	}
+}
+
+// amount 依照分布產生一個介於 0 與 MaxAmount 之間的金額
+func (g *Generator) amount() int64 {
+	var value float64
+	switch g.opts.Distribution {
+	case DistributionFixed:
+		return g.opts.Amount
+	case DistributionUniform:
+		return g.rng.Int63n(g.opts.MaxAmount + 1)
+	case DistributionNormal:
+		value = float64(g.opts.Amount) + g.rng.NormFloat64()*float64(g.opts.Amount)/3
+	case DistributionPareto:
+		value = float64(g.opts.Amount) / math.Pow(1-g.rng.Float64(), 1/paretoAlpha)
+	}
+
+	if value < 0 {
+		return 0
+	}
+	if value > float64(g.opts.MaxAmount) {
+		return g.opts.MaxAmount
+	}
+	return int64(value)
+}
+
+// unique 回傳 base + suffix, base 重複時在 base 後加上遞增的序號
+// 名稱清單不含數字, 因此不同 base 加上序號後不會互相重複
+func (g *Generator) unique(namespace, base, suffix string) string {
+	key := namespace + base
+	g.used[key]++
+	if g.used[key] == 1 {
+		return base + suffix
+	}
+	return fmt.Sprintf("%v%v%v", base, g.used[key], suffix)
+}
@@ -0,0 +1,152 @@
+package generator
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// SystemUserID 為初始入金的出款用戶, 錢包的初始金額以一筆由系統轉入的轉帳記錄表示
+// 因此錢包餘額恆等於存款總和減去出款總和
+const SystemUserID = 0
+
+// hourly 為一天中各時段的相對轉帳量, 深夜最少, 午休與晚間最多
+var hourly = [24]float64{
+	0.30, 0.18, 0.12, 0.10, 0.10, 0.15, 0.35, 0.70, 1.00, 1.10, 1.15, 1.30,
+	1.55, 1.35, 1.10, 1.05, 1.10, 1.20, 1.45, 1.75, 1.90, 1.70, 1.20, 0.65,
+}
+
+// weekend 為週末相對於平日的轉帳量
+const weekend = 1.25
+
+// meanSeasonality 為一週內 Seasonality 的平均值, 讓 Rate 表示平均每小時的轉帳量
+var meanSeasonality = func() float64 {
+	sum := 0.0
+	for _, w := range hourly {
+		sum += w
+	}
+	return sum / 24 * (5 + 2*weekend) / 7
+}()
+
+// Seasonality 回傳 t 所在時段的相對轉帳量, 一週的平均為 1
+func Seasonality(t time.Time) float64 {
+	w := hourly[t.Hour()]
+	if day := t.Weekday(); day == time.Saturday || day == time.Sunday {
+		w *= weekend
+	}
+	return w / meanSeasonality
+}
+
+// activity 以柏拉圖分布產生用戶的活躍度, 少數用戶佔多數的轉帳
+func activity(rng *rand.Rand, alpha float64) float64 {
+	return math.Pow(1-rng.Float64(), -1/alpha)
+}
+
+// transferAmount 以 0 到 1 之間的亂數 r 決定出款用戶本次轉帳的金額, 小額轉帳居多, 最多為餘額的一半, 餘額為 0 時回傳 0
+func transferAmount(balance int64, r float64) int64 {
+	if balance <= 0 {
+		return 0
+	}
+
+	amount := int64(float64(balance) * r * r / 2)
+	if amount < 1 {
+		amount = 1
+	}
+	return amount
+}
+
+// binomial 回傳 n 次成功機率為 p 的試驗中成功的次數, n 很大時以常態或 Poisson 分布近似
+func binomial(rng *rand.Rand, n int, p float64) int {
+	if p <= 0 || n <= 0 {
+		return 0
+	}
+	if p >= 1 {
+		return n
+	}
+
+	if n <= 64 {
+		k := 0
+		for i := 0; i < n; i++ {
+			if rng.Float64() < p {
+				k++
+			}
+		}
+		return k
+	}
+
+	mean := float64(n) * p
+	var k int
+	if mean < 30 {
+		// Knuth 的 Poisson 抽樣
+		limit, product := math.Exp(-mean), rng.Float64()
+		for product > limit {
+			k++
+			product *= rng.Float64()
+		}
+	} else {
+		k = int(math.Round(mean + rng.NormFloat64()*math.Sqrt(mean*(1-p))))
+	}
+
+	if k < 0 {
+		return 0
+	}
+	if k > n {
+		return n
+	}
+	return k
+}
+
+// offsets 回傳 k 個介於 0 與 span 之間, 由小到大排列的隨機時間
+func offsets(rng *rand.Rand, k int, span time.Duration) []time.Duration {
+	result := make([]time.Duration, k)
+	for i := range result {
+		result[i] = time.Duration(rng.Int63n(int64(span)))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// weights 以 Fenwick tree 保存用戶的活躍度, 可以在加入用戶的同時依照活躍度加權抽樣
+type weights struct {
+	tree  []float64
+	size  int
+	total float64
+}
+
+func newWeights(capacity int) *weights {
+	return &weights{tree: make([]float64, capacity+1)}
+}
+
+// add 加入第 i 個用戶 (由 0 開始) 的活躍度
+func (w *weights) add(i int, weight float64) {
+	if i+1 > w.size {
+		w.size = i + 1
+	}
+	w.total += weight
+	for j := i + 1; j < len(w.tree); j += j & -j {
+		w.tree[j] += weight
+	}
+}
+
+// sample 依照活躍度加權挑選一個已加入的用戶
+func (w *weights) sample(rng *rand.Rand) int {
+	target := rng.Float64() * w.total
+
+	i, step := 0, 1
+	for step*2 < len(w.tree) {
+		step *= 2
+	}
+	for ; step > 0; step /= 2 {
+		if next := i + step; next < len(w.tree) && w.tree[next] <= target {
+			i = next
+			target -= w.tree[next]
+		}
+	}
+
+	// 浮點數誤差可能讓結果超出已加入的範圍
+	if i >= w.size {
+		return w.size - 1
+	}
+	return i
+}
@@ -0,0 +1,24 @@
+package generator
+
+// 產生用戶暱稱與信箱使用的名稱, 不可包含數字, 重複時由 unique 加上序號
+var firstNames = []string{
+	"Aaron", "Abigail", "Adam", "Alice", "Amelia", "Andrew", "Anna", "Benjamin", "Brian", "Camila",
+	"Charlotte", "Chloe", "Christopher", "Daniel", "David", "Dylan", "Elena", "Elijah", "Emily", "Emma",
+	"Ethan", "Evelyn", "Gabriel", "Grace", "Hannah", "Henry", "Isaac", "Isabella", "Jack", "James",
+	"Jason", "Jessica", "John", "Joseph", "Julia", "Kevin", "Laura", "Liam", "Lily", "Logan",
+	"Lucas", "Madison", "Mason", "Mia", "Michael", "Natalie", "Noah", "Olivia", "Owen", "Rachel",
+	"Ryan", "Samuel", "Sarah", "Sofia", "Sophie", "Thomas", "Victoria", "William", "Yuki", "Zoe",
+}
+
+var lastNames = []string{
+	"Anderson", "Brown", "Campbell", "Carter", "Chang", "Chen", "Clark", "Collins", "Davis", "Evans",
+	"Garcia", "Green", "Hall", "Harris", "Hayashi", "Hernandez", "Hill", "Huang", "Jackson", "Johnson",
+	"Jones", "Kim", "King", "Lee", "Lewis", "Lin", "Lopez", "Martin", "Martinez", "Miller",
+	"Mitchell", "Moore", "Nakamura", "Nelson", "Nguyen", "Parker", "Perez", "Roberts", "Robinson", "Rodriguez",
+	"Sato", "Scott", "Smith", "Suzuki", "Tanaka", "Taylor", "Thomas", "Thompson", "Tsai", "Wang",
+	"White", "Williams", "Wilson", "Wong", "Wright", "Wu", "Yamamoto", "Yang", "Young", "Zhang",
+}
+
+var domains = []string{
+	"example.com", "example.net", "example.org", "mail.example.com", "inbox.example.net",
+}
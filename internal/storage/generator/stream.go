@@ -0,0 +1,211 @@
+package generator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"practice/internal/storage"
+	"practice/internal/storage/dialect"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxRetries 為轉帳因死結或序列化失敗被中止後重試的次數
+const maxRetries = 3
+
+// StreamOptions 為即時產生轉帳的設定
+type StreamOptions struct {
+	Rate     float64       // 平均每小時的轉帳數量, 實際速率依照 Seasonality 隨時段變化
+	Activity float64       // 用戶活躍度的柏拉圖分布形狀參數
+	Seed     int64         // 亂數種子, 轉帳時間取決於執行時間, 因此串流產生的資料不可重現
+	Report   time.Duration // 回報統計的間隔
+}
+
+// StreamStats 為串流產生轉帳的統計
+type StreamStats struct {
+	Transfers int64 // 已提交的轉帳
+	Skipped   int64 // 出款用戶餘額為 0 而略過的轉帳
+	Retries   int64 // 被資料庫中止後重試的次數
+}
+
+// Streamer 以交易即時新增轉帳記錄並調整兩個錢包的餘額, 讓 CDC pipeline 有持續的異動可以消化
+type Streamer struct {
+	db           *sql.DB
+	repositories *storage.Repositories
+	dialect      dialect.Dialect
+	opts         StreamOptions
+	rng          *rand.Rand
+
+	users  []int64 // 擁有錢包的用戶
+	active *weights
+}
+
+// NewStreamer New Real-Time Transfer Streamer
+// @param db            connection of the source database
+// @param repositories  typed repositories of the same database
+// @param d             dialect of the same database
+// @param opts          rate and user activity
+func NewStreamer(db *sql.DB, repositories *storage.Repositories, d dialect.Dialect, opts StreamOptions) (*Streamer, error) {
+	if opts.Rate <= 0 {
+		return nil, errors.New("rate must be positive")
+	}
+	if opts.Activity <= 0 {
+		return nil, errors.New("activity must be positive")
+	}
+
+	return &Streamer{
+		db:           db,
+		repositories: repositories,
+		dialect:      d,
+		opts:         opts,
+		rng:          rand.New(rand.NewSource(opts.Seed)),
+	}, nil
+}
+
+// Run 持續產生轉帳直到 ctx 結束, ctx 結束不視為錯誤
+func (s *Streamer) Run(ctx context.Context) (*StreamStats, error) {
+	if err := s.loadUsers(ctx); err != nil {
+		return nil, err
+	}
+
+	stats := &StreamStats{}
+	report := time.NewTicker(s.opts.Report)
+	defer report.Stop()
+
+	var reported int64
+	for {
+		// Poisson process, 間隔為指數分布, 速率依照目前時段調整
+		perSecond := s.opts.Rate / 3600 * Seasonality(time.Now())
+		wait := time.Duration(s.rng.ExpFloat64() / perSecond * float64(time.Second))
+
+		select {
+		case <-ctx.Done():
+			return stats, nil
+		case <-report.C:
+			logrus.Infof("%v transfers, %v skipped, %v retries, %.1f transfers/s in the last %v",
+				stats.Transfers, stats.Skipped, stats.Retries,
+				float64(stats.Transfers-reported)/s.opts.Report.Seconds(), s.opts.Report)
+			reported = stats.Transfers
+			continue
+		case <-time.After(wait):
+		}
+
+		withdraw, deposit := s.pick()
+		if err := s.transfer(ctx, withdraw, deposit, stats); err != nil {
+			if ctx.Err() != nil {
+				return stats, nil
+			}
+			return stats, err
+		}
+	}
+}
+
+// loadUsers 讀取所有擁有錢包的用戶, 並給予每個用戶柏拉圖分布的活躍度
+func (s *Streamer) loadUsers(ctx context.Context) error {
+	var afterID int64
+	for {
+		wallets, err := s.repositories.Wallets.List(ctx, s.db, afterID, 10000)
+		if err != nil {
+			return err
+		}
+		if len(wallets) == 0 {
+			break
+		}
+
+		for _, wallet := range wallets {
+			s.users = append(s.users, wallet.UserID)
+		}
+		afterID = wallets[len(wallets)-1].ID
+	}
+
+	if len(s.users) < 2 {
+		return fmt.Errorf("streaming transfers needs at least 2 wallets, found %v", len(s.users))
+	}
+
+	s.active = newWeights(len(s.users))
+	for i := range s.users {
+		s.active.add(i, activity(s.rng, s.opts.Activity))
+	}
+	logrus.Infof("streaming transfers between %v users at %v transfers/hour", len(s.users), s.opts.Rate)
+	return nil
+}
+
+// pick 依照活躍度挑選兩個不同的用戶
+func (s *Streamer) pick() (int64, int64) {
+	withdraw := s.active.sample(s.rng)
+	deposit := s.active.sample(s.rng)
+	for deposit == withdraw {
+		deposit = s.active.sample(s.rng)
+	}
+	return s.users[withdraw], s.users[deposit]
+}
+
+// transfer 在交易內鎖定兩個錢包後轉帳, 被資料庫中止時重試
+func (s *Streamer) transfer(ctx context.Context, withdraw, deposit int64, stats *StreamStats) error {
+	// 轉帳金額在鎖定後才決定, 先抽出亂數讓重試使用相同的比例
+	r := s.rng.Float64()
+
+	for attempt := 0; ; attempt++ {
+		committed, err := s.transferOnce(ctx, withdraw, deposit, r)
+		if err == nil {
+			if committed {
+				stats.Transfers++
+			} else {
+				stats.Skipped++
+			}
+			return nil
+		}
+		if !s.dialect.Retryable(err) || attempt == maxRetries {
+			return err
+		}
+		stats.Retries++
+	}
+}
+
+func (s *Streamer) transferOnce(ctx context.Context, withdraw, deposit int64, r float64) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// 依照用戶 ID 順序上鎖, 兩筆方向相反的轉帳不會互相等待形成死結
+	first, second := withdraw, deposit
+	if first > second {
+		first, second = second, first
+	}
+	locked := map[int64]*storage.Wallet{}
+	for _, userID := range []int64{first, second} {
+		wallet, err := s.repositories.Wallets.GetByUserID(ctx, tx, userID, storage.LockUpdate)
+		if err != nil {
+			return false, fmt.Errorf("failed to lock the wallet of user %v: %w", userID, err)
+		}
+		locked[userID] = wallet
+	}
+
+	from, to := locked[withdraw], locked[deposit]
+	amount := transferAmount(from.Amount, r)
+	if amount == 0 || to.Amount+amount > maxUnsigned {
+		return false, nil
+	}
+
+	if err := s.repositories.Wallets.AddAmount(ctx, tx, from.ID, -amount); err != nil {
+		return false, err
+	}
+	if err := s.repositories.Wallets.AddAmount(ctx, tx, to.ID, amount); err != nil {
+		return false, err
+	}
+	if err := s.repositories.Logs.Create(ctx, tx, &storage.TransferLog{
+		DepositUserID:  deposit,
+		WithdrawUserID: withdraw,
+		Amount:         amount,
+		CreatedAt:      time.Now().Truncate(time.Second),
+	}); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
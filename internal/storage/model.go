@@ -1 +1,59 @@
 package storage
+
+import "time"
+
+// ChangeEventVersion 為 ChangeEvent 結構的版本, 結構有不相容的調整時必須遞增
+const ChangeEventVersion = 1
+
+// 資料異動的操作類型
+const (
+	OperationInsert = "insert"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+	OperationRead   = "read" // 初始快照讀出的既有資料列
+)
+
+// ChangeEvent 表示來源資料庫中單筆資料列的異動, 為所有 CDC source 與 sink 之間共用的資料格式
+type ChangeEvent struct {
+	Version         int                    `json:"version"`          // ChangeEvent 結構版本, 參考 ChangeEventVersion
+	Source          string                 `json:"source"`           // 來源資料庫種類 (mysql or postgresql)
+	Database        string                 `json:"database"`         // 來源資料庫名稱
+	Table           string                 `json:"table"`            // 來源資料表名稱
+	Operation       string                 `json:"operation"`        // insert, update, delete or read
+	SchemaVersion   int                    `json:"schema_version"`   // 來源資料表的結構版本, 參考 pipeline/schema.Registry
+	PrimaryKey      map[string]interface{} `json:"primary_key"`      // 主鍵欄位內容
+	Before          map[string]interface{} `json:"before"`           // 異動前的欄位內容 (insert 時為 nil)
+	After           map[string]interface{} `json:"after"`            // 異動後的欄位內容 (delete 時為 nil)
+	Position        string                 `json:"position"`         // 來源位置, 例如 binlog file:pos 或 LSN
+	TransactionID   string                 `json:"transaction_id"`   // 來源交易編號, 例如 GTID 或 xid
+	CommitTimestamp time.Time              `json:"commit_timestamp"` // 來源交易的提交時間
+}
+
+// User 對應 users 資料表
+type User struct {
+	ID         int64     `json:"id"`          // 用戶 UUID
+	Account    string    `json:"account"`     // 用戶帳號
+	Password   string    `json:"password"`    // 用戶密碼
+	Nickname   string    `json:"nickname"`    // 用戶暱稱
+	Email      string    `json:"email"`       // 用戶信箱
+	CreatedAt  time.Time `json:"created_at"`  // 註冊日期
+	ModifiedAt time.Time `json:"modified_at"` // 修改日期
+}
+
+// Wallet 對應 wallets 資料表, 每個用戶只有一個錢包
+type Wallet struct {
+	ID         int64     `json:"id"`          // 錢包 UUID
+	UserID     int64     `json:"user_id"`     // 用戶 UUID
+	Amount     int64     `json:"amount"`      // 用戶餘額, 不可為負數
+	CreatedAt  time.Time `json:"created_at"`  // 註冊日期
+	ModifiedAt time.Time `json:"modified_at"` // 修改日期
+}
+
+// TransferLog 對應 logs 資料表, 由 WithdrawUserID 轉帳 Amount 給 DepositUserID
+type TransferLog struct {
+	ID             int64     `json:"id"`               // 日誌 UUID
+	DepositUserID  int64     `json:"deposit_user_id"`  // 存款用戶 UUID
+	WithdrawUserID int64     `json:"withdraw_user_id"` // 出款用戶 UUID
+	Amount         int64     `json:"amount"`           // 轉帳金額
+	CreatedAt      time.Time `json:"created_at"`       // 註冊日期
+}
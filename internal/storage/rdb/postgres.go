@@ -4,13 +4,15 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"practice/internal/storage"
+	"practice/internal/storage/dialect"
 
 	_ "github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 )
 
 type postgres struct {
-	conn *sql.DB
+	scenarios
 }
 
 // NewPostgresClient New PostgreSQL Client Driver
@@ -39,7 +41,7 @@ func NewPostgresClient(ctx context.Context, host string, port int, user, passwor
 	}
 
 	return &postgres{
-		conn: conn,
+		scenarios: scenarios{conn: conn, dialect: dialect.Postgres{}, repositories: NewPostgresRepositories()},
 	}
 }
 
@@ -49,34 +51,10 @@ func (p *postgres) Shutdown(ctx context.Context) {
 	}
 }
 
-func (p *postgres) ShowTables(ctx context.Context) {
-
-}
-
-func (p *postgres) GenerateData(ctx context.Context) {
-
-}
-
-func (p *postgres) SimulateDirtyRead(ctx context.Context) {
-
-}
-
-func (p *postgres) SimulateReadSkew(ctx context.Context) {
-
+func (p *postgres) DB() *sql.DB {
+	return p.conn
 }
 
-func (p *postgres) SimulateLostUpdate(ctx context.Context) {
-
-}
-
-func (p *postgres) SimulateWriteSkew1(ctx context.Context) {
-
-}
-
-func (p *postgres) SimulateWriteSkew2(ctx context.Context) {
-
-}
-
-func (p *postgres) SimulateLockFailed1(ctx context.Context) {
-
+func (p *postgres) Repositories() *storage.Repositories {
+	return p.repositories
 }
@@ -2,6 +2,11 @@ package rdb
 
 import (
 	"context"
+	"database/sql"
+	"practice/internal/ddl"
+	"practice/internal/storage"
+	"practice/internal/storage/generator"
+	"practice/internal/storage/router"
 
 	"github.com/sirupsen/logrus"
 )
@@ -9,11 +14,17 @@ import (
 type Rdb interface {
 	Shutdown(ctx context.Context)
 
-	// 顯示目前關連式資料庫中所有的 tables & columns
-	ShowTables(ctx context.Context)
+	// 取得底層的資料庫連線, 提供 pipeline 等模組直接操作
+	DB() *sql.DB
 
-	// 建立測試資料
-	GenerateData(ctx context.Context)
+	// 取得 users, wallets 與 logs 的 typed repositories
+	Repositories() *storage.Repositories
+
+	// 讀取目前關連式資料庫中所有 tables 的欄位、索引、估計的資料列數量與大小
+	ShowTables(ctx context.Context) (*ddl.Live, error)
+
+	// 清空 users, wallets 與 logs 後依照設定建立測試資料, 相同的設定產生相同的資料
+	GenerateData(ctx context.Context, opts generator.Options) error
 
 	// 模擬髒讀(Dirty Read) 情境
 	SimulateDirtyRead(ctx context.Context)
@@ -34,6 +45,18 @@ type Rdb interface {
 
 	// 模擬因為聚簇索引(Clustered index) 與覆蓋索引(Covering index) 不同造成上鎖失敗的情境
 	SimulateLockFailed1(ctx context.Context)
+
+	// 模擬非同步複寫下讀不到自己寫入(Read Your Writes) 的情境
+	// fix 時寫入後等待 replica 套用到 primary 的位置才讀取
+	SimulateReadYourWrites(ctx context.Context, r *router.Router, fix bool)
+
+	// 模擬輪流讀取不同 replicas 時讀到的資料倒退(Monotonic Reads) 的情境
+	// fix 時 session 固定使用同一個 replica
+	SimulateMonotonicReads(ctx context.Context, r *router.Router, fix bool)
+
+	// 模擬 wallets 與 logs 由不同 replicas 讀取時看到不一致前綴(Consistent Prefix) 的情境
+	// fix 時在同一個 replica 的唯讀交易中讀取
+	SimulateConsistentPrefix(ctx context.Context, r *router.Router, fix bool)
 }
 
 func checkError(err error, msg string) {
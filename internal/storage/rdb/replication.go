@@ -0,0 +1,296 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"practice/internal/storage/router"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// replicationTimeout 為情境開始前等待 replicas 套用初始資料的上限, 需大於 replica 刻意設定的延遲 (SOURCE_DELAY)
+const replicationTimeout = 30 * time.Second
+
+// source 為情境中讀取的對象, primary 或其中一個 replica
+type source struct {
+	name string
+	db   *sql.DB
+}
+
+// sources 依照設定的順序回傳所有 replicas, 只有一個 replica 時在最前面加入 primary, 讓情境仍然可以交替讀取兩個來源
+func sources(r *router.Router) []source {
+	sources := []source{}
+	if len(r.Replicas()) == 1 {
+		sources = append(sources, source{name: router.PrimaryName, db: r.Primary()})
+	}
+	for _, replica := range r.Replicas() {
+		sources = append(sources, source{name: replica.Name, db: replica.DB})
+	}
+	return sources
+}
+
+// replicated 建立 wallets 1 (100000) 與 wallets 2 (0), 等待所有 replicas 套用後情境才開始
+// 沒有設定 replicas 時所有讀取都送往 primary, 無法重現情境因此回傳 false
+func (s *scenarios) replicated(ctx context.Context, r *router.Router) bool {
+	if len(r.Replicas()) == 0 {
+		logrus.Warn("rdb.replicas is empty, every read goes to the primary and the scenario cannot be reproduced")
+		return false
+	}
+
+	s.truncate("wallets", "logs")
+
+	timeNow := time.Now().Format("2006-01-02 15:04:05")
+	for _, amount := range []int{100000, 0} {
+		_, err := s.conn.Exec(s.dialect.Rebind("INSERT INTO wallets (user_id, amount, created_at, modified_at) VALUES (?, ?, ?, ?);"),
+			"1",
+			amount,
+			timeNow,
+			timeNow,
+		)
+		checkError(err, "failed to execute:")
+	}
+
+	checkError(r.WaitReplicas(ctx, replicationTimeout), "failed to wait for replicas:")
+	return true
+}
+
+// transfer 在 primary 以單一交易由 wallets 1 轉帳 60000 至 wallets 2 並寫入轉帳記錄
+func (s *scenarios) transfer(ctx context.Context, tx *sql.Tx) {
+	_, err := tx.ExecContext(ctx, "UPDATE wallets SET amount = amount - 60000 WHERE id = 1;")
+	checkError(err, "failed to execute:")
+
+	_, err = tx.ExecContext(ctx, "UPDATE wallets SET amount = amount + 60000 WHERE id = 2;")
+	checkError(err, "failed to execute:")
+
+	_, err = tx.ExecContext(ctx, s.dialect.Rebind("INSERT INTO logs (deposit_user_id, withdraw_user_id, amount, created_at) VALUES (?, ?, ?, ?);"),
+		2,
+		1,
+		60000,
+		time.Now().Format("2006-01-02 15:04:05"),
+	)
+	checkError(err, "failed to execute:")
+}
+
+func (s *scenarios) SimulateReadYourWrites(ctx context.Context, r *router.Router, fix bool) {
+	// init
+	if !s.replicated(ctx, r) {
+		return
+	}
+
+	logrus.Info("========== start ==========")
+	defer logrus.Info("=========== end ===========")
+
+	// 模擬讀不到自己的寫入(Read Your Writes) 情境
+	//
+	//                          Session                                                   Primary                          Replica (SOURCE_DELAY)
+	//                             |                                                         |                                      |
+	//                             |   START TRANSACTION                                     |                                      |   wallets
+	//                             | ------------------------------------------------------> |                                      |  +----+--------+-----+
+	//                             |   UPDATE wallets SET amount = amount - 60000 ...        |                                      |  | id | amount | ... |
+	//                             | ------------------------------------------------------> |                                      |  +----+--------+-----+
+	//                             |   COMMIT                                                |                                      |  | 1  | 100000 | ... |
+	//                             | ------------------------------------------------------> |   binlog / WAL, applied later        |  +----+--------+-----+
+	//                             |                                                         | - - - - - - - - - - - - - - - - - -> |
+	//                             |   SELECT amount FROM wallets WHERE id = 1               |                                      |
+	//   wallets                   | ---------------------------------------------------------------------------------------------> |
+	//  +----+--------+-----+      |                                                         |                                      |
+	//  | id | amount | ... |      |  使用者完成轉帳後立刻查詢餘額, 讀取被分配到尚未套用轉帳的 replica,                        |
+	//  +----+--------+-----+      |  看到轉帳前的餘額, 如同轉帳沒有發生                                                       |
+	//  | 1  | 100000 | ... |      |                                                         |                                      |
+	//  +----+--------+-----+      |                                                         |                                      |
+	//
+	// 解決辦法:
+	//   - 寫入後記錄 primary 的位置 (MySQL 為 GTID set, PostgreSQL 為 WAL LSN), 讀取前等待 replica 套用到該位置,
+	//     逾時則改讀 primary (SessionOptions.ReadYourWrites 或 routing.policy 為 read_your_writes)
+	//   - 寫入後一段時間內的讀取都送往 primary
+
+	session := r.Session(router.SessionOptions{ReadYourWrites: fix})
+
+	tx, err := session.BeginTx(ctx, nil)
+	checkError(err, "failed to start transaction:")
+	s.transfer(ctx, tx)
+	checkError(tx.Commit(), "failed to commit transaction:")
+	checkError(session.Wrote(ctx), "failed to record the position of the primary:")
+	logrus.Infoln("transfer committed on the primary, amount of wallet 1 is 40000.")
+
+	db, replica, err := session.Reader(ctx)
+	checkError(err, "failed to choose the replica:")
+	target := router.PrimaryName
+	if replica != nil {
+		target = replica.Name
+	}
+
+	var amount int
+	err = db.QueryRowContext(ctx, "SELECT amount FROM wallets WHERE id = 1;").Scan(&amount)
+	checkError(err, "failed to querying row:")
+
+	if amount != 40000 {
+		logrus.Warnf("read your writes violated, %v returns amount = %v after the transfer committed", target, amount)
+		return
+	}
+	logrus.Infof("%v returns amount = %v", target, amount)
+}
+
+func (s *scenarios) SimulateMonotonicReads(ctx context.Context, r *router.Router, fix bool) {
+	// init
+	if !s.replicated(ctx, r) {
+		return
+	}
+
+	logrus.Info("========== start ==========")
+	defer logrus.Info("=========== end ===========")
+
+	// 模擬單調讀(Monotonic Reads) 被破壞的情境
+	//
+	//     Replica 1 (no delay)                          Reader                           Replica 2 (SOURCE_DELAY)
+	//              |                                       |                                        |
+	//              |   SELECT amount FROM wallets ...      |                                        |
+	//   wallets    | <------------------------------------ |                                        |
+	//  +----+--------+-----+                               |                                        |
+	//  | id | amount | ... |                               |                                        |
+	//  +----+--------+-----+                               |                                        |
+	//  | 1  |  40000 | ... |                               |                                        |
+	//  +----+--------+-----+                               |   SELECT amount FROM wallets ...       |    wallets
+	//              |                                       | -------------------------------------> |  +----+--------+-----+
+	//              |                                       |                                        |  | id | amount | ... |
+	//              |                                       |                                        |  +----+--------+-----+
+	//              |                                       |                                        |  | 1  | 100000 | ... |
+	//              |                                       |                                        |  +----+--------+-----+
+	//
+	// 轉帳已經在 primary commit, 兩個 replicas 套用的進度不同, 輪流讀取不同的 replicas 時
+	// 第二次讀取看到比第一次更舊的資料, 如同時間倒流
+	//
+	// 解決辦法:
+	//   - 同一個使用者的讀取固定送往同一個 replica (SessionOptions.Pinned), 資料可能過時但不會倒退
+	//   - 記錄讀取到的位置, 之後的讀取等待 replica 套用到該位置
+
+	tx, err := r.BeginTx(ctx, nil)
+	checkError(err, "failed to start transaction:")
+	s.transfer(ctx, tx)
+	checkError(tx.Commit(), "failed to commit transaction:")
+	logrus.Infoln("transfer committed on the primary, amount of wallet 1 is 40000.")
+
+	read := func(target string, db *sql.DB) int {
+		var amount int
+		err := db.QueryRowContext(ctx, "SELECT amount FROM wallets WHERE id = 1;").Scan(&amount)
+		checkError(err, "failed to querying row:")
+		logrus.Infof("%v returns amount = %v", target, amount)
+		return amount
+	}
+
+	sources := sources(r)
+	session := r.Session(router.SessionOptions{Pinned: true})
+
+	// 餘額只會減少, 讀到比上一次更大的餘額表示讀到更舊的資料
+	last := 0
+	for i := 0; i < 2*len(sources); i++ {
+		var target string
+		var db *sql.DB
+		if fix {
+			var replica *router.Replica
+			db, replica, err = session.Reader(ctx)
+			checkError(err, "failed to choose the replica:")
+			target = router.PrimaryName
+			if replica != nil {
+				target = replica.Name
+			}
+		} else {
+			target, db = sources[i%len(sources)].name, sources[i%len(sources)].db
+		}
+
+		amount := read(target, db)
+		if i > 0 && amount > last {
+			logrus.Warnf("monotonic reads violated, %v returns amount = %v after a previous read returned %v", target, amount, last)
+		}
+		last = amount
+	}
+}
+
+func (s *scenarios) SimulateConsistentPrefix(ctx context.Context, r *router.Router, fix bool) {
+	// init
+	if !s.replicated(ctx, r) {
+		return
+	}
+
+	logrus.Info("========== start ==========")
+	defer logrus.Info("=========== end ===========")
+
+	// 模擬一致前綴讀(Consistent Prefix Reads) 被破壞的情境
+	//
+	//          Writer                          Primary                   Replica 1 (no delay)         Replica 2 (SOURCE_DELAY)            Reader
+	//            |                                |                              |                             |                            |
+	//            |   UPDATE wallets ... COMMIT    |                              |                             |                            |
+	//            | -----------------------------> |                              |                             |                            |
+	//            |   INSERT INTO logs ... COMMIT  |                              |                             |                            |
+	//            | -----------------------------> |  ---------------------->     |                             |                            |
+	//            |                                |                              |   SELECT ... FROM logs      |                            |
+	//            |                                |                              | <-------------------------------------------------------- |  讀到轉帳記錄
+	//            |                                |                              |                             |   SELECT ... FROM wallets  |
+	//            |                                |                              |                             | <------------------------- |  讀到轉帳前的餘額
+	//            |                                |                              |                             |                            |
+	//            |  logs 的寫入發生在 wallets 之後, 讀取者卻看到 logs 而沒有看到 wallets 的異動,
+	//            |  看到的資料不是 primary 上任何時間點的狀態 (轉帳記錄與餘額不一致)
+	//
+	// 解決辦法:
+	//   - 同一個讀取的所有查詢送往同一個 replica (SessionOptions.Pinned), 並在同一個唯讀交易中讀取,
+	//     replica 依照 commit 順序套用交易 (MySQL 8.0.27 起 replica_preserve_commit_order 預設開啟), 因此看到的是 primary 的某個前綴
+	//   - 將有因果關係的異動寫在同一個交易中
+
+	// 由出款扣款開始, 完成後才寫入轉帳記錄, 兩個交易依序 commit
+	_, err := r.ExecContext(ctx, "UPDATE wallets SET amount = amount - 60000 WHERE id = 1;")
+	checkError(err, "failed to execute:")
+	_, err = r.ExecContext(ctx, "UPDATE wallets SET amount = amount + 60000 WHERE id = 2;")
+	checkError(err, "failed to execute:")
+	_, err = r.ExecContext(ctx, s.dialect.Rebind("INSERT INTO logs (deposit_user_id, withdraw_user_id, amount, created_at) VALUES (?, ?, ?, ?);"),
+		2,
+		1,
+		60000,
+		time.Now().Format("2006-01-02 15:04:05"),
+	)
+	checkError(err, "failed to execute:")
+	logrus.Infoln("wallets updated and then the transfer logged on the primary.")
+
+	var withdrawn, amount int
+	logsFrom, walletsFrom := "", ""
+	if fix {
+		session := r.Session(router.SessionOptions{Pinned: true})
+		db, replica, err := session.Reader(ctx)
+		checkError(err, "failed to choose the replica:")
+		logsFrom = router.PrimaryName
+		if replica != nil {
+			logsFrom = replica.Name
+		}
+		walletsFrom = logsFrom
+
+		// 以 repeatable read 讀取同一個快照, 兩個查詢之間 replica 套用的交易不會被看到
+		level, err := s.dialect.Isolation(sql.LevelRepeatableRead)
+		if err != nil {
+			logrus.Warnf("%v, the scenario runs with %v", err, level)
+		}
+		tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: level, ReadOnly: true})
+		checkError(err, "failed to start transaction:")
+
+		err = tx.QueryRowContext(ctx, "SELECT COALESCE(SUM(amount), 0) FROM logs WHERE withdraw_user_id = 1;").Scan(&withdrawn)
+		checkError(err, "failed to querying row:")
+		err = tx.QueryRowContext(ctx, "SELECT amount FROM wallets WHERE id = 1;").Scan(&amount)
+		checkError(err, "failed to querying row:")
+		checkError(tx.Commit(), "failed to commit transaction:")
+	} else {
+		// 依照設定的順序, 延遲最小的 replica 應該設定在最前面, 刻意延遲的 replica 設定在最後面
+		sources := sources(r)
+		logs, wallets := sources[0], sources[len(sources)-1]
+		logsFrom, walletsFrom = logs.name, wallets.name
+
+		err = logs.db.QueryRowContext(ctx, "SELECT COALESCE(SUM(amount), 0) FROM logs WHERE withdraw_user_id = 1;").Scan(&withdrawn)
+		checkError(err, "failed to querying row:")
+		err = wallets.db.QueryRowContext(ctx, "SELECT amount FROM wallets WHERE id = 1;").Scan(&amount)
+		checkError(err, "failed to querying row:")
+	}
+
+	logrus.Infof("%v returns withdrawn = %v from logs, %v returns amount = %v from wallets", logsFrom, withdrawn, walletsFrom, amount)
+	// primary 依序經過 (100000, 0), (40000, 0), (40000, 60000) 三個狀態, 看到出款記錄卻沒有看到扣款不是其中任何一個
+	if amount+withdrawn > 100000 {
+		logrus.Warnf("consistent prefix violated, logs show %v withdrawn but wallet 1 still has %v", withdrawn, amount)
+	}
+}
@@ -0,0 +1,381 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"practice/internal/storage"
+	"practice/internal/storage/dialect"
+	"strings"
+)
+
+const (
+	userColumns   = "id, account, password, nickname, email, created_at, modified_at"
+	walletColumns = "id, user_id, amount, created_at, modified_at"
+	logColumns    = "id, deposit_user_id, withdraw_user_id, amount, created_at"
+)
+
+// NewMysqlRepositories New MySQL Typed Repositories
+func NewMysqlRepositories() *storage.Repositories {
+	return &storage.Repositories{
+		Users:   &mysqlUsers{},
+		Wallets: &mysqlWallets{},
+		Logs:    &mysqlLogs{},
+	}
+}
+
+// lockClause 回傳 SELECT 的上鎖子句, 兩種資料庫都支援 repository 使用的 share 與 update 鎖
+func lockClause(d dialect.Dialect, lock storage.Lock) string {
+	var strength dialect.Strength
+	switch lock {
+	case storage.LockShare:
+		strength = dialect.ForShare
+	case storage.LockUpdate:
+		strength = dialect.ForUpdate
+	default:
+		return ""
+	}
+
+	clause, err := d.Lock(strength, dialect.Block)
+	if err != nil {
+		panic(err)
+	}
+	return " " + clause
+}
+
+// mysqlInserted 依序設定 multi-row INSERT 新增的 ID, InnoDB 對筆數已知的 INSERT 配置連續的 auto increment
+func mysqlInserted(result sql.Result, n int, set func(i int, id int64)) error {
+	first, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		set(i, first+int64(i))
+	}
+	return nil
+}
+
+type mysqlUsers struct{}
+
+func (r *mysqlUsers) Create(ctx context.Context, q storage.Querier, user *storage.User) error {
+	return r.CreateBatch(ctx, q, []*storage.User{user})
+}
+
+func (r *mysqlUsers) CreateBatch(ctx context.Context, q storage.Querier, users []*storage.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	holders := make([]string, len(users))
+	args := make([]interface{}, 0, len(users)*6)
+	for i, user := range users {
+		holders[i] = "(?, ?, ?, ?, ?, ?)"
+		args = append(args, user.Account, user.Password, user.Nickname, user.Email, user.CreatedAt, user.ModifiedAt)
+	}
+
+	result, err := q.ExecContext(ctx, "INSERT INTO users (account, password, nickname, email, created_at, modified_at) VALUES "+
+		strings.Join(holders, ", "), args...)
+	if err != nil {
+		return fmt.Errorf("failed to insert users: %w", err)
+	}
+	return mysqlInserted(result, len(users), func(i int, id int64) { users[i].ID = id })
+}
+
+func (r *mysqlUsers) Get(ctx context.Context, q storage.Querier, id int64, lock storage.Lock) (*storage.User, error) {
+	return scanUser(q.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = ?"+lockClause(dialect.Mysql{}, lock), id))
+}
+
+func (r *mysqlUsers) GetByAccount(ctx context.Context, q storage.Querier, account string, lock storage.Lock) (*storage.User, error) {
+	return scanUser(q.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE account = ?"+lockClause(dialect.Mysql{}, lock), account))
+}
+
+func (r *mysqlUsers) List(ctx context.Context, q storage.Querier, afterID int64, limit int) ([]*storage.User, error) {
+	rows, err := q.QueryContext(ctx, "SELECT "+userColumns+" FROM users WHERE id > ? ORDER BY id LIMIT ?", afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return scanUsers(rows)
+}
+
+func (r *mysqlUsers) Update(ctx context.Context, q storage.Querier, user *storage.User) error {
+	result, err := q.ExecContext(ctx, "UPDATE users SET account = ?, password = ?, nickname = ?, email = ?, created_at = ?, modified_at = ? WHERE id = ?",
+		user.Account, user.Password, user.Nickname, user.Email, user.CreatedAt, user.ModifiedAt, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update user %v: %w", user.ID, err)
+	}
+	return mysqlAffected(ctx, q, result, "users", user.ID)
+}
+
+func (r *mysqlUsers) Delete(ctx context.Context, q storage.Querier, id int64) error {
+	return deleteByID(ctx, q, "DELETE FROM users WHERE id = ?", id)
+}
+
+type mysqlWallets struct{}
+
+func (r *mysqlWallets) Create(ctx context.Context, q storage.Querier, wallet *storage.Wallet) error {
+	return r.CreateBatch(ctx, q, []*storage.Wallet{wallet})
+}
+
+func (r *mysqlWallets) CreateBatch(ctx context.Context, q storage.Querier, wallets []*storage.Wallet) error {
+	if len(wallets) == 0 {
+		return nil
+	}
+
+	holders := make([]string, len(wallets))
+	args := make([]interface{}, 0, len(wallets)*4)
+	for i, wallet := range wallets {
+		holders[i] = "(?, ?, ?, ?)"
+		args = append(args, wallet.UserID, wallet.Amount, wallet.CreatedAt, wallet.ModifiedAt)
+	}
+
+	result, err := q.ExecContext(ctx, "INSERT INTO wallets (user_id, amount, created_at, modified_at) VALUES "+
+		strings.Join(holders, ", "), args...)
+	if err != nil {
+		return fmt.Errorf("failed to insert wallets: %w", err)
+	}
+	return mysqlInserted(result, len(wallets), func(i int, id int64) { wallets[i].ID = id })
+}
+
+func (r *mysqlWallets) Get(ctx context.Context, q storage.Querier, id int64, lock storage.Lock) (*storage.Wallet, error) {
+	return scanWallet(q.QueryRowContext(ctx, "SELECT "+walletColumns+" FROM wallets WHERE id = ?"+lockClause(dialect.Mysql{}, lock), id))
+}
+
+func (r *mysqlWallets) GetByUserID(ctx context.Context, q storage.Querier, userID int64, lock storage.Lock) (*storage.Wallet, error) {
+	return scanWallet(q.QueryRowContext(ctx, "SELECT "+walletColumns+" FROM wallets WHERE user_id = ?"+lockClause(dialect.Mysql{}, lock), userID))
+}
+
+func (r *mysqlWallets) List(ctx context.Context, q storage.Querier, afterID int64, limit int) ([]*storage.Wallet, error) {
+	rows, err := q.QueryContext(ctx, "SELECT "+walletColumns+" FROM wallets WHERE id > ? ORDER BY id LIMIT ?", afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list wallets: %w", err)
+	}
+	return scanWallets(rows)
+}
+
+func (r *mysqlWallets) SetAmount(ctx context.Context, q storage.Querier, id, amount int64) error {
+	result, err := q.ExecContext(ctx, "UPDATE wallets SET amount = ?, modified_at = NOW() WHERE id = ?", amount, id)
+	if err != nil {
+		return fmt.Errorf("failed to set amount of wallet %v: %w", id, err)
+	}
+	return mysqlAffected(ctx, q, result, "wallets", id)
+}
+
+func (r *mysqlWallets) AddAmount(ctx context.Context, q storage.Querier, id, delta int64) error {
+	// unsigned 欄位在運算結果為負數時會直接報錯, 因此以 amount >= -delta 判斷餘額是否足夠
+	result, err := q.ExecContext(ctx, "UPDATE wallets SET amount = amount + ?, modified_at = NOW() WHERE id = ? AND amount >= ?",
+		delta, id, required(delta))
+	if err != nil {
+		return fmt.Errorf("failed to add amount of wallet %v: %w", id, err)
+	}
+	return insufficient(ctx, q, result, "SELECT 1 FROM wallets WHERE id = ?", id)
+}
+
+func (r *mysqlWallets) CompareAndSetAmount(ctx context.Context, q storage.Querier, id, old, new int64) (bool, error) {
+	result, err := q.ExecContext(ctx, "UPDATE wallets SET amount = ?, modified_at = NOW() WHERE id = ? AND amount = ?", new, id, old)
+	if err != nil {
+		return false, fmt.Errorf("failed to compare and set amount of wallet %v: %w", id, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n > 0 || old != new {
+		return n > 0, nil
+	}
+
+	// 新舊餘額相同時 MySQL 不計入 affected rows, 需要再確認餘額是否仍為 old
+	var one int
+	err = q.QueryRowContext(ctx, "SELECT 1 FROM wallets WHERE id = ? AND amount = ?", id, old).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *mysqlWallets) Delete(ctx context.Context, q storage.Querier, id int64) error {
+	return deleteByID(ctx, q, "DELETE FROM wallets WHERE id = ?", id)
+}
+
+type mysqlLogs struct{}
+
+func (r *mysqlLogs) Create(ctx context.Context, q storage.Querier, log *storage.TransferLog) error {
+	return r.CreateBatch(ctx, q, []*storage.TransferLog{log})
+}
+
+func (r *mysqlLogs) CreateBatch(ctx context.Context, q storage.Querier, logs []*storage.TransferLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	holders := make([]string, len(logs))
+	args := make([]interface{}, 0, len(logs)*4)
+	for i, log := range logs {
+		holders[i] = "(?, ?, ?, ?)"
+		args = append(args, log.DepositUserID, log.WithdrawUserID, log.Amount, log.CreatedAt)
+	}
+
+	result, err := q.ExecContext(ctx, "INSERT INTO logs (deposit_user_id, withdraw_user_id, amount, created_at) VALUES "+
+		strings.Join(holders, ", "), args...)
+	if err != nil {
+		return fmt.Errorf("failed to insert logs: %w", err)
+	}
+	return mysqlInserted(result, len(logs), func(i int, id int64) { logs[i].ID = id })
+}
+
+func (r *mysqlLogs) Get(ctx context.Context, q storage.Querier, id int64) (*storage.TransferLog, error) {
+	return scanLog(q.QueryRowContext(ctx, "SELECT "+logColumns+" FROM logs WHERE id = ?", id))
+}
+
+func (r *mysqlLogs) ListByUser(ctx context.Context, q storage.Querier, userID, afterID int64, limit int) ([]*storage.TransferLog, error) {
+	rows, err := q.QueryContext(ctx, "SELECT "+logColumns+" FROM logs WHERE (deposit_user_id = ? OR withdraw_user_id = ?) AND id > ? ORDER BY id LIMIT ?",
+		userID, userID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list logs of user %v: %w", userID, err)
+	}
+	return scanLogs(rows)
+}
+
+func (r *mysqlLogs) Count(ctx context.Context, q storage.Querier) (int64, error) {
+	var count int64
+	if err := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM logs").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count logs: %w", err)
+	}
+	return count, nil
+}
+
+// mysqlAffected 更新後的內容與原本相同時 MySQL 回傳 0 affected rows, 因此需要再確認資料列是否存在
+func mysqlAffected(ctx context.Context, q storage.Querier, result sql.Result, table string, id int64) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	return exists(ctx, q, "SELECT 1 FROM "+table+" WHERE id = ?", id)
+}
+
+// required 回傳扣除 delta 所需的最低餘額
+func required(delta int64) int64 {
+	if delta < 0 {
+		return -delta
+	}
+	return 0
+}
+
+// insufficient 沒有更新任何資料列時, 區分錢包不存在與餘額不足
+func insufficient(ctx context.Context, q storage.Querier, result sql.Result, query string, id int64) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	if err := exists(ctx, q, query, id); err != nil {
+		return err
+	}
+	return fmt.Errorf("wallet %v: %w", id, storage.ErrInsufficientAmount)
+}
+
+func exists(ctx context.Context, q storage.Querier, query string, id int64) error {
+	var one int
+	err := q.QueryRowContext(ctx, query, id).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("id %v: %w", id, storage.ErrNotFound)
+	}
+	return err
+}
+
+func deleteByID(ctx context.Context, q storage.Querier, query string, id int64) error {
+	result, err := q.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete %v: %w", id, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("id %v: %w", id, storage.ErrNotFound)
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrNotFound
+	}
+	return err
+}
+
+func scanUser(row scanner) (*storage.User, error) {
+	u := &storage.User{}
+	if err := row.Scan(&u.ID, &u.Account, &u.Password, &u.Nickname, &u.Email, &u.CreatedAt, &u.ModifiedAt); err != nil {
+		return nil, notFound(err)
+	}
+	return u, nil
+}
+
+func scanUsers(rows *sql.Rows) ([]*storage.User, error) {
+	defer rows.Close()
+
+	users := []*storage.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func scanWallet(row scanner) (*storage.Wallet, error) {
+	w := &storage.Wallet{}
+	if err := row.Scan(&w.ID, &w.UserID, &w.Amount, &w.CreatedAt, &w.ModifiedAt); err != nil {
+		return nil, notFound(err)
+	}
+	return w, nil
+}
+
+func scanWallets(rows *sql.Rows) ([]*storage.Wallet, error) {
+	defer rows.Close()
+
+	wallets := []*storage.Wallet{}
+	for rows.Next() {
+		wallet, err := scanWallet(rows)
+		if err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, wallet)
+	}
+	return wallets, rows.Err()
+}
+
+func scanLog(row scanner) (*storage.TransferLog, error) {
+	l := &storage.TransferLog{}
+	if err := row.Scan(&l.ID, &l.DepositUserID, &l.WithdrawUserID, &l.Amount, &l.CreatedAt); err != nil {
+		return nil, notFound(err)
+	}
+	return l, nil
+}
+
+func scanLogs(rows *sql.Rows) ([]*storage.TransferLog, error) {
+	defer rows.Close()
+
+	logs := []*storage.TransferLog{}
+	for rows.Next() {
+		log, err := scanLog(rows)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, log)
+	}
+	return logs, rows.Err()
+}
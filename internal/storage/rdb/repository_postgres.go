@@ -0,0 +1,223 @@
+package rdb
+
+import (
+	"context"
+	"fmt"
+	"practice/internal/storage"
+	"practice/internal/storage/dialect"
+	"strings"
+)
+
+// NewPostgresRepositories New PostgreSQL Typed Repositories
+func NewPostgresRepositories() *storage.Repositories {
+	return &storage.Repositories{
+		Users:   &postgresUsers{},
+		Wallets: &postgresWallets{},
+		Logs:    &postgresLogs{},
+	}
+}
+
+// postgresValues 產生 multi-row INSERT 的 VALUES, 每列 width 個 $n placeholder
+func postgresValues(rows, width int) string {
+	values := make([]string, rows)
+	for i := range values {
+		holders := make([]string, width)
+		for j := range holders {
+			holders[j] = fmt.Sprintf("$%d", i*width+j+1)
+		}
+		values[i] = "(" + strings.Join(holders, ", ") + ")"
+	}
+	return strings.Join(values, ", ")
+}
+
+// postgresInserted 依序讀取 RETURNING id, PostgreSQL 依照 VALUES 的順序回傳
+func postgresInserted(ctx context.Context, q storage.Querier, query string, args []interface{}, set func(i int, id int64)) error {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for i := 0; rows.Next(); i++ {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		set(i, id)
+	}
+	return rows.Err()
+}
+
+type postgresUsers struct{}
+
+func (r *postgresUsers) Create(ctx context.Context, q storage.Querier, user *storage.User) error {
+	return r.CreateBatch(ctx, q, []*storage.User{user})
+}
+
+func (r *postgresUsers) CreateBatch(ctx context.Context, q storage.Querier, users []*storage.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(users)*6)
+	for _, user := range users {
+		args = append(args, user.Account, user.Password, user.Nickname, user.Email, user.CreatedAt, user.ModifiedAt)
+	}
+
+	query := "INSERT INTO users (account, password, nickname, email, created_at, modified_at) VALUES " +
+		postgresValues(len(users), 6) + " RETURNING id"
+	if err := postgresInserted(ctx, q, query, args, func(i int, id int64) { users[i].ID = id }); err != nil {
+		return fmt.Errorf("failed to insert users: %w", err)
+	}
+	return nil
+}
+
+func (r *postgresUsers) Get(ctx context.Context, q storage.Querier, id int64, lock storage.Lock) (*storage.User, error) {
+	return scanUser(q.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1"+lockClause(dialect.Postgres{}, lock), id))
+}
+
+func (r *postgresUsers) GetByAccount(ctx context.Context, q storage.Querier, account string, lock storage.Lock) (*storage.User, error) {
+	return scanUser(q.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE account = $1"+lockClause(dialect.Postgres{}, lock), account))
+}
+
+func (r *postgresUsers) List(ctx context.Context, q storage.Querier, afterID int64, limit int) ([]*storage.User, error) {
+	rows, err := q.QueryContext(ctx, "SELECT "+userColumns+" FROM users WHERE id > $1 ORDER BY id LIMIT $2", afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return scanUsers(rows)
+}
+
+func (r *postgresUsers) Update(ctx context.Context, q storage.Querier, user *storage.User) error {
+	return updateByID(ctx, q, user.ID, "UPDATE users SET account = $1, password = $2, nickname = $3, email = $4, created_at = $5, modified_at = $6 WHERE id = $7",
+		user.Account, user.Password, user.Nickname, user.Email, user.CreatedAt, user.ModifiedAt, user.ID)
+}
+
+func (r *postgresUsers) Delete(ctx context.Context, q storage.Querier, id int64) error {
+	return deleteByID(ctx, q, "DELETE FROM users WHERE id = $1", id)
+}
+
+type postgresWallets struct{}
+
+func (r *postgresWallets) Create(ctx context.Context, q storage.Querier, wallet *storage.Wallet) error {
+	return r.CreateBatch(ctx, q, []*storage.Wallet{wallet})
+}
+
+func (r *postgresWallets) CreateBatch(ctx context.Context, q storage.Querier, wallets []*storage.Wallet) error {
+	if len(wallets) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(wallets)*4)
+	for _, wallet := range wallets {
+		args = append(args, wallet.UserID, wallet.Amount, wallet.CreatedAt, wallet.ModifiedAt)
+	}
+
+	query := "INSERT INTO wallets (user_id, amount, created_at, modified_at) VALUES " + postgresValues(len(wallets), 4) + " RETURNING id"
+	if err := postgresInserted(ctx, q, query, args, func(i int, id int64) { wallets[i].ID = id }); err != nil {
+		return fmt.Errorf("failed to insert wallets: %w", err)
+	}
+	return nil
+}
+
+func (r *postgresWallets) Get(ctx context.Context, q storage.Querier, id int64, lock storage.Lock) (*storage.Wallet, error) {
+	return scanWallet(q.QueryRowContext(ctx, "SELECT "+walletColumns+" FROM wallets WHERE id = $1"+lockClause(dialect.Postgres{}, lock), id))
+}
+
+func (r *postgresWallets) GetByUserID(ctx context.Context, q storage.Querier, userID int64, lock storage.Lock) (*storage.Wallet, error) {
+	return scanWallet(q.QueryRowContext(ctx, "SELECT "+walletColumns+" FROM wallets WHERE user_id = $1"+lockClause(dialect.Postgres{}, lock), userID))
+}
+
+func (r *postgresWallets) List(ctx context.Context, q storage.Querier, afterID int64, limit int) ([]*storage.Wallet, error) {
+	rows, err := q.QueryContext(ctx, "SELECT "+walletColumns+" FROM wallets WHERE id > $1 ORDER BY id LIMIT $2", afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list wallets: %w", err)
+	}
+	return scanWallets(rows)
+}
+
+func (r *postgresWallets) SetAmount(ctx context.Context, q storage.Querier, id, amount int64) error {
+	return updateByID(ctx, q, id, "UPDATE wallets SET amount = $1, modified_at = NOW() WHERE id = $2", amount, id)
+}
+
+func (r *postgresWallets) AddAmount(ctx context.Context, q storage.Querier, id, delta int64) error {
+	result, err := q.ExecContext(ctx, "UPDATE wallets SET amount = amount + $1, modified_at = NOW() WHERE id = $2 AND amount >= $3",
+		delta, id, required(delta))
+	if err != nil {
+		return fmt.Errorf("failed to add amount of wallet %v: %w", id, err)
+	}
+	return insufficient(ctx, q, result, "SELECT 1 FROM wallets WHERE id = $1", id)
+}
+
+func (r *postgresWallets) CompareAndSetAmount(ctx context.Context, q storage.Querier, id, old, new int64) (bool, error) {
+	result, err := q.ExecContext(ctx, "UPDATE wallets SET amount = $1, modified_at = NOW() WHERE id = $2 AND amount = $3", new, id, old)
+	if err != nil {
+		return false, fmt.Errorf("failed to compare and set amount of wallet %v: %w", id, err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (r *postgresWallets) Delete(ctx context.Context, q storage.Querier, id int64) error {
+	return deleteByID(ctx, q, "DELETE FROM wallets WHERE id = $1", id)
+}
+
+type postgresLogs struct{}
+
+func (r *postgresLogs) Create(ctx context.Context, q storage.Querier, log *storage.TransferLog) error {
+	return r.CreateBatch(ctx, q, []*storage.TransferLog{log})
+}
+
+func (r *postgresLogs) CreateBatch(ctx context.Context, q storage.Querier, logs []*storage.TransferLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(logs)*4)
+	for _, log := range logs {
+		args = append(args, log.DepositUserID, log.WithdrawUserID, log.Amount, log.CreatedAt)
+	}
+
+	query := "INSERT INTO logs (deposit_user_id, withdraw_user_id, amount, created_at) VALUES " + postgresValues(len(logs), 4) + " RETURNING id"
+	if err := postgresInserted(ctx, q, query, args, func(i int, id int64) { logs[i].ID = id }); err != nil {
+		return fmt.Errorf("failed to insert logs: %w", err)
+	}
+	return nil
+}
+
+func (r *postgresLogs) Get(ctx context.Context, q storage.Querier, id int64) (*storage.TransferLog, error) {
+	return scanLog(q.QueryRowContext(ctx, "SELECT "+logColumns+" FROM logs WHERE id = $1", id))
+}
+
+func (r *postgresLogs) ListByUser(ctx context.Context, q storage.Querier, userID, afterID int64, limit int) ([]*storage.TransferLog, error) {
+	rows, err := q.QueryContext(ctx, "SELECT "+logColumns+" FROM logs WHERE (deposit_user_id = $1 OR withdraw_user_id = $1) AND id > $2 ORDER BY id LIMIT $3",
+		userID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list logs of user %v: %w", userID, err)
+	}
+	return scanLogs(rows)
+}
+
+func (r *postgresLogs) Count(ctx context.Context, q storage.Querier) (int64, error) {
+	var count int64
+	if err := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM logs").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count logs: %w", err)
+	}
+	return count, nil
+}
+
+// updateByID PostgreSQL 的 affected rows 為符合條件的資料列數量, 為 0 時表示資料列不存在
+func updateByID(ctx context.Context, q storage.Querier, id int64, query string, args ...interface{}) error {
+	result, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update %v: %w", id, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("id %v: %w", id, storage.ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,694 @@
+package rdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"practice/internal/ddl"
+	"practice/internal/storage"
+	"practice/internal/storage/dialect"
+	"practice/internal/storage/generator"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// scenarios 為各資料庫共用的情境實作, 每個情境只寫一次, 語法與語意的差異交由 dialect 處理
+type scenarios struct {
+	conn         *sql.DB
+	dialect      dialect.Dialect
+	repositories *storage.Repositories
+}
+
+// truncate 清空資料表並重設自動遞增主鍵, 讓情境中新增的資料列 id 由 1 開始
+func (s *scenarios) truncate(tables ...string) {
+	for _, statement := range s.dialect.Truncate(tables...) {
+		_, err := s.conn.Exec(statement)
+		checkError(err, "failed to execute:")
+	}
+}
+
+// lock 回傳上鎖子句, 情境需要的鎖不被支援時無法繼續
+func (s *scenarios) lock(strength dialect.Strength, wait dialect.WaitPolicy) string {
+	clause, err := s.dialect.Lock(strength, wait)
+	checkError(err, "failed to build locking clause:")
+	return clause
+}
+
+// begin 以指定的隔離等級開始交易, 資料庫不支援時記錄警告並以資料庫實際執行的等級開始
+func (s *scenarios) begin(ctx context.Context, level sql.IsolationLevel) (*sql.Tx, error) {
+	actual, err := s.dialect.Isolation(level)
+	if err != nil {
+		logrus.Warnf("%v, the scenario runs with %v", err, actual)
+	}
+	return s.conn.BeginTx(ctx, &sql.TxOptions{Isolation: actual})
+}
+
+// aborted 判斷交易是否因為序列化失敗或死結被資料庫中止, 是的話 rollback 並回傳 true, 其他錯誤則無法繼續
+func (s *scenarios) aborted(tx *sql.Tx, name string, err error) bool {
+	if err == nil {
+		return false
+	}
+	if !s.dialect.Retryable(err) {
+		checkError(err, "failed to execute:")
+	}
+
+	logrus.Warnf("%v aborted by %v: %v", name, s.dialect.Driver(), err)
+	checkError(tx.Rollback(), "failed to rollback:")
+	return true
+}
+
+// ShowTables 由 information_schema 或 pg_catalog 讀取結構與統計資訊, 不需要查詢資料表本身
+func (s *scenarios) ShowTables(ctx context.Context) (*ddl.Live, error) {
+	var namespace string
+	if err := s.conn.QueryRowContext(ctx, "SELECT "+s.dialect.CurrentSchema()).Scan(&namespace); err != nil {
+		return nil, fmt.Errorf("failed to query the current schema: %w", err)
+	}
+	return ddl.Inspect(ctx, s.conn, s.dialect.Driver(), namespace)
+}
+
+func (s *scenarios) GenerateData(ctx context.Context, opts generator.Options) error {
+	logrus.Info("========== start ==========")
+	defer logrus.Info("=========== end ===========")
+
+	g, err := generator.New(opts)
+	if err != nil {
+		return err
+	}
+
+	// 清空舊資料, 並重設自動遞增主鍵讓產生的 ID 由 1 開始
+	s.truncate("users", "wallets", "logs")
+
+	// 轉帳記錄會調整錢包餘額, 因此 logs 必須在 wallets 之前產生
+	users := 0
+	if err := g.Users(func(batch []*storage.User) error {
+		users += len(batch)
+		logrus.Debugf("inserting users %v/%v", users, opts.Users)
+		return s.repositories.Users.CreateBatch(ctx, s.conn, batch)
+	}); err != nil {
+		return err
+	}
+
+	logs := 0
+	if err := g.Logs(func(batch []*storage.TransferLog) error {
+		logs += len(batch)
+		logrus.Debugf("inserting logs %v, including the initial deposit of every wallet", logs)
+		return s.repositories.Logs.CreateBatch(ctx, s.conn, batch)
+	}); err != nil {
+		return err
+	}
+
+	wallets := 0
+	if err := g.Wallets(func(batch []*storage.Wallet) error {
+		wallets += len(batch)
+		logrus.Debugf("inserting wallets %v/%v", wallets, opts.Wallets)
+		return s.repositories.Wallets.CreateBatch(ctx, s.conn, batch)
+	}); err != nil {
+		return err
+	}
+
+	logrus.Infof("generated %v users, %v wallets and %v logs with seed %v", users, wallets, logs, opts.Seed)
+	return nil
+}
+
+func (s *scenarios) SimulateDirtyRead(ctx context.Context) {
+	// init
+	s.truncate("logs")
+
+	logrus.Info("========== start ==========")
+	defer logrus.Info("=========== end ===========")
+
+	// 模擬髒讀(Dirty Read) 情境
+	//
+	//                            Transaction 1                                Database                              Transaction 2
+	//                                  |                                         |                                       |
+	//                                  |                                         |   logs                                |
+	//                                  |                                         |  +----+-----------------+-----+       |
+	//                                  |                                         |  | id | deposit_user_id | ... |       |
+	//                                  |                                         |  +----+-----------------+-----+       |
+	//   logs                           |   START TRANSACTION                     |                                       |
+	//  +----+-----------------+-----+  | --------------------------------------> |                                       |
+	//  | id | deposit_user_id | ... |  |   INSERT INTO logs (...) VALUES (...)   |                                       |
+	//  +----+-----------------+-----+  | --------------------------------------> |                                       |
+	//  | 1  | 1               | ... |  |                                         |                   START TRANSACTION   |
+	//  +----+-----------------+-----+  |                                         | <------------------------------------ |
+	//                                  |                                         |           SELECT count(*) FROM logs   |  isolation level 為 read uncommitted 時會讀到
+	//                                  |                                         | <------------------------------------ |  transaction 1 尚未 committed 的資料導致 dirty read
+	//                                  |   ROLLBACK                              |                                       |  必須是 read committed 以上的等級才可避免
+	//                                  | --------------------------------------> |                                       |
+	//                                  |                                         |                              COMMIT   |
+	//                                  |                                         | <------------------------------------ |
+	//                                  |                                         |                                       |
+
+	// 執行 trx1: 寫入一筆 log
+	tx1, err := s.conn.Begin()
+	checkError(err, "failed to start transaction:")
+
+	_, err = tx1.Exec(InsertLog)
+	checkError(err, "failed to execute:")
+
+	// 在 trx1 結束前, 執行 trx2 取得相同 table 裡面的資料數量
+	// 強制本次的 transaction isolation level 使用 read-uncommitted 等級
+	// PostgreSQL 以 read committed 執行 read uncommitted, 因此不會發生 dirty read
+	tx2, err := s.begin(ctx, sql.LevelReadUncommitted)
+	checkError(err, "failed to start transaction:")
+
+	var count int
+	err = tx2.QueryRow(CountLogs).Scan(&count)
+	checkError(err, "failed to query:")
+
+	logrus.Warnf("Read Uncommitted: %v", count)
+
+	// 結束 trx2
+	err = tx2.Commit()
+	checkError(err, "failed to commit transaction:")
+
+	// 結束 trx1
+	err = tx1.Rollback()
+	checkError(err, "failed to rollback transaction:")
+}
+
+func (s *scenarios) SimulateReadSkew(ctx context.Context) {
+	// init
+	s.truncate("wallets")
+
+	timeNow := time.Now().Format("2006-01-02 15:04:05")
+	_, err := s.conn.Exec(s.dialect.Rebind("INSERT INTO wallets (user_id, amount, created_at, modified_at) VALUES (?, ?, ?, ?);"),
+		"1",
+		100000,
+		timeNow,
+		timeNow,
+	)
+	checkError(err, "failed to execute:")
+
+	logrus.Info("========== start ==========")
+	defer logrus.Info("=========== end ===========")
+
+	// 模擬讀偏差(Read Skew) 情境，又稱不可重複讀(Non-repeatable Read)
+	//
+	//                    Transaction 1                                                   Database                                    Transaction 2
+	//                         |                                                             |                                             |
+	//                         |                                                             |   wallets                                   |
+	//                         |                                                             |  +----+--------+-----+                      |
+	//                         |                                                             |  | id | amount | ... |                      |
+	//                         |                                                             |  +----+--------+-----+                      |
+	//                         |                                                             |  | 1  | 100000 | ... |                      |
+	//                         |                                                             |  +----+--------+-----+                      |
+	//                         |   START TRANSACTION                                         |                                             |
+	//                         | ----------------------------------------------------------> |                                             |
+	//                         |                                                             |   START TRANSACTION                         |
+	//   wallets               |                                                             | <------------------------------------------ |
+	//  +----+--------+-----+  |   UPDATE wallets SET amount = amount - 60000 WHERE id = 1   |                                             |
+	//  | id | amount | ... |  | ----------------------------------------------------------> |                                             |   wallets
+	//  +----+--------+-----+  |                                                             |   SELECT amount FROM wallets WHERE id = 1   |  +----+--------+-----+
+	//  | 1  |  40000 | ... |  |                                                             | <------------------------------------------ |  | id | amount | ... |
+	//  +----+--------+-----+  |   COMMIT                                                    |                                             |  +----+--------+-----+
+	//                         | ----------------------------------------------------------> |                                             |  | 1  | 100000 | ... |
+	//                         |                                                             |                                             |  +----+--------+-----+
+	//                         |                                                             |                                             |
+	//                         |                                                             |                                             |   wallets
+	//                         |                                                             |   SELECT amount FROM wallets WHERE id = 1   |  +----+--------+-----+
+	//                         |                                                             | <------------------------------------------ |  | id | amount | ... |
+	//                         |                                                             |   COMMIT                                    |  +----+--------+-----+
+	//                         |                                                             | <------------------------------------------ |  | 1  |  40000 | ... |
+	//                         |                                                             |                                             |  +----+--------+-----+
+	//                         |                                                             |                                             |
+	//                         |                                                             |                                             |  發生同一個 transaction 內讀取到兩次不同的
+	//                         |                                                             |                                             |  結果 (read skew)
+	//                         |                                                             |                                             |  必須是 repeatable read 以上的等級才可避免
+	//                         |                                                             |                                             |
+
+	tx1, err := s.conn.Begin()
+	checkError(err, "failed to start transaction:")
+
+	tx2, err := s.conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	checkError(err, "failed to start transaction:")
+
+	_, err = tx1.Exec(Withdraw)
+	checkError(err, "failed to execute:")
+
+	var amount int
+	err = tx2.QueryRow(SelectAmount).Scan(&amount)
+	checkError(err, "failed to querying row:")
+
+	logrus.Infof("amount = %v", amount)
+
+	err = tx1.Commit()
+	checkError(err, "failed to commit transaction:")
+
+	err = tx2.QueryRow(SelectAmount).Scan(&amount)
+	checkError(err, "failed to querying row:")
+
+	logrus.Warnf("amount = %v", amount)
+
+	err = tx2.Commit()
+	checkError(err, "failed to commit transaction:")
+}
+
+func (s *scenarios) SimulateLostUpdate(ctx context.Context) {
+	// init
+	s.truncate("wallets")
+
+	timeNow := time.Now().Format("2006-01-02 15:04:05")
+	_, err := s.conn.Exec(s.dialect.Rebind("INSERT INTO wallets (user_id, amount, created_at, modified_at) VALUES (?, ?, ?, ?);"),
+		"1",
+		100000,
+		timeNow,
+		timeNow,
+	)
+	checkError(err, "failed to execute:")
+
+	logrus.Info("========== start ==========")
+	defer logrus.Info("=========== end ===========")
+
+	// 模擬更新丟失(Lost Update) 情境
+	//
+	//                    Transaction 1                                          Database                                           Transaction 2
+	//                         |                                                    |                                                    |
+	//                         |                                                    |   wallets                                          |
+	//                         |                                                    |  +----+--------+-----+                             |
+	//                         |                                                    |  | id | amount | ... |                             |
+	//                         |                                                    |  +----+--------+-----+                             |
+	//                         |                                                    |  | 1  | 100000 | ... |                             |
+	//                         |                                                    |  +----+--------+-----+                             |
+	//                         |                                                    |                                                    |
+	//                         |                                                    |                                START TRANSACTION   |
+	//                         |                                                    | <------------------------------------------------- |
+	//                         |   START TRANSACTION                                |                                                    |
+	//                         | -------------------------------------------------> |                                                    |   wallets
+	//                         |                                                    |          SELECT amount FROM wallets WHERE id = 1   |  +----+--------+-----+
+	//   wallets               |                                                    | <------------------------------------------------- |  | id | amount | ... |
+	//  +----+--------+-----+  |   SELECT amount FROM wallets WHERE id = 1          |                                                    |  +----+--------+-----+
+	//  | id | amount | ... |  | -------------------------------------------------> |                                                    |  | 1  | 100000 | ... |
+	//  +----+--------+-----+  |                                                    |                                                    |  +----+--------+-----+
+	//  | 1  | 100000 | ... |  |                                                    |                                                    |
+	//  +----+--------+-----+  |                                                    |                                                    |
+	//                         |                                                    |                                                    |   wallets
+	//                         |                                                    |   UPDATE wallets SET amount = 60000 WHERE id = 1   |  +----+--------+-----+
+	//                         |                                                    | <------------------------------------------------- |  | id | amount | ... |
+	//                         |                                                    |                                           COMMIT   |  +----+--------+-----+
+	//   wallets               |                                                    | <------------------------------------------------- |  | 1  |  60000 | ... |
+	//  +----+--------+-----+  |   UPDATE wallets SET amount = 40000 WHERE id = 1   |                                                    |  +----+--------+-----+
+	//  | id | amount | ... |  | -------------------------------------------------> |                                                    |
+	//  +----+--------+-----+  |   COMMIT                                           |                                                    |  transaction2 的更新結果最後被 transaction1 覆蓋掉
+	//  | 1  |  40000 | ... |  | -------------------------------------------------> |                                                    |  造成 lost update
+	//  +----+--------+-----+  |                                                    |                                                    |
+	//                         |                                                    |                                                    |
+	//
+	// 兩種解決 Lost Update 的辦法:
+	//
+	// 1. 交給 Database 的 atomic write
+	//     - 改寫 UPDATE wallets SET amount = {value} WHERE id = 1 成 UPDATE wallets SET amount = amount - {value} WHERE id = 1
+	//     - 要特別注意 transaction failed 時的重試流程，若未能保證冪等性可能造成重複扣款問題
+	//
+	// 2. 自行實現樂觀鎖流程 (CAS)
+	//     - 改寫 UPDATE wallets SET amount = {value} WHERE id = 1 成 UPDATE wallets SET amount = {new} WHERE id = 1 AND amount = {old}
+	//     - 強制 transaction 1 更新失敗, 但要自行驗證 transaction 執行結果是否符合預期
+
+	tx2, err := s.conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	checkError(err, "failed to start transaction:")
+
+	tx1, err := s.conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	checkError(err, "failed to start transaction:")
+
+	var amount_tx1, amount_tx2, amount_result int
+
+	err = tx2.QueryRow(SelectAmount).Scan(&amount_tx2)
+	checkError(err, "failed to querying row:")
+
+	err = tx1.QueryRow(SelectAmount).Scan(&amount_tx1)
+	checkError(err, "failed to querying row:")
+
+	// 表示業務邏輯處理結果
+	amount_tx2 = 60000
+	_, err = tx2.Exec(SetAmount(amount_tx2))
+	checkError(err, "failed to execute:")
+
+	err = tx2.Commit()
+	checkError(err, "failed to commit:")
+
+	// 表示業務邏輯處理結果
+	amount_tx1 = 40000
+	// PostgreSQL 的 repeatable read 不允許更新已被其他交易修改的資料列, transaction 1 會因序列化失敗而中止, 不會發生 lost update
+	_, err = tx1.Exec(SetAmount(amount_tx1))
+	if !s.aborted(tx1, "transaction 1", err) {
+		err = tx1.Commit()
+		checkError(err, "failed to commit:")
+	}
+
+	err = s.conn.QueryRow(SelectAmount).Scan(&amount_result)
+	checkError(err, "failed to querying row:")
+
+	logrus.Warnf("Amount = %v", amount_result)
+}
+
+func (s *scenarios) SimulateWriteSkew1(ctx context.Context) {
+	// init
+	s.truncate("wallets")
+
+	timeNow := time.Now().Format("2006-01-02 15:04:05")
+	_, err := s.conn.Exec(s.dialect.Rebind("INSERT INTO wallets (user_id, amount, created_at, modified_at) VALUES (?, ?, ?, ?);"),
+		"1",
+		100000,
+		timeNow,
+		timeNow,
+	)
+	checkError(err, "failed to execute:")
+
+	logrus.Info("========== start ==========")
+	defer logrus.Info("=========== end ===========")
+
+	// 模擬因為幻讀(Phantom Read) 造成寫偏差(Write Skew) 情境
+	//
+	//                    Transaction 1                                  Database                                       Transaction 2
+	//                         |                                            |                                                |
+	//                         |                                            |   wallets                                      |
+	//                         |                                            |  +----+--------+-----+                         |
+	//                         |                                            |  | id | amount | ... |                         |
+	//                         |                                            |  +----+--------+-----+                         |
+	//                         |                                            |  | 1  | 100000 | ... |                         |
+	//                         |                                            |  +----+--------+-----+                         |
+	//                         |                                            |                                                |
+	//                         |                                            |                            START TRANSACTION   |
+	//                         |                                            | <--------------------------------------------- |   wallets
+	//                         |                                            |                   SELECT amount FROM wallets   |  +----+--------+-----+
+	//                         |                                            | <--------------------------------------------- |  | id | amount | ... |
+	//                         |   START TRANSACTION                        |                                                |  +----+--------+-----+
+	//   wallets               | -----------------------------------------> |                                                |  | 1  | 100000 | ... |
+	//  +----+--------+-----+  |   INSERT INTO wallets (...) VALUES (...)   |                                                |  +----+--------+-----+
+	//  | id | amount | ... |  | -----------------------------------------> |                                                |
+	//  +----+--------+-----+  |   COMMIT                                   |                                                |
+	//  | 1  | 100000 | ... |  | -----------------------------------------> |                                                |   wallets
+	//  +----+--------+-----+  |                                            |                   SELECT amount FROM wallets   |  +----+--------+-----+
+	//  | 2  | 100000 | ... |  |                                            | <--------------------------------------------- |  | id | amount | ... |
+	//  +----+--------+-----+  |                                            |                                                |  +----+--------+-----+
+	//                         |                                            |                                                |  | 1  | 100000 | ... |
+	//                         |                                            |                                                |  +----+--------+-----+
+	//                         |                                            |                                                |
+	//                         |                                            |                                                |
+	//                         |                                            |                                                |   wallets
+	//                         |                                            |   UPDATE wallets SET amount = amount + 10000   |  +----+--------+-----+
+	//                         |                                            | <--------------------------------------------- |  | id | amount | ... |
+	//                         |                                            |                                       COMMIT   |  +----+--------+-----+
+	//                         |                                            | <--------------------------------------------- |  | 1  | 110000 | ... |
+	//                         |                                            |                                                |  +----+--------+-----+
+	//                         |                                            |                                                |  | 2  | 110000 | ... |
+	//                         |                                            |                                                |  +----+--------+-----+
+	//                         |                                            |                                                |
+	//                         |                                            |                                                | 發生 write skew 導致更新到未讀取過的資料
+	//                         |                                            |                                                | 不一定所有的 repeatable read 等級都能阻止!
+	//
+	// 上述情境可以直接透過調整 isolation level 至 serializable level 解決 Write Skew 問題
+
+	wg := new(sync.WaitGroup)
+	wg.Add(2)
+
+	go func(_wg *sync.WaitGroup) {
+		defer _wg.Done()
+
+		tx2, err := s.conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+		checkError(err, "failed to start transaction:")
+		logrus.Infoln("transaction 2 started.")
+
+		var count int
+		err = tx2.QueryRow(CountWallets).Scan(&count)
+		checkError(err, "failed to querying row:")
+		logrus.Infof("transaction 2 selected, count = %v", count)
+
+		time.Sleep(1 * time.Second)
+
+		err = tx2.QueryRow(CountWallets).Scan(&count)
+		checkError(err, "failed to querying row:")
+		logrus.Infof("transaction 2 selected, count = %v", count)
+
+		_, err = tx2.Exec(RaiseWallets)
+		checkError(err, "failed to execute:")
+		logrus.Infoln("transaction 2 updated")
+
+		// err = tx2.QueryRow(CountWallets).Scan(&count)
+		// checkError(err, "failed to querying row:")
+		// logrus.Warnf("transaction 2 selected, count = %v", count)
+
+		err = tx2.Commit()
+		checkError(err, "failed to commit:")
+		logrus.Infoln("transaction 2 committed.")
+
+	}(wg)
+
+	time.Sleep(1 * time.Millisecond)
+
+	go func(_wg *sync.WaitGroup) {
+		defer _wg.Done()
+
+		tx1, err := s.conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+		checkError(err, "failed to start transaction:")
+		logrus.Infoln("transaction 1 started.")
+
+		_, err = tx1.Exec(InsertWallet(2, 100000, time.Now()))
+		checkError(err, "failed to execute:")
+		logrus.Infoln("transaction 1 inserted.")
+
+		err = tx1.Commit()
+		checkError(err, "failed to commit:")
+		logrus.Infoln("transaction 1 committed.")
+
+	}(wg)
+
+	wg.Wait()
+
+	var count int
+	err = s.conn.QueryRow(CountRaisedWallets).Scan(&count)
+	checkError(err, "failed to querying row:")
+	logrus.Warnf("%v is %v", CountRaisedWallets, count)
+}
+
+func (s *scenarios) SimulateWriteSkew2(ctx context.Context) {
+	// init
+	s.truncate("wallets")
+
+	timeNow := time.Now().Format("2006-01-02 15:04:05")
+	_, err := s.conn.Exec(s.dialect.Rebind("INSERT INTO wallets (user_id, amount, created_at, modified_at) VALUES (?, ?, ?, ?);"),
+		"1",
+		100000,
+		timeNow,
+		timeNow,
+	)
+	checkError(err, "failed to execute:")
+
+	logrus.Info("========== start ==========")
+	defer logrus.Info("=========== end ===========")
+
+	// 模擬因為幻讀(Phantom Read) 造成寫偏差(Write Skew) 情境
+	//
+	//                    Transaction 1                                                   Database                                                    Transaction 2
+	//                         |                                                             |                                                             |
+	//                         |                                                             |   wallets                                                   |
+	//                         |                                                             |  +----+--------+-----+                                      |
+	//                         |                                                             |  | id | amount | ... |                                      |
+	//                         |                                                             |  +----+--------+-----+                                      |
+	//                         |                                                             |  | 1  | 100000 | ... |                                      |
+	//                         |                                                             |  +----+--------+-----+                                      |
+	//                         |                                                             |                                                             |
+	//                         |   START TRANSACTION                                         |                                                             |
+	//                         | ----------------------------------------------------------> |                                                             |
+	//                         |                                                             |                                         START TRANSACTION   |
+	//   wallets               |                                                             | <---------------------------------------------------------- |
+	//  +----+--------+-----+  |   SELECT amount FROM wallets WHERE id = 1                   |                                                             |
+	//  | id | amount | ... |  | ----------------------------------------------------------> |                                                             |   wallets
+	//  +----+--------+-----+  |                                                             |                   SELECT amount FROM wallets WHERE id = 1   |  +----+--------+-----+
+	//  | 1  | 100000 | ... |  |                                                             | <---------------------------------------------------------- |  | id | amount | ... |
+	//  +----+--------+-----+  |       does the amount more than 60000? Yes!                 |                                                             |  +----+--------+-----+
+	//                         |                                                             |                                                             |  | 1  | 100000 | ... |
+	//   wallets               |                                                             |       does the amount more than 60000? Yes!                 |  +----+--------+-----+
+	//  +----+--------+-----+  |   UPDATE wallets SET amount = amount - 60000 WHERE id = 1   |                                                             |
+	//  | id | amount | ... |  | ----------------------------------------------------------> |                                                             |
+	//  +----+--------+-----+  |   COMMIT                                                    |                                                             |
+	//  | 1  |  40000 | ... |  | ----------------------------------------------------------> |                                                             |   wallets
+	//  +----+--------+-----+  |                                                             |   UPDATE wallets SET amount = amount - 60000 WHERE id = 1   |  +----+--------+-----+
+	//                         |                                                             | <---------------------------------------------------------- |  | id | amount | ... |
+	//                         |                                                             |                                           COMMIT            |  +----+--------+-----+
+	//                         |                                                             | <---------------------------------------------------------- |  | 1  | -20000 | ... |
+	//                         |                                                             |                                                             |  +----+--------+-----+
+	//                         |                                                             |                                                             |
+	//                         |                                                             |                                                             | 因為業務邏輯造成 phantom read (讀到錯誤的錢包餘額)
+	//                         |                                                             |                                                             | 導致後續發生 write skew (額度不足導致餘額為負數)
+	//
+	// 兩種解決 Write Skew 的辦法:
+	//
+	// 1. 自行加上 Explicit Lock
+	//     - 改寫 SELECT amount FROM wallets WHERE id = 1 成 SELECT amount FROM wallets WHERE id = 1 FOR UPDATE
+	//     - 加上排他鎖明確限制同時間只允許一個 transaction 進行後續流程
+	//     - 注意 Row Lock 升級成 Next-key Lock 可能造成的衍生問題
+	//
+	// 2. 將 isolation level 升級成 serializable level
+	//     - 在上述情境中還是無法避免同時 SELECT 後因為業務邏輯產生的 Phantom Read 問題
+
+	wg := new(sync.WaitGroup)
+	wg.Add(2)
+
+	go func(_wg *sync.WaitGroup) {
+		defer _wg.Done()
+
+		tx1, err := s.conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+		checkError(err, "failed to start transaction:")
+		logrus.Infoln("transaction 1 started.")
+
+		var amount int
+		err = tx1.QueryRow(SelectAmount).Scan(&amount)
+		checkError(err, "failed to querying row:")
+		logrus.Infoln("transaction 1 selected.")
+
+		time.Sleep(1 * time.Second)
+
+		// 表示業務邏輯處理結果
+		if amount > 60000 {
+			_, err = tx1.Exec(Withdraw)
+			checkError(err, "failed to execute:")
+			logrus.Infoln("transaction 1 updated.")
+		}
+
+		err = tx1.Commit()
+		checkError(err, "failed to commit:")
+		logrus.Infoln("transaction 1 committed.")
+
+	}(wg)
+
+	time.Sleep(1 * time.Millisecond)
+
+	go func(_wg *sync.WaitGroup) {
+		defer _wg.Done()
+
+		tx2, err := s.conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+		checkError(err, "failed to start transaction:")
+		logrus.Infoln("transaction 2 started.")
+
+		var amount int
+		err = tx2.QueryRow(SelectAmount).Scan(&amount)
+		checkError(err, "failed to querying row:")
+		logrus.Infoln("transaction 2 selected.")
+
+		time.Sleep(1 * time.Second)
+
+		// 表示業務邏輯處理結果
+		// PostgreSQL 的 repeatable read 下 transaction 1 已修改同一筆資料列, transaction 2 會因序列化失敗而中止
+		if amount > 60000 {
+			_, err = tx2.Exec(Withdraw)
+			if s.aborted(tx2, "transaction 2", err) {
+				return
+			}
+			logrus.Infoln("transaction 2 updated.")
+		}
+
+		err = tx2.Commit()
+		checkError(err, "failed to commit:")
+		logrus.Infoln("transaction 2 committed.")
+
+	}(wg)
+
+	wg.Wait()
+
+	var amount int
+	err = s.conn.QueryRow(SelectAmount).Scan(&amount)
+	checkError(err, "failed to querying row:")
+
+	logrus.Warnf("Amount = %v", amount)
+}
+
+func (s *scenarios) SimulateLockFailed1(ctx context.Context) {
+	// init
+	s.truncate("wallets")
+
+	timeNow := time.Now().Format("2006-01-02 15:04:05")
+	_, err := s.conn.Exec(s.dialect.Rebind("INSERT INTO wallets (user_id, amount, created_at, modified_at) VALUES (?, ?, ?, ?);"),
+		"1",
+		100000,
+		timeNow,
+		timeNow,
+	)
+	checkError(err, "failed to execute:")
+
+	logrus.Info("========== start ==========")
+	defer logrus.Info("=========== end ===========")
+
+	// 模擬因為觸發覆蓋索引(Covering Index) 導致上鎖失敗
+	//
+	//  Transaction 1                                    Database                                       Transaction 2
+	//       |                                              |                                                |
+	//       |                                              |   wallets                                      |
+	//       |                                              |  +----+--------+-----+                         |
+	//       |                                              |  | id | amount | ... |                         |
+	//       |                                              |  +----+--------+-----+                         |
+	//       |                                              |  | 1  | 100000 | ... |                         |
+	//       |                                              |  +----+--------+-----+                         |
+	//       |                                              |                                                |
+	//       |   START TRANSACTION                          |                                                |
+	//       | -------------------------------------------> |                                                |
+	//       |                                              |                            START TRANSACTION   |
+	//       |                                              | <--------------------------------------------- |
+	//       |   SELECT id FROM wallets WHERE user_id = 1   |                                                |
+	//       |   LOCK IN SHARE MODE                         |                                                |
+	//       | -------------------------------------------> |                                                |
+	//       |                                              |   UPDATE wallets SET amount = 0 WHERE id = 1   |  預想情況中, 此時 Transaction 2 應該要阻塞直到 Transaction 1 結束後
+	//       |                                              | <--------------------------------------------- |  才能執行, 但 Transaction 1 卻沒有成功鎖上
+	//       |                                              |                                       COMMIT   |
+	//       |                                              | <--------------------------------------------- |
+	//       |   COMMIT                                     |                                                |
+	//       | -------------------------------------------> |                                                |
+	//       |                                              |                                                |
+	//
+	// 原因在於 transaction 1 在執行過程中不需要回到 clustered index 查找資料，因此只需要對 secondary index 上鎖 (即 user_id)
+	// 而 transaction 2 請求的鎖是在 clustered index, 因此 transaction 2 可以很順利的執行不必等待 transaction 1 結束
+	//
+	// 解決辦法:
+	//
+	// 1. 若 transaction 1 修改查詢欄位, 迫使執行時必須回到 clustered index 查找該欄位, 才會使得 transaction 2 一定得等到 transaction 1 結束後才可繼續動作
+	//
+	// 2. 將上鎖指令從 LOCK IN SHARE MODE 升級成 FOR UPDATE, 也會同時將 clustered index 上鎖
+	//
+	// PostgreSQL 沒有 clustered index, FOR SHARE 一律鎖在資料列 (heap tuple) 上, 因此 transaction 2 會等待 transaction 1 結束
+
+	wg := new(sync.WaitGroup)
+	wg.Add(2)
+
+	go func(_wg *sync.WaitGroup) {
+		defer _wg.Done()
+
+		tx1, err := s.conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+		checkError(err, "failed to start transaction:")
+		logrus.Infoln("transaction 1 started.")
+
+		var id int
+		err = tx1.QueryRow(SelectIDByUser + " " + s.lock(dialect.ForShare, dialect.Block)).Scan(&id)
+		checkError(err, "failed to querying row:")
+		logrus.Infoln("transaction 1 selected")
+
+		time.Sleep(1 * time.Second)
+
+		err = tx1.Commit()
+		checkError(err, "failed to commit:")
+		logrus.Infoln("transaction 1 committed.")
+
+	}(wg)
+
+	time.Sleep(1 * time.Millisecond)
+
+	go func(_wg *sync.WaitGroup) {
+		defer _wg.Done()
+
+		tx2, err := s.conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+		checkError(err, "failed to start transaction:")
+		logrus.Infoln("transaction 2 started.")
+
+		_, err = tx2.Exec(WithdrawSome)
+		checkError(err, "failed to execute:")
+		logrus.Infoln("transaction 2 updated.")
+
+		err = tx2.Commit()
+		checkError(err, "failed to commit:")
+		logrus.Infoln("transaction 2 committed.")
+
+	}(wg)
+
+	wg.Wait()
+}
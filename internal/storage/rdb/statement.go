@@ -0,0 +1,31 @@
+package rdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// 情境中交易執行的語句, 參數以常值寫在語句中, internal/explain 以相同的語句分析執行計畫
+const (
+	InsertLog          = "INSERT INTO logs (deposit_user_id, withdraw_user_id, amount, created_at) VALUES (1, 2, 1, '2022-12-22 20:57:47')"
+	CountLogs          = "SELECT count(*) FROM logs"
+	SelectAmount       = "SELECT amount FROM wallets WHERE id = 1"
+	Withdraw           = "UPDATE wallets SET amount = amount - 60000 WHERE id = 1"
+	CountWallets       = "SELECT COUNT(amount) FROM wallets"
+	RaiseWallets       = "UPDATE wallets SET amount = amount + 10000"
+	CountRaisedWallets = "SELECT COUNT(amount) FROM wallets WHERE amount >= 110000"
+	SelectIDByUser     = "SELECT id FROM wallets WHERE user_id = 1"     // 只需要 user_id 索引即可回答, 後面接上鎖子句
+	SelectAmountByUser = "SELECT amount FROM wallets WHERE user_id = 1" // lock_failed_1 的解法, 必須回到 clustered index 讀取 amount
+	WithdrawSome       = "UPDATE wallets SET amount = amount - 10000 WHERE id = 1"
+)
+
+// SetAmount 以業務邏輯算出的餘額直接覆寫錢包, 為 lost_update 的寫入
+func SetAmount(amount int) string {
+	return fmt.Sprintf("UPDATE wallets SET amount = %d WHERE id = 1", amount)
+}
+
+// InsertWallet 新增錢包, 為 write_skew_1 中 transaction 1 的寫入
+func InsertWallet(userID, amount int, at time.Time) string {
+	timestamp := at.Format("2006-01-02 15:04:05")
+	return fmt.Sprintf("INSERT INTO wallets (user_id, amount, created_at, modified_at) VALUES (%d, %d, '%s', '%s')", userID, amount, timestamp, timestamp)
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrNotFound 表示查詢的資料列不存在
+var ErrNotFound = errors.New("record not found")
+
+// ErrInsufficientAmount 表示錢包餘額不足以扣除
+var ErrInsufficientAmount = errors.New("insufficient wallet amount")
+
+// Querier 為 *sql.DB, *sql.Tx 與 *sql.Conn 共同的方法, repository 的操作可以在交易內或交易外執行
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Lock 為讀取資料列時的上鎖方式, 只在交易內有效
+type Lock int
+
+const (
+	LockNone   Lock = iota // 一般的一致性讀取
+	LockShare              // 共享鎖 (MySQL LOCK IN SHARE MODE, PostgreSQL FOR SHARE)
+	LockUpdate             // 排他鎖 (FOR UPDATE)
+)
+
+// Repositories 為同一個資料庫的所有 repository
+type Repositories struct {
+	Users   UserRepository
+	Wallets WalletRepository
+	Logs    LogRepository
+}
+
+type UserRepository interface {
+	// 新增用戶, 並設定 user.ID
+	Create(ctx context.Context, q Querier, user *User) error
+
+	// 以單一 INSERT 新增多個用戶, 並依序設定 ID
+	CreateBatch(ctx context.Context, q Querier, users []*User) error
+
+	// 以 ID 取得用戶, 不存在時回傳 ErrNotFound
+	Get(ctx context.Context, q Querier, id int64, lock Lock) (*User, error)
+
+	// 以帳號取得用戶, 不存在時回傳 ErrNotFound
+	GetByAccount(ctx context.Context, q Querier, account string, lock Lock) (*User, error)
+
+	// 依照 ID 順序取得 ID 大於 afterID 的 limit 個用戶
+	List(ctx context.Context, q Querier, afterID int64, limit int) ([]*User, error)
+
+	// 更新用戶所有欄位, 不存在時回傳 ErrNotFound
+	Update(ctx context.Context, q Querier, user *User) error
+
+	// 刪除用戶, 不存在時回傳 ErrNotFound
+	Delete(ctx context.Context, q Querier, id int64) error
+}
+
+type WalletRepository interface {
+	// 新增錢包, 並設定 wallet.ID
+	Create(ctx context.Context, q Querier, wallet *Wallet) error
+
+	// 以單一 INSERT 新增多個錢包, 並依序設定 ID
+	CreateBatch(ctx context.Context, q Querier, wallets []*Wallet) error
+
+	// 以 ID 取得錢包, 不存在時回傳 ErrNotFound
+	Get(ctx context.Context, q Querier, id int64, lock Lock) (*Wallet, error)
+
+	// 以用戶 ID 取得錢包, 不存在時回傳 ErrNotFound
+	GetByUserID(ctx context.Context, q Querier, userID int64, lock Lock) (*Wallet, error)
+
+	// 依照 ID 順序取得 ID 大於 afterID 的 limit 個錢包
+	List(ctx context.Context, q Querier, afterID int64, limit int) ([]*Wallet, error)
+
+	// 將餘額設為 amount, 不存在時回傳 ErrNotFound
+	SetAmount(ctx context.Context, q Querier, id, amount int64) error
+
+	// 由資料庫以 amount = amount + delta 原子地調整餘額, 餘額不足以扣除時回傳 ErrInsufficientAmount
+	AddAmount(ctx context.Context, q Querier, id, delta int64) error
+
+	// 餘額仍為 old 時才設為 new (樂觀鎖), 回傳是否更新成功
+	CompareAndSetAmount(ctx context.Context, q Querier, id, old, new int64) (bool, error)
+
+	// 刪除錢包, 不存在時回傳 ErrNotFound
+	Delete(ctx context.Context, q Querier, id int64) error
+}
+
+type LogRepository interface {
+	// 新增轉帳記錄, 並設定 log.ID
+	Create(ctx context.Context, q Querier, log *TransferLog) error
+
+	// 以單一 INSERT 新增多筆轉帳記錄, 並依序設定 ID
+	CreateBatch(ctx context.Context, q Querier, logs []*TransferLog) error
+
+	// 以 ID 取得轉帳記錄, 不存在時回傳 ErrNotFound
+	Get(ctx context.Context, q Querier, id int64) (*TransferLog, error)
+
+	// 依照 ID 順序取得用戶存款或出款且 ID 大於 afterID 的 limit 筆轉帳記錄
+	ListByUser(ctx context.Context, q Querier, userID, afterID int64, limit int) ([]*TransferLog, error)
+
+	// 回傳轉帳記錄總數
+	Count(ctx context.Context, q Querier) (int64, error)
+}
@@ -0,0 +1,174 @@
+package router
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// replication 為各資料庫讀取複寫位置與延遲的方式
+type replication interface {
+	// 回傳 primary 目前已提交的位置, MySQL 為 GTID set, PostgreSQL 為 WAL LSN
+	position(ctx context.Context, primary *sql.DB) (string, error)
+
+	// 等待 replica 套用到 position, 在 timeout 內追上時回傳 true
+	wait(ctx context.Context, replica *sql.DB, position string, timeout time.Duration) (bool, error)
+
+	// 回傳 replica 落後 primary 的時間, 複寫停止時回傳錯誤
+	lag(ctx context.Context, replica *sql.DB) (time.Duration, error)
+}
+
+func newReplication(driver string) (replication, error) {
+	switch driver {
+	case "mysql":
+		return mysqlReplication{}, nil
+	case "postgresql":
+		return postgresReplication{}, nil
+	}
+	return nil, fmt.Errorf("replication of driver %v is undifined", driver)
+}
+
+// mysqlReplication 需要 primary 與 replica 皆開啟 gtid_mode, 否則 position 為空白, 讀取改送 primary
+type mysqlReplication struct{}
+
+// position 讀取全域的 gtid_executed, 包含其他連線的交易, 因此等待的範圍可能多於 session 自己的寫入
+func (mysqlReplication) position(ctx context.Context, primary *sql.DB) (string, error) {
+	var gtids string
+	if err := primary.QueryRowContext(ctx, "SELECT @@GLOBAL.gtid_executed").Scan(&gtids); err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(gtids, "\n", ""), nil
+}
+
+func (mysqlReplication) wait(ctx context.Context, replica *sql.DB, position string, timeout time.Duration) (bool, error) {
+	var timedOut int
+	err := replica.QueryRowContext(ctx, "SELECT WAIT_FOR_EXECUTED_GTID_SET(?, ?)", position, timeout.Seconds()).Scan(&timedOut)
+	if err != nil {
+		return false, err
+	}
+	return timedOut == 0, nil
+}
+
+// lag 讀取 SHOW REPLICA STATUS 的 Seconds_Behind_Source, 包含 SOURCE_DELAY 刻意設定的延遲
+// MySQL 8.0.22 之前只有 SHOW SLAVE STATUS 與 Seconds_Behind_Master
+func (mysqlReplication) lag(ctx context.Context, replica *sql.DB) (time.Duration, error) {
+	status, err := showStatus(ctx, replica, "SHOW REPLICA STATUS")
+	if err != nil {
+		if status, err = showStatus(ctx, replica, "SHOW SLAVE STATUS"); err != nil {
+			return 0, err
+		}
+	}
+	if status == nil {
+		return 0, fmt.Errorf("not replicating from any source")
+	}
+
+	for _, column := range []string{"Seconds_Behind_Source", "Seconds_Behind_Master"} {
+		value, ok := status[column]
+		if !ok {
+			continue
+		}
+		// 複寫的 IO 或 SQL thread 停止時為 NULL
+		if !value.Valid {
+			return 0, fmt.Errorf("replication threads are stopped")
+		}
+		seconds, err := strconv.ParseInt(value.String, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, fmt.Errorf("replica status has no lag column")
+}
+
+// showStatus 回傳 SHOW REPLICA STATUS 的第一列, 不是 replica 時回傳 nil
+func showStatus(ctx context.Context, db *sql.DB, statement string) (map[string]sql.NullString, error) {
+	rows, err := db.QueryContext(ctx, statement)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	values := make([]sql.NullString, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return nil, err
+	}
+
+	status := make(map[string]sql.NullString, len(columns))
+	for i, column := range columns {
+		status[column] = values[i]
+	}
+	return status, nil
+}
+
+// postgresReplication 以 streaming replication 的 WAL 位置判斷
+type postgresReplication struct{}
+
+func (postgresReplication) position(ctx context.Context, primary *sql.DB) (string, error) {
+	var lsn string
+	err := primary.QueryRowContext(ctx, "SELECT pg_current_wal_lsn()::text").Scan(&lsn)
+	return lsn, err
+}
+
+// wait PostgreSQL 沒有等待 LSN 的函式, 以短間隔輪詢 pg_last_wal_replay_lsn
+func (postgresReplication) wait(ctx context.Context, replica *sql.DB, position string, timeout time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		// 不在 recovery 中 (不是 replica) 時 pg_last_wal_replay_lsn 為 NULL, 視為已追上
+		var caughtUp sql.NullBool
+		err := replica.QueryRowContext(ctx, "SELECT pg_last_wal_replay_lsn() >= $1::pg_lsn", position).Scan(&caughtUp)
+		if ctx.Err() != nil {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if !caughtUp.Valid || caughtUp.Bool {
+			return true, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// lag 以最後套用的交易時間計算, primary 閒置時 replay timestamp 不會前進, 因此已套用所有接收到的 WAL 時視為沒有延遲
+func (postgresReplication) lag(ctx context.Context, replica *sql.DB) (time.Duration, error) {
+	var inRecovery bool
+	var seconds sql.NullFloat64
+	err := replica.QueryRowContext(ctx, `
+	SELECT pg_is_in_recovery(),
+		CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()) END`).Scan(&inRecovery, &seconds)
+	if err != nil {
+		return 0, err
+	}
+	if !inRecovery {
+		return 0, fmt.Errorf("not in recovery, the server is not a replica")
+	}
+	if !seconds.Valid {
+		return 0, fmt.Errorf("no transaction replayed yet")
+	}
+	return time.Duration(seconds.Float64 * float64(time.Second)), nil
+}
@@ -0,0 +1,281 @@
+package router
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"practice/internal/storage/dialect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 讀取的路由策略
+const (
+	RoundRobin     = "round_robin"      // 依序輪流使用健康的 replicas
+	LeastLag       = "least_lag"        // 使用最後一次量測延遲最小的 replica
+	ReadYourWrites = "read_your_writes" // session 寫入後, 等待 replica 套用該次寫入才讀取, 其餘讀取與 least_lag 相同
+)
+
+// PrimaryName 為讀取送往 primary 時回傳的名稱
+const PrimaryName = "primary"
+
+// DefaultHealthTimeout 為未設定 HealthTimeout 時單一 replica 健康檢查的逾時
+const DefaultHealthTimeout = 500 * time.Millisecond
+
+// DefaultWaitTimeout 為未設定 WaitTimeout 時 read_your_writes 等待 replica 追上的上限
+const DefaultWaitTimeout = time.Second
+
+// Options 為 router 的設定
+type Options struct {
+	Policy         string
+	MaxLag         time.Duration // 延遲超過時暫時移出讀取名單, 0 表示不因延遲移出
+	HealthInterval time.Duration // 健康檢查與延遲量測的間隔
+	HealthTimeout  time.Duration // 單一 replica 健康檢查的逾時, 0 時使用 DefaultHealthTimeout
+	WaitTimeout    time.Duration // read_your_writes 等待 replica 追上的上限, 逾時改讀 primary, 0 時使用 DefaultWaitTimeout
+}
+
+// Replica 為單一唯讀 replica 與最後一次健康檢查的結果
+type Replica struct {
+	Name string
+	DB   *sql.DB
+
+	mu      sync.RWMutex
+	lag     time.Duration
+	healthy bool
+	err     error
+	checked time.Time
+}
+
+// NewReplica New Read-Only Replica
+// @param name  name shown in logs and status
+// @param db    connection of the replica
+func NewReplica(name string, db *sql.DB) *Replica {
+	return &Replica{Name: name, DB: db}
+}
+
+// Status 回傳最後一次量測的延遲, 是否可以讀取與無法讀取的原因
+func (r *Replica) Status() (time.Duration, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lag, r.healthy, r.err
+}
+
+func (r *Replica) update(lag time.Duration, healthy bool, err error) (changed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changed = r.checked.IsZero() || r.healthy != healthy
+	r.lag, r.healthy, r.err, r.checked = lag, healthy, err, time.Now()
+	return changed
+}
+
+// Router 將寫入與交易送往 primary, 將可以在 replica 執行的讀取依照策略分配至健康的 replicas
+// 沒有健康的 replica 時讀取送往 primary
+type Router struct {
+	primary     *sql.DB
+	dialect     dialect.Dialect
+	replication replication
+	replicas    []*Replica
+	opts        Options
+
+	next   uint64
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRouter New Read/Write Splitting Router
+// @param primary   connection receiving writes and transactions
+// @param d         dialect of the primary and the replicas
+// @param replicas  read-only replicas of the primary
+// @param opts      routing policy, lag limit, health check interval and timeout, wait timeout of read_your_writes
+func NewRouter(primary *sql.DB, d dialect.Dialect, replicas []*Replica, opts Options) (*Router, error) {
+	switch opts.Policy {
+	case RoundRobin, LeastLag, ReadYourWrites:
+	default:
+		return nil, fmt.Errorf("routing policy %v undifined", opts.Policy)
+	}
+	if opts.HealthInterval <= 0 {
+		return nil, fmt.Errorf("health check interval must be positive")
+	}
+	if opts.HealthTimeout < 0 {
+		return nil, fmt.Errorf("health check timeout must not be negative")
+	}
+	if opts.HealthTimeout == 0 {
+		opts.HealthTimeout = DefaultHealthTimeout
+	}
+	if opts.WaitTimeout < 0 {
+		return nil, fmt.Errorf("replica wait timeout must not be negative")
+	}
+	if opts.WaitTimeout == 0 {
+		opts.WaitTimeout = DefaultWaitTimeout
+	}
+
+	repl, err := newReplication(d.Driver())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Router{primary: primary, dialect: d, replication: repl, replicas: replicas, opts: opts}, nil
+}
+
+// Start 立即檢查一次所有 replicas, 之後在背景定期檢查直到 Close
+func (r *Router) Start(ctx context.Context) {
+	r.check(ctx)
+	if len(r.replicas) == 0 {
+		return
+	}
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.opts.HealthInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.check(ctx)
+			}
+		}
+	}()
+}
+
+// Close 停止背景的健康檢查, 不關閉 primary 與 replicas 的連線
+func (r *Router) Close() {
+	if r.cancel != nil {
+		r.cancel()
+		<-r.done
+	}
+}
+
+// check 量測每個 replica 的延遲, 無法連線、複寫停止或延遲超過上限的 replica 移出讀取名單, 恢復後自動加回
+func (r *Router) check(ctx context.Context) {
+	for _, replica := range r.replicas {
+		checkCtx, cancel := context.WithTimeout(ctx, r.opts.HealthTimeout)
+		lag, err := r.replication.lag(checkCtx, replica.DB)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		healthy := err == nil
+		if healthy && r.opts.MaxLag > 0 && lag > r.opts.MaxLag {
+			healthy = false
+			err = fmt.Errorf("lag %v exceeds %v", lag, r.opts.MaxLag)
+		}
+
+		if replica.update(lag, healthy, err) {
+			if healthy {
+				logrus.Infof("replica %v is eligible for reads, lag %v", replica.Name, lag)
+			} else {
+				logrus.Warnf("replica %v is evicted from reads: %v", replica.Name, err)
+			}
+		}
+	}
+}
+
+// Primary 回傳 primary 的連線
+func (r *Router) Primary() *sql.DB {
+	return r.primary
+}
+
+// Replicas 回傳所有 replicas, 包含目前被移出讀取名單的 replicas
+func (r *Router) Replicas() []*Replica {
+	return r.replicas
+}
+
+// Policy 回傳讀取的路由策略
+func (r *Router) Policy() string {
+	return r.opts.Policy
+}
+
+// Reader 依照策略選擇讀取的連線, 回傳 nil replica 表示送往 primary
+func (r *Router) Reader() (*sql.DB, *Replica) {
+	replica := r.pick()
+	if replica == nil {
+		return r.primary, nil
+	}
+	return replica.DB, replica
+}
+
+// pick 依照策略選擇健康的 replica, 沒有健康的 replica 時回傳 nil
+func (r *Router) pick() *Replica {
+	healthy := make([]*Replica, 0, len(r.replicas))
+	for _, replica := range r.replicas {
+		if _, ok, _ := replica.Status(); ok {
+			healthy = append(healthy, replica)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	if r.opts.Policy == RoundRobin {
+		return healthy[(atomic.AddUint64(&r.next, 1)-1)%uint64(len(healthy))]
+	}
+
+	least := healthy[0]
+	leastLag, _, _ := least.Status()
+	for _, replica := range healthy[1:] {
+		if lag, _, _ := replica.Status(); lag < leastLag {
+			least, leastLag = replica, lag
+		}
+	}
+	return least
+}
+
+// WaitReplicas 等待所有 replicas 套用 primary 目前已提交的交易, 包含被移出讀取名單的 replicas
+func (r *Router) WaitReplicas(ctx context.Context, timeout time.Duration) error {
+	position, err := r.replication.position(ctx, r.primary)
+	if err != nil {
+		return err
+	}
+	if position == "" {
+		return fmt.Errorf("primary reports no replication position, gtid_mode may be off")
+	}
+
+	for _, replica := range r.replicas {
+		caughtUp, err := r.replication.wait(ctx, replica.DB, position, timeout)
+		if err != nil {
+			return fmt.Errorf("failed to wait for replica %v: %w", replica.Name, err)
+		}
+		if !caughtUp {
+			return fmt.Errorf("replica %v did not catch up within %v", replica.Name, timeout)
+		}
+	}
+	return nil
+}
+
+// ExecContext 寫入一律送往 primary
+func (r *Router) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.primary.ExecContext(ctx, query, args...)
+}
+
+// BeginTx 交易一律送往 primary, 交易中的讀取必須與寫入看到相同的資料
+func (r *Router) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return r.primary.BeginTx(ctx, opts)
+}
+
+// QueryContext 唯讀的查詢依照策略送往 replica, 上鎖讀取等其他語句送往 primary
+func (r *Router) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return r.route(query).QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext 與 QueryContext 相同, 只回傳第一列
+func (r *Router) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return r.route(query).QueryRowContext(ctx, query, args...)
+}
+
+func (r *Router) route(query string) *sql.DB {
+	if !ReadOnly(query) {
+		return r.primary
+	}
+	db, _ := r.Reader()
+	return db
+}
@@ -0,0 +1,30 @@
+package router
+
+import (
+	"practice/internal/storage/dialect"
+	"testing"
+	"time"
+)
+
+func TestNewRouterOptions(t *testing.T) {
+	opts := Options{Policy: ReadYourWrites, HealthInterval: time.Second}
+	r, err := NewRouter(nil, dialect.Mysql{}, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.opts.HealthTimeout != DefaultHealthTimeout || r.opts.WaitTimeout != DefaultWaitTimeout {
+		t.Errorf("expected default timeouts, got %v and %v", r.opts.HealthTimeout, r.opts.WaitTimeout)
+	}
+
+	invalid := []Options{
+		{Policy: "random", HealthInterval: time.Second},
+		{Policy: RoundRobin},
+		{Policy: RoundRobin, HealthInterval: time.Second, HealthTimeout: -time.Second},
+		{Policy: ReadYourWrites, HealthInterval: time.Second, WaitTimeout: -time.Second},
+	}
+	for _, opts := range invalid {
+		if _, err := NewRouter(nil, dialect.Postgres{}, nil, opts); err == nil {
+			t.Errorf("expected options %+v to fail", opts)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package router
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SessionOptions 為 session 讀取的保證
+type SessionOptions struct {
+	Pinned         bool // 所有讀取固定使用第一次選擇的 replica, 讀到的資料不會倒退 (monotonic reads)
+	ReadYourWrites bool // 寫入後等待 replica 套用該次寫入才讀取, read_your_writes 策略下一律開啟
+}
+
+// Session 為單一使用者的一連串讀寫, 讓讀取可以看到 session 自己的寫入或固定使用同一個 replica
+type Session struct {
+	router *Router
+	opts   SessionOptions
+
+	mu       sync.Mutex
+	position string   // 最後一次寫入後 primary 的位置, 空白表示沒有需要等待的寫入
+	wrote    bool     // 寫入後無法取得位置時, 之後的讀取送往 primary
+	replica  *Replica // pinned 時第一次讀取選擇的 replica
+}
+
+// Session 建立新的 session
+func (r *Router) Session(opts SessionOptions) *Session {
+	if r.opts.Policy == ReadYourWrites {
+		opts.ReadYourWrites = true
+	}
+	return &Session{router: r, opts: opts}
+}
+
+// ExecContext 在 primary 寫入, 並記錄寫入後的位置
+func (s *Session) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	result, err := s.router.primary.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return result, s.Wrote(ctx)
+}
+
+// BeginTx 在 primary 開始交易, commit 後需要呼叫 Wrote 記錄位置
+func (s *Session) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return s.router.primary.BeginTx(ctx, opts)
+}
+
+// Wrote 記錄 primary 目前的位置, 之後的讀取在 ReadYourWrites 時會等待 replica 套用到此位置
+func (s *Session) Wrote(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.wrote = true
+	if !s.opts.ReadYourWrites {
+		return nil
+	}
+
+	position, err := s.router.replication.position(ctx, s.router.primary)
+	if err != nil {
+		return err
+	}
+	s.position = position
+	return nil
+}
+
+// QueryContext 唯讀的查詢依照 session 的狀態送往 replica 或 primary, 只需要單列時以 Reader 取得連線
+func (s *Session) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if !ReadOnly(query) {
+		return s.router.primary.QueryContext(ctx, query, args...)
+	}
+	db, _, err := s.Reader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return db.QueryContext(ctx, query, args...)
+}
+
+// Reader 選擇 session 讀取的連線, 回傳 nil replica 表示送往 primary
+//
+// ReadYourWrites 時寫入後等待 replica 套用寫入的位置, 逾時或無法取得位置時改讀 primary;
+// pinned 時固定使用同一個 replica, 該 replica 被移出讀取名單後才重新選擇
+func (s *Session) Reader(ctx context.Context) (*sql.DB, *Replica, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	replica := s.replica
+	if replica != nil {
+		if _, healthy, _ := replica.Status(); !healthy {
+			logrus.Warnf("session leaves evicted replica %v, reads may go back in time", replica.Name)
+			replica = nil
+		}
+	}
+	if replica == nil {
+		replica = s.router.pick()
+	}
+	if replica == nil {
+		return s.router.primary, nil, nil
+	}
+	if s.opts.Pinned {
+		s.replica = replica
+	}
+
+	if s.opts.ReadYourWrites && s.wrote {
+		if s.position == "" {
+			return s.router.primary, nil, nil
+		}
+
+		caughtUp, err := s.router.replication.wait(ctx, replica.DB, s.position, s.router.opts.WaitTimeout)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !caughtUp {
+			logrus.Debugf("replica %v did not apply %v within %v, reading from the primary", replica.Name, s.position, s.router.opts.WaitTimeout)
+			return s.router.primary, nil, nil
+		}
+	}
+	return replica.DB, replica, nil
+}
@@ -0,0 +1,193 @@
+package router
+
+import (
+	"strings"
+)
+
+// writingKeywords 出現在語句中任何位置就必須在 primary 執行的關鍵字
+// WITH 中可以包含 INSERT, UPDATE 與 DELETE (PostgreSQL data-modifying CTE), SELECT ... INTO 會建立資料表或寫入檔案與變數, REPLACE INTO 由 into 排除
+var writingKeywords = map[string]bool{
+	"insert": true,
+	"update": true,
+	"delete": true,
+	"merge":  true,
+	"into":   true,
+}
+
+// sideEffectFunctions 有副作用或依賴 session 狀態的函式, 在 replica 執行會失敗或失去效果
+var sideEffectFunctions = map[string]bool{
+	"nextval":           true,
+	"setval":            true,
+	"currval":           true,
+	"lastval":           true,
+	"set_config":        true,
+	"pg_notify":         true,
+	"get_lock":          true,
+	"release_lock":      true,
+	"release_all_locks": true,
+	"last_insert_id":    true,
+}
+
+// sideEffectPrefixes 以此開頭的函式同樣有副作用, e.g. pg_advisory_lock, pg_try_advisory_xact_lock_shared
+var sideEffectPrefixes = []string{"pg_advisory_", "pg_try_advisory_"}
+
+// token 為語句中的單字或符號, 引號內的識別字不視為關鍵字
+type token struct {
+	text   string
+	quoted bool
+}
+
+// ReadOnly 判斷語句是否可以在 replica 執行, 只有不上鎖, 不寫入且不呼叫有副作用函式的 SELECT 與 WITH 查詢可以
+// 字串常值與註解中的內容不影響判斷, 無法確定時一律回傳 false 送往 primary
+func ReadOnly(query string) bool {
+	tokens := tokenize(query)
+
+	first := 0
+	for first < len(tokens) && tokens[first].text == "(" {
+		first++
+	}
+	if first == len(tokens) || tokens[first].quoted || (tokens[first].text != "select" && tokens[first].text != "with") {
+		return false
+	}
+
+	for i, t := range tokens {
+		next := ""
+		if i+1 < len(tokens) {
+			next = tokens[i+1].text
+		}
+
+		if next == "(" && sideEffect(t.text) {
+			return false
+		}
+		if t.quoted {
+			continue
+		}
+		if writingKeywords[t.text] || t.text == "\\" {
+			return false
+		}
+		// FOR UPDATE 已由 update 排除, 其餘為 FOR SHARE, FOR NO KEY UPDATE, FOR KEY SHARE 與 LOCK IN SHARE MODE
+		if t.text == "share" || (t.text == "for" && (next == "no" || next == "key")) {
+			return false
+		}
+	}
+	return true
+}
+
+func sideEffect(function string) bool {
+	if sideEffectFunctions[function] {
+		return true
+	}
+	for _, prefix := range sideEffectPrefixes {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenize 將語句切割為小寫的單字與符號, 略過字串常值與註解
+// 支援 MySQL 與 PostgreSQL 的語法: '...', "...", `...`, $tag$...$tag$, -- 與 # 單行註解, /* */ 區塊註解
+// MySQL 的 /*! */ 會被執行, 其中的內容照常切割
+func tokenize(query string) []token {
+	tokens := []token{}
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+
+		case c == '-' && strings.HasPrefix(query[i:], "--"), c == '#':
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return tokens
+			}
+			i += end + 1
+
+		case c == '/' && strings.HasPrefix(query[i:], "/*!"):
+			i += 3
+			for i < len(query) && query[i] >= '0' && query[i] <= '9' {
+				i++
+			}
+
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 4
+
+		case c == '*' && strings.HasPrefix(query[i:], "*/"):
+			// /*! */ 的結尾
+			i += 2
+
+		case c == '\'':
+			end := skipQuoted(query, i, c)
+			if strings.IndexByte(query[i:end], '\\') >= 0 {
+				// 反斜線在 MySQL 為跳脫字元, 在 PostgreSQL 不是, 無法確定字串結束的位置
+				tokens = append(tokens, token{text: "\\"})
+			}
+			tokens = append(tokens, token{text: "''", quoted: true})
+			i = end
+
+		case c == '"' || c == '`':
+			end := skipQuoted(query, i, c)
+			text := strings.TrimSuffix(query[i+1:end], string(c))
+			tokens = append(tokens, token{text: strings.ToLower(text), quoted: true})
+			i = end
+
+		case c == '$' && dollarTag(query[i:]) != "":
+			tag := dollarTag(query[i:])
+			end := strings.Index(query[i+len(tag):], tag)
+			if end < 0 {
+				return tokens
+			}
+			i += len(tag) + end + len(tag)
+			tokens = append(tokens, token{text: "''", quoted: true})
+
+		case isWord(c):
+			start := i
+			for i < len(query) && isWord(query[i]) {
+				i++
+			}
+			tokens = append(tokens, token{text: strings.ToLower(query[start:i])})
+
+		default:
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		}
+	}
+	return tokens
+}
+
+// skipQuoted 回傳引號結束後的位置, 重複的引號視為跳脫
+func skipQuoted(query string, start int, quote byte) int {
+	for i := start + 1; i < len(query); i++ {
+		if query[i] != quote {
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(query)
+}
+
+// dollarTag 回傳 PostgreSQL dollar quoting 的起始標記 ($$ 或 $tag$), 不是 dollar quoting 時回傳空字串 (e.g. $1)
+func dollarTag(query string) string {
+	for i := 1; i < len(query); i++ {
+		c := query[i]
+		if c == '$' {
+			return query[:i+1]
+		}
+		if !isWord(c) || (i == 1 && c >= '0' && c <= '9') {
+			return ""
+		}
+	}
+	return ""
+}
+
+func isWord(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80
+}
@@ -0,0 +1,50 @@
+package router
+
+import "testing"
+
+func TestReadOnly(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		readOnly bool
+	}{
+		{name: "select", query: "SELECT id, amount FROM wallets WHERE user_id = ?", readOnly: true},
+		{name: "parenthesized select", query: "(SELECT 1) UNION (SELECT 2)", readOnly: true},
+		{name: "with select", query: "WITH w AS (SELECT * FROM wallets) SELECT * FROM w", readOnly: true},
+		{name: "keyword in string", query: "SELECT * FROM logs WHERE note = 'update; delete from users'", readOnly: true},
+		{name: "keyword in comment", query: "SELECT 1 -- insert into logs\n FROM dual /* for update */", readOnly: true},
+		{name: "keyword in dollar quoting", query: "SELECT $tag$delete$tag$, $1", readOnly: true},
+		{name: "quoted identifier", query: `SELECT "update", ` + "`delete`" + ` FROM t`, readOnly: true},
+		{name: "string function", query: "SELECT REPLACE(email, '@', ' at ') FROM users", readOnly: true},
+		{name: "column named like keyword", query: "SELECT updated_at, deleted FROM users", readOnly: true},
+
+		{name: "insert", query: "INSERT INTO logs (amount) VALUES (1)"},
+		{name: "leading comment hides nothing", query: "/* SELECT */ DELETE FROM logs"},
+		{name: "for update", query: "SELECT * FROM wallets WHERE id = 1 FOR UPDATE"},
+		{name: "for share", query: "select * from wallets for share"},
+		{name: "for no key update", query: "SELECT * FROM wallets FOR NO KEY UPDATE"},
+		{name: "for key share", query: "SELECT * FROM wallets FOR KEY SHARE"},
+		{name: "lock in share mode", query: "SELECT * FROM wallets LOCK IN SHARE MODE"},
+		{name: "data-modifying cte", query: "WITH moved AS (DELETE FROM logs RETURNING *) SELECT * FROM moved"},
+		{name: "select into table", query: "SELECT * INTO backup FROM users"},
+		{name: "select into outfile", query: "SELECT * FROM users INTO OUTFILE '/tmp/users'"},
+		{name: "nextval", query: "SELECT nextval('users_id_seq')"},
+		{name: "qualified setval", query: "SELECT pg_catalog.setval('users_id_seq', 10)"},
+		{name: "quoted function", query: `SELECT "nextval"('users_id_seq')`},
+		{name: "get lock", query: "SELECT GET_LOCK('transfer', 10)"},
+		{name: "advisory lock", query: "SELECT pg_advisory_xact_lock(42)"},
+		{name: "try advisory lock", query: "SELECT pg_try_advisory_lock(42)"},
+		{name: "executable comment", query: "SELECT 1 /*!40101 FOR UPDATE */"},
+		{name: "multiple statements", query: "SELECT 1; UPDATE wallets SET amount = 0"},
+		{name: "ambiguous backslash", query: `SELECT 'a\', nextval('s')`},
+		{name: "empty", query: "  -- nothing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReadOnly(tt.query); got != tt.readOnly {
+				t.Errorf("ReadOnly(%q) = %v, expected %v", tt.query, got, tt.readOnly)
+			}
+		})
+	}
+}